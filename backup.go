@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"time"
+)
+
+var (
+	// backupConfig enables the one-per-run copy of the config file taken
+	// before the first write (-backup, on by default).
+	backupConfig = true
+	// configBackupPath is the backup taken by this run, if any.
+	configBackupPath string
+	// configBackupDone records that the backup step already ran, so several
+	// writes in one run share a single backup.
+	configBackupDone bool
+)
+
+// configBackupTimeFormat is the timestamp appended to backup file names.
+const configBackupTimeFormat = "20060102T150405"
+
+// ensureConfigBackup copies the config file to <config>.bak-<timestamp>
+// before the run's first write. It does nothing in dry-run, with -backup=false,
+// after the first call, or when the config does not exist yet.
+func ensureConfigBackup() error {
+	if !backupConfig || dryRun || configBackupDone {
+		return nil
+	}
+	configBackupDone = true
+	data, err := os.ReadFile(ssoConfigFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	path := ssoConfigFile + ".bak-" + time.Now().Format(configBackupTimeFormat)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return err
+	}
+	configBackupPath = path
+	infof("%sBacked up %s to %s\n", cyan(icon("write")), ssoConfigFile, path)
+	return nil
+}
+
+// printRestoreHint tells the user how to undo a run whose writes failed
+// partway through.
+func printRestoreHint() {
+	if configBackupPath == "" {
+		return
+	}
+	warnf("%sSome changes could not be written. To restore the config from before this run:\n    cp %s %s\n", yellow(icon("warn")), shellSingleQuote(configBackupPath), shellSingleQuote(ssoConfigFile))
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestConfigBackup asserts a run backs up the existing config exactly once
+// before its first write, never in dry-run, and points at the backup when a
+// write fails.
+func TestConfigBackup(t *testing.T) {
+	oldConfig, oldDry, oldBackup, oldPath, oldDone, oldSession := ssoConfigFile, dryRun, backupConfig, configBackupPath, configBackupDone, ssoSessionConfigName
+	defer func() {
+		ssoConfigFile, dryRun, backupConfig, configBackupPath, configBackupDone, ssoSessionConfigName = oldConfig, oldDry, oldBackup, oldPath, oldDone, oldSession
+	}()
+	dir := t.TempDir()
+	ssoConfigFile = filepath.Join(dir, "config")
+	ssoSessionConfigName = "corp"
+	original := "[default]\nregion = us-east-1\n"
+	if err := os.WriteFile(ssoConfigFile, []byte(original), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	backups := func() []string {
+		matches, _ := filepath.Glob(ssoConfigFile + ".bak-*")
+		return matches
+	}
+	roles := []CombinedRole{{AccountId: "111", AccountName: "Prod", RoleName: "ReadOnly"}, {AccountId: "222", AccountName: "Dev", RoleName: "ReadOnly"}}
+
+	backupConfig, configBackupDone, configBackupPath = true, false, ""
+	dryRun = true
+	captureStdout(t, func() {
+		for _, r := range roles {
+			writeProfileToConfig(getProfileNameFromRole(r), r)
+		}
+	})
+	if len(backups()) != 0 || configBackupDone {
+		t.Fatalf("dry-run took a backup: %v", backups())
+	}
+
+	dryRun = false
+	out := captureStdout(t, func() {
+		for _, r := range roles {
+			if err := writeProfileToConfig(getProfileNameFromRole(r), r); err != nil {
+				t.Fatalf("writeProfileToConfig failed: %v", err)
+			}
+		}
+	})
+	got := backups()
+	if len(got) != 1 || got[0] != configBackupPath {
+		t.Fatalf("expected exactly one backup, got %v (recorded %q)", got, configBackupPath)
+	}
+	if data, _ := os.ReadFile(got[0]); string(data) != original {
+		t.Fatalf("backup does not hold the original config: %q", data)
+	}
+	if !strings.Contains(out, "Backed up "+ssoConfigFile+" to "+configBackupPath) {
+		t.Errorf("backup path not printed:\n%s", out)
+	}
+
+	out = captureStdout(t, printRestoreHint)
+	if !strings.Contains(out, "cp '"+configBackupPath+"' '"+ssoConfigFile+"'") {
+		t.Errorf("expected a restore command, got %q", out)
+	}
+
+	backupConfig, configBackupDone, configBackupPath = false, false, ""
+	os.Remove(got[0])
+	captureStdout(t, func() { writeProfileToConfig("x", roles[0]) })
+	if len(backups()) != 0 {
+		t.Fatalf("-backup=false still took a backup")
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/ini.v1"
+)
+
+// loadBootstrapTemplate reads the -bootstrap-template file and checks that
+// it parses as INI.
+func loadBootstrapTemplate(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := ini.Load(data); err != nil {
+		return nil, fmt.Errorf("bootstrap template %s is not valid INI: %v", path, err)
+	}
+	return data, nil
+}
+
+// bootstrapConfigFile writes template as the config file when it does not
+// exist yet, so profiles are appended below it. An existing file is never
+// touched. It reports whether the file was (or, in dry-run, would be)
+// bootstrapped.
+func bootstrapConfigFile(template []byte) (bool, error) {
+	if _, err := os.Stat(ssoConfigFile); err == nil {
+		return false, nil
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return false, err
+	}
+	if dryRun {
+		infof("%sWould create %s from the bootstrap template\n", cyan(icon("write")), ssoConfigFile)
+		return true, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(ssoConfigFile), 0o700); err != nil {
+		return false, err
+	}
+	// O_EXCL so a file created in the meantime is never overwritten.
+	f, err := os.OpenFile(ssoConfigFile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		if errors.Is(err, fs.ErrExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	if _, err := f.Write(template); err != nil {
+		f.Close()
+		return false, err
+	}
+	if err := f.Close(); err != nil {
+		return false, err
+	}
+	infof("%sCreated %s from the bootstrap template\n", green(icon("write")), ssoConfigFile)
+	return true, nil
+}
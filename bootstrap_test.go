@@ -0,0 +1,58 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestBootstrapConfigFromTemplate bootstraps a missing config from a template,
+// writes a profile after it and asserts the template content survives, while
+// an existing config and an invalid template are left alone or rejected.
+func TestBootstrapConfigFromTemplate(t *testing.T) {
+	oldConfig, oldSession, oldOutput, oldDry := ssoConfigFile, ssoSessionConfigName, profileOutput, dryRun
+	defer func() {
+		ssoConfigFile, ssoSessionConfigName, profileOutput, dryRun = oldConfig, oldSession, oldOutput, oldDry
+	}()
+	dir := t.TempDir()
+	ssoConfigFile, ssoSessionConfigName, profileOutput, dryRun = filepath.Join(dir, "aws", "config"), "corp", "json", false
+
+	tmplPath := filepath.Join(dir, "template.ini")
+	if err := os.WriteFile(tmplPath, []byte("# Managed by IT\n[default]\nregion = eu-west-1\n"), 0o600); err != nil {
+		t.Fatalf("failed to write template: %v", err)
+	}
+	template, err := loadBootstrapTemplate(tmplPath)
+	if err != nil {
+		t.Fatalf("loadBootstrapTemplate failed: %v", err)
+	}
+
+	var created bool
+	captureStdout(t, func() { created, err = bootstrapConfigFile(template) })
+	if err != nil || !created {
+		t.Fatalf("expected a new config to be bootstrapped, created=%v err=%v", created, err)
+	}
+	if err := writeProfileToConfig("ReadOnly_Prod_111", CombinedRole{AccountId: "111", RoleName: "ReadOnly"}); err != nil {
+		t.Fatalf("writeProfileToConfig failed: %v", err)
+	}
+	data, _ := os.ReadFile(ssoConfigFile)
+	for _, want := range []string{"# Managed by IT", "[default]", "region = eu-west-1", "[profile ReadOnly_Prod_111]"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("bootstrapped config is missing %q:\n%s", want, data)
+		}
+	}
+
+	captureStdout(t, func() { created, err = bootstrapConfigFile([]byte("[default]\noutput = text\n")) })
+	if err != nil || created {
+		t.Fatalf("an existing config must not be bootstrapped, created=%v err=%v", created, err)
+	}
+	if after, _ := os.ReadFile(ssoConfigFile); string(after) != string(data) {
+		t.Fatalf("existing config was modified")
+	}
+
+	badPath := filepath.Join(dir, "bad.ini")
+	os.WriteFile(badPath, []byte("[default\nregion = eu-west-1\n"), 0o600)
+	if _, err := loadBootstrapTemplate(badPath); err == nil {
+		t.Fatalf("expected an invalid template to be rejected")
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// browserOpenTimeout bounds how long openBrowserURL waits for the launcher
+// to exit (-browser-open-timeout).
+var browserOpenTimeout = 5 * time.Second
+
+// browserLauncher returns the command and arguments that open url in the
+// default browser. It is a variable so tests can substitute a launcher.
+var browserLauncher = func(url string) (string, []string) {
+	switch runtime.GOOS {
+	case "darwin":
+		return "open", []string{url}
+	case "windows":
+		return "rundll32", []string{"url.dll,FileProtocolHandler", url}
+	default:
+		return "xdg-open", []string{url}
+	}
+}
+
+// openBrowserURL attempts to open the provided URL in the user's default
+// browser. It's a convenience for the device authorization flow.
+//
+// The launcher normally hands the URL to the browser and exits, so its exit
+// status is the best available signal of whether a browser appeared: a
+// missing launcher or a non-zero exit is returned as an error, including
+// whatever the launcher wrote to stderr. Some launchers (xdg-open without a
+// desktop environment) run the browser in the foreground instead; one still
+// running after browserOpenTimeout is left alone and assumed to have opened
+// the URL so it never blocks the sign-in.
+func openBrowserURL(url string) error {
+	name, args := browserLauncher(url)
+	cmd := exec.Command(name, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	ctx, cancel := context.WithTimeout(runContext, browserOpenTimeout)
+	defer cancel()
+	select {
+	case err := <-done:
+		if err == nil {
+			return nil
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%s: %w: %s", name, err, msg)
+		}
+		return fmt.Errorf("%s: %w", name, err)
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			debugf("%s still running after %s; assuming the browser opened\n", name, browserOpenTimeout)
+			return nil
+		}
+		return ctx.Err()
+	}
+}
+
+// openVerificationURL opens the device authorization URL in the browser
+// and tells the user what happened. When the launcher fails the URL and code
+// are printed prominently so the sign-in can be finished by hand.
+func openVerificationURL(verificationURL, userCode string) {
+	if err := openBrowserURL(verificationURL); err != nil {
+		promptf("%sFailed to open browser automatically (%v), please open this URL manually:\n\n    %s\n\n", yellow(icon("warn")), err, bold(verificationURL))
+		promptf("And enter this code if prompted: %s\n", bold(userCode))
+		return
+	}
+	promptf("%sOpened default browser to: %s\n", cyan(icon("link")), verificationURL)
+	promptf("If prompted, enter this code: %s\n", userCode)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestOpenVerificationURLFallback verifies a failing launcher surfaces its
+// error and prints the URL and code for manual sign-in, a successful one
+// reports the opened browser, and a launcher that keeps running doesn't
+// block past -browser-open-timeout.
+func TestOpenVerificationURLFallback(t *testing.T) {
+	oldLauncher, oldTimeout := browserLauncher, browserOpenTimeout
+	defer func() { browserLauncher, browserOpenTimeout = oldLauncher, oldTimeout }()
+	const url = "https://device.sso.us-east-1.amazonaws.com/?user_code=ABCD-EFGH"
+
+	browserLauncher = func(string) (string, []string) {
+		return "sh", []string{"-c", "echo no method available for opening >&2; exit 3"}
+	}
+	if err := openBrowserURL(url); err == nil || !strings.Contains(err.Error(), "no method available") {
+		t.Fatalf("expected the launcher's stderr in the error, got %v", err)
+	}
+	out := captureStderr(t, func() { openVerificationURL(url, "ABCD-EFGH") })
+	if !strings.Contains(out, "Failed to open browser") || !strings.Contains(out, url) || !strings.Contains(out, "ABCD-EFGH") {
+		t.Fatalf("expected the manual fallback with URL and code, got %q", out)
+	}
+
+	browserLauncher = func(string) (string, []string) { return "aws-sso-profile-sync-no-such-launcher", nil }
+	if err := openBrowserURL(url); err == nil {
+		t.Fatal("expected a missing launcher to be reported")
+	}
+
+	browserLauncher = func(string) (string, []string) { return "true", nil }
+	out = captureStderr(t, func() { openVerificationURL(url, "ABCD-EFGH") })
+	if !strings.Contains(out, "Opened default browser") {
+		t.Fatalf("expected success output, got %q", out)
+	}
+
+	browserLauncher = func(string) (string, []string) { return "sleep", []string{"2"} }
+	browserOpenTimeout = 50 * time.Millisecond
+	start := time.Now()
+	if err := openBrowserURL(url); err != nil {
+		t.Fatalf("expected a still-running launcher to count as opened, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("openBrowserURL blocked for %s", elapsed)
+	}
+}
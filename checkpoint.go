@@ -0,0 +1,91 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// syncCheckpoint records the accounts a sync has fully processed so an
+// interrupted run can continue with -resume. Key binds it to one start URL
+// and access token; a checkpoint written under another token is ignored.
+type syncCheckpoint struct {
+	Key      string   `json:"key"`
+	Accounts []string `json:"accounts"`
+
+	path string
+}
+
+// checkpointPath returns where the checkpoint for the active config file is
+// kept.
+func checkpointPath() string {
+	return ssoConfigFile + ".sync-checkpoint"
+}
+
+// checkpointKey derives the checkpoint key from the start URL and access
+// token without storing the token itself.
+func checkpointKey(startURL, accessToken string) string {
+	sum := sha256.Sum256([]byte(strings.TrimRight(startURL, "/") + "\n" + accessToken))
+	return hex.EncodeToString(sum[:])
+}
+
+// loadCheckpoint reads the checkpoint at path. It returns nil when there is
+// no checkpoint, it cannot be parsed, or it was written for another key.
+func loadCheckpoint(path, key string) *syncCheckpoint {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var cp syncCheckpoint
+	if err := json.Unmarshal(data, &cp); err != nil || cp.Key != key {
+		return nil
+	}
+	cp.path = path
+	return &cp
+}
+
+// done reports whether accountId was fully processed.
+func (cp *syncCheckpoint) done(accountId string) bool {
+	if cp == nil {
+		return false
+	}
+	for _, id := range cp.Accounts {
+		if id == accountId {
+			return true
+		}
+	}
+	return false
+}
+
+// markDone records accountId as fully processed and saves the checkpoint. It
+// is a no-op on a nil checkpoint (dry-run).
+func (cp *syncCheckpoint) markDone(accountId string) error {
+	if cp == nil || cp.done(accountId) {
+		return nil
+	}
+	cp.Accounts = append(cp.Accounts, accountId)
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	tmp := cp.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, cp.path)
+}
+
+// remove deletes the checkpoint once a sync has completed.
+func (cp *syncCheckpoint) remove() error {
+	if cp == nil {
+		return nil
+	}
+	if err := os.Remove(cp.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
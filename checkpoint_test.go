@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestResumeFromCheckpoint interrupts a sync at the third of three accounts,
+// then asserts -resume only enumerates and writes the remaining account and
+// removes the checkpoint, while a new token invalidates the checkpoint.
+func TestResumeFromCheckpoint(t *testing.T) {
+	stubDiscovery(t,
+		[]ssoTypesAccount{{AccountId: "111", AccountName: "Prod"}, {AccountId: "222", AccountName: "Dev"}, {AccountId: "333", AccountName: "Test"}},
+		map[string][]string{"111": {"ReadOnly"}, "222": {"ReadOnly"}, "333": {"ReadOnly"}})
+	stubbed := getListOfSsoAccountRolesForAccountFunc
+	var enumerated []string
+	getListOfSsoAccountRolesForAccountFunc = func(accessToken, accountId string) ([]ssoTypesRole, error) {
+		enumerated = append(enumerated, accountId)
+		return stubbed(accessToken, accountId)
+	}
+	origWrite := writeProfileToConfigFunc
+	oldConfig, oldSession, oldStart, oldRoles, oldDry, oldResume := ssoConfigFile, ssoSessionConfigName, ssoStartURL, ssoRoleNames, dryRun, resumeMode
+	oldPrefix, oldAuto := profilePrefix, useAutoPrefix
+	defer func() {
+		writeProfileToConfigFunc = origWrite
+		ssoConfigFile, ssoSessionConfigName, ssoStartURL, ssoRoleNames, dryRun, resumeMode = oldConfig, oldSession, oldStart, oldRoles, oldDry, oldResume
+		profilePrefix, useAutoPrefix = oldPrefix, oldAuto
+	}()
+	ssoConfigFile = filepath.Join(t.TempDir(), "config")
+	ssoSessionConfigName, ssoStartURL, ssoRoleNames, dryRun = "corp", "https://corp.awsapps.com/start", []string{"ReadOnly"}, false
+	profilePrefix, useAutoPrefix = "", false
+
+	var written []string
+	interrupted := true
+	writeProfileToConfigFunc = func(profileName string, role CombinedRole) error {
+		if interrupted && role.AccountId == "333" {
+			return errors.New("interrupted")
+		}
+		written = append(written, role.AccountId)
+		return origWrite(profileName, role)
+	}
+
+	captureStdout(t, func() { configureSsoProfiles("token-1") })
+	if _, err := os.Stat(checkpointPath()); err != nil {
+		t.Fatalf("expected a checkpoint after the interrupted run: %v", err)
+	}
+
+	interrupted, resumeMode, enumerated, written = false, true, nil, nil
+	out := captureStdout(t, func() { configureSsoProfiles("token-1") })
+	if strings.Join(enumerated, ",") != "333" || strings.Join(written, ",") != "333" {
+		t.Fatalf("expected the resume to process only 333, enumerated=%v written=%v", enumerated, written)
+	}
+	if !strings.Contains(out, "skipping 2 account(s)") {
+		t.Fatalf("expected a resume notice, got %q", out)
+	}
+	if _, err := os.Stat(checkpointPath()); !os.IsNotExist(err) {
+		t.Fatalf("expected the checkpoint to be removed after a complete run, got %v", err)
+	}
+
+	// A checkpoint written under another token is ignored.
+	interrupted = true
+	captureStdout(t, func() { configureSsoProfiles("token-1") })
+	interrupted, enumerated = false, nil
+	captureStdout(t, func() { configureSsoProfiles("token-2") })
+	if len(enumerated) != 3 {
+		t.Fatalf("expected a new token to invalidate the checkpoint, enumerated=%v", enumerated)
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// programName is the command name completion scripts are registered for.
+const programName = "aws-sso-profile-sync"
+
+// subcommands maps each subcommand name to its entry point. It is filled in
+// init because the completion subcommand itself reads it.
+var subcommands map[string]func(args []string) int
+
+func init() {
+	subcommands = map[string]func(args []string) int{
+		"env":             runEnvCommand,
+		"dedupe-sessions": runDedupeSessionsCommand,
+		"doctor":          runDoctorCommand,
+		"token":           runTokenCommand,
+		"list-sessions":   runListSessionsCommand,
+		"reconcile":       runReconcileCommand,
+		"whoami":          runWhoamiCommand,
+		"completion":      runCompletionCommand,
+		"version":         runVersionCommand,
+		"get-credentials": runGetCredentialsCommand,
+
+		"print-credentials": runPrintCredentialsCommand,
+	}
+}
+
+// subcommandNames returns the subcommand names in sorted order, leaving out
+// hiddenSubcommands.
+func subcommandNames() []string {
+	names := make([]string, 0, len(subcommands))
+	for name := range subcommands {
+		if !hiddenSubcommands[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// completionFlag is a flag as needed by the completion generators.
+type completionFlag struct {
+	name      string
+	usage     string
+	takesArgs bool
+}
+
+// completionFlags lists the flags registered on fs in name order.
+func completionFlags(fs *flag.FlagSet) []completionFlag {
+	var out []completionFlag
+	fs.VisitAll(func(f *flag.Flag) {
+		boolFlag, ok := f.Value.(interface{ IsBoolFlag() bool })
+		usage, _, _ := strings.Cut(f.Usage, "\n")
+		out = append(out, completionFlag{name: f.Name, usage: usage, takesArgs: !(ok && boolFlag.IsBoolFlag())})
+	})
+	return out
+}
+
+// completionScript renders the completion script for shell covering the
+// flags on fs and the given subcommands.
+func completionScript(shell string, fs *flag.FlagSet, subs []string) (string, error) {
+	flags := completionFlags(fs)
+	var b strings.Builder
+	switch shell {
+	case "bash":
+		var names []string
+		for _, f := range flags {
+			names = append(names, "-"+f.name)
+		}
+		fn := "_" + strings.ReplaceAll(programName, "-", "_")
+		fmt.Fprintf(&b, "# bash completion for %s\n", programName)
+		fmt.Fprintf(&b, "%s() {\n", fn)
+		b.WriteString("    local cur=\"${COMP_WORDS[COMP_CWORD]}\"\n")
+		b.WriteString("    if [[ ${COMP_CWORD} -eq 1 && \"${cur}\" != -* ]]; then\n")
+		fmt.Fprintf(&b, "        COMPREPLY=( $(compgen -W %q -- \"${cur}\") )\n", strings.Join(subs, " "))
+		b.WriteString("        return\n")
+		b.WriteString("    fi\n")
+		fmt.Fprintf(&b, "    COMPREPLY=( $(compgen -W %q -- \"${cur}\") )\n", strings.Join(names, " "))
+		b.WriteString("}\n")
+		fmt.Fprintf(&b, "complete -o default -F %s %s\n", fn, programName)
+	case "zsh":
+		fn := "_" + strings.ReplaceAll(programName, "-", "_")
+		fmt.Fprintf(&b, "#compdef %s\n\n", programName)
+		fmt.Fprintf(&b, "%s() {\n", fn)
+		b.WriteString("    local -a subcommands flags\n")
+		fmt.Fprintf(&b, "    subcommands=(%s)\n", strings.Join(subs, " "))
+		b.WriteString("    flags=(\n")
+		for _, f := range flags {
+			fmt.Fprintf(&b, "        %s\n", shellSingleQuote("-"+f.name+":"+f.usage))
+		}
+		b.WriteString("    )\n")
+		b.WriteString("    if (( CURRENT == 2 )) && [[ ${words[CURRENT]} != -* ]]; then\n")
+		b.WriteString("        _describe 'subcommand' subcommands\n")
+		b.WriteString("        return\n")
+		b.WriteString("    fi\n")
+		b.WriteString("    _describe 'flag' flags\n")
+		b.WriteString("}\n\n")
+		fmt.Fprintf(&b, "compdef %s %s\n", fn, programName)
+	case "fish":
+		fmt.Fprintf(&b, "# fish completion for %s\n", programName)
+		for _, s := range subs {
+			fmt.Fprintf(&b, "complete -c %s -f -n '__fish_use_subcommand' -a %s\n", programName, s)
+		}
+		for _, f := range flags {
+			line := fmt.Sprintf("complete -c %s -o %s -d %s", programName, f.name, shellSingleQuote(f.usage))
+			if f.takesArgs {
+				line += " -r"
+			}
+			b.WriteString(line + "\n")
+		}
+	default:
+		return "", fmt.Errorf("unsupported shell %q (expected bash, zsh or fish)", shell)
+	}
+	return b.String(), nil
+}
+
+// shellSingleQuote quotes s for use as one word in a POSIX-style shell.
+func shellSingleQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// runCompletionCommand implements the completion subcommand, which prints a
+// shell completion script for the main flags and subcommands, and returns the
+// process exit code.
+func runCompletionCommand(args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintf(os.Stderr, "%s%s\n", red(icon("error")), bold("Error: usage: completion <bash|zsh|fish>"))
+		return 2
+	}
+	fs := flag.NewFlagSet(programName, flag.ContinueOnError)
+	registerSyncFlags(fs)
+	script, err := completionScript(args[0], fs, subcommandNames())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s%s %v\n", red(icon("error")), bold("Error:"), err)
+		return 2
+	}
+	fmt.Print(script)
+	return 0
+}
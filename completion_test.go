@@ -0,0 +1,38 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestCompletionScripts renders each supported shell's script and asserts it
+// covers key flags and subcommands. An unknown shell is rejected.
+func TestCompletionScripts(t *testing.T) {
+	// Registering the flags resets package settings to their defaults.
+	t.Setenv("HOME", t.TempDir())
+	oldStart, oldSession, oldRegion, oldConfig := ssoStartURL, ssoSessionConfigName, ssoRegion, ssoConfigFile
+	oldPrefix, oldAuto, oldOutput := profilePrefix, useAutoPrefix, profileOutput
+	defer func() {
+		ssoStartURL, ssoSessionConfigName, ssoRegion, ssoConfigFile = oldStart, oldSession, oldRegion, oldConfig
+		profilePrefix, useAutoPrefix, profileOutput = oldPrefix, oldAuto, oldOutput
+	}()
+
+	for _, shell := range []string{"bash", "zsh", "fish"} {
+		var code int
+		out := captureStdout(t, func() { code = runCompletionCommand([]string{shell}) })
+		if code != 0 {
+			t.Fatalf("%s: exit code %d", shell, code)
+		}
+		for _, want := range []string{"sso-start-url", "role", "exclude-role", "dry-run", "theme", "whoami", "dedupe-sessions", "completion"} {
+			if !strings.Contains(out, want) {
+				t.Errorf("%s: script is missing %q:\n%s", shell, want, out)
+			}
+		}
+	}
+
+	var code int
+	captureStdout(t, func() { code = runCompletionCommand([]string{"powershell"}) })
+	if code != 2 {
+		t.Fatalf("expected exit code 2 for an unsupported shell, got %d", code)
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// The helpers in this file edit the AWS config as text, one section at a
+// time, so everything outside the sections the tool manages (comments,
+// spacing, key order, other sections) is left byte-for-byte as it was.
+
+// configLines splits data into lines that keep their line endings.
+func configLines(data []byte) []string {
+	if len(data) == 0 {
+		return nil
+	}
+	return strings.SplitAfter(string(data), "\n")
+}
+
+// sectionHeaderName returns the name of the section a line opens, if any.
+func sectionHeaderName(line string) (string, bool) {
+	trimmed := strings.TrimSpace(line)
+	if !strings.HasPrefix(trimmed, "[") || !strings.HasSuffix(trimmed, "]") {
+		return "", false
+	}
+	return strings.Join(strings.Fields(trimmed[1:len(trimmed)-1]), " "), true
+}
+
+// lineKey returns the key a "key = value" line sets, if any.
+func lineKey(line string) (string, bool) {
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" || trimmed[0] == '#' || trimmed[0] == ';' {
+		return "", false
+	}
+	key, _, ok := strings.Cut(trimmed, "=")
+	if !ok {
+		return "", false
+	}
+	return strings.TrimSpace(key), true
+}
+
+// findSection returns the line range [start, end) of section name, where
+// start is its header line, or -1 when it is absent.
+func findSection(lines []string, name string) (int, int) {
+	for i, line := range lines {
+		if n, ok := sectionHeaderName(line); ok && n == name {
+			end := i + 1
+			for end < len(lines) {
+				if _, ok := sectionHeaderName(lines[end]); ok {
+					break
+				}
+				end++
+			}
+			return i, end
+		}
+	}
+	return -1, -1
+}
+
+// keyValue is one key written by upsertSection.
+type keyValue struct {
+	key, value string
+}
+
+// upsertSection sets the managed keys of section name to values in data.
+// Managed keys missing from values are removed; other lines of the section
+// are kept. Keys the section lacks are added after its last key line. A
+// missing section is appended, preceded by comment lines if given.
+func upsertSection(data []byte, name string, managed []string, values []keyValue, comment string) []byte {
+	lines := configLines(data)
+	start, end := findSection(lines, name)
+	if start < 0 {
+		var b bytes.Buffer
+		b.Write(data)
+		if len(data) > 0 {
+			if data[len(data)-1] != '\n' {
+				b.WriteString("\n")
+			}
+			b.WriteString("\n")
+		}
+		if comment != "" {
+			b.WriteString(comment + "\n")
+		}
+		b.WriteString("[" + name + "]\n")
+		for _, kv := range values {
+			b.WriteString(kv.key + " = " + kv.value + "\n")
+		}
+		return b.Bytes()
+	}
+
+	isManaged := make(map[string]bool)
+	for _, k := range managed {
+		isManaged[k] = true
+	}
+	value := make(map[string]string)
+	for _, kv := range values {
+		value[kv.key] = kv.value
+	}
+	written := make(map[string]bool)
+	body := []string{lines[start]}
+	lastKey := 0
+	for _, line := range lines[start+1 : end] {
+		key, ok := lineKey(line)
+		if ok && isManaged[key] {
+			v, keep := value[key]
+			if !keep || written[key] {
+				continue
+			}
+			ending := line[len(strings.TrimRight(line, "\r\n")):]
+			if ending == "" {
+				ending = "\n"
+			}
+			line = key + " = " + v + ending
+			written[key] = true
+		}
+		body = append(body, line)
+		if ok {
+			lastKey = len(body) - 1
+		}
+	}
+	var added []string
+	for _, kv := range values {
+		if !written[kv.key] {
+			added = append(added, kv.key+" = "+kv.value+"\n")
+		}
+	}
+	if len(added) > 0 && !strings.HasSuffix(body[lastKey], "\n") {
+		body[lastKey] += "\n"
+	}
+	body = append(body[:lastKey+1], append(added, body[lastKey+1:]...)...)
+
+	out := append(append(append([]string{}, lines[:start]...), body...), lines[end:]...)
+	return []byte(strings.Join(out, ""))
+}
+
+// removeSection deletes section name, including the comment lines directly
+// above its header, from data.
+func removeSection(data []byte, name string) []byte {
+	lines := configLines(data)
+	start, end := findSection(lines, name)
+	if start < 0 {
+		return data
+	}
+	for start > 0 {
+		trimmed := strings.TrimSpace(lines[start-1])
+		if trimmed == "" || (trimmed[0] != '#' && trimmed[0] != ';') {
+			break
+		}
+		start--
+	}
+	return []byte(strings.Join(append(lines[:start:start], lines[end:]...), ""))
+}
+
+// hasGroupHeader reports whether data already contains the -group-by header
+// line, so re-runs don't write a second separator for the same group.
+func hasGroupHeader(data []byte, header string) bool {
+	for _, line := range configLines(data) {
+		if strings.TrimSpace(line) == header {
+			return true
+		}
+	}
+	return false
+}
+
+// writeConfigFile saves data as the config file. When data is byte-identical
+// to the current file nothing is written, so the file's mtime is preserved
+// for tools that watch it, and no backup is taken.
+func writeConfigFile(data []byte) error {
+	if err := acquireConfigLock(); err != nil {
+		return err
+	}
+	current, err := os.ReadFile(ssoConfigFile)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err == nil && bytes.Equal(current, data) {
+		debugf("Config file %s is unchanged, not rewriting it\n", ssoConfigFile)
+		return nil
+	}
+	if err := ensureConfigBackup(); err != nil {
+		return err
+	}
+	// Ensure parent directory exists before saving (tests may use temp dirs).
+	if err := os.MkdirAll(filepath.Dir(ssoConfigFile), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(ssoConfigFile, data, 0o600)
+}
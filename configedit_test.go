@@ -0,0 +1,95 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestWritePreservesComments writes a new and an existing profile into a
+// hand-maintained config and asserts comments, spacing and other sections
+// survive byte-for-byte; only the profiles' own keys change.
+func TestWritePreservesComments(t *testing.T) {
+	oldConfig, oldDry, oldBackup, oldSession, oldOutput, oldRegion := ssoConfigFile, dryRun, backupConfig, ssoSessionConfigName, profileOutput, ssoRegion
+	defer func() {
+		ssoConfigFile, dryRun, backupConfig, ssoSessionConfigName, profileOutput, ssoRegion = oldConfig, oldDry, oldBackup, oldSession, oldOutput, oldRegion
+	}()
+	ssoConfigFile = filepath.Join(t.TempDir(), "config")
+	dryRun, backupConfig = false, false
+	ssoSessionConfigName, profileOutput, ssoRegion = "corp", "json", "us-east-1"
+
+	head := "# Managed by hand, keep this header\n\n[default]\nregion    = eu-west-1 ; my home region\n\n"
+	existing := "# the prod read-only profile\n[profile ReadOnly_Prod_111]\nsso_session = corp\n# pinned on purpose\ncli_pager =\nregion = eu-west-1\n\n"
+	tail := "[profile personal]\n  aws_access_key_id = AKIA...  \n"
+	if err := os.WriteFile(ssoConfigFile, []byte(head+existing+tail), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writeProfileToConfig("ReadOnly_Prod_111", CombinedRole{AccountId: "111", AccountName: "Prod", RoleName: "ReadOnly"}); err != nil {
+		t.Fatalf("updating the existing profile failed: %v", err)
+	}
+	if err := writeProfileToConfig("ReadOnly_Dev_222", CombinedRole{AccountId: "222", AccountName: "Dev", RoleName: "ReadOnly"}); err != nil {
+		t.Fatalf("adding a profile failed: %v", err)
+	}
+	data, _ := os.ReadFile(ssoConfigFile)
+	got := string(data)
+
+	wantExisting := "# the prod read-only profile\n[profile ReadOnly_Prod_111]\nsso_session = corp\n# pinned on purpose\ncli_pager =\nregion = us-east-1\nsso_account_id = 111\nsso_role_name = ReadOnly\noutput = json\n\n"
+	want := head + wantExisting + tail + "\n[profile ReadOnly_Dev_222]\nsso_session = corp\nsso_account_id = 222\nsso_role_name = ReadOnly\nregion = us-east-1\noutput = json\n"
+	if got != want {
+		t.Fatalf("unexpected config:\n%s\nwant:\n%s", got, want)
+	}
+
+	oldRoles := ssoRoleNames
+	defer func() { ssoRoleNames = oldRoles }()
+	ssoRoleNames = []string{"ReadOnly"}
+	pruned, err := pruneStaleProfiles([]CombinedRole{{AccountId: "222", RoleName: "ReadOnly"}}, nil)
+	if err != nil || len(pruned) != 1 {
+		t.Fatalf("pruneStaleProfiles = %v, %v", pruned, err)
+	}
+	data, _ = os.ReadFile(ssoConfigFile)
+	if got := string(data); !strings.HasPrefix(got, head+tail) || strings.Contains(got, "ReadOnly_Prod_111") || strings.Contains(got, "the prod read-only profile") {
+		t.Fatalf("prune disturbed the rest of the config:\n%s", got)
+	}
+}
+
+// TestUnchangedWritePreservesMtime writes the same profile twice and asserts
+// the second, byte-identical write leaves the file and its mtime alone.
+func TestUnchangedWritePreservesMtime(t *testing.T) {
+	oldConfig, oldDry, oldBackup, oldSession, oldOutput, oldRegion := ssoConfigFile, dryRun, backupConfig, ssoSessionConfigName, profileOutput, ssoRegion
+	defer func() {
+		ssoConfigFile, dryRun, backupConfig, ssoSessionConfigName, profileOutput, ssoRegion = oldConfig, oldDry, oldBackup, oldSession, oldOutput, oldRegion
+	}()
+	ssoConfigFile = filepath.Join(t.TempDir(), "config")
+	dryRun, backupConfig = false, false
+	ssoSessionConfigName, profileOutput, ssoRegion = "corp", "json", "us-east-1"
+	role := CombinedRole{AccountId: "111", AccountName: "Prod", RoleName: "ReadOnly"}
+
+	if err := writeProfileToConfig("ReadOnly_Prod_111", role); err != nil {
+		t.Fatalf("first write failed: %v", err)
+	}
+	past := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(ssoConfigFile, past, past); err != nil {
+		t.Fatalf("failed to set mtime: %v", err)
+	}
+	if err := writeProfileToConfig("ReadOnly_Prod_111", role); err != nil {
+		t.Fatalf("second write failed: %v", err)
+	}
+	info, err := os.Stat(ssoConfigFile)
+	if err != nil {
+		t.Fatalf("failed to stat config: %v", err)
+	}
+	if !info.ModTime().Equal(past) {
+		t.Fatalf("unchanged write touched the file: mtime %v, want %v", info.ModTime(), past)
+	}
+
+	profileOutput = "text"
+	if err := writeProfileToConfig("ReadOnly_Prod_111", role); err != nil {
+		t.Fatalf("third write failed: %v", err)
+	}
+	if info, _ := os.Stat(ssoConfigFile); info.ModTime().Equal(past) {
+		t.Fatalf("a changed write did not update the file")
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var (
+	// lockTimeout is how long a run waits for another run's config lock
+	// before giving up (-lock-timeout).
+	lockTimeout = 30 * time.Second
+	// configLock is the lock file held by this run, if any.
+	configLock *os.File
+)
+
+// configLockPollInterval is how often a held lock is retried.
+const configLockPollInterval = 250 * time.Millisecond
+
+// configLockPath returns the lock file guarding the config this run writes.
+func configLockPath() string {
+	return sessionConfigPath() + ".lock"
+}
+
+// acquireConfigLock takes an advisory lock on <config>.lock before the run's
+// first write, so parallel runs sharing a home directory don't interleave
+// their read-modify-write cycles. The lock is kept until releaseConfigLock
+// or process exit, when the operating system drops it. It does nothing in
+// dry-run or when this run already holds the lock. A lock held by another
+// run is retried until lockTimeout elapses.
+func acquireConfigLock() error {
+	if dryRun || configLock != nil {
+		return nil
+	}
+	path := configLockPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+	for waited := time.Duration(0); ; waited += configLockPollInterval {
+		locked, err := tryLockFile(f)
+		if err != nil {
+			f.Close()
+			return fmt.Errorf("locking %s: %w", path, err)
+		}
+		if locked {
+			break
+		}
+		if waited >= lockTimeout {
+			f.Close()
+			return fmt.Errorf("%s is locked by another run of %s; gave up after %s (raise -lock-timeout to wait longer)", path, programName, lockTimeout)
+		}
+		debugf("Waiting for the config lock %s\n", path)
+		if err := sleepFunc(runContext, configLockPollInterval); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	configLock = f
+	debugf("Acquired the config lock %s\n", path)
+	return nil
+}
+
+// releaseConfigLock drops the lock taken by acquireConfigLock. The lock file
+// itself is left in place; removing it could let a waiting run lock a file
+// that a third run then recreates.
+func releaseConfigLock() {
+	if configLock == nil {
+		return
+	}
+	unlockFile(configLock)
+	configLock.Close()
+	configLock = nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestConfigLock verifies a lock held by another run is waited for up to
+// -lock-timeout and then reported, that the lock is taken once released,
+// and that dry-run never locks.
+func TestConfigLock(t *testing.T) {
+	oldConfig, oldDir, oldTimeout, oldSleep, oldDry, oldLock := ssoConfigFile, profilesDir, lockTimeout, sleepFunc, dryRun, configLock
+	defer func() {
+		releaseConfigLock()
+		ssoConfigFile, profilesDir, lockTimeout, sleepFunc, dryRun, configLock = oldConfig, oldDir, oldTimeout, oldSleep, oldDry, oldLock
+	}()
+	ssoConfigFile, profilesDir, configLock = filepath.Join(t.TempDir(), "config"), "", nil
+	lockTimeout = time.Second
+
+	other, err := os.OpenFile(configLockPath(), os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	if locked, err := tryLockFile(other); !locked || err != nil {
+		t.Fatalf("failed to take the competing lock: %v", err)
+	}
+
+	dryRun = true
+	if err := acquireConfigLock(); err != nil || configLock != nil {
+		t.Fatalf("expected dry-run to skip locking, got %v", err)
+	}
+
+	dryRun = false
+	waits := 0
+	sleepFunc = func(ctx context.Context, d time.Duration) error {
+		waits++
+		return nil
+	}
+	err = acquireConfigLock()
+	if err == nil || !strings.Contains(err.Error(), "locked by another run") {
+		t.Fatalf("expected a held-lock error, got %v", err)
+	}
+	if want := int(lockTimeout / configLockPollInterval); waits != want {
+		t.Fatalf("expected %d waits, got %d", want, waits)
+	}
+
+	sleepFunc = func(ctx context.Context, d time.Duration) error {
+		unlockFile(other)
+		return nil
+	}
+	if err := acquireConfigLock(); err != nil || configLock == nil {
+		t.Fatalf("expected the lock once released, got %v", err)
+	}
+	if locked, _ := tryLockFile(other); locked {
+		t.Fatal("expected the lock to be exclusive")
+	}
+	releaseConfigLock()
+	if locked, _ := tryLockFile(other); !locked {
+		t.Fatal("expected releaseConfigLock to drop the lock")
+	}
+}
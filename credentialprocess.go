@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// credentialProcessMode makes generated profiles use a credential_process
+// that runs the print-credentials subcommand instead of the sso_session keys
+// (-credential-process).
+var credentialProcessMode bool
+
+// executablePath returns the path of the running binary, which the
+// credential_process command invokes. Tests override it for stable output.
+var executablePath = os.Executable
+
+// hiddenSubcommands are subcommands meant to be run by other tools rather
+// than by hand. They work like any other subcommand but are left out of
+// completion scripts.
+var hiddenSubcommands = map[string]bool{"print-credentials": true}
+
+// credentialProcessCommand returns the credential_process value for role: a
+// print-credentials invocation carrying the session settings of this run, so
+// the profile works without the sso-session block.
+func credentialProcessCommand(role CombinedRole) (string, error) {
+	exe, err := executablePath()
+	if err != nil {
+		return "", fmt.Errorf("locating the executable for credential_process: %v", err)
+	}
+	args := []string{
+		exe, "print-credentials",
+		"-sso-start-url", strings.TrimRight(ssoStartURL, "/"),
+		"-sso-session-name", ssoSessionConfigName,
+		"-sso-region", ssoRegion,
+		"-config-file", ssoConfigFile,
+		"-account-id", role.AccountId,
+		"-role", role.RoleName,
+	}
+	for i, a := range args {
+		args[i] = credentialProcessQuote(a)
+	}
+	return strings.Join(args, " "), nil
+}
+
+// credentialProcessQuote double-quotes arg when it contains whitespace or
+// quotes, which is the quoting the AWS SDKs understand in credential_process.
+func credentialProcessQuote(arg string) string {
+	if arg != "" && !strings.ContainsAny(arg, " \t\"'") {
+		return arg
+	}
+	return `"` + strings.ReplaceAll(arg, `"`, `\"`) + `"`
+}
+
+// credentialProcessOutput is the JSON document a credential_process must
+// print, as specified by the AWS SDKs.
+type credentialProcessOutput struct {
+	Version         int
+	AccessKeyId     string
+	SecretAccessKey string
+	SessionToken    string
+	Expiration      string
+}
+
+// formatCredentialProcessOutput renders creds in the credential_process
+// format.
+func formatCredentialProcessOutput(creds roleCredentials) ([]byte, error) {
+	return json.Marshal(credentialProcessOutput{
+		Version:         1,
+		AccessKeyId:     creds.AccessKeyId,
+		SecretAccessKey: creds.SecretAccessKey,
+		SessionToken:    creds.SessionToken,
+		Expiration:      creds.Expiration.UTC().Format(time.RFC3339),
+	})
+}
+
+// runPrintCredentialsCommand implements the hidden print-credentials
+// subcommand used by credential_process profiles. It fetches (cached) role
+// credentials with the cached SSO token and prints them as credential_process
+// JSON; errors go to stderr, where the AWS SDKs surface them. It returns the
+// process exit code.
+func runPrintCredentialsCommand(args []string) int {
+	fs := flag.NewFlagSet("print-credentials", flag.ContinueOnError)
+	registerSsoFlags(fs)
+	accountId := fs.String("account-id", "", "AWS account ID to fetch credentials for (required)")
+	roleName := fs.String("role", "", "SSO role name to fetch credentials for (required)")
+	useCache := fs.Bool("cache", true, "Reuse cached role credentials until shortly before they expire")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if ssoStartURL == "" || *accountId == "" || *roleName == "" {
+		fmt.Fprintf(os.Stderr, "%s%s\n", red(icon("error")), bold("Error: print-credentials requires -sso-start-url, -account-id and -role"))
+		return 2
+	}
+
+	creds, err := roleCredentialsFromCachedToken(*accountId, *roleName, *useCache)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s%s %v\n", red(icon("error")), bold("Error:"), err)
+		return 1
+	}
+	out, err := formatCredentialProcessOutput(creds)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s%s %v\n", red(icon("error")), bold("Error:"), err)
+		return 1
+	}
+	fmt.Println(string(out))
+	return 0
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestCredentialProcessProfiles asserts -credential-process writes a
+// credential_process key instead of the sso_session keys, and that the
+// profile counts as existing on the next run.
+func TestCredentialProcessProfiles(t *testing.T) {
+	oldMode, oldExe, oldConfig, oldStart, oldSession := credentialProcessMode, executablePath, ssoConfigFile, ssoStartURL, ssoSessionConfigName
+	defer func() {
+		credentialProcessMode, executablePath, ssoConfigFile, ssoStartURL, ssoSessionConfigName = oldMode, oldExe, oldConfig, oldStart, oldSession
+	}()
+	credentialProcessMode = true
+	executablePath = func() (string, error) { return "/opt/my tools/aws-sso-profile-sync", nil }
+	ssoConfigFile = filepath.Join(t.TempDir(), "config")
+	ssoStartURL, ssoSessionConfigName = "https://unit.test/start/", "corp"
+
+	role := CombinedRole{AccountId: "111", AccountName: "Prod", RoleName: "AWSReadOnlyAccess"}
+	if err := writeProfileToConfig("ReadOnly_Prod_111", role); err != nil {
+		t.Fatalf("writeProfileToConfig failed: %v", err)
+	}
+	data, err := os.ReadFile(ssoConfigFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `credential_process = "/opt/my tools/aws-sso-profile-sync" print-credentials -sso-start-url https://unit.test/start -sso-session-name corp`
+	if !strings.Contains(string(data), want) || !strings.Contains(string(data), "-account-id 111 -role AWSReadOnlyAccess") {
+		t.Fatalf("expected a credential_process key, got:\n%s", data)
+	}
+	if strings.Contains(string(data), "sso_session") || strings.Contains(string(data), "sso_account_id") {
+		t.Fatalf("expected no SSO keys, got:\n%s", data)
+	}
+	if !profileExists("ReadOnly_Prod_111", ssoConfigFile) {
+		t.Fatal("credential_process profile was not found as existing")
+	}
+}
+
+// TestPrintCredentials asserts the print-credentials subcommand prints the
+// credential_process JSON document.
+func TestPrintCredentials(t *testing.T) {
+	origGet, origFetch := getAccessTokenFunc, getRoleCredentialsFunc
+	oldStart, oldSession, oldRegion, oldConfig := ssoStartURL, ssoSessionConfigName, ssoRegion, ssoConfigFile
+	defer func() {
+		getAccessTokenFunc, getRoleCredentialsFunc = origGet, origFetch
+		ssoStartURL, ssoSessionConfigName, ssoRegion, ssoConfigFile = oldStart, oldSession, oldRegion, oldConfig
+	}()
+	getAccessTokenFunc = func() (string, string, error) { return "tok", "/tmp/tok.json", nil }
+	expiry := time.Date(2026, 1, 1, 13, 0, 0, 0, time.UTC)
+	getRoleCredentialsFunc = func(accessToken, accountId, roleName string) (roleCredentials, error) {
+		return roleCredentials{AccessKeyId: "AKIA1", SecretAccessKey: "secret", SessionToken: "token", Expiration: expiry}, nil
+	}
+
+	var code int
+	out := captureStdout(t, func() {
+		code = runPrintCredentialsCommand([]string{"-sso-start-url", "https://unit.test/start", "-account-id", "111", "-role", "ReadOnly", "-cache=false"})
+	})
+	if code != 0 {
+		t.Fatalf("expected exit 0, got %d", code)
+	}
+	var got credentialProcessOutput
+	if err := json.Unmarshal([]byte(out), &got); err != nil {
+		t.Fatalf("invalid JSON %q: %v", out, err)
+	}
+	want := credentialProcessOutput{Version: 1, AccessKeyId: "AKIA1", SecretAccessKey: "secret", SessionToken: "token", Expiration: "2026-01-01T13:00:00Z"}
+	if got != want {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	if strings.Contains(strings.Join(subcommandNames(), " "), "print-credentials") {
+		t.Fatal("print-credentials should be hidden from completion")
+	}
+}
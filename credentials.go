@@ -0,0 +1,204 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sso"
+)
+
+// roleCredentials holds the short-lived credentials returned by
+// sso:GetRoleCredentials for a single account/role pair.
+type roleCredentials struct {
+	AccessKeyId     string
+	SecretAccessKey string
+	SessionToken    string
+	Expiration      time.Time
+}
+
+// getRoleCredentialsFunc fetches role credentials using the SSO access token.
+// Tests can override this to avoid contacting AWS.
+var getRoleCredentialsFunc = func(accessToken, accountId, roleName string) (roleCredentials, error) {
+	return getRoleCredentials(accessToken, accountId, roleName)
+}
+
+// Get short-lived credentials for a role in an account
+func getRoleCredentials(accessToken, accountId, roleName string) (roleCredentials, error) {
+	cfg, err := loadSsoClientConfig()
+	if err != nil {
+		return roleCredentials{}, err
+	}
+	client := sso.NewFromConfig(cfg)
+	out, err := client.GetRoleCredentials(runContext, &sso.GetRoleCredentialsInput{
+		AccessToken: aws.String(accessToken),
+		AccountId:   aws.String(accountId),
+		RoleName:    aws.String(roleName),
+	})
+	if err != nil {
+		return roleCredentials{}, err
+	}
+	if out.RoleCredentials == nil {
+		return roleCredentials{}, fmt.Errorf("no credentials returned for account %s role %s", accountId, roleName)
+	}
+	rc := out.RoleCredentials
+	return roleCredentials{
+		AccessKeyId:     aws.ToString(rc.AccessKeyId),
+		SecretAccessKey: aws.ToString(rc.SecretAccessKey),
+		SessionToken:    aws.ToString(rc.SessionToken),
+		Expiration:      time.UnixMilli(rc.Expiration).UTC(),
+	}, nil
+}
+
+// credentialRefreshWindow is how long before expiry cached role credentials
+// stop being reused, so callers never receive credentials about to expire.
+const credentialRefreshWindow = 5 * time.Minute
+
+// nowFunc returns the current time; tests override it to move the clock.
+var nowFunc = time.Now
+
+// credentialCachePath returns the sidecar cache file for an account/role
+// pair of the current start URL.
+func credentialCachePath(accountId, roleName string) (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha1.Sum([]byte(strings.TrimRight(ssoStartURL, "/") + "|" + accountId + "|" + roleName))
+	return filepath.Join(cacheDir, "aws-sso-profile-sync", "credentials", hex.EncodeToString(sum[:])+".json"), nil
+}
+
+// getCachedRoleCredentials returns role credentials from the sidecar cache
+// while they are valid for longer than credentialRefreshWindow, and
+// otherwise fetches fresh credentials and caches them. Repeated invocations
+// (e.g. frequent credential_process calls) then avoid a GetRoleCredentials
+// round trip. Cache problems are never fatal; they just cause a fetch.
+func getCachedRoleCredentials(accessToken, accountId, roleName string) (roleCredentials, error) {
+	path, pathErr := credentialCachePath(accountId, roleName)
+	if pathErr == nil {
+		if data, err := os.ReadFile(path); err == nil {
+			var cached roleCredentials
+			if err := json.Unmarshal(data, &cached); err == nil && cached.AccessKeyId != "" &&
+				nowFunc().Add(credentialRefreshWindow).Before(cached.Expiration) {
+				return cached, nil
+			}
+		}
+	}
+
+	creds, err := getRoleCredentialsFunc(accessToken, accountId, roleName)
+	if err != nil {
+		return roleCredentials{}, err
+	}
+	if pathErr == nil {
+		if b, err := json.Marshal(creds); err == nil {
+			if err := os.MkdirAll(filepath.Dir(path), 0o700); err == nil {
+				// Write atomically: write to a temp file then rename.
+				tmpPath := path + ".tmp"
+				if err := os.WriteFile(tmpPath, b, 0o600); err == nil {
+					if err := os.Rename(tmpPath, path); err != nil {
+						_ = os.Remove(tmpPath)
+					}
+				}
+			}
+		}
+	}
+	return creds, nil
+}
+
+// formatCredentialExports renders credentials as environment variable
+// assignments for the given shell ("sh", "fish" or "powershell") so the
+// output can be eval'd directly.
+func formatCredentialExports(creds roleCredentials, shell string) string {
+	vars := [][2]string{
+		{"AWS_ACCESS_KEY_ID", creds.AccessKeyId},
+		{"AWS_SECRET_ACCESS_KEY", creds.SecretAccessKey},
+		{"AWS_SESSION_TOKEN", creds.SessionToken},
+	}
+	var b strings.Builder
+	for _, v := range vars {
+		switch shell {
+		case "fish":
+			fmt.Fprintf(&b, "set -gx %s %s;\n", v[0], v[1])
+		case "powershell":
+			fmt.Fprintf(&b, "$env:%s = \"%s\"\n", v[0], v[1])
+		default:
+			fmt.Fprintf(&b, "export %s=%s\n", v[0], v[1])
+		}
+	}
+	return b.String()
+}
+
+// roleCredentialsFromCachedToken fetches credentials for accountId/roleName
+// with the cached SSO token, through the sidecar cache when useCache is set.
+// It never signs in; without a usable cached token it fails with a hint to
+// run a sync first. The env, print-credentials and get-credentials
+// subcommands share it.
+func roleCredentialsFromCachedToken(accountId, roleName string, useCache bool) (roleCredentials, error) {
+	accessToken, tokenPath, err := getAccessTokenFunc()
+	if err != nil {
+		reason := "no cached SSO token found"
+		if errors.Is(err, errTokenExpired) {
+			reason = "the cached SSO token at " + tokenPath + " has expired"
+		}
+		return roleCredentials{}, fmt.Errorf("%s for %s (run %s without a subcommand to sign in first): %w", reason, strings.TrimRight(ssoStartURL, "/"), programName, err)
+	}
+	fetch := getRoleCredentialsFunc
+	if useCache {
+		fetch = getCachedRoleCredentials
+	}
+	creds, err := fetch(accessToken, accountId, roleName)
+	if err != nil {
+		return roleCredentials{}, fmt.Errorf("fetching role credentials: %w", err)
+	}
+	return creds, nil
+}
+
+// runEnvCommand implements the `env` subcommand: it looks up the cached SSO
+// token, fetches role credentials for one account/role and prints them as
+// shell exports. Everything except the exports goes to stderr so the output
+// can be used with eval. It returns the process exit code.
+func runEnvCommand(args []string) int {
+	fs := flag.NewFlagSet("env", flag.ContinueOnError)
+	registerSsoFlags(fs)
+	registerOutputFlags(fs)
+	accountId := fs.String("account-id", "", "AWS account ID to fetch credentials for (required)")
+	roleName := fs.String("role", "", "SSO role name to fetch credentials for (required)")
+	fish := fs.Bool("fish", false, "Print fish shell syntax")
+	powershell := fs.Bool("powershell", false, "Print PowerShell syntax")
+	useCache := fs.Bool("cache", true, "Reuse cached role credentials until shortly before they expire")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if ssoStartURL == "" || *accountId == "" || *roleName == "" {
+		fmt.Fprintf(os.Stderr, "%s%s\n", red(icon("error")), bold("Error: env requires -sso-start-url, -account-id and -role"))
+		fs.Usage()
+		return 2
+	}
+	if *fish && *powershell {
+		fmt.Fprintf(os.Stderr, "%s%s\n", red(icon("error")), bold("Error: -fish and -powershell are mutually exclusive"))
+		return 2
+	}
+	shell := "sh"
+	if *fish {
+		shell = "fish"
+	} else if *powershell {
+		shell = "powershell"
+	}
+
+	creds, err := roleCredentialsFromCachedToken(*accountId, *roleName, *useCache)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s%s %v\n", red(icon("error")), bold("Error:"), err)
+		return 1
+	}
+	fmt.Print(formatCredentialExports(creds, shell))
+	return 0
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestFormatCredentialExports asserts the default (POSIX shell) output is one
+// `export NAME=value` line per credential variable, in a stable order.
+func TestFormatCredentialExports(t *testing.T) {
+	creds := roleCredentials{AccessKeyId: "AKIAEXAMPLE", SecretAccessKey: "secret/key+1", SessionToken: "token=="}
+	got := formatCredentialExports(creds, "sh")
+	want := "export AWS_ACCESS_KEY_ID=AKIAEXAMPLE\n" +
+		"export AWS_SECRET_ACCESS_KEY=secret/key+1\n" +
+		"export AWS_SESSION_TOKEN=token==\n"
+	if got != want {
+		t.Fatalf("unexpected exports:\n%s\nwant:\n%s", got, want)
+	}
+
+	if fish := formatCredentialExports(creds, "fish"); !strings.HasPrefix(fish, "set -gx AWS_ACCESS_KEY_ID AKIAEXAMPLE;") {
+		t.Fatalf("unexpected fish output: %s", fish)
+	}
+	if ps := formatCredentialExports(creds, "powershell"); !strings.HasPrefix(ps, `$env:AWS_ACCESS_KEY_ID = "AKIAEXAMPLE"`) {
+		t.Fatalf("unexpected powershell output: %s", ps)
+	}
+}
+
+// TestGetCachedRoleCredentials asserts cached credentials are reused while
+// valid and refreshed once they are within the refresh window of expiry.
+func TestGetCachedRoleCredentials(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	origFetch := getRoleCredentialsFunc
+	origNow := nowFunc
+	oldStart := ssoStartURL
+	defer func() { getRoleCredentialsFunc, nowFunc, ssoStartURL = origFetch, origNow, oldStart }()
+	ssoStartURL = "https://unit.test/start"
+
+	clock := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	nowFunc = func() time.Time { return clock }
+	fetches := 0
+	getRoleCredentialsFunc = func(accessToken, accountId, roleName string) (roleCredentials, error) {
+		fetches++
+		return roleCredentials{
+			AccessKeyId:     fmt.Sprintf("AKIA%d", fetches),
+			SecretAccessKey: "secret",
+			SessionToken:    "token",
+			Expiration:      clock.Add(time.Hour),
+		}, nil
+	}
+
+	first, err := getCachedRoleCredentials("tok", "111", "AWSReadOnlyAccess")
+	if err != nil {
+		t.Fatalf("getCachedRoleCredentials failed: %v", err)
+	}
+	clock = clock.Add(30 * time.Minute)
+	second, err := getCachedRoleCredentials("tok", "111", "AWSReadOnlyAccess")
+	if err != nil {
+		t.Fatalf("getCachedRoleCredentials failed: %v", err)
+	}
+	if fetches != 1 || second.AccessKeyId != first.AccessKeyId {
+		t.Fatalf("expected cached credentials within TTL, fetches=%d", fetches)
+	}
+
+	// Inside the refresh window the credentials are fetched again.
+	clock = clock.Add(28 * time.Minute)
+	third, err := getCachedRoleCredentials("tok", "111", "AWSReadOnlyAccess")
+	if err != nil {
+		t.Fatalf("getCachedRoleCredentials failed: %v", err)
+	}
+	if fetches != 2 || third.AccessKeyId == first.AccessKeyId {
+		t.Fatalf("expected refresh near expiry, fetches=%d", fetches)
+	}
+
+	// A different role has its own cache entry.
+	if _, err := getCachedRoleCredentials("tok", "111", "AWSAdministratorAccess"); err != nil || fetches != 3 {
+		t.Fatalf("expected separate cache entry per role, fetches=%d err=%v", fetches, err)
+	}
+}
+
+// TestCredentialSubcommandsShareTokenLookup asserts env, print-credentials
+// and get-credentials go through the same token lookup: each reports an
+// expired cached token the same way and fetches with the cached token.
+func TestCredentialSubcommandsShareTokenLookup(t *testing.T) {
+	origGet, origFetch, oldStart := getAccessTokenFunc, getRoleCredentialsFunc, ssoStartURL
+	defer func() { getAccessTokenFunc, getRoleCredentialsFunc, ssoStartURL = origGet, origFetch, oldStart }()
+	var fetched []string
+	getRoleCredentialsFunc = func(accessToken, accountId, roleName string) (roleCredentials, error) {
+		fetched = append(fetched, accessToken+"|"+accountId+"|"+roleName)
+		return roleCredentials{AccessKeyId: "AKIA1", SecretAccessKey: "secret", SessionToken: "token", Expiration: time.Now().Add(time.Hour)}, nil
+	}
+	args := []string{"-sso-start-url", "https://unit.test/start/", "-account-id", "111", "-role", "ReadOnly", "-cache=false"}
+
+	for name, run := range map[string]func([]string) int{
+		"env":               runEnvCommand,
+		"print-credentials": runPrintCredentialsCommand,
+		"get-credentials":   runGetCredentialsCommand,
+	} {
+		t.Run(name, func(t *testing.T) {
+			fetched = nil
+			getAccessTokenFunc = func() (string, string, error) { return "", "/tmp/tok.json", errTokenExpired }
+			var code int
+			stderr := captureStderr(t, func() { captureStdout(t, func() { code = run(args) }) })
+			if code != 1 || !strings.Contains(stderr, "the cached SSO token at /tmp/tok.json has expired for https://unit.test/start") {
+				t.Fatalf("expected the shared expired-token error, got code %d stderr %q", code, stderr)
+			}
+
+			getAccessTokenFunc = func() (string, string, error) { return "tok", "/tmp/tok.json", nil }
+			captureStdout(t, func() { code = run(args) })
+			if code != 0 || len(fetched) != 1 || fetched[0] != "tok|111|ReadOnly" {
+				t.Fatalf("expected one fetch with the cached token, got code %d calls %v", code, fetched)
+			}
+		})
+	}
+}
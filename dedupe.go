@@ -0,0 +1,154 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"gopkg.in/ini.v1"
+)
+
+// sessionMerge records one set of duplicate sso-session blocks folded into a
+// canonical session.
+type sessionMerge struct {
+	StartURL  string
+	Region    string
+	Canonical string
+	Removed   []string
+	// Repointed lists the profiles whose sso_session moved to Canonical.
+	Repointed []string
+}
+
+// dedupeSessions finds sso-session blocks that share a start URL and region,
+// keeps one per group, repoints the profiles referencing the others to it and
+// removes the duplicates. The kept session is keep when it is in the group,
+// otherwise the first block in file order. The config file is edited as
+// text, so comments and unrelated sections are kept. In dry-run nothing is
+// written.
+func dedupeSessions(keep string, dry bool) ([]sessionMerge, error) {
+	if !dry {
+		if err := acquireConfigLock(); err != nil {
+			return nil, err
+		}
+	}
+	data, err := os.ReadFile(ssoConfigFile)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := ini.Load(data)
+	if err != nil {
+		return nil, err
+	}
+
+	type sessionKey struct{ startURL, region string }
+	groups := make(map[sessionKey][]string)
+	var order []sessionKey
+	keepFound := keep == ""
+	for _, section := range cfg.Sections() {
+		if !strings.HasPrefix(section.Name(), "sso-session ") {
+			continue
+		}
+		name := strings.TrimPrefix(section.Name(), "sso-session ")
+		if name == keep {
+			keepFound = true
+		}
+		key := sessionKey{strings.TrimRight(section.Key("sso_start_url").String(), "/"), section.Key("sso_region").String()}
+		if _, ok := groups[key]; !ok {
+			order = append(order, key)
+		}
+		groups[key] = append(groups[key], name)
+	}
+	if !keepFound {
+		return nil, fmt.Errorf("-keep %q does not name an sso-session in %s", keep, ssoConfigFile)
+	}
+
+	var merges []sessionMerge
+	for _, key := range order {
+		names := groups[key]
+		if len(names) < 2 {
+			continue
+		}
+		merge := sessionMerge{StartURL: key.startURL, Region: key.region, Canonical: names[0]}
+		for _, name := range names {
+			if name == keep {
+				merge.Canonical = name
+			}
+		}
+		duplicate := make(map[string]bool)
+		for _, name := range names {
+			if name != merge.Canonical {
+				duplicate[name] = true
+				merge.Removed = append(merge.Removed, name)
+			}
+		}
+		sessionKeyName := profileKey("sso_session")
+		for _, section := range cfg.Sections() {
+			if !strings.HasPrefix(section.Name(), "profile ") || !section.HasKey(sessionKeyName) {
+				continue
+			}
+			if duplicate[section.Key(sessionKeyName).String()] {
+				merge.Repointed = append(merge.Repointed, strings.TrimPrefix(section.Name(), "profile "))
+				data = upsertSection(data, section.Name(), []string{sessionKeyName}, []keyValue{{sessionKeyName, merge.Canonical}}, "")
+			}
+		}
+		for _, name := range merge.Removed {
+			data = removeSection(data, "sso-session "+name)
+		}
+		sort.Strings(merge.Repointed)
+		merges = append(merges, merge)
+	}
+
+	if dry || len(merges) == 0 {
+		return merges, nil
+	}
+	return merges, writeConfigFile(data)
+}
+
+// runDedupeSessionsCommand implements the dedupe-sessions subcommand and
+// returns the process exit code.
+func runDedupeSessionsCommand(args []string) int {
+	fs := flag.NewFlagSet("dedupe-sessions", flag.ContinueOnError)
+	fs.StringVar(&ssoConfigFile, "config-file", config.DefaultSharedConfigFilename(), "AWS config file path")
+	fs.BoolVar(&allowExternalConfig, "allow-external-config", false, "Allow -config-file to point outside the ~/.aws directory")
+	fs.BoolVar(&dryRun, "dry-run", false, "Show what would be consolidated without changing the config")
+	registerOutputFlags(fs)
+	keep := fs.String("keep", "", "Name of the sso-session to keep when consolidating its duplicates (default: the first in the file)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if err := checkConfigFileLocation(ssoConfigFile); err != nil {
+		errorf("%s%s %v\n", red(icon("error")), bold("Error:"), err)
+		return 2
+	}
+
+	infof("%s\n", cyan("\n========== AWS SSO Session Dedupe =========="))
+	merges, err := dedupeSessions(*keep, dryRun)
+	if err != nil {
+		errorf("%s%s %v\n", red(icon("error")), bold("Error deduplicating sessions:"), err)
+		return 1
+	}
+	if len(merges) == 0 {
+		resultf("%sNo duplicate sso-session blocks found.\n", green(icon("ok")))
+		return 0
+	}
+	verb := "Kept"
+	if dryRun {
+		verb = "Would keep"
+	}
+	for _, m := range merges {
+		resultf("%s%s %s for %s (%s)\n", green(icon("ok")), verb, bold(m.Canonical), m.StartURL, m.Region)
+		for _, name := range m.Removed {
+			resultf("    %ssso-session %s\n", red(icon("remove")), name)
+		}
+		for _, name := range m.Repointed {
+			resultf("    %sprofile %s -> %s\n", cyan(icon("repoint")), name, m.Canonical)
+		}
+	}
+	if dryRun {
+		resultf("\n%s%s no changes written.\n", cyan(icon("summary")), bold("Dry-run:"))
+	}
+	return 0
+}
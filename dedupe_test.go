@@ -0,0 +1,108 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/ini.v1"
+)
+
+const duplicateSessionsConfig = `[sso-session corp]
+sso_start_url = https://corp.awsapps.com/start/
+sso_region = us-east-1
+
+[sso-session corp-old]
+# corp SSO, see the wiki
+sso_start_url = https://corp.awsapps.com/start
+sso_region = us-east-1
+
+[sso-session other]
+sso_start_url = https://other.awsapps.com/start
+sso_region = us-east-1
+
+[profile A_111]
+# shared with the on-call rota
+sso_session = corp
+sso_account_id = 111
+
+[profile B_222]
+sso_session = corp-old
+sso_account_id = 222
+
+[profile C_333]
+sso_session = other
+`
+
+// TestDedupeSessions seeds two sessions for the same start URL and region
+// with profiles on both, and asserts dry-run leaves the file alone while a
+// real run keeps one session and repoints the other's profiles to it,
+// keeping comments and backing the config up first.
+func TestDedupeSessions(t *testing.T) {
+	oldConfig, oldBackupDone, oldBackupPath, oldLock := ssoConfigFile, configBackupDone, configBackupPath, configLock
+	defer func() {
+		releaseConfigLock()
+		ssoConfigFile, configBackupDone, configBackupPath, configLock = oldConfig, oldBackupDone, oldBackupPath, oldLock
+	}()
+	cfgPath := filepath.Join(t.TempDir(), "config")
+	ssoConfigFile, configBackupDone, configBackupPath, configLock = cfgPath, false, "", nil
+	if err := os.WriteFile(cfgPath, []byte(duplicateSessionsConfig), 0o600); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	merges, err := dedupeSessions("", true)
+	if err != nil || len(merges) != 1 {
+		t.Fatalf("dry-run dedupeSessions: merges=%v err=%v", merges, err)
+	}
+	if m := merges[0]; m.Canonical != "corp" || len(m.Removed) != 1 || m.Removed[0] != "corp-old" || len(m.Repointed) != 1 || m.Repointed[0] != "B_222" {
+		t.Fatalf("unexpected dry-run merge: %+v", m)
+	}
+	if data, _ := os.ReadFile(cfgPath); string(data) != duplicateSessionsConfig {
+		t.Fatalf("dry-run modified the config file")
+	}
+
+	if _, err := dedupeSessions("missing", false); err == nil {
+		t.Fatalf("expected an error for an unknown -keep session")
+	}
+
+	if _, err := dedupeSessions("corp-old", false); err != nil {
+		t.Fatalf("dedupeSessions failed: %v", err)
+	}
+	cfg, err := ini.Load(cfgPath)
+	if err != nil {
+		t.Fatalf("failed to reload config: %v", err)
+	}
+	if _, err := cfg.GetSection("sso-session corp"); err == nil {
+		t.Fatalf("duplicate session corp was not removed")
+	}
+	if _, err := cfg.GetSection("sso-session corp-old"); err != nil {
+		t.Fatalf("kept session corp-old was removed")
+	}
+	if _, err := cfg.GetSection("sso-session other"); err != nil {
+		t.Fatalf("unrelated session was removed")
+	}
+	for profile, want := range map[string]string{"A_111": "corp-old", "B_222": "corp-old", "C_333": "other"} {
+		if got := cfg.Section("profile " + profile).Key("sso_session").String(); got != want {
+			t.Errorf("profile %s: expected sso_session %q, got %q", profile, want, got)
+		}
+	}
+
+	data, err := os.ReadFile(cfgPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, comment := range []string{"# corp SSO, see the wiki\n", "# shared with the on-call rota\n"} {
+		if !strings.Contains(string(data), comment) {
+			t.Errorf("comment %q was dropped:\n%s", comment, data)
+		}
+	}
+	if backup, _ := os.ReadFile(configBackupPath); string(backup) != duplicateSessionsConfig {
+		t.Errorf("expected a backup of the original config, got:\n%s", backup)
+	}
+
+	merges, err = dedupeSessions("", false)
+	if err != nil || len(merges) != 0 {
+		t.Fatalf("expected nothing left to dedupe, got merges=%v err=%v", merges, err)
+	}
+}
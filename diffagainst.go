@@ -0,0 +1,129 @@
+package main
+
+import (
+	"sort"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+// managedProfileKeys returns the keys of every managed profile in the config
+// at path, by profile name. With anySession, a profile of any sso-session
+// counts as managed; otherwise only those isManagedProfile accepts.
+func managedProfileKeys(path string, anySession bool) (map[string]map[string]string, error) {
+	cfg, err := ini.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	profiles := make(map[string]map[string]string)
+	for _, section := range cfg.Sections() {
+		managed := isManagedProfile(section)
+		if anySession && strings.HasPrefix(section.Name(), "profile ") && section.HasKey(profileKey("sso_session")) {
+			managed = true
+		}
+		if !managed {
+			continue
+		}
+		keys := make(map[string]string)
+		for _, k := range section.Keys() {
+			keys[k.Name()] = k.String()
+		}
+		profiles[strings.TrimPrefix(section.Name(), "profile ")] = keys
+	}
+	return profiles, nil
+}
+
+// profileDiff is how one profile of the live config differs from the
+// baseline: only in one of them, or with differing keys.
+type profileDiff struct {
+	ProfileName string
+	// Action is planAdd for a profile only in the live config, planRemove
+	// for one only in the baseline and planUpdate for differing keys.
+	Action  string
+	Changes []keyChange
+}
+
+// diffManagedProfiles compares live against baseline profile by profile.
+// Key changes have Old from the baseline and New from the live config. The
+// result is sorted by profile name, and key changes by key.
+func diffManagedProfiles(baseline, live map[string]map[string]string) []profileDiff {
+	names := make(map[string]bool)
+	for name := range baseline {
+		names[name] = true
+	}
+	for name := range live {
+		names[name] = true
+	}
+	var diffs []profileDiff
+	for name := range names {
+		want, inBaseline := baseline[name]
+		got, inLive := live[name]
+		switch {
+		case !inLive:
+			diffs = append(diffs, profileDiff{ProfileName: name, Action: planRemove})
+		case !inBaseline:
+			diffs = append(diffs, profileDiff{ProfileName: name, Action: planAdd})
+		default:
+			var changes []keyChange
+			for key, old := range want {
+				if got[key] != old {
+					changes = append(changes, keyChange{Key: key, Old: old, New: got[key]})
+				}
+			}
+			for key, value := range got {
+				if _, ok := want[key]; !ok {
+					changes = append(changes, keyChange{Key: key, New: value})
+				}
+			}
+			if len(changes) > 0 {
+				sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+				diffs = append(diffs, profileDiff{ProfileName: name, Action: planUpdate, Changes: changes})
+			}
+		}
+	}
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].ProfileName < diffs[j].ProfileName })
+	return diffs
+}
+
+// runDiffAgainst implements -diff-against: it prints how the managed
+// profiles of the live config differ from those in baselinePath, without
+// contacting AWS, and returns the process exit code (1 when they differ).
+func runDiffAgainst(baselinePath string, anySession bool) int {
+	baseline, err := managedProfileKeys(baselinePath, anySession)
+	if err != nil {
+		errorf("%s%s %v\n", red(icon("error")), bold("Error reading baseline:"), err)
+		return exitRuntimeError
+	}
+	live, err := managedProfileKeys(ssoConfigFile, anySession)
+	if err != nil {
+		errorf("%s%s %v\n", red(icon("error")), bold("Error reading config:"), err)
+		return exitRuntimeError
+	}
+	diffs := diffManagedProfiles(baseline, live)
+	if len(diffs) == 0 {
+		resultf("%sManaged profiles in %s match %s (%d profile(s)).\n", green(icon("ok")), ssoConfigFile, baselinePath, len(live))
+		return exitOK
+	}
+	for _, d := range diffs {
+		switch d.Action {
+		case planAdd:
+			resultf("%s profile %s (only in %s)\n", green(planAdd), bold(d.ProfileName), ssoConfigFile)
+		case planRemove:
+			resultf("%s profile %s (only in %s)\n", red(planRemove), bold(d.ProfileName), baselinePath)
+		default:
+			resultf("%s profile %s\n", yellow(planUpdate), bold(d.ProfileName))
+			for _, c := range d.Changes {
+				switch {
+				case c.New == "":
+					resultf("      %s %s = %s\n", red(planRemove), c.Key, c.Old)
+				case c.Old == "":
+					resultf("      %s %s = %s\n", green(planAdd), c.Key, c.New)
+				default:
+					resultf("      %s %s = %s -> %s\n", yellow(planUpdate), c.Key, c.Old, c.New)
+				}
+			}
+		}
+	}
+	resultf("\n%s%s %d managed profile(s) differ from %s.\n", cyan(icon("summary")), bold("Diff:"), len(diffs), baselinePath)
+	return exitRuntimeError
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestDiffAgainst compares a live config against a baseline that differs in
+// one profile's region and asserts only that profile is reported, and that
+// identical configs exit 0.
+func TestDiffAgainst(t *testing.T) {
+	dir := t.TempDir()
+	baseline := `[sso-session corp]
+sso_start_url = https://corp.awsapps.com/start
+sso_region = us-east-1
+
+[profile ReadOnly_Prod_111]
+sso_session = corp
+sso_account_id = 111
+sso_role_name = AWSReadOnlyAccess
+region = us-east-1
+
+[profile ReadOnly_Dev_222]
+sso_session = corp
+sso_account_id = 222
+sso_role_name = AWSReadOnlyAccess
+region = us-east-1
+
+[profile personal]
+region = eu-west-1
+`
+	live := strings.Replace(baseline, "sso_account_id = 222\nsso_role_name = AWSReadOnlyAccess\nregion = us-east-1", "sso_account_id = 222\nsso_role_name = AWSReadOnlyAccess\nregion = eu-west-1", 1)
+	live = strings.Replace(live, "[profile personal]\nregion = eu-west-1", "[profile personal]\nregion = us-west-2", 1)
+	baselinePath, livePath := filepath.Join(dir, "baseline"), filepath.Join(dir, "config")
+	for path, content := range map[string]string{baselinePath: baseline, livePath: live} {
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	oldConfig := ssoConfigFile
+	defer func() { ssoConfigFile = oldConfig }()
+	ssoConfigFile = livePath
+
+	var code int
+	out := captureStdout(t, func() { code = runDiffAgainst(baselinePath, true) })
+	if code != exitRuntimeError {
+		t.Fatalf("expected exit %d for differing configs, got %d", exitRuntimeError, code)
+	}
+	if !strings.Contains(out, "~ profile ReadOnly_Dev_222") || !strings.Contains(out, "~ region = us-east-1 -> eu-west-1") {
+		t.Fatalf("expected the Dev region change, got:\n%s", out)
+	}
+	if strings.Contains(out, "ReadOnly_Prod_111") || strings.Contains(out, "personal") {
+		t.Fatalf("unchanged or unmanaged profiles were reported:\n%s", out)
+	}
+	if !strings.Contains(out, "1 managed profile(s) differ") {
+		t.Fatalf("expected a one-profile summary, got:\n%s", out)
+	}
+
+	ssoConfigFile = baselinePath
+	out = captureStdout(t, func() { code = runDiffAgainst(baselinePath, true) })
+	if code != exitOK || !strings.Contains(out, "match") {
+		t.Fatalf("expected identical configs to match, got exit %d:\n%s", code, out)
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+var (
+	// diffMode prints a unified diff of the config before and after the sync
+	// instead of writing it (-diff).
+	diffMode bool
+	// pendingSessionBlock is the sso-session block a -diff run would add.
+	pendingSessionBlock string
+)
+
+// diffContext is the number of unchanged lines shown around each change.
+const diffContext = 3
+
+// printConfigDiff builds the config the sync would write for roles in
+// memory, starting from the current file, and prints the difference as a
+// unified diff. Like a real run it adds the pending sso-session block, adds
+// missing profiles, with -force rewrites existing ones and, with -prune,
+// removes the profiles pruneStaleProfiles would remove.
+func printConfigDiff(roles []CombinedRole, accounts []ssoTypesAccount) error {
+	before, err := os.ReadFile(ssoConfigFile)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	after := append([]byte(nil), before...)
+	if pendingSessionBlock != "" {
+		if len(after) > 0 && after[len(after)-1] != '\n' {
+			after = append(after, '\n')
+		}
+		after = append(after, pendingSessionBlock...)
+	}
+	for _, role := range roles {
+		profileName := getProfileNameFromRole(role)
+		if profileExists(profileName, ssoConfigFile) && !forceUpdate {
+			continue
+		}
+		after = setProfileKeys(after, profileName, role, profileValues(role))
+	}
+	if pruneMode && len(before) > 0 {
+		cfg, err := ini.Load(before)
+		if err != nil {
+			return err
+		}
+		stale, err := staleProfiles(cfg, roles, accounts)
+		if err != nil {
+			return err
+		}
+		for _, entry := range stale {
+			after = removeSection(after, "profile "+entry.ProfileName)
+		}
+	}
+	diff := unifiedDiff(ssoConfigFile, ssoConfigFile+" (after sync)", string(before), string(after))
+	if diff == "" {
+		resultf("%sNo changes to %s.\n", cyan(icon("summary")), ssoConfigFile)
+		return nil
+	}
+	for _, line := range strings.SplitAfter(diff, "\n") {
+		switch {
+		case strings.HasPrefix(line, "---"), strings.HasPrefix(line, "+++"):
+			resultf("%s", bold(line))
+		case strings.HasPrefix(line, "@@"):
+			resultf("%s", cyan(line))
+		case strings.HasPrefix(line, "-"):
+			resultf("%s", red(line))
+		case strings.HasPrefix(line, "+"):
+			resultf("%s", green(line))
+		default:
+			resultf("%s", line)
+		}
+	}
+	return nil
+}
+
+// diffOp is one line of an edit script: ' ' kept, '-' removed, '+' added.
+type diffOp struct {
+	kind byte
+	line string
+}
+
+// unifiedDiff returns the unified diff turning a into b, with diffContext
+// lines of context, or "" when they are equal.
+func unifiedDiff(nameA, nameB, a, b string) string {
+	if a == b {
+		return ""
+	}
+	ops := diffLines(splitLines(a), splitLines(b))
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", nameA, nameB)
+	for start := 0; start < len(ops); {
+		// Find the next change and the run of changes (and short gaps) after it.
+		first := start
+		for first < len(ops) && ops[first].kind == ' ' {
+			first++
+		}
+		if first == len(ops) {
+			break
+		}
+		last := first
+		for i := first; i < len(ops); i++ {
+			if ops[i].kind != ' ' {
+				last = i
+			} else if i-last > 2*diffContext {
+				break
+			}
+		}
+		from := max(first-diffContext, start)
+		to := min(last+diffContext+1, len(ops))
+
+		// Line numbers are 1-based positions in a and b.
+		lineA, lineB := 1, 1
+		for _, op := range ops[:from] {
+			if op.kind != '+' {
+				lineA++
+			}
+			if op.kind != '-' {
+				lineB++
+			}
+		}
+		countA, countB := 0, 0
+		for _, op := range ops[from:to] {
+			if op.kind != '+' {
+				countA++
+			}
+			if op.kind != '-' {
+				countB++
+			}
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(lineA, countA), hunkRange(lineB, countB))
+		for _, op := range ops[from:to] {
+			fmt.Fprintf(&out, "%c%s\n", op.kind, op.line)
+		}
+		start = to
+	}
+	return out.String()
+}
+
+// hunkRange formats the start,count of a hunk side; an empty side starts at
+// the line before it.
+func hunkRange(start, count int) string {
+	if count == 0 {
+		start--
+	}
+	if count == 1 {
+		return fmt.Sprint(start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
+
+// splitLines splits s into lines without their line breaks.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// diffLines returns an edit script turning a into b from their longest
+// common subsequence. The common prefix and suffix are trimmed first, as
+// a sync usually changes a few sections of a large file.
+func diffLines(a, b []string) []diffOp {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	var ops []diffOp
+	for _, line := range a[:prefix] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	midA, midB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	// lcs[i][j] is the LCS length of midA[i:] and midB[j:].
+	lcs := make([][]int, len(midA)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(midB)+1)
+	}
+	for i := len(midA) - 1; i >= 0; i-- {
+		for j := len(midB) - 1; j >= 0; j-- {
+			if midA[i] == midB[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < len(midA) || j < len(midB) {
+		switch {
+		case i < len(midA) && j < len(midB) && midA[i] == midB[j]:
+			ops = append(ops, diffOp{' ', midA[i]})
+			i++
+			j++
+		case j < len(midB) && (i == len(midA) || lcs[i][j+1] > lcs[i+1][j]):
+			ops = append(ops, diffOp{'+', midB[j]})
+			j++
+		default:
+			ops = append(ops, diffOp{'-', midA[i]})
+			i++
+		}
+	}
+	for _, line := range a[len(a)-suffix:] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	return ops
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestUnifiedDiff checks hunk headers, context trimming and merging of
+// nearby changes.
+func TestUnifiedDiff(t *testing.T) {
+	a := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n14\n15\n16\n17\n18\n19\n20\n"
+	b := "1\n2\nthree\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n14\n15\n16\n17\n18\n19\n20\n21\n"
+	want := `--- a
++++ b
+@@ -1,6 +1,6 @@
+ 1
+ 2
+-3
++three
+ 4
+ 5
+ 6
+@@ -18,3 +18,4 @@
+ 18
+ 19
+ 20
++21
+`
+	if got := unifiedDiff("a", "b", a, b); got != want {
+		t.Fatalf("unexpected diff:\n%s\nwant:\n%s", got, want)
+	}
+	if got := unifiedDiff("a", "b", "", "x\n"); got != "--- a\n+++ b\n@@ -0,0 +1 @@\n+x\n" {
+		t.Fatalf("unexpected diff for a new file:\n%s", got)
+	}
+	if unifiedDiff("a", "b", a, a) != "" {
+		t.Fatal("expected no diff for equal inputs")
+	}
+}
+
+// TestPrintConfigDiff verifies -diff shows the added session block and
+// profile as diff lines, skips existing profiles without -force, and
+// leaves the config file untouched.
+func TestPrintConfigDiff(t *testing.T) {
+	roles := []CombinedRole{
+		{AccountId: "111", AccountName: "Prod", RoleName: "AWSReadOnlyAccess"},
+		{AccountId: "222", AccountName: "Dev", RoleName: "AWSReadOnlyAccess"},
+	}
+	existing, added := getProfileNameFromRole(roles[0]), getProfileNameFromRole(roles[1])
+	cfgPath := filepath.Join(t.TempDir(), "config")
+	original := "[default]\nregion = us-east-1\n\n[profile " + existing + "]\nsso_session = corp\nsso_account_id = 111\nsso_role_name = AWSReadOnlyAccess\nregion = us-west-2\noutput = json\n"
+	if err := os.WriteFile(cfgPath, []byte(original), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	oldConfig, oldSession, oldStart, oldRegion, oldDry, oldDiff, oldPending := ssoConfigFile, ssoSessionConfigName, ssoStartURL, ssoRegion, dryRun, diffMode, pendingSessionBlock
+	defer func() {
+		ssoConfigFile, ssoSessionConfigName, ssoStartURL, ssoRegion, dryRun, diffMode, pendingSessionBlock = oldConfig, oldSession, oldStart, oldRegion, oldDry, oldDiff, oldPending
+	}()
+	ssoConfigFile, ssoSessionConfigName, ssoStartURL, ssoRegion = cfgPath, "corp", "https://unit.test/start", "us-east-1"
+	dryRun, diffMode = true, true
+
+	if added, err := ensureSsoSessionConfigPresent(); err != nil || !added {
+		t.Fatalf("expected the session block to be pending, got added=%v err=%v", added, err)
+	}
+	out := captureStdout(t, func() {
+		if err := printConfigDiff(roles, nil); err != nil {
+			t.Fatalf("printConfigDiff failed: %v", err)
+		}
+	})
+	for _, want := range []string{"+[sso-session corp]", "+sso_start_url = https://unit.test/start", "+[profile " + added + "]", "+sso_account_id = 222"} {
+		if !strings.Contains(out, want+"\n") {
+			t.Errorf("expected %q in the diff, got:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "-sso_account_id = 111") || strings.Contains(out, "+sso_account_id = 111") {
+		t.Errorf("existing profile should not change without -force:\n%s", out)
+	}
+	if data, _ := os.ReadFile(cfgPath); string(data) != original {
+		t.Fatalf("config changed in diff mode:\n%s", data)
+	}
+}
+
+// TestPrintConfigDiffPrune verifies -diff -prune shows the removal of a
+// profile whose role was not discovered.
+func TestPrintConfigDiffPrune(t *testing.T) {
+	roles := []CombinedRole{{AccountId: "111", AccountName: "Prod", RoleName: "AWSReadOnlyAccess"}}
+	cfgPath := filepath.Join(t.TempDir(), "config")
+	original := "[sso-session corp]\nsso_start_url = https://unit.test/start\nsso_region = us-east-1\n\n" +
+		"[profile " + getProfileNameFromRole(roles[0]) + "]\nsso_session = corp\nsso_account_id = 111\nsso_role_name = AWSReadOnlyAccess\n\n" +
+		"[profile Revoked]\nsso_session = corp\nsso_account_id = 111\nsso_role_name = AWSAdministratorAccess\n"
+	if err := os.WriteFile(cfgPath, []byte(original), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	oldConfig, oldSession, oldRoles, oldDry, oldDiff, oldPrune, oldPending := ssoConfigFile, ssoSessionConfigName, ssoRoleNames, dryRun, diffMode, pruneMode, pendingSessionBlock
+	defer func() {
+		ssoConfigFile, ssoSessionConfigName, ssoRoleNames, dryRun, diffMode, pruneMode, pendingSessionBlock = oldConfig, oldSession, oldRoles, oldDry, oldDiff, oldPrune, oldPending
+	}()
+	ssoConfigFile, ssoSessionConfigName, ssoRoleNames, pendingSessionBlock = cfgPath, "corp", defaultRoleNames, ""
+	dryRun, diffMode, pruneMode = true, true, true
+
+	out := captureStdout(t, func() {
+		if err := printConfigDiff(roles, nil); err != nil {
+			t.Fatalf("printConfigDiff failed: %v", err)
+		}
+	})
+	for _, want := range []string{"-[profile Revoked]", "-sso_role_name = AWSAdministratorAccess"} {
+		if !strings.Contains(out, want+"\n") {
+			t.Errorf("expected %q in the diff, got:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "-sso_role_name = AWSReadOnlyAccess") {
+		t.Errorf("discovered profile should be kept:\n%s", out)
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"flag"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"gopkg.in/ini.v1"
+)
+
+// startURLRegionPattern extracts the Identity Center region from the newer
+// start URL form https://<id>.portal.<region>.app.aws. The classic
+// awsapps.com form does not carry the region.
+var startURLRegionPattern = regexp.MustCompile(`^https://[^/]+\.portal\.([a-z]{2}(?:-[a-z]+)+-\d+)\.app\.aws(?:/|$)`)
+
+// regionPattern matches the shape of an AWS region name.
+var regionPattern = regexp.MustCompile(`^[a-z]{2}(?:-[a-z]+)+-\d+$`)
+
+// configIssue is one consistency problem found by the doctor subcommand.
+type configIssue struct {
+	// Section is the config section the issue was found in.
+	Section string
+	Message string
+}
+
+// startURLRegion returns the region encoded in startURL, or "" when the URL
+// does not carry one.
+func startURLRegion(startURL string) string {
+	if m := startURLRegionPattern.FindStringSubmatch(startURL); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// lintSessionReferences checks the sso-session blocks and the profiles that
+// reference them: a profile naming a session that does not exist, and a
+// session whose sso_region is missing, malformed or disagrees with the region
+// in its start URL. Issues are sorted by section.
+func lintSessionReferences(cfg *ini.File) []configIssue {
+	var issues []configIssue
+	sessions := make(map[string]bool)
+	for _, section := range cfg.Sections() {
+		if !strings.HasPrefix(section.Name(), "sso-session ") {
+			continue
+		}
+		sessions[strings.TrimPrefix(section.Name(), "sso-session ")] = true
+		region := section.Key("sso_region").String()
+		switch urlRegion := startURLRegion(section.Key("sso_start_url").String()); {
+		case region == "":
+			issues = append(issues, configIssue{section.Name(), "sso_region is not set"})
+		case !regionPattern.MatchString(region):
+			issues = append(issues, configIssue{section.Name(), "sso_region " + region + " is not a region name"})
+		case urlRegion != "" && urlRegion != region:
+			issues = append(issues, configIssue{section.Name(), "sso_region " + region + " does not match the start URL region " + urlRegion})
+		}
+	}
+
+	sessionKeyName := profileKey("sso_session")
+	for _, section := range cfg.Sections() {
+		if !strings.HasPrefix(section.Name(), "profile ") || !section.HasKey(sessionKeyName) {
+			continue
+		}
+		if name := section.Key(sessionKeyName).String(); !sessions[name] {
+			issues = append(issues, configIssue{section.Name(), "sso_session " + name + " has no [sso-session " + name + "] block"})
+		}
+	}
+	sort.SliceStable(issues, func(i, j int) bool { return issues[i].Section < issues[j].Section })
+	return issues
+}
+
+// runDoctorCommand implements the doctor subcommand, which checks the config
+// file for inconsistent session references, and returns the process exit
+// code: 0 when the file is consistent, 1 when issues were found.
+func runDoctorCommand(args []string) int {
+	fs := flag.NewFlagSet("doctor", flag.ContinueOnError)
+	fs.StringVar(&ssoConfigFile, "config-file", config.DefaultSharedConfigFilename(), "AWS config file path")
+	fs.BoolVar(&allowExternalConfig, "allow-external-config", false, "Allow -config-file to point outside the ~/.aws directory")
+	registerOutputFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if err := checkConfigFileLocation(ssoConfigFile); err != nil {
+		errorf("%s%s %v\n", red(icon("error")), bold("Error:"), err)
+		return 2
+	}
+
+	infof("%s\n", cyan("\n========== AWS SSO Config Doctor =========="))
+	cfg, err := ini.Load(ssoConfigFile)
+	if err != nil {
+		errorf("%s%s %v\n", red(icon("error")), bold("Error reading config:"), err)
+		return 1
+	}
+	issues := lintSessionReferences(cfg)
+	if len(issues) == 0 {
+		resultf("%sNo issues found in %s.\n", green(icon("ok")), ssoConfigFile)
+		return 0
+	}
+	for _, issue := range issues {
+		resultf("%s[%s] %s\n", yellow(icon("warn")), issue.Section, issue.Message)
+	}
+	resultf("\n%s%s %d issue(s) found in %s.\n", cyan(icon("summary")), bold("Doctor:"), len(issues), ssoConfigFile)
+	return 1
+}
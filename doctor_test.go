@@ -0,0 +1,67 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/ini.v1"
+)
+
+const mismatchedSessionsConfig = `[sso-session corp]
+sso_start_url = https://ssoins-1234.portal.eu-west-1.app.aws
+sso_region = us-east-1
+
+[sso-session classic]
+sso_start_url = https://classic.awsapps.com/start
+sso_region = eu-central-1
+
+[profile A_111]
+sso_session = corp
+sso_account_id = 111
+region = us-east-1
+
+[profile B_222]
+sso_session = retired
+sso_account_id = 222
+
+[profile C_333]
+sso_session = classic
+sso_account_id = 333
+
+[profile static]
+region = us-east-1
+`
+
+// TestLintSessionReferences seeds a session whose region disagrees with its
+// start URL and a profile pointing at a missing session, and asserts both
+// are reported while consistent sections and non-SSO profiles are not.
+func TestLintSessionReferences(t *testing.T) {
+	cfg, err := ini.Load([]byte(mismatchedSessionsConfig))
+	if err != nil {
+		t.Fatalf("failed to parse config: %v", err)
+	}
+	issues := lintSessionReferences(cfg)
+	if len(issues) != 2 {
+		t.Fatalf("expected 2 issues, got %+v", issues)
+	}
+	if issues[0].Section != "profile B_222" || !strings.Contains(issues[0].Message, "no [sso-session retired] block") {
+		t.Fatalf("unexpected missing-session issue: %+v", issues[0])
+	}
+	if issues[1].Section != "sso-session corp" || !strings.Contains(issues[1].Message, "does not match the start URL region eu-west-1") {
+		t.Fatalf("unexpected region issue: %+v", issues[1])
+	}
+
+	cfgPath := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(cfgPath, []byte(mismatchedSessionsConfig), 0o600); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	oldConfig, oldExternal := ssoConfigFile, allowExternalConfig
+	defer func() { ssoConfigFile, allowExternalConfig = oldConfig, oldExternal }()
+	var code int
+	out := captureStdout(t, func() { code = runDoctorCommand([]string{"-config-file", cfgPath, "-allow-external-config"}) })
+	if code != 1 || !strings.Contains(out, "2 issue(s) found") {
+		t.Fatalf("expected doctor to report 2 issues with exit code 1, got %d:\n%s", code, out)
+	}
+}
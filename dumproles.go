@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Supported values for -dump-roles
+const (
+	dumpRolesJSON = "json"
+	dumpRolesCSV  = "csv"
+)
+
+var (
+	// dumpRolesFormat, when set, makes the run write the discovered
+	// account/role matrix to dumpRolesOut instead of configuring profiles.
+	dumpRolesFormat string
+	// dumpRolesOut receives the -dump-roles output: the real stdout, while
+	// all other output is moved to stderr.
+	dumpRolesOut io.Writer
+)
+
+// validateDumpRolesFormat checks that -dump-roles is one of the supported
+// values.
+func validateDumpRolesFormat(format string) error {
+	switch format {
+	case dumpRolesJSON, dumpRolesCSV:
+		return nil
+	}
+	return fmt.Errorf("invalid -dump-roles %q: expected %s or %s", format, dumpRolesJSON, dumpRolesCSV)
+}
+
+// roleDumpEntry is one row of the -dump-roles output.
+type roleDumpEntry struct {
+	AccountId   string `json:"accountId"`
+	AccountName string `json:"accountName"`
+	RoleName    string `json:"roleName"`
+}
+
+// collectRoleMatrix returns the account/role pairs to dump: the selected
+// roles when a role selection was given, and otherwise every role of the
+// selected accounts. The result is sorted by account id and role name.
+func collectRoleMatrix(accessToken string) ([]roleDumpEntry, error) {
+	var roles []CombinedRole
+	if rolesRequested() {
+		var err error
+		if roles, err = getCombinedListOfSsoAccountsAndRoles(accessToken, ssoRoleNames); err != nil {
+			return nil, err
+		}
+	} else {
+		accounts, err := getSelectedSsoAccounts(accessToken)
+		if err != nil {
+			return nil, err
+		}
+		for _, account := range accounts {
+			accountRoles, err := getListOfSsoAccountRolesForAccountFunc(accessToken, account.AccountId)
+			if err != nil {
+				return nil, err
+			}
+			for _, r := range accountRoles {
+				roles = append(roles, CombinedRole{AccountId: account.AccountId, AccountName: account.AccountName, RoleName: r.RoleName})
+			}
+		}
+	}
+	entries := make([]roleDumpEntry, 0, len(roles))
+	for _, r := range roles {
+		entries = append(entries, roleDumpEntry{AccountId: r.AccountId, AccountName: r.AccountName, RoleName: r.RoleName})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].AccountId != entries[j].AccountId {
+			return entries[i].AccountId < entries[j].AccountId
+		}
+		return entries[i].RoleName < entries[j].RoleName
+	})
+	return entries, nil
+}
+
+// writeRoleDump writes entries to w as a JSON array of objects or as CSV
+// with an account_id,account_name,role_name header row.
+func writeRoleDump(w io.Writer, format string, entries []roleDumpEntry) error {
+	if format == dumpRolesCSV {
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"account_id", "account_name", "role_name"})
+		for _, e := range entries {
+			cw.Write([]string{e.AccountId, e.AccountName, e.RoleName})
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// dumpRoles implements -dump-roles: it discovers the account/role matrix and
+// writes it to dumpRolesOut without touching the config.
+func dumpRoles(accessToken string) error {
+	entries, err := collectRoleMatrix(accessToken)
+	if err != nil {
+		return err
+	}
+	return writeRoleDump(dumpRolesOut, dumpRolesFormat, entries)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+)
+
+// TestDumpRoles asserts -dump-roles writes every role as CSV with a header
+// row when no role is selected, and only the selected roles as a JSON array
+// with -role, without configuring any profile.
+func TestDumpRoles(t *testing.T) {
+	stubDiscovery(t,
+		[]ssoTypesAccount{{AccountId: "222", AccountName: "Dev, EU"}, {AccountId: "111", AccountName: "Prod"}},
+		map[string][]string{"111": {"Billing", "AWSReadOnlyAccess"}, "222": {"AWSReadOnlyAccess"}})
+	oldFormat, oldOut, oldRoles, oldExcluded, oldRegexes, oldConfig := dumpRolesFormat, dumpRolesOut, ssoRoleNames, excludedRoles, roleRegexes, ssoConfigFile
+	defer func() {
+		dumpRolesFormat, dumpRolesOut, ssoRoleNames, excludedRoles, roleRegexes, ssoConfigFile = oldFormat, oldOut, oldRoles, oldExcluded, oldRegexes, oldConfig
+	}()
+	ssoRoleNames, excludedRoles, roleRegexes = nil, nil, nil
+	ssoConfigFile = filepath.Join(t.TempDir(), "config")
+	var buf bytes.Buffer
+	dumpRolesOut = &buf
+
+	dumpRolesFormat = dumpRolesCSV
+	if _, err := configureSsoProfiles("token"); err != nil {
+		t.Fatalf("configureSsoProfiles failed: %v", err)
+	}
+	want := "account_id,account_name,role_name\n" +
+		"111,Prod,AWSReadOnlyAccess\n" +
+		"111,Prod,Billing\n" +
+		"222,\"Dev, EU\",AWSReadOnlyAccess\n"
+	if buf.String() != want {
+		t.Fatalf("unexpected CSV:\n%s\nwant:\n%s", buf.String(), want)
+	}
+
+	buf.Reset()
+	dumpRolesFormat = dumpRolesJSON
+	ssoRoleNames = []string{"AWSReadOnlyAccess"}
+	if _, err := configureSsoProfiles("token"); err != nil {
+		t.Fatalf("configureSsoProfiles failed: %v", err)
+	}
+	var got []roleDumpEntry
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON %q: %v", buf.String(), err)
+	}
+	if len(got) != 2 || got[0] != (roleDumpEntry{"111", "Prod", "AWSReadOnlyAccess"}) || got[1].AccountId != "222" {
+		t.Fatalf("unexpected JSON entries %+v", got)
+	}
+	if profileExists("ReadOnly_Prod_111", ssoConfigFile) {
+		t.Fatal("-dump-roles configured a profile")
+	}
+	if validateDumpRolesFormat("yaml") == nil {
+		t.Fatal("expected -dump-roles=yaml to be rejected")
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import "flag"
+
+// envFallbacks are the environment variables read for flags the command
+// line leaves unset, for containerized runs configured through the
+// environment.
+var envFallbacks = []struct{ flag, env string }{
+	{"sso-start-url", "AWS_SSO_START_URL"},
+	{"sso-region", "AWS_SSO_REGION"},
+	{"sso-session-name", "AWS_SSO_SESSION_NAME"},
+}
+
+// applyEnvFallbacks sets each envFallbacks flag not given on the command
+// line from its environment variable, when that is non-empty. It runs before
+// the settings file is loaded, so like the AWS SDK the environment wins over
+// the file while explicit flags win over both.
+func applyEnvFallbacks(fs *flag.FlagSet, lookup func(string) (string, bool)) error {
+	for _, f := range envFallbacks {
+		if flagWasSet(fs, f.flag) {
+			continue
+		}
+		value, ok := lookup(f.env)
+		if !ok || value == "" {
+			continue
+		}
+		if err := fs.Set(f.flag, value); err != nil {
+			return err
+		}
+		debugf("Using -%s from %s\n", f.flag, f.env)
+	}
+	return nil
+}
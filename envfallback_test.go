@@ -0,0 +1,45 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"testing"
+)
+
+// TestEnvFallbacks verifies AWS_SSO_START_URL and friends fill in omitted
+// flags, that an explicit flag wins over the environment, and that the
+// environment wins over the settings file.
+func TestEnvFallbacks(t *testing.T) {
+	oldStart, oldSession, oldRegion, oldConfig := ssoStartURL, ssoSessionConfigName, ssoRegion, ssoConfigFile
+	defer func() {
+		ssoStartURL, ssoSessionConfigName, ssoRegion, ssoConfigFile = oldStart, oldSession, oldRegion, oldConfig
+	}()
+	t.Setenv("AWS_SSO_START_URL", "https://env.awsapps.com/start")
+	t.Setenv("AWS_SSO_REGION", "eu-central-1")
+	t.Setenv("AWS_SSO_SESSION_NAME", "")
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	registerSsoFlags(fs)
+	if err := fs.Parse([]string{"-sso-region", "us-west-2"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyEnvFallbacks(fs, os.LookupEnv); err != nil {
+		t.Fatalf("applyEnvFallbacks failed: %v", err)
+	}
+	if ssoStartURL != "https://env.awsapps.com/start" {
+		t.Fatalf("expected the start URL from the environment, got %q", ssoStartURL)
+	}
+	if ssoRegion != "us-west-2" {
+		t.Fatalf("expected the -sso-region flag to win, got %q", ssoRegion)
+	}
+	if ssoSessionConfigName != defaultSSOSessionConfigName {
+		t.Fatalf("expected an empty variable to be ignored, got %q", ssoSessionConfigName)
+	}
+
+	if err := applySettings([]settingEntry{{Line: 1, Flag: "sso-start-url", Value: "https://file.awsapps.com/start"}}, fs); err != nil {
+		t.Fatalf("applySettings failed: %v", err)
+	}
+	if ssoStartURL != "https://env.awsapps.com/start" {
+		t.Fatalf("expected the environment to win over the settings file, got %q", ssoStartURL)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"time"
+)
+
+// estimatedRoleCallLatency is the assumed duration of one ListAccountRoles
+// call, used by -estimate to project the run time.
+const estimatedRoleCallLatency = 250 * time.Millisecond
+
+// callEstimate is the projected API cost of a full sync.
+type callEstimate struct {
+	Accounts      int
+	ListAccounts  int
+	RoleCalls     int
+	Concurrency   int
+	EstimatedTime time.Duration
+}
+
+// estimateCalls projects the API calls and duration of a full run over the
+// given number of accounts: one ListAccountRoles call per account, spread
+// over the configured worker count.
+func estimateCalls(accounts, concurrency int) callEstimate {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	rounds := (accounts + concurrency - 1) / concurrency
+	return callEstimate{
+		Accounts:      accounts,
+		ListAccounts:  1,
+		RoleCalls:     accounts,
+		Concurrency:   concurrency,
+		EstimatedTime: time.Duration(rounds) * estimatedRoleCallLatency,
+	}
+}
+
+// printCallEstimate lists the accounts once and prints the projected cost of
+// a full run, without enumerating any roles. -account-id and
+// -account-name-regex are honored; -account-tag is not, as it needs
+// Organizations calls of its own.
+func printCallEstimate(accessToken string) error {
+	accounts, err := getListOfSsoAccountsFunc(accessToken)
+	if err != nil {
+		return err
+	}
+	est := estimateCalls(len(filterAccountsByIdAndName(accounts)), accountConcurrency)
+	resultf("%s%s\n", cyan(icon("summary")), bold("API call estimate"))
+	resultf("    Accounts:         %d\n", est.Accounts)
+	resultf("    ListAccounts:     %d paginated call\n", est.ListAccounts)
+	resultf("    ListAccountRoles: %d call(s)\n", est.RoleCalls)
+	resultf("    Estimated time:   ~%s with -concurrency %d (assuming %s per call)\n", est.EstimatedTime.Round(time.Second), est.Concurrency, estimatedRoleCallLatency)
+	return nil
+}
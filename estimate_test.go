@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// TestPrintCallEstimate stubs 25 accounts and asserts the estimate reports
+// one ListAccountRoles call per account without enumerating any roles.
+func TestPrintCallEstimate(t *testing.T) {
+	var accounts []ssoTypesAccount
+	for i := 0; i < 25; i++ {
+		accounts = append(accounts, ssoTypesAccount{AccountId: fmt.Sprintf("%012d", i), AccountName: "acct"})
+	}
+	stubDiscovery(t, accounts, nil)
+	getListOfSsoAccountRolesForAccountFunc = func(accessToken, accountId string) ([]ssoTypesRole, error) {
+		t.Fatalf("-estimate enumerated roles for %s", accountId)
+		return nil, nil
+	}
+	oldConcurrency := accountConcurrency
+	defer func() { accountConcurrency = oldConcurrency }()
+	accountConcurrency = 4
+
+	var err error
+	out := captureStdout(t, func() { err = printCallEstimate("token") })
+	if err != nil {
+		t.Fatalf("printCallEstimate failed: %v", err)
+	}
+	for _, want := range []string{"Accounts:         25", "ListAccountRoles: 25 call(s)", "-concurrency 4"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("estimate missing %q:\n%s", want, out)
+		}
+	}
+
+	est := estimateCalls(25, 4)
+	if est.RoleCalls != 25 || est.EstimatedTime != 7*estimatedRoleCallLatency {
+		t.Fatalf("unexpected estimate %+v", est)
+	}
+	if got := estimateCalls(3, 0).EstimatedTime; got != 3*estimatedRoleCallLatency {
+		t.Fatalf("expected serial estimate for concurrency 0, got %s", got)
+	}
+}
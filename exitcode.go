@@ -0,0 +1,59 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+)
+
+// Process exit codes of a sync run. Subcommands use exitRuntimeError and
+// exitValidationError the same way.
+const (
+	// exitOK means changes were applied, or a dry-run, plan or listing
+	// completed.
+	exitOK = 0
+	// exitRuntimeError means discovery, login or a write failed.
+	exitRuntimeError = 1
+	// exitValidationError means the flags or their values were invalid.
+	exitValidationError = 2
+	// exitNoChanges means the sync ran but every profile already existed.
+	exitNoChanges = 3
+)
+
+// syncResults collects the result of every sync in this run (one per
+// geography), for main to choose the exit code.
+var syncResults []SyncResult
+
+// syncExitCode returns the exit code for a run that completed without an
+// error. A dry-run always exits 0; a real run exits 1 if any profile failed,
+// 3 if nothing was added, updated or removed, and 0 otherwise.
+func syncExitCode(results []SyncResult, dry bool) int {
+	if dry || len(results) == 0 {
+		return exitOK
+	}
+	changed := false
+	for _, r := range results {
+		if len(r.Failed) > 0 {
+			return exitRuntimeError
+		}
+		if len(r.Added) > 0 || len(r.Updated) > 0 || len(r.Pruned) > 0 {
+			changed = true
+		}
+	}
+	if !changed {
+		return exitNoChanges
+	}
+	return exitOK
+}
+
+// printUsage prints the flag help followed by the exit codes.
+func printUsage(fs *flag.FlagSet) {
+	fmt.Fprintf(fs.Output(), "Usage of %s:\n", fs.Name())
+	fs.PrintDefaults()
+	fmt.Fprintf(fs.Output(), `
+Exit codes:
+  0  changes applied (a dry-run, plan or listing also exits 0)
+  1  runtime error, e.g. discovery or login failed or a profile could not be written
+  2  invalid flags or flag values
+  3  no changes needed: every selected profile already existed
+`)
+}
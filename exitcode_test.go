@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestSyncExitCode runs a sync twice against an empty config and asserts the
+// first run exits 0 for its added profile, the second exits 3 as nothing
+// changed, a dry-run exits 0 and a failed profile exits 1.
+func TestSyncExitCode(t *testing.T) {
+	stubDiscovery(t,
+		[]ssoTypesAccount{{AccountId: "111", AccountName: "Prod"}},
+		map[string][]string{"111": {"AWSReadOnlyAccess"}})
+	oldConfig, oldRoles, oldDry := ssoConfigFile, ssoRoleNames, dryRun
+	defer func() { ssoConfigFile, ssoRoleNames, dryRun = oldConfig, oldRoles, oldDry }()
+	ssoConfigFile = filepath.Join(t.TempDir(), "config")
+	ssoRoleNames, dryRun = []string{"AWSReadOnlyAccess"}, false
+
+	var results []SyncResult
+	for i := 0; i < 2; i++ {
+		var result SyncResult
+		var err error
+		captureStdout(t, func() { result, err = configureSsoProfiles("token") })
+		if err != nil {
+			t.Fatalf("run %d failed: %v", i+1, err)
+		}
+		results = append(results, result)
+	}
+	if code := syncExitCode(results[:1], false); code != exitOK {
+		t.Fatalf("run with an added profile: exit %d, want %d", code, exitOK)
+	}
+	if code := syncExitCode(results[1:], false); code != exitNoChanges {
+		t.Fatalf("run without changes: exit %d, want %d", code, exitNoChanges)
+	}
+	if code := syncExitCode(results[1:], true); code != exitOK {
+		t.Fatalf("dry-run: exit %d, want %d", code, exitOK)
+	}
+	failed := []SyncResult{{Added: results[0].Added, Failed: []ProfileResult{{ProfileName: "x"}}}}
+	if code := syncExitCode(failed, false); code != exitRuntimeError {
+		t.Fatalf("run with a failed profile: exit %d, want %d", code, exitRuntimeError)
+	}
+
+	var buf bytes.Buffer
+	fs := flag.NewFlagSet(programName, flag.ContinueOnError)
+	fs.SetOutput(&buf)
+	fs.Bool("dry-run", false, "Show what would be done")
+	printUsage(fs)
+	if !strings.Contains(buf.String(), "-dry-run") || !strings.Contains(buf.String(), "3  no changes needed") {
+		t.Fatalf("usage is missing the flags or exit codes:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// extraProfileKeys are the -extra-key pairs written into every generated
+// profile, in flag order.
+var extraProfileKeys []keyValue
+
+// parseExtraKeys parses -extra-key values of the form KEY=VALUE. The value
+// may be empty (e.g. cli_pager=). A key must be a plain INI key name, may
+// appear once, and may only replace one of the keys the tool manages
+// (sso_session, region, ...) when override is set (-force).
+func parseExtraKeys(pairs []string, override bool) ([]keyValue, error) {
+	var out []keyValue
+	seen := make(map[string]bool)
+	for _, pair := range pairs {
+		key, value, ok := strings.Cut(pair, "=")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid -extra-key %q: expected KEY=VALUE", pair)
+		}
+		if strings.ContainsAny(key, " \t[];#") {
+			return nil, fmt.Errorf("invalid -extra-key %q: %q is not a valid key name", pair, key)
+		}
+		if seen[key] {
+			return nil, fmt.Errorf("-extra-key %s is given more than once", key)
+		}
+		seen[key] = true
+		if managedProfileKey(key) != "" && !override {
+			return nil, fmt.Errorf("-extra-key %s would replace a key the tool writes; pass -force to override it", key)
+		}
+		out = append(out, keyValue{key, value})
+	}
+	return out, nil
+}
+
+// managedProfileKey returns the logical profile key that key names, either
+// directly or through its -key-name mapping, or "" when key is not managed.
+func managedProfileKey(key string) string {
+	for _, logical := range profileWriteKeys {
+		if key == logical || key == profileKey(logical) {
+			return logical
+		}
+	}
+	return ""
+}
+
+// profileKeys returns the logical keys a generated profile is written with:
+// the managed keys followed by the -extra-key names that don't override
+// one of them.
+func profileKeys() []string {
+	keys := slices.Clone(profileWriteKeys)
+	for _, kv := range extraProfileKeys {
+		if managedProfileKey(kv.key) == "" {
+			keys = append(keys, kv.key)
+		}
+	}
+	return keys
+}
+
+// applyExtraKeys adds the -extra-key pairs to the written values of a
+// profile, replacing a managed key's value when the pair overrides it.
+func applyExtraKeys(values map[string]string) {
+	for _, kv := range extraProfileKeys {
+		if logical := managedProfileKey(kv.key); logical != "" {
+			values[logical] = kv.value
+			continue
+		}
+		values[kv.key] = kv.value
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/ini.v1"
+)
+
+// TestExtraKeys verifies -extra-key validation, that the pairs are written
+// into the profile section and the dry-run block, and that a managed key is
+// only replaced with -force.
+func TestExtraKeys(t *testing.T) {
+	for _, bad := range [][]string{{"=x"}, {"novalue"}, {"bad key=x"}, {"a=1", "a=2"}, {"region=eu-west-1"}} {
+		if _, err := parseExtraKeys(bad, false); err == nil {
+			t.Errorf("expected %v to be rejected", bad)
+		}
+	}
+	if _, err := parseExtraKeys([]string{"region=eu-west-1"}, true); err != nil {
+		t.Fatalf("expected -force to allow overriding region, got %v", err)
+	}
+
+	cfgPath := filepath.Join(t.TempDir(), "config")
+	oldConfig, oldSession, oldRegion, oldDry, oldExtra := ssoConfigFile, ssoSessionConfigName, ssoRegion, dryRun, extraProfileKeys
+	defer func() {
+		ssoConfigFile, ssoSessionConfigName, ssoRegion, dryRun, extraProfileKeys = oldConfig, oldSession, oldRegion, oldDry, oldExtra
+	}()
+	ssoConfigFile, ssoSessionConfigName, ssoRegion = cfgPath, "corp", "us-east-1"
+	extras, err := parseExtraKeys([]string{"cli_pager=", "ca_bundle=/etc/ca.pem", "region=eu-west-1"}, true)
+	if err != nil {
+		t.Fatalf("parseExtraKeys failed: %v", err)
+	}
+	extraProfileKeys = extras
+	role := CombinedRole{AccountId: "111", AccountName: "Prod", RoleName: "AWSReadOnlyAccess"}
+
+	dryRun = true
+	out := captureStdout(t, func() {
+		if err := writeProfileToConfig("ReadOnly_Prod_111", role); err != nil {
+			t.Fatalf("dry-run writeProfileToConfig failed: %v", err)
+		}
+	})
+	if !strings.Contains(out, "ca_bundle = /etc/ca.pem") || !strings.Contains(out, "cli_pager = ") {
+		t.Fatalf("expected the extra keys in the dry-run block, got %q", out)
+	}
+
+	dryRun = false
+	if err := writeProfileToConfig("ReadOnly_Prod_111", role); err != nil {
+		t.Fatalf("writeProfileToConfig failed: %v", err)
+	}
+	cfg, err := ini.Load(cfgPath)
+	if err != nil {
+		t.Fatalf("failed to load written config: %v", err)
+	}
+	sec := cfg.Section("profile ReadOnly_Prod_111")
+	if !sec.HasKey("cli_pager") || sec.Key("cli_pager").String() != "" {
+		t.Fatalf("expected an empty cli_pager, got keys %v", sec.KeyStrings())
+	}
+	if got := sec.Key("ca_bundle").String(); got != "/etc/ca.pem" {
+		t.Fatalf("unexpected ca_bundle %q", got)
+	}
+	if got := sec.Key("region").String(); got != "eu-west-1" {
+		t.Fatalf("expected the overridden region, got %q", got)
+	}
+	if got := sec.Key("sso_role_name").String(); got != "AWSReadOnlyAccess" {
+		t.Fatalf("expected the managed keys to be kept, got sso_role_name %q", got)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+
+	"gopkg.in/ini.v1"
+)
+
+// forceUpdate makes a sync rewrite the keys of existing profiles instead of
+// skipping them (-force).
+var forceUpdate bool
+
+// profileChanges compares the keys written for role with the existing
+// profile section and returns the differences in write order. A key with an
+// empty New is one that would be removed.
+func profileChanges(profileName string, role CombinedRole) ([]keyChange, error) {
+	cfg, err := ini.Load(profileConfigPath(profileName))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			cfg = ini.Empty()
+		} else {
+			return nil, err
+		}
+	}
+	section := cfg.Section("profile " + profileName)
+	values := profileValues(role)
+	var changes []keyChange
+	for _, logical := range profileKeys() {
+		key := profileKey(logical)
+		value, want := values[logical]
+		has := section.HasKey(key)
+		old := section.Key(key).String()
+		switch {
+		case want && (!has || old != value):
+			changes = append(changes, keyChange{Key: key, Old: old, New: value})
+		case !want && has:
+			changes = append(changes, keyChange{Key: key, Old: old})
+		}
+	}
+	return changes, nil
+}
+
+// printKeyChanges prints the key differences of an updated profile, diff
+// style.
+func printKeyChanges(changes []keyChange) {
+	for _, c := range changes {
+		switch {
+		case c.New == "":
+			infof("      %s %s = %s\n", red(planRemove), c.Key, c.Old)
+		case c.Old == "":
+			infof("      %s %s = %s\n", green(planAdd), c.Key, c.New)
+		default:
+			infof("      %s %s = %s -> %s\n", yellow(planUpdate), c.Key, c.Old, c.New)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/ini.v1"
+)
+
+// TestForceUpdatesExistingProfile asserts -force rewrites drifted keys of an
+// existing profile, reports it as updated, shows the key diff in dry-run and
+// counts an unchanged profile as up to date.
+func TestForceUpdatesExistingProfile(t *testing.T) {
+	stubDiscovery(t,
+		[]ssoTypesAccount{{AccountId: "111", AccountName: "Prod"}},
+		map[string][]string{"111": {"AWSReadOnlyAccess"}})
+	oldConfig, oldRoles, oldDry, oldForce, oldOutput := ssoConfigFile, ssoRoleNames, dryRun, forceUpdate, profileOutput
+	defer func() {
+		ssoConfigFile, ssoRoleNames, dryRun, forceUpdate, profileOutput = oldConfig, oldRoles, oldDry, oldForce, oldOutput
+	}()
+	ssoConfigFile = filepath.Join(t.TempDir(), "config")
+	ssoRoleNames = []string{"AWSReadOnlyAccess"}
+	profileOutput = "json"
+	dryRun, forceUpdate = false, false
+
+	var err error
+	captureStdout(t, func() { _, err = configureSsoProfiles("token") })
+	if err != nil {
+		t.Fatalf("initial sync failed: %v", err)
+	}
+	data, err := os.ReadFile(ssoConfigFile)
+	if err != nil {
+		t.Fatalf("failed to read config: %v", err)
+	}
+	if err := os.WriteFile(ssoConfigFile, []byte(strings.Replace(string(data), "output = json", "output = text", 1)), 0600); err != nil {
+		t.Fatalf("failed to edit config: %v", err)
+	}
+
+	out := captureStdout(t, func() { _, err = configureSsoProfiles("token") })
+	if err != nil || !strings.Contains(out, "0 new profile(s), 1 already configured") {
+		t.Fatalf("expected the profile to be skipped without -force, err=%v output:\n%s", err, out)
+	}
+
+	forceUpdate, dryRun = true, true
+	out = captureStdout(t, func() { _, err = configureSsoProfiles("token") })
+	if err != nil || !strings.Contains(out, "output = text -> json") || !strings.Contains(out, "0 profile(s) would be added, 1 updated, 0 already up to date") {
+		t.Fatalf("expected a dry-run key diff, err=%v output:\n%s", err, out)
+	}
+	if reloaded, _ := ini.Load(ssoConfigFile); reloaded.Section(findProfileSection(t, reloaded)).Key("output").String() != "text" {
+		t.Fatalf("dry-run modified the config file")
+	}
+
+	dryRun = false
+	out = captureStdout(t, func() { _, err = configureSsoProfiles("token") })
+	if err != nil || !strings.Contains(out, "0 new profile(s), 1 updated, 0 already up to date") {
+		t.Fatalf("expected the profile to be updated, err=%v output:\n%s", err, out)
+	}
+	if reloaded, _ := ini.Load(ssoConfigFile); reloaded.Section(findProfileSection(t, reloaded)).Key("output").String() != "json" {
+		t.Fatalf("-force did not rewrite the drifted key")
+	}
+
+	out = captureStdout(t, func() { _, err = configureSsoProfiles("token") })
+	if err != nil || !strings.Contains(out, "0 new profile(s), 0 updated, 1 already up to date") {
+		t.Fatalf("expected an unchanged profile to count as up to date, err=%v output:\n%s", err, out)
+	}
+}
+
+// findProfileSection returns the name of the only profile section in cfg.
+func findProfileSection(t *testing.T, cfg *ini.File) string {
+	t.Helper()
+	for _, name := range cfg.SectionStrings() {
+		if strings.HasPrefix(name, "profile ") {
+			return name
+		}
+	}
+	t.Fatalf("no profile section in %v", cfg.SectionStrings())
+	return ""
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// syncGeography is one IAM Identity Center instance of a multi-geography
+// config: a declarative config given as a JSON list of these entries.
+type syncGeography struct {
+	StartURL    string   `json:"start_url"`
+	Region      string   `json:"region"`
+	SessionName string   `json:"session_name"`
+	Roles       []string `json:"roles"`
+}
+
+// geographies holds the instances of a multi-geography config. When set, the
+// sync runs once per entry, each with its own sso-session block, and every
+// profile name is namespaced with "<session_name>-".
+var geographies []syncGeography
+
+// profileNamespace is prepended to every generated profile name while a
+// geography is being synced.
+var profileNamespace string
+
+// parseGeographies decodes and validates the list form of a declarative
+// config. Every entry needs all four fields, and session names must be
+// unique so each geography gets its own session block and namespace.
+func parseGeographies(data []byte) ([]syncGeography, error) {
+	var list []syncGeography
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&list); err != nil {
+		return nil, fmt.Errorf("config does not match the expected schema: %v", err)
+	}
+	if len(list) == 0 {
+		return nil, fmt.Errorf("config does not match the expected schema: the geography list is empty")
+	}
+	for i := range list {
+		g := &list[i]
+		fields := []envField{
+			{fmt.Sprintf("geography %d start_url", i+1), &g.StartURL},
+			{fmt.Sprintf("geography %d region", i+1), &g.Region},
+			{fmt.Sprintf("geography %d session_name", i+1), &g.SessionName},
+		}
+		for j := range g.Roles {
+			fields = append(fields, envField{fmt.Sprintf("geography %d roles", i+1), &g.Roles[j]})
+		}
+		if err := expandFieldsEnv(fields); err != nil {
+			return nil, err
+		}
+	}
+	seen := make(map[string]bool)
+	for i, g := range list {
+		if g.StartURL == "" || g.Region == "" || g.SessionName == "" || len(g.Roles) == 0 {
+			return nil, fmt.Errorf("config does not match the expected schema: geography %d needs start_url, region, session_name and roles", i+1)
+		}
+		if seen[g.SessionName] {
+			return nil, fmt.Errorf("config does not match the expected schema: session_name %q is used by more than one geography", g.SessionName)
+		}
+		seen[g.SessionName] = true
+	}
+	return list, nil
+}
+
+// syncGeographies runs sync once per geography with the SSO settings, roles
+// and profile namespace of that geography, stopping at the first failure.
+func syncGeographies(list []syncGeography, sync func() error) error {
+	defer func() { profileNamespace = "" }()
+	for i, g := range list {
+		infof("\n%s%s %d/%d: %s (%s, session %s)\n", cyan(icon("location")), bold("Geography"), i+1, len(list), g.StartURL, g.Region, g.SessionName)
+		ssoStartURL, ssoRegion, ssoSessionConfigName = g.StartURL, g.Region, g.SessionName
+		ssoRoleNames = g.Roles
+		profileNamespace = g.SessionName + "-"
+		if err := sync(); err != nil {
+			return fmt.Errorf("geography %s: %w", g.SessionName, err)
+		}
+	}
+	return nil
+}
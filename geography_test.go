@@ -0,0 +1,137 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/ini.v1"
+)
+
+// TestMultiGeographySync parses a two-geography config and runs the full
+// login() -> configure path for each against an in-memory store of tokens,
+// accounts and roles keyed by start URL. The result is one config with a
+// session block per geography and namespaced profiles.
+func TestMultiGeographySync(t *testing.T) {
+	cfg, err := parseSyncConfig([]byte(`[
+		{"start_url": "https://eu.awsapps.com/start", "region": "eu-central-1", "session_name": "eu", "roles": ["AWSReadOnlyAccess"]},
+		{"start_url": "https://us.awsapps.com/start", "region": "us-east-1", "session_name": "us", "roles": ["AWSAdministratorAccess"]}
+	]`))
+	if err != nil {
+		t.Fatalf("parseSyncConfig failed: %v", err)
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	registerSyncFlags(fs)
+	oldGeographies := geographies
+	defer func() { geographies = oldGeographies }()
+	if err := applySyncConfig(cfg, fs); err != nil || len(geographies) != 2 {
+		t.Fatalf("applySyncConfig = %v, geographies %+v", err, geographies)
+	}
+	fs.Set("role", "Other")
+	if err := applySyncConfig(cfg, fs); err == nil || !strings.Contains(err.Error(), "-role") {
+		t.Fatalf("expected -role to conflict with a multi-geography config, got %v", err)
+	}
+
+	type instance struct {
+		token    string
+		accounts []ssoTypesAccount
+		roles    map[string][]string
+	}
+	store := map[string]instance{
+		"https://eu.awsapps.com/start": {"eu-token", []ssoTypesAccount{{AccountId: "111", AccountName: "Prod"}}, map[string][]string{"111": {"AWSReadOnlyAccess", "AWSAdministratorAccess"}}},
+		"https://us.awsapps.com/start": {"us-token", []ssoTypesAccount{{AccountId: "111", AccountName: "Prod"}, {AccountId: "222", AccountName: "Dev"}}, map[string][]string{"111": {"AWSAdministratorAccess"}, "222": {"AWSAdministratorAccess"}}},
+	}
+	origGet, origValid, origAccounts, origRoles := getAccessTokenFunc, isSsoTokenValidFunc, getListOfSsoAccountsFunc, getListOfSsoAccountRolesForAccountFunc
+	oldURL, oldRegion, oldSession, oldRoleNames, oldConfig, oldDry, oldAuto, oldPrefix := ssoStartURL, ssoRegion, ssoSessionConfigName, ssoRoleNames, ssoConfigFile, dryRun, useAutoPrefix, profilePrefix
+	defer func() {
+		getAccessTokenFunc, isSsoTokenValidFunc, getListOfSsoAccountsFunc, getListOfSsoAccountRolesForAccountFunc = origGet, origValid, origAccounts, origRoles
+		ssoStartURL, ssoRegion, ssoSessionConfigName, ssoRoleNames, ssoConfigFile, dryRun, useAutoPrefix, profilePrefix = oldURL, oldRegion, oldSession, oldRoleNames, oldConfig, oldDry, oldAuto, oldPrefix
+	}()
+	getAccessTokenFunc = func() (string, string, error) {
+		return store[ssoStartURL].token, "memory:" + ssoStartURL, nil
+	}
+	isSsoTokenValidFunc = func(token string) bool { return token == store[ssoStartURL].token }
+	getListOfSsoAccountsFunc = func(token string) ([]ssoTypesAccount, error) { return store[ssoStartURL].accounts, nil }
+	getListOfSsoAccountRolesForAccountFunc = func(token, accountId string) ([]ssoTypesRole, error) {
+		var out []ssoTypesRole
+		for _, r := range store[ssoStartURL].roles[accountId] {
+			out = append(out, ssoTypesRole{RoleName: r})
+		}
+		return out, nil
+	}
+	ssoConfigFile = filepath.Join(t.TempDir(), "config")
+	dryRun, useAutoPrefix, profilePrefix = false, true, ""
+
+	var syncErr error
+	out := captureStdout(t, func() { syncErr = syncGeographies(geographies, login) })
+	if syncErr != nil {
+		t.Fatalf("syncGeographies failed: %v\n%s", syncErr, out)
+	}
+	if profileNamespace != "" {
+		t.Errorf("namespace %q leaked past the run", profileNamespace)
+	}
+
+	file, err := ini.Load(ssoConfigFile)
+	if err != nil {
+		t.Fatalf("failed to load written config: %v", err)
+	}
+	for session, url := range map[string]string{"eu": "https://eu.awsapps.com/start", "us": "https://us.awsapps.com/start"} {
+		if got := file.Section("sso-session " + session).Key("sso_start_url").String(); got != url {
+			t.Errorf("sso-session %s start URL = %q", session, got)
+		}
+	}
+	want := map[string]string{
+		"eu-ReadOnly_Prod_111":      "eu",
+		"us-Administrator_Prod_111": "us",
+		"us-Administrator_Dev_222":  "us",
+	}
+	var profiles []string
+	for _, section := range file.Sections() {
+		if name, ok := strings.CutPrefix(section.Name(), "profile "); ok {
+			profiles = append(profiles, name)
+			if want[name] != section.Key("sso_session").String() {
+				t.Errorf("profile %s has sso_session %q", name, section.Key("sso_session").String())
+			}
+		}
+	}
+	if len(profiles) != len(want) {
+		t.Errorf("unexpected profiles %v", profiles)
+	}
+
+	for name, body := range map[string]string{
+		"missing roles":     `[{"start_url": "https://a", "region": "us-east-1", "session_name": "a"}]`,
+		"duplicate session": `[{"start_url": "https://a", "region": "us-east-1", "session_name": "a", "roles": ["R"]}, {"start_url": "https://b", "region": "us-east-1", "session_name": "a", "roles": ["R"]}]`,
+		"unknown field":     `[{"start_url": "https://a", "region": "us-east-1", "session_name": "a", "roles": ["R"], "extra": 1}]`,
+	} {
+		if _, err := parseSyncConfig([]byte(body)); err == nil || !strings.Contains(err.Error(), "schema") {
+			t.Errorf("%s: expected a schema error, got %v", name, err)
+		}
+	}
+}
+
+// TestParseGeographiesEnvExpansion asserts environment variables are
+// expanded in every geography, and that an unset variable is an error
+// naming the geography and field.
+func TestParseGeographiesEnvExpansion(t *testing.T) {
+	t.Setenv("ACME_EU_URL", "https://acme-eu.awsapps.com/start")
+	list, err := parseSyncConfig([]byte(`[
+		{"start_url": "${ACME_EU_URL}", "region": "eu-west-1", "session_name": "eu", "roles": ["${ACME_EU_ROLE:-AWSReadOnlyAccess}"]}
+	]`))
+	if err != nil {
+		t.Fatalf("parseSyncConfig failed: %v", err)
+	}
+	if g := list.Geographies[0]; g.StartURL != "https://acme-eu.awsapps.com/start" || g.Roles[0] != "AWSReadOnlyAccess" {
+		t.Fatalf("unexpected expanded geography: %+v", g)
+	}
+
+	_, err = parseGeographies([]byte(`[
+		{"start_url": "https://acme-eu.awsapps.com/start", "region": "eu-west-1", "session_name": "eu", "roles": ["AWSReadOnlyAccess"]},
+		{"start_url": "${ACME_US_URL}", "region": "us-east-1", "session_name": "us", "roles": ["AWSReadOnlyAccess"]}
+	]`))
+	if err == nil || !strings.Contains(err.Error(), "geography 2 start_url") || !strings.Contains(err.Error(), "ACME_US_URL is not set") {
+		t.Fatalf("expected an unset variable error for geography 2, got %v", err)
+	}
+}
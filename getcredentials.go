@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Supported values for get-credentials -format
+const (
+	credentialsFormatEnv  = "env"
+	credentialsFormatJSON = "json"
+)
+
+// validateCredentialsFormat checks that get-credentials -format is one of
+// the supported values.
+func validateCredentialsFormat(format string) error {
+	switch format {
+	case credentialsFormatEnv, credentialsFormatJSON:
+		return nil
+	}
+	return fmt.Errorf("invalid -format %q: expected %s or %s", format, credentialsFormatEnv, credentialsFormatJSON)
+}
+
+// credentialsDocument is the get-credentials JSON output.
+type credentialsDocument struct {
+	AccountId       string `json:"accountId"`
+	RoleName        string `json:"roleName"`
+	AccessKeyId     string `json:"accessKeyId"`
+	SecretAccessKey string `json:"secretAccessKey"`
+	SessionToken    string `json:"sessionToken"`
+	Expiration      string `json:"expiration"`
+}
+
+// formatCredentials renders creds for accountId/roleName in format: shell
+// exports (with AWS_CREDENTIAL_EXPIRATION, as `aws configure
+// export-credentials` prints it) or an indented JSON object.
+func formatCredentials(creds roleCredentials, accountId, roleName, format string) (string, error) {
+	expiration := creds.Expiration.UTC().Format(time.RFC3339)
+	if format == credentialsFormatEnv {
+		return formatCredentialExports(creds, "sh") + fmt.Sprintf("export AWS_CREDENTIAL_EXPIRATION=%s\n", expiration), nil
+	}
+	b, err := json.MarshalIndent(credentialsDocument{
+		AccountId:       accountId,
+		RoleName:        roleName,
+		AccessKeyId:     creds.AccessKeyId,
+		SecretAccessKey: creds.SecretAccessKey,
+		SessionToken:    creds.SessionToken,
+		Expiration:      expiration,
+	}, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(b) + "\n", nil
+}
+
+// runGetCredentialsCommand implements the get-credentials subcommand, which
+// fetches role credentials for one account/role with the cached SSO token
+// and prints them as shell exports or JSON. It never signs in; without a
+// usable cached token it fails with a hint to run a sync first. It returns
+// the process exit code.
+func runGetCredentialsCommand(args []string) int {
+	fs := flag.NewFlagSet("get-credentials", flag.ContinueOnError)
+	registerSsoFlags(fs)
+	registerOutputFlags(fs)
+	accountId := fs.String("account-id", "", "AWS account ID to fetch credentials for (required)")
+	roleName := fs.String("role", "", "SSO role name to fetch credentials for (required)")
+	format := fs.String("format", credentialsFormatEnv, "Output format: env (export lines) or json")
+	useCache := fs.Bool("cache", true, "Reuse cached role credentials until shortly before they expire")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if ssoStartURL == "" || *accountId == "" || *roleName == "" {
+		fmt.Fprintf(os.Stderr, "%s%s\n", red(icon("error")), bold("Error: get-credentials requires -sso-start-url, -account-id and -role"))
+		fs.Usage()
+		return 2
+	}
+	if err := validateCredentialsFormat(*format); err != nil {
+		fmt.Fprintf(os.Stderr, "%s%s %v\n", red(icon("error")), bold("Error:"), err)
+		return 2
+	}
+
+	creds, err := roleCredentialsFromCachedToken(*accountId, *roleName, *useCache)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s%s %v\n", red(icon("error")), bold("Error:"), err)
+		return 1
+	}
+	out, err := formatCredentials(creds, *accountId, *roleName, *format)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s%s %v\n", red(icon("error")), bold("Error:"), err)
+		return 1
+	}
+	fmt.Print(out)
+	return 0
+}
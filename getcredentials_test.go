@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestGetCredentials covers the env and json formats of get-credentials,
+// including the expiration, and the failure without a cached token.
+func TestGetCredentials(t *testing.T) {
+	origGet, origFetch := getAccessTokenFunc, getRoleCredentialsFunc
+	oldStart, oldSession, oldRegion, oldConfig, oldLevel := ssoStartURL, ssoSessionConfigName, ssoRegion, ssoConfigFile, currentLogLevel
+	defer func() {
+		getAccessTokenFunc, getRoleCredentialsFunc = origGet, origFetch
+		ssoStartURL, ssoSessionConfigName, ssoRegion, ssoConfigFile, currentLogLevel = oldStart, oldSession, oldRegion, oldConfig, oldLevel
+	}()
+	getAccessTokenFunc = func() (string, string, error) { return "tok", "/tmp/tok.json", nil }
+	expiry := time.Date(2026, 1, 1, 13, 0, 0, 0, time.UTC)
+	var fetched []string
+	getRoleCredentialsFunc = func(accessToken, accountId, roleName string) (roleCredentials, error) {
+		fetched = append(fetched, accessToken+"|"+accountId+"|"+roleName)
+		return roleCredentials{AccessKeyId: "AKIA1", SecretAccessKey: "secret", SessionToken: "token", Expiration: expiry}, nil
+	}
+	run := func(extra ...string) (int, string) {
+		var code int
+		args := append([]string{"-sso-start-url", "https://unit.test/start", "-account-id", "111", "-role", "ReadOnly", "-cache=false"}, extra...)
+		out := captureStdout(t, func() { code = runGetCredentialsCommand(args) })
+		return code, out
+	}
+
+	code, out := run()
+	if code != 0 {
+		t.Fatalf("expected exit 0, got %d", code)
+	}
+	for _, line := range []string{"export AWS_ACCESS_KEY_ID=AKIA1", "export AWS_SECRET_ACCESS_KEY=secret", "export AWS_SESSION_TOKEN=token", "export AWS_CREDENTIAL_EXPIRATION=2026-01-01T13:00:00Z"} {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("expected %q in the env output, got:\n%s", line, out)
+		}
+	}
+	if len(fetched) != 1 || fetched[0] != "tok|111|ReadOnly" {
+		t.Fatalf("unexpected GetRoleCredentials calls %v", fetched)
+	}
+
+	code, out = run("-format", "json")
+	if code != 0 {
+		t.Fatalf("expected exit 0 for json, got %d", code)
+	}
+	var got credentialsDocument
+	if err := json.Unmarshal([]byte(out), &got); err != nil {
+		t.Fatalf("invalid JSON %q: %v", out, err)
+	}
+	want := credentialsDocument{AccountId: "111", RoleName: "ReadOnly", AccessKeyId: "AKIA1", SecretAccessKey: "secret", SessionToken: "token", Expiration: "2026-01-01T13:00:00Z"}
+	if got != want {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+
+	if code, _ := run("-format", "yaml"); code != 2 {
+		t.Fatalf("expected exit 2 for an unknown format, got %d", code)
+	}
+
+	getAccessTokenFunc = func() (string, string, error) { return "", "/tmp/tok.json", errTokenExpired }
+	stderr := captureStderr(t, func() { code, out = run() })
+	if code != 1 || out != "" || !strings.Contains(stderr, "has expired") {
+		t.Fatalf("expected a clear expired-token failure, got code %d stdout %q stderr %q", code, out, stderr)
+	}
+}
@@ -6,7 +6,14 @@ require (
 	github.com/aws/aws-sdk-go-v2 v1.39.0
 	github.com/aws/aws-sdk-go-v2/config v1.31.8
 	github.com/aws/aws-sdk-go-v2/service/sso v1.29.3
+	github.com/aws/aws-sdk-go-v2/service/ssooidc v1.34.4
+	github.com/aws/aws-sdk-go-v2/service/sts v1.38.4
+	github.com/aws/smithy-go v1.23.0
 	github.com/fatih/color v1.18.0
+	github.com/jmespath/go-jmespath v0.4.0
+	github.com/mattn/go-isatty v0.0.20
+	golang.org/x/sys v0.25.0
+	gopkg.in/ini.v1 v1.67.0
 )
 
 require (
@@ -17,11 +24,5 @@ require (
 	github.com/aws/aws-sdk-go-v2/internal/ini v1.8.3 // indirect
 	github.com/aws/aws-sdk-go-v2/service/internal/accept-encoding v1.13.1 // indirect
 	github.com/aws/aws-sdk-go-v2/service/internal/presigned-url v1.13.7 // indirect
-	github.com/aws/aws-sdk-go-v2/service/ssooidc v1.34.4 // indirect
-	github.com/aws/aws-sdk-go-v2/service/sts v1.38.4 // indirect
-	github.com/aws/smithy-go v1.23.0 // indirect
 	github.com/mattn/go-colorable v0.1.13 // indirect
-	github.com/mattn/go-isatty v0.0.20 // indirect
-	golang.org/x/sys v0.25.0 // indirect
-	gopkg.in/ini.v1 v1.67.0 // indirect
 )
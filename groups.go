@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// profileGrouper derives the group a generated profile belongs to, for the
+// cosmetic section separators written by -group-by.
+type profileGrouper struct {
+	// pattern is matched against the account name; the first capture group
+	// (or the whole match) is the group.
+	pattern *regexp.Regexp
+	// mapping assigns groups by account id or account name.
+	mapping map[string]string
+}
+
+// parseGroupBy parses a -group-by value: "pattern:<regexp>" derives the group
+// from the account name, and "map:<account>=<group>,..." assigns groups by
+// account id or name.
+func parseGroupBy(spec string) (*profileGrouper, error) {
+	switch {
+	case strings.HasPrefix(spec, "pattern:"):
+		re, err := regexp.Compile(strings.TrimPrefix(spec, "pattern:"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid -group-by pattern: %v", err)
+		}
+		return &profileGrouper{pattern: re}, nil
+	case strings.HasPrefix(spec, "map:"):
+		mapping := make(map[string]string)
+		for _, pair := range strings.Split(strings.TrimPrefix(spec, "map:"), ",") {
+			parts := strings.SplitN(pair, "=", 2)
+			if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || strings.TrimSpace(parts[1]) == "" {
+				return nil, fmt.Errorf("invalid -group-by mapping %q: expected account=group", pair)
+			}
+			mapping[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+		}
+		return &profileGrouper{mapping: mapping}, nil
+	default:
+		return nil, fmt.Errorf("invalid -group-by %q: expected pattern:<regexp> or map:<account>=<group>,...", spec)
+	}
+}
+
+// group returns the group for role, or "" if it belongs to none.
+func (g *profileGrouper) group(role CombinedRole) string {
+	if g.mapping != nil {
+		if name, ok := g.mapping[role.AccountId]; ok {
+			return name
+		}
+		return g.mapping[role.AccountName]
+	}
+	m := g.pattern.FindStringSubmatch(role.AccountName)
+	switch {
+	case len(m) > 1 && m[1] != "":
+		return strings.ToLower(m[1])
+	case len(m) > 0:
+		return strings.ToLower(m[0])
+	}
+	return ""
+}
+
+// sortRolesByGroup orders roles so each group's profiles are written
+// together, keeping discovery order within a group.
+func sortRolesByGroup(roles []CombinedRole, g *profileGrouper) {
+	sort.SliceStable(roles, func(i, j int) bool { return g.group(roles[i]) < g.group(roles[j]) })
+}
+
+// groupHeader is the separator comment written above the first profile of a
+// group.
+func groupHeader(group string) string {
+	return fmt.Sprintf("# ===== %s =====", group)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestGroupByHeadersWrittenOnce syncs twice with -group-by, adding another
+// prod account on the second run, and asserts each group header appears
+// exactly once and precedes its group's first profile.
+func TestGroupByHeadersWrittenOnce(t *testing.T) {
+	oldConfig, oldDry, oldRoles, oldGroup := ssoConfigFile, dryRun, ssoRoleNames, profileGroupBy
+	oldPrefix, oldAuto, oldSession := profilePrefix, useAutoPrefix, ssoSessionConfigName
+	defer func() {
+		ssoConfigFile, dryRun, ssoRoleNames, profileGroupBy = oldConfig, oldDry, oldRoles, oldGroup
+		profilePrefix, useAutoPrefix, ssoSessionConfigName = oldPrefix, oldAuto, oldSession
+	}()
+	ssoConfigFile = filepath.Join(t.TempDir(), "config")
+	dryRun, ssoRoleNames = false, []string{"AWSReadOnlyAccess"}
+	profilePrefix, useAutoPrefix, ssoSessionConfigName = "", false, "corp"
+	grouper, err := parseGroupBy("pattern:(?i)(prod|dev)")
+	if err != nil {
+		t.Fatalf("parseGroupBy failed: %v", err)
+	}
+	profileGroupBy = grouper
+
+	roles := map[string][]string{"111": {"AWSReadOnlyAccess"}, "222": {"AWSReadOnlyAccess"}, "333": {"AWSReadOnlyAccess"}}
+	runs := [][]ssoTypesAccount{
+		{{AccountId: "111", AccountName: "Payments-Prod"}, {AccountId: "222", AccountName: "Payments-Dev"}},
+		{{AccountId: "111", AccountName: "Payments-Prod"}, {AccountId: "222", AccountName: "Payments-Dev"}, {AccountId: "333", AccountName: "Search-Prod"}},
+	}
+	for i, accounts := range runs {
+		stubDiscovery(t, accounts, roles)
+		if out := captureStdout(t, func() { _, err = configureSsoProfiles("token") }); err != nil {
+			t.Fatalf("run %d failed: %v\n%s", i+1, err, out)
+		}
+	}
+
+	data, err := os.ReadFile(ssoConfigFile)
+	if err != nil {
+		t.Fatalf("failed to read config: %v", err)
+	}
+	content := string(data)
+	for _, header := range []string{"# ===== prod =====", "# ===== dev =====", "[profile Search-Prod_333]"} {
+		if n := strings.Count(content, header); n != 1 {
+			t.Fatalf("expected %q once, found %d times:\n%s", header, n, content)
+		}
+	}
+	if !strings.Contains(content, "# ===== prod =====\n[profile Payments-Prod_111]") {
+		t.Fatalf("prod header does not precede the first prod profile:\n%s", content)
+	}
+	if !strings.Contains(content, "# ===== dev =====\n[profile Payments-Dev_222]") {
+		t.Fatalf("dev header does not precede the first dev profile:\n%s", content)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+)
+
+var (
+	// healthCheck makes the run probe sso:GetRoleCredentials for the
+	// selected roles and report which work, writing nothing (-health-check).
+	healthCheck bool
+	// healthCheckSample limits the probe to this many randomly chosen roles;
+	// 0 probes every selected role (-health-check-sample).
+	healthCheckSample int
+)
+
+// healthProbe is the outcome of probing one role.
+type healthProbe struct {
+	Role CombinedRole
+	Err  error
+}
+
+// sampleRoles returns n randomly chosen roles, in their original order, or
+// all of them when n is 0 or not smaller than len(roles).
+func sampleRoles(roles []CombinedRole, n int) []CombinedRole {
+	if n <= 0 || n >= len(roles) {
+		return roles
+	}
+	picked := make(map[int]bool)
+	for _, i := range rand.Perm(len(roles))[:n] {
+		picked[i] = true
+	}
+	var out []CombinedRole
+	for i, r := range roles {
+		if picked[i] {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// probeRoles fetches credentials for each role with up to
+// accountConcurrency probes in flight. Throttled calls are retried like
+// discovery calls. The results keep the order of roles.
+func probeRoles(accessToken string, roles []CombinedRole) []healthProbe {
+	workers := accountConcurrency
+	if workers < 1 {
+		workers = 1
+	}
+	results := make([]healthProbe, len(roles))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers && w < len(roles); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				role := roles[i]
+				err := withThrottleRetry("sso:GetRoleCredentials "+role.AccountId+"/"+role.RoleName, func() error {
+					_, err := getRoleCredentialsFunc(accessToken, role.AccountId, role.RoleName)
+					return err
+				})
+				results[i] = healthProbe{Role: role, Err: err}
+			}
+		}()
+	}
+	for i := range roles {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+// runHealthCheck implements -health-check: it probes the sampled roles,
+// prints each failure and a pass/fail summary, and returns an error when any
+// probe failed.
+func runHealthCheck(accessToken string, roles []CombinedRole) error {
+	sample := sampleRoles(roles, healthCheckSample)
+	infof("%sProbing credentials for %d of %d role(s)...\n", cyan(icon("search")), len(sample), len(roles))
+	failed := 0
+	for _, p := range probeRoles(accessToken, sample) {
+		if p.Err != nil {
+			failed++
+			errorf("%s%s/%s (%s): %v\n", red(icon("error")), p.Role.AccountName, p.Role.RoleName, p.Role.AccountId, p.Err)
+			continue
+		}
+		debugf("%s/%s (%s): ok\n", p.Role.AccountName, p.Role.RoleName, p.Role.AccountId)
+	}
+	resultf("\n%s%s %d passed, %d failed (%d probed).\n", cyan(icon("summary")), bold("Health check:"), len(sample)-failed, failed, len(sample))
+	if failed > 0 {
+		return fmt.Errorf("%d of %d role credential probe(s) failed", failed, len(sample))
+	}
+	return nil
+}
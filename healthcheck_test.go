@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestHealthCheck stubs credential probes that fail for one role and asserts
+// the pass/fail counts, that the run reports an error, and that a sample
+// limits the number of probes.
+func TestHealthCheck(t *testing.T) {
+	stubDiscovery(t,
+		[]ssoTypesAccount{{AccountId: "111", AccountName: "Prod"}, {AccountId: "222", AccountName: "Dev"}},
+		map[string][]string{"111": {"AWSReadOnlyAccess", "AWSAdministratorAccess"}, "222": {"AWSReadOnlyAccess"}})
+	origFetch := getRoleCredentialsFunc
+	oldCheck, oldSample, oldRoles, oldConfig, oldDry, oldConcurrency := healthCheck, healthCheckSample, ssoRoleNames, ssoConfigFile, dryRun, accountConcurrency
+	defer func() {
+		getRoleCredentialsFunc = origFetch
+		healthCheck, healthCheckSample, ssoRoleNames, ssoConfigFile, dryRun, accountConcurrency = oldCheck, oldSample, oldRoles, oldConfig, oldDry, oldConcurrency
+	}()
+	getRoleCredentialsFunc = func(accessToken, accountId, roleName string) (roleCredentials, error) {
+		if accountId == "111" && roleName == "AWSAdministratorAccess" {
+			return roleCredentials{}, errors.New("ForbiddenException: No access")
+		}
+		return roleCredentials{AccessKeyId: "AKIA"}, nil
+	}
+	healthCheck, dryRun, accountConcurrency = true, true, 2
+	ssoRoleNames = []string{"AWSReadOnlyAccess", "AWSAdministratorAccess"}
+	ssoConfigFile = filepath.Join(t.TempDir(), "config")
+
+	var err error
+	out := captureStdout(t, func() { _, err = configureSsoProfiles("token") })
+	if err == nil || !strings.Contains(err.Error(), "1 of 3") {
+		t.Fatalf("expected 1 of 3 probes to fail, got %v", err)
+	}
+	if !strings.Contains(out, "Health check: 2 passed, 1 failed (3 probed).") {
+		t.Fatalf("unexpected summary:\n%s", out)
+	}
+	if profileExists("ReadOnly_Prod_111", ssoConfigFile) {
+		t.Fatal("-health-check configured a profile")
+	}
+
+	healthCheckSample = 1
+	probes := 0
+	getRoleCredentialsFunc = func(accessToken, accountId, roleName string) (roleCredentials, error) {
+		probes++
+		return roleCredentials{AccessKeyId: "AKIA"}, nil
+	}
+	out = captureStdout(t, func() { _, err = configureSsoProfiles("token") })
+	if err != nil || probes != 1 || !strings.Contains(out, "1 passed, 0 failed (1 probed)") {
+		t.Fatalf("expected a single passing probe, got err=%v probes=%d output:\n%s", err, probes, out)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+)
+
+// Supported values for -list-format
+const (
+	listFormatInline = "inline"
+	listFormatLines  = "lines"
+	listFormatTable  = "table"
+)
+
+// validateListFormat checks that -list-format is one of the supported values.
+func validateListFormat(format string) error {
+	switch format {
+	case listFormatInline, listFormatLines, listFormatTable:
+		return nil
+	}
+	return fmt.Errorf("invalid -list-format %q: expected %s, %s or %s", format, listFormatInline, listFormatLines, listFormatTable)
+}
+
+// accountRoleListing is one account in the role listing, with its role names
+// already decorated for display.
+type accountRoleListing struct {
+	AccountId   string
+	AccountName string
+	Roles       []string
+}
+
+// printAccountRoleListings prints listings in the given -list-format layout:
+// inline puts each account's roles on one line, lines puts one role per line
+// under its account, and table aligns account, account id and role columns.
+func printAccountRoleListings(listings []accountRoleListing, format string) {
+	switch format {
+	case listFormatLines:
+		for _, l := range listings {
+			if len(l.Roles) == 0 {
+				infof("    %s%s: (no roles)\n", cyan(icon("auth")), l.AccountName)
+				continue
+			}
+			infof("    %s%s:\n", cyan(icon("auth")), l.AccountName)
+			for _, r := range l.Roles {
+				infof("        %s\n", r)
+			}
+		}
+	case listFormatTable:
+		// The role column comes last so colour codes in it can't throw off
+		// the alignment of the other columns.
+		var buf bytes.Buffer
+		w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ACCOUNT\tACCOUNT ID\tROLE")
+		for _, l := range listings {
+			if len(l.Roles) == 0 {
+				fmt.Fprintf(w, "%s\t%s\t%s\n", l.AccountName, l.AccountId, "(no roles)")
+			}
+			for _, r := range l.Roles {
+				fmt.Fprintf(w, "%s\t%s\t%s\n", l.AccountName, l.AccountId, r)
+			}
+		}
+		w.Flush()
+		for _, line := range strings.SplitAfter(buf.String(), "\n") {
+			if line != "" {
+				infof("    %s", line)
+			}
+		}
+	default:
+		for _, l := range listings {
+			if len(l.Roles) == 0 {
+				infof("    %s%s: (no roles)\n", cyan(icon("auth")), l.AccountName)
+				continue
+			}
+			infof("    %s%s: %s\n", cyan(icon("auth")), l.AccountName, strings.Join(l.Roles, ", "))
+		}
+	}
+}
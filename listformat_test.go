@@ -0,0 +1,39 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestListFormatLines asserts -list-format=lines prints one line per role
+// under its account header, and that invalid formats are rejected.
+func TestListFormatLines(t *testing.T) {
+	stubDiscovery(t,
+		[]ssoTypesAccount{{AccountId: "111", AccountName: "Prod"}, {AccountId: "222", AccountName: "Dev"}},
+		map[string][]string{"111": {"Billing", "AWSReadOnlyAccess", "AWSAdministratorAccess"}, "222": {"AWSReadOnlyAccess"}})
+	oldFormat, oldRoles, oldExcluded, oldRegexes := listFormat, ssoRoleNames, excludedRoles, roleRegexes
+	defer func() {
+		listFormat, ssoRoleNames, excludedRoles, roleRegexes = oldFormat, oldRoles, oldExcluded, oldRegexes
+	}()
+	ssoRoleNames, excludedRoles, roleRegexes = nil, nil, nil
+	listFormat = listFormatLines
+
+	out := captureStdout(t, func() { listAllRolesPerAccount("token") })
+	var roleLines []string
+	for _, line := range strings.Split(strings.TrimRight(out, "\n"), "\n") {
+		if strings.HasPrefix(line, "        ") {
+			roleLines = append(roleLines, strings.TrimSpace(line))
+		}
+	}
+	want := "AWSAdministratorAccess,AWSReadOnlyAccess,Billing,AWSReadOnlyAccess"
+	if strings.Join(roleLines, ",") != want {
+		t.Fatalf("expected one line per role %q, got %q in:\n%s", want, roleLines, out)
+	}
+	if !strings.Contains(out, "Prod:\n") || !strings.Contains(out, "Dev:\n") {
+		t.Fatalf("expected an account header per account, got:\n%s", out)
+	}
+
+	if err := validateListFormat("grid"); err == nil {
+		t.Fatal("expected -list-format=grid to be rejected")
+	}
+}
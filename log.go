@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// logLevel orders the status output. Lines below the current level are
+// dropped.
+type logLevel int
+
+const (
+	levelDebug logLevel = iota
+	levelInfo
+	levelWarn
+	levelError
+)
+
+// currentLogLevel is set by -verbose (debug) and -quiet (error). Results
+// printed with resultf are shown at every level.
+var currentLogLevel = levelInfo
+
+// logf prints a status line at level. It writes to os.Stdout as it is at call
+// time, so -json-summary - and tests that capture stdout see it.
+func logf(level logLevel, format string, args ...interface{}) {
+	if level < currentLogLevel {
+		return
+	}
+	fmt.Fprintf(os.Stdout, format, args...)
+}
+
+// debugf prints detail shown only with -verbose, such as each AWS API call.
+func debugf(format string, args ...interface{}) {
+	logf(levelDebug, "%s", dim(icon("debug")+fmt.Sprintf(format, args...)))
+}
+
+// infof prints progress, hidden by -quiet.
+func infof(format string, args ...interface{}) { logf(levelInfo, format, args...) }
+
+// warnf prints a warning, hidden by -quiet.
+func warnf(format string, args ...interface{}) { logf(levelWarn, format, args...) }
+
+// errorf prints an error; it is always shown.
+func errorf(format string, args ...interface{}) { logf(levelError, format, args...) }
+
+// promptf prints an interactive prompt, such as the device authorization
+// URL and code, to stderr. Prompts are shown at every level and never mix
+// with machine-readable stdout.
+func promptf(format string, args ...interface{}) { fmt.Fprintf(os.Stderr, format, args...) }
+
+// resultf prints the outcome of a run (the final summary, a plan, a listing
+// that was asked for); it is always shown.
+func resultf(format string, args ...interface{}) { fmt.Fprintf(os.Stdout, format, args...) }
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestLogLevels asserts -quiet keeps only errors and the final summary,
+// -verbose adds debug detail such as the chosen token file, and the two
+// flags cannot be combined.
+func TestLogLevels(t *testing.T) {
+	oldLevel, oldTheme := currentLogLevel, outputTheme
+	defer func() { currentLogLevel, outputTheme = oldLevel, oldTheme }()
+	outputTheme = themeASCII
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	registerOutputFlags(fs)
+	if err := fs.Parse([]string{"-verbose", "-quiet"}); err == nil {
+		t.Fatalf("expected -verbose with -quiet to be rejected")
+	}
+
+	stubDiscovery(t,
+		[]ssoTypesAccount{{AccountId: "111", AccountName: "Prod"}},
+		map[string][]string{"111": {"AWSReadOnlyAccess"}})
+	oldConfig, oldDry, oldRoles := ssoConfigFile, dryRun, ssoRoleNames
+	defer func() { ssoConfigFile, dryRun, ssoRoleNames = oldConfig, oldDry, oldRoles }()
+	ssoConfigFile = filepath.Join(t.TempDir(), "config")
+	dryRun = false
+	ssoRoleNames = []string{"AWSReadOnlyAccess"}
+
+	currentLogLevel = levelError
+	out := captureStdout(t, func() {
+		configureSsoProfiles("token")
+		errorf("%sboom\n", red(icon("error")))
+	})
+	if strings.Contains(out, "Adding profile") || strings.Contains(out, "Found") {
+		t.Errorf("-quiet printed progress:\n%s", out)
+	}
+	if !strings.Contains(out, "[SUMMARY] Summary: 1 new profile(s)") || !strings.Contains(out, "[ERR] boom") {
+		t.Errorf("-quiet dropped the summary or an error:\n%s", out)
+	}
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	cacheDir := filepath.Join(home, ".aws", "sso", "cache")
+	if err := os.MkdirAll(cacheDir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	tokenPath := filepath.Join(cacheDir, "token.json")
+	if err := os.WriteFile(tokenPath, []byte(`{"startUrl": "https://example.awsapps.com/start", "accessToken": "tok"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	oldURL := ssoStartURL
+	defer func() { ssoStartURL = oldURL }()
+	ssoStartURL = "https://example.awsapps.com/start"
+	for _, level := range []logLevel{levelInfo, levelDebug} {
+		currentLogLevel = level
+		out = captureStdout(t, func() { getAccessTokenFromSsoSessionWithPath() })
+		if got := strings.Contains(out, "[DEBUG] Using token file "+tokenPath+" (modified "); got != (level == levelDebug) {
+			t.Errorf("level %d: debug token line shown=%v, output %q", level, got, out)
+		}
+	}
+}
@@ -2,23 +2,37 @@ package main
 
 import (
 	"context"
+	"crypto/sha1"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/url"
 	"os"
-	"os/exec"
+	"os/signal"
 	"path/filepath"
 	"regexp"
 	"runtime"
+	"slices"
 	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"text/template"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/sso"
 	"github.com/aws/aws-sdk-go-v2/service/ssooidc"
 	"github.com/fatih/color"
+	"github.com/jmespath/go-jmespath"
 	"gopkg.in/ini.v1"
 )
 
@@ -28,6 +42,19 @@ const (
 	defaultSSORegion            = "us-east-1"
 )
 
+// Build metadata, set at release time with
+//
+//	go build -ldflags "-X main.version=v1.2.3 -X main.commit=$(git rev-parse HEAD) -X main.buildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// version is also recorded in annotated sso-session blocks. Values left
+// unset fall back to the module build info where it has them (see
+// currentBuildInfo).
+var (
+	version   = "dev"
+	commit    = ""
+	buildDate = ""
+)
+
 // Configuration variables populated by flags
 var (
 	ssoRoleNames         []string
@@ -39,9 +66,121 @@ var (
 	ssoConfigFile        string
 	dryRun               bool
 	openBrowser          bool
+	allowInsecureURL     bool
 	profileOutput        string
+	profileKeyNames      map[string]string
+	allowExternalConfig  bool
+	autoRelogin          bool
+	nameStyle            = nameStyleRoleAccount
+	listFormat           = listFormatInline
+	regionSource         = regionSourceProfile
+	roleFilter           *jmespath.JMESPath
+	summaryTemplate      *template.Template
+	preferSession        string
+	progressWriter       io.Writer
+	compactNames         bool
+	userAbbreviations    map[string]string
+	accountTagFilters    map[string]string
+	reportEmptyAccounts  bool
+	annotateSession      = true
+	minTokenLifetime     = 2 * time.Minute
+	planMode             bool
+	dedupeNames          bool
+	estimateMode         bool
+	legacyKeys           []string
+	pruneMode            bool
+	pruneScopePrefix     string
+	resumeMode           bool
+	printRoleArns        bool
+	jsonSummaryPath      string
+	jsonSummaryOut       io.Writer
+	accountRegions       map[string]string
+	exportPath           string
+	cliCompatibleCache   bool
+	bootstrapTemplate    []byte
+	profileGroupBy       *profileGrouper
+	regionTagKey         string
+	accountConcurrency   = 1
+	strictTokenMatch     bool
+	excludedRoles        map[string]bool
+	managedPattern       *regexp.Regexp
+	roleRegexes          []*regexp.Regexp
+	accountIdFilter      map[string]bool
+	accountNameRegex     *regexp.Regexp
+	// userCodeFile receives the device-flow user code (-code-file).
+	userCodeFile string
+	// endpointURL overrides the SSO OIDC and portal endpoints (-endpoint-url).
+	endpointURL string
+	// runContext bounds every AWS call; main cancels it on Ctrl-C and after
+	// -timeout.
+	runContext = context.Background()
 )
 
+// requiredProfileKeys lists the logical keys written into every generated
+// profile, in the order they are written. The -key-name flag can remap the
+// name each one is written under.
+var requiredProfileKeys = []string{"sso_session", "sso_account_id", "sso_role_name", "region", "output"}
+
+// defaultLegacyKeys are the SSO keys written by -legacy profiles, which carry
+// the session settings inline instead of referencing an sso-session block.
+var defaultLegacyKeys = []string{"sso_start_url", "sso_region", "sso_account_id", "sso_role_name"}
+
+// profileWriteKeys lists every logical key a generated profile may carry, in
+// the order they are written. Keys absent from profileValues are removed.
+var profileWriteKeys = []string{"sso_session", "sso_start_url", "sso_region", "sso_account_id", "sso_role_name", "credential_process", "region", "output", "duration_seconds"}
+
+// parseLegacyKeys parses the comma-separated -legacy-keys list. Only the
+// legacy SSO keys are accepted, and the account and role keys are required
+// because the profile is unusable without them.
+func parseLegacyKeys(value string) ([]string, error) {
+	known := make(map[string]bool)
+	for _, k := range defaultLegacyKeys {
+		known[k] = true
+	}
+	var keys []string
+	seen := make(map[string]bool)
+	for _, k := range strings.Split(value, ",") {
+		k = strings.TrimSpace(k)
+		if k == "" || seen[k] {
+			continue
+		}
+		if !known[k] {
+			return nil, fmt.Errorf("invalid -legacy-keys: unknown key %q (expected some of %s)", k, strings.Join(defaultLegacyKeys, ", "))
+		}
+		seen[k] = true
+		keys = append(keys, k)
+	}
+	for _, required := range []string{"sso_account_id", "sso_role_name"} {
+		if !seen[required] {
+			return nil, fmt.Errorf("invalid -legacy-keys: %s must be included", required)
+		}
+	}
+	return keys, nil
+}
+
+// defaultRoleNames is the role set selected by -defaults: the roles created
+// by the AWS-managed default permission sets.
+var defaultRoleNames = []string{"AWSReadOnlyAccess", "AWSAdministratorAccess", "AWSPowerUserAccess"}
+
+// expandRoleNames merges the explicit -role values with defaultRoleNames when
+// -defaults is set, keeping the first occurrence of each name.
+func expandRoleNames(explicit []string, useDefaults bool) []string {
+	all := append([]string{}, explicit...)
+	if useDefaults {
+		all = append(all, defaultRoleNames...)
+	}
+	seen := make(map[string]bool)
+	var out []string
+	for _, name := range all {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		out = append(out, name)
+	}
+	return out
+}
+
 // Custom flag type for multiple strings
 type stringSliceFlag []string
 
@@ -60,16 +199,22 @@ var (
 	cyan   = color.New(color.FgCyan).SprintFunc()
 	red    = color.New(color.FgRed).SprintFunc()
 	bold   = color.New(color.Bold).SprintFunc()
+	dim    = color.New(color.Faint).SprintFunc()
 )
 
 // Injectable hooks for easier testing
 var (
+	// sleepFunc pauses between CreateToken polls and before retrying
+	// throttled calls, returning early with the context's error when it is
+	// cancelled. Tests override it so retries don't wait in real time.
+	sleepFunc = sleepContext
+
 	// runAwsSsoLogin performs the interactive SSO OIDC device authorization
 	// flow using the AWS SDK (no shell-out). Tests can override this to avoid
 	// actually contacting AWS.
 	runAwsSsoLogin = func(session string) error {
 		// Use sso-oidc for device authorization
-		cfg, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion(ssoRegion))
+		cfg, err := loadSsoClientConfig()
 		if err != nil {
 			return err
 		}
@@ -80,7 +225,8 @@ var (
 			ClientName: aws.String("aws-sso-profile-sync"),
 			ClientType: aws.String("public"),
 		}
-		regOut, err := client.RegisterClient(context.TODO(), regIn)
+		debugf("sso-oidc:RegisterClient\n")
+		regOut, err := client.RegisterClient(runContext, regIn)
 		if err != nil {
 			return err
 		}
@@ -91,7 +237,8 @@ var (
 			ClientSecret: regOut.ClientSecret,
 			StartUrl:     aws.String(strings.TrimRight(ssoStartURL, "/")),
 		}
-		devOut, err := client.StartDeviceAuthorization(context.TODO(), devIn)
+		debugf("sso-oidc:StartDeviceAuthorization %s\n", aws.ToString(devIn.StartUrl))
+		devOut, err := client.StartDeviceAuthorization(runContext, devIn)
 		if err != nil {
 			return err
 		}
@@ -99,24 +246,25 @@ var (
 		// Show the verification URL and optionally open it in the default
 		// browser when the user passed --open. If --open is set we do not
 		// require the user to press Enter; polling starts immediately.
+		// These prompts go to stderr at every log level so they never mix
+		// with -json-summary output and still show with -quiet.
 		verificationURL := aws.ToString(devOut.VerificationUriComplete)
 		userCode := aws.ToString(devOut.UserCode)
+		if userCodeFile != "" {
+			if err := os.WriteFile(userCodeFile, []byte(userCode+"\n"), 0o600); err != nil {
+				return fmt.Errorf("writing -code-file: %w", err)
+			}
+		}
 		if openBrowser {
 			// Attempt to open the URL in the default browser; fall back to
 			// printing the URL if this fails.
-			if err := openBrowserURL(verificationURL); err != nil {
-				fmt.Printf("%s Failed to open browser automatically, please open this URL manually:\n%s\n", yellow("⚠️"), verificationURL)
-				fmt.Printf("And enter this code if prompted: %s\n", userCode)
-			} else {
-				fmt.Printf("%s Opened default browser to: %s\n", cyan("🔗"), verificationURL)
-				fmt.Printf("If prompted, enter this code: %s\n", userCode)
-			}
+			openVerificationURL(verificationURL, userCode)
 		} else {
 			// Do not open the browser for the user; show the URL and proceed
 			// immediately to polling. This avoids blocking on an Enter press
 			// and works well in non-interactive or scripted environments.
-			fmt.Printf("To authenticate, open this URL in your browser:\n%s\nAnd enter this code if prompted: %s\n", verificationURL, userCode)
-			fmt.Printf("Starting background polling for authorization; open the URL to complete authorization.\n")
+			promptf("To authenticate, open this URL in your browser:\n%s\nAnd enter this code if prompted: %s\n", verificationURL, userCode)
+			promptf("Starting background polling for authorization; open the URL to complete authorization.\n")
 		}
 
 		// Poll for token
@@ -125,25 +273,17 @@ var (
 			interval = int64(devOut.Interval)
 		}
 		deadline := time.Now().Add(time.Duration(devOut.ExpiresIn) * time.Second)
-		var tokenOut *ssooidc.CreateTokenOutput
-		for time.Now().Before(deadline) {
-			tokIn := &ssooidc.CreateTokenInput{
-				ClientId:     regOut.ClientId,
-				ClientSecret: regOut.ClientSecret,
-				GrantType:    aws.String("urn:ietf:params:oauth:grant-type:device_code"),
-				DeviceCode:   devOut.DeviceCode,
-			}
-			tokenOut, err = client.CreateToken(context.TODO(), tokIn)
-			if err == nil {
-				break
-			}
-			// Check for authorization pending or slow down; if so, wait and retry
-			// Fallback: examine error string for common tokens
-			es := err.Error()
-			if strings.Contains(es, "authorization_pending") || strings.Contains(es, "AuthorizationPending") || strings.Contains(es, "slow_down") || strings.Contains(es, "SlowDown") {
-				time.Sleep(time.Duration(interval) * time.Second)
-				continue
-			}
+		tokIn := &ssooidc.CreateTokenInput{
+			ClientId:     regOut.ClientId,
+			ClientSecret: regOut.ClientSecret,
+			GrantType:    aws.String("urn:ietf:params:oauth:grant-type:device_code"),
+			DeviceCode:   devOut.DeviceCode,
+		}
+		tokenOut, err := pollCreateToken(runContext, func() (*ssooidc.CreateTokenOutput, error) {
+			debugf("sso-oidc:CreateToken\n")
+			return client.CreateToken(runContext, tokIn)
+		}, time.Duration(interval)*time.Second, deadline)
+		if err != nil {
 			return err
 		}
 		if tokenOut == nil || tokenOut.AccessToken == nil {
@@ -157,22 +297,9 @@ var (
 			return err
 		}
 
-		// Create a simple temporary filename using timestamp to avoid
-		// overengineering token compatibility with the AWS CLI. This keeps
-		// behavior local to this tool and avoids creating multiple session
-		// name symlinks or deterministic hashed filenames.
-		ts := time.Now().UnixNano()
-		filename := fmt.Sprintf("sso_token_%d.json", ts)
+		filename, m := buildTokenCache(tokenOut, regOut, time.Now())
 		outPath := filepath.Join(cacheDir, filename)
 
-		expiresAt := time.Now().Add(time.Duration(tokenOut.ExpiresIn) * time.Second).UTC().Format(time.RFC3339)
-		m := map[string]interface{}{
-			"startUrl":    strings.TrimRight(ssoStartURL, "/"),
-			"region":      ssoRegion,
-			"accessToken": aws.ToString(tokenOut.AccessToken),
-			"expiresAt":   expiresAt,
-		}
-
 		b, err := json.MarshalIndent(m, "", "  ")
 		if err != nil {
 			return err
@@ -200,16 +327,244 @@ var (
 	}
 
 	// isSsoTokenValidFunc allows tests to stub token validation without
-	// calling AWS. By default it calls the real discovery function.
+	// calling AWS. By default it calls the real discovery function and
+	// records why a token was rejected.
 	isSsoTokenValidFunc = func(accessToken string) bool {
-		_, err := getListOfSsoAccounts(accessToken)
-		return err == nil
+		lastTokenCheckErr = checkSsoToken(accessToken)
+		return lastTokenCheckErr == nil
 	}
 
 	// Allow configureSsoProfiles to be stubbed in tests to avoid AWS calls.
-	configureSsoProfilesFunc = func(accessToken string) error { return configureSsoProfiles(accessToken) }
+	// The default also records the result for the exit code.
+	configureSsoProfilesFunc = func(accessToken string) error {
+		result, err := configureSsoProfiles(accessToken)
+		if err == nil {
+			syncResults = append(syncResults, result)
+		}
+		return err
+	}
+
+	// Account and role discovery indirections so tests can supply synthetic
+	// accounts and roles without calling the SSO portal API.
+	getListOfSsoAccountsFunc               = getListOfSsoAccounts
+	getListOfSsoAccountRolesForAccountFunc = getListOfSsoAccountRolesForAccount
+
+	// writeProfileToConfigFunc writes one profile; tests override it to
+	// simulate a sync interrupted partway through.
+	writeProfileToConfigFunc = writeProfileToConfig
+)
+
+// buildTokenCache returns the cache file name and contents for a token from
+// the device flow. By default the file gets a timestamped name private to
+// this tool. With -cli-compatible-cache it gets the AWS CLI's deterministic
+// name and the client registration fields, so `aws sso login` and this tool
+// share one token.
+func buildTokenCache(token *ssooidc.CreateTokenOutput, reg *ssooidc.RegisterClientOutput, now time.Time) (string, map[string]interface{}) {
+	m := map[string]interface{}{
+		"startUrl":    strings.TrimRight(ssoStartURL, "/"),
+		"region":      ssoRegion,
+		"accessToken": aws.ToString(token.AccessToken),
+		"expiresAt":   now.Add(time.Duration(token.ExpiresIn) * time.Second).UTC().Format(time.RFC3339),
+	}
+	if !cliCompatibleCache {
+		return fmt.Sprintf("sso_token_%d.json", now.UnixNano()), m
+	}
+	if reg != nil {
+		m["clientId"] = aws.ToString(reg.ClientId)
+		m["clientSecret"] = aws.ToString(reg.ClientSecret)
+		m["registrationExpiresAt"] = time.Unix(reg.ClientSecretExpiresAt, 0).UTC().Format(time.RFC3339)
+	}
+	if token.RefreshToken != nil {
+		m["refreshToken"] = aws.ToString(token.RefreshToken)
+	}
+	// The CLI keys the cache by sso-session name, or by start URL for legacy
+	// profiles that configure the session inline.
+	key := ssoSessionConfigName
+	if legacyKeys != nil {
+		key = strings.TrimRight(ssoStartURL, "/")
+	}
+	return cliTokenCacheFilename(key), m
+}
+
+// cliTokenCacheFilename returns the AWS CLI's token cache file name for key:
+// the hex SHA1 digest of key plus ".json".
+func cliTokenCacheFilename(key string) string {
+	sum := sha1.Sum([]byte(key))
+	return hex.EncodeToString(sum[:]) + ".json"
+}
+
+// loadSsoClientConfig builds the SDK config for the SSO OIDC and portal
+// clients: the config of loadRegionOnlyConfig, with -endpoint-url pointing
+// both clients at another server, such as a mock.
+func loadSsoClientConfig() (aws.Config, error) {
+	cfg, err := loadRegionOnlyConfig()
+	if err != nil {
+		return aws.Config{}, err
+	}
+	if endpointURL != "" {
+		cfg.BaseEndpoint = aws.String(endpointURL)
+	}
+	return cfg, nil
+}
+
+// loadRegionOnlyConfig builds an SDK config for ssoRegion without the shared
+// config and credentials files. The SSO APIs are authorized by the device
+// flow and bearer token, and role credentials are set by the caller, not
+// taken from ambient credentials, so those files are skipped entirely. That
+// keeps login working when ~/.aws/config is malformed, which is often exactly
+// what the user is running this tool to fix. If the environment still names
+// a profile (AWS_PROFILE) that can't be resolved without those files, a bare
+// config with just the region is used.
+func loadRegionOnlyConfig() (aws.Config, error) {
+	cfg, err := config.LoadDefaultConfig(runContext,
+		config.WithRegion(ssoRegion),
+		config.WithSharedConfigFiles([]string{}),
+		config.WithSharedCredentialsFiles([]string{}),
+	)
+	if err != nil {
+		var missing config.SharedConfigProfileNotExistError
+		if !errors.As(err, &missing) {
+			return aws.Config{}, err
+		}
+		cfg = aws.Config{Region: ssoRegion}
+	}
+	return cfg, nil
+}
+
+// validateEndpointURL checks an -endpoint-url value is an absolute http or
+// https URL.
+func validateEndpointURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid -endpoint-url %q: expected an http or https URL", raw)
+	}
+	return nil
+}
+
+// startURLHostPattern and portalHostPattern match the hosts of the two AWS
+// start URL forms: https://<name>.awsapps.com/start (awsapps.cn in the
+// China regions) and https://<id>.portal.<region>.app.aws.
+var (
+	startURLHostPattern = regexp.MustCompile(`^([a-z0-9-]+\.)+awsapps\.(com|cn)$`)
+	portalHostPattern   = regexp.MustCompile(`^[a-z0-9-]+\.portal\.[a-z]{2}(-[a-z]+)+-\d+\.app\.aws$`)
 )
 
+// validateStartURL checks that raw looks like an AWS access portal URL, so
+// a typo fails here instead of deep in the device flow. The awsapps form
+// must have the /start path (or /directory/... in GovCloud); anything after
+// "#" is ignored. With -allow-insecure-url any http or https URL passes, for
+// testing against mock endpoints.
+func validateStartURL(raw string) error {
+	const expected = "expected https://<name>.awsapps.com/start or https://<id>.portal.<region>.app.aws"
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("invalid -sso-start-url %q: not an absolute URL; %s", raw, expected)
+	}
+	if allowInsecureURL {
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return fmt.Errorf("invalid -sso-start-url %q: expected an http or https URL", raw)
+		}
+		return nil
+	}
+	if u.Scheme != "https" {
+		return fmt.Errorf("invalid -sso-start-url %q: must use https (pass -allow-insecure-url for a mock endpoint)", raw)
+	}
+	host := strings.ToLower(u.Hostname())
+	switch {
+	case u.Port() != "":
+		// AWS portals never use an explicit port; fall through to the error.
+	case startURLHostPattern.MatchString(host):
+		if u.Path == "/start" || u.Path == "/start/" || strings.HasPrefix(u.Path, "/directory/") {
+			return nil
+		}
+		return fmt.Errorf("invalid -sso-start-url %q: the path should be /start; %s", raw, expected)
+	case portalHostPattern.MatchString(host):
+		if u.Path == "" || u.Path == "/" {
+			return nil
+		}
+		return fmt.Errorf("invalid -sso-start-url %q: portal URLs have no path; %s", raw, expected)
+	}
+	return fmt.Errorf("invalid -sso-start-url %q: %s is not an AWS access portal host; %s", raw, u.Host, expected)
+}
+
+// maxTransientCreateTokenRetries bounds how many consecutive transient
+// CreateToken failures are retried before the device flow gives up.
+const maxTransientCreateTokenRetries = 3
+
+// pollCreateToken polls create until it returns a token or the device code
+// expires. authorization_pending and slow_down wait one interval and poll
+// again. Transient failures (timeouts, 5xx) are retried with a short backoff
+// up to maxTransientCreateTokenRetries times in a row, so a network blip
+// doesn't throw away an authorization the user already completed. Any other
+// error aborts, as does cancelling ctx (Ctrl-C or -timeout).
+func pollCreateToken(ctx context.Context, create func() (*ssooidc.CreateTokenOutput, error), interval time.Duration, deadline time.Time) (*ssooidc.CreateTokenOutput, error) {
+	transientFailures := 0
+	for time.Now().Before(deadline) {
+		tokenOut, err := create()
+		if err == nil {
+			return tokenOut, nil
+		}
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("waiting for authorization: %w", ctx.Err())
+		}
+		// Check for authorization pending or slow down; if so, wait and retry
+		// Fallback: examine error string for common tokens
+		es := err.Error()
+		if strings.Contains(es, "authorization_pending") || strings.Contains(es, "AuthorizationPending") || strings.Contains(es, "slow_down") || strings.Contains(es, "SlowDown") {
+			transientFailures = 0
+			if err := sleepFunc(ctx, interval); err != nil {
+				return nil, fmt.Errorf("waiting for authorization: %w", err)
+			}
+			continue
+		}
+		if isTransientError(err) && transientFailures < maxTransientCreateTokenRetries {
+			transientFailures++
+			promptf("%sTransient error while waiting for authorization, retrying (%d/%d): %v\n", yellow(icon("warn")), transientFailures, maxTransientCreateTokenRetries, err)
+			if err := sleepFunc(ctx, time.Duration(transientFailures)*time.Second); err != nil {
+				return nil, fmt.Errorf("waiting for authorization: %w", err)
+			}
+			continue
+		}
+		return nil, err
+	}
+	return nil, fmt.Errorf("failed to obtain access token via device authorization")
+}
+
+// sleepContext waits for d, or until ctx is done, in which case it returns
+// the context's error.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// isTransientError reports whether err looks like a momentary network or
+// service failure: a timeout, a dropped connection or an HTTP 5xx response.
+func isTransientError(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	var respErr *awshttp.ResponseError
+	if errors.As(err, &respErr) && respErr.HTTPStatusCode() >= 500 {
+		return true
+	}
+	es := strings.ToLower(err.Error())
+	return strings.Contains(es, "connection reset") || strings.Contains(es, "unexpected eof")
+}
+
+// errTokenExpired is returned by getAccessTokenFromSsoSessionWithPath when
+// cached tokens exist for the start URL but all of them have expired.
+var errTokenExpired = errors.New("SSO token expired")
+
 // Get the newest valid SSO access token and its file path
 func getAccessTokenFromSsoSessionWithPath() (string, string, error) {
 	homeDir, _ := os.UserHomeDir()
@@ -224,7 +579,8 @@ func getAccessTokenFromSsoSessionWithPath() (string, string, error) {
 		token    string
 		modTime  int64
 	}
-	var candidates []candidate
+	var candidates, expired []candidate
+	nearMatches := 0
 	for _, f := range files {
 		if strings.HasSuffix(f.Name(), ".json") {
 			fullPath := filepath.Join(ssoCacheDir, f.Name())
@@ -238,30 +594,65 @@ func getAccessTokenFromSsoSessionWithPath() (string, string, error) {
 			}
 			startUrl, ok := cache["startUrl"].(string)
 			accessToken, tokenOk := cache["accessToken"].(string)
-			if ok && (startUrl == ssoStartURL || startUrl == strings.TrimRight(ssoStartURL, "/")) && tokenOk {
+			debugf("Token cache candidate %s (startUrl: %s)\n", fullPath, startUrl)
+			matched := ok && (startUrl == ssoStartURL || startUrl == strings.TrimRight(ssoStartURL, "/"))
+			if strictTokenMatch {
+				// Normalize both sides, and require the region to match too
+				// so a token for another instance is never used.
+				region, _ := cache["region"].(string)
+				matched = ok && strings.TrimRight(startUrl, "/") == strings.TrimRight(ssoStartURL, "/")
+				if matched && region != ssoRegion {
+					nearMatches++
+					matched = false
+				}
+			}
+			if matched && tokenOk {
 				info, err := f.Info()
 				if err != nil {
 					continue
 				}
-				modTime := info.ModTime().Unix()
-				candidates = append(candidates, candidate{
+				c := candidate{
 					path:     fullPath,
 					startUrl: startUrl,
 					token:    accessToken,
-					modTime:  modTime,
-				})
+					modTime:  info.ModTime().Unix(),
+				}
+				// Skip tokens whose recorded expiry has passed rather than
+				// finding out with a failing ListAccounts call. Tokens without
+				// a parseable expiry are still considered.
+				expiresAtValue, _ := cache["expiresAt"].(string)
+				if expiresAt, ok := parseTokenExpiry(expiresAtValue); ok && !expiresAt.After(nowFunc()) {
+					debugf("Token cache candidate %s expired at %s\n", fullPath, expiresAt.Format(time.RFC3339))
+					expired = append(expired, c)
+					continue
+				}
+				candidates = append(candidates, c)
 			}
 		}
 	}
-	if len(candidates) == 0 {
-		return "", "", fmt.Errorf("no valid SSO accessToken found for startUrl %s", ssoStartURL)
+	newest := func(cs []candidate) candidate {
+		latest := cs[0]
+		for _, c := range cs {
+			if c.modTime > latest.modTime {
+				latest = c
+			}
+		}
+		return latest
+	}
+	if len(candidates) == 0 && len(expired) > 0 {
+		// Return the newest expired file too, so callers can still try its
+		// refresh token or report on it.
+		latest := newest(expired)
+		return "", latest.path, fmt.Errorf("%w: all %d cached token(s) for startUrl %s have expired", errTokenExpired, len(expired), ssoStartURL)
 	}
-	latest := candidates[0]
-	for _, c := range candidates {
-		if c.modTime > latest.modTime {
-			latest = c
+	if len(candidates) == 0 {
+		if nearMatches > 0 {
+			return "", "", fmt.Errorf("no valid SSO accessToken found for startUrl %s in region %s (-strict-token-match rejected %d token(s) for the same URL in another region)", ssoStartURL, ssoRegion, nearMatches)
 		}
+		return "", "", fmt.Errorf("no valid SSO accessToken found for startUrl %s", ssoStartURL)
 	}
+	latest := newest(candidates)
+	debugf("Using token file %s (modified %s)\n", latest.path, time.Unix(latest.modTime, 0).Format(time.RFC3339))
 	return latest.token, latest.path, nil
 }
 
@@ -278,11 +669,17 @@ type CombinedRole struct {
 	AccountId   string
 	RoleName    string
 	AccountName string
+	// Region overrides the region written into the profile when set (see
+	// -region-from-tag).
+	Region string
+	// ProfileName overrides the generated profile name when set (see
+	// -name-command).
+	ProfileName string
 }
 
 // Get all accounts for the SSO session
 func getListOfSsoAccounts(accessToken string) ([]ssoTypesAccount, error) {
-	cfg, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion(ssoRegion))
+	cfg, err := loadSsoClientConfig()
 	if err != nil {
 		return nil, err
 	}
@@ -293,8 +690,13 @@ func getListOfSsoAccounts(accessToken string) ([]ssoTypesAccount, error) {
 	}
 	var accounts []ssoTypesAccount
 	paginator := sso.NewListAccountsPaginator(client, input)
-	for paginator.HasMorePages() {
-		page, err := paginator.NextPage(context.TODO())
+	for pageNum := 1; paginator.HasMorePages(); pageNum++ {
+		debugf("sso:ListAccounts page %d\n", pageNum)
+		var page *sso.ListAccountsOutput
+		err := withThrottleRetry("sso:ListAccounts", func() (err error) {
+			page, err = paginator.NextPage(runContext)
+			return err
+		})
 		if err != nil {
 			return nil, err
 		}
@@ -310,7 +712,7 @@ func getListOfSsoAccounts(accessToken string) ([]ssoTypesAccount, error) {
 
 // Get all roles for a given account
 func getListOfSsoAccountRolesForAccount(accessToken, accountId string) ([]ssoTypesRole, error) {
-	cfg, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion(ssoRegion))
+	cfg, err := loadSsoClientConfig()
 	if err != nil {
 		return nil, err
 	}
@@ -322,8 +724,13 @@ func getListOfSsoAccountRolesForAccount(accessToken, accountId string) ([]ssoTyp
 	}
 	var roles []ssoTypesRole
 	paginator := sso.NewListAccountRolesPaginator(client, input)
-	for paginator.HasMorePages() {
-		page, err := paginator.NextPage(context.TODO())
+	for pageNum := 1; paginator.HasMorePages(); pageNum++ {
+		debugf("sso:ListAccountRoles %s page %d\n", accountId, pageNum)
+		var page *sso.ListAccountRolesOutput
+		err := withThrottleRetry("sso:ListAccountRoles "+accountId, func() (err error) {
+			page, err = paginator.NextPage(runContext)
+			return err
+		})
 		if err != nil {
 			return nil, err
 		}
@@ -338,56 +745,285 @@ func getListOfSsoAccountRolesForAccount(accessToken, accountId string) ([]ssoTyp
 
 // Get all accounts with any of the desired roles
 func getCombinedListOfSsoAccountsAndRoles(accessToken string, roleNames []string) ([]CombinedRole, error) {
-	accounts, err := getListOfSsoAccounts(accessToken)
+	accounts, err := getSelectedSsoAccounts(accessToken)
 	if err != nil {
 		return nil, err
 	}
+	return getRolesForAccounts(accessToken, accounts, roleNames)
+}
+
+// getSelectedSsoAccounts lists the SSO accounts and applies the account
+// filters, returning the accounts whose roles should be enumerated.
+func getSelectedSsoAccounts(accessToken string) ([]ssoTypesAccount, error) {
+	accounts, err := getListOfSsoAccountsFunc(accessToken)
+	if err != nil {
+		return nil, err
+	}
+	considered := filterAccountsByIdAndName(accounts)
+	if dryRun && len(considered) != len(accounts) {
+		infof("%sAccount filters: %d account(s) considered, %d filtered out\n", cyan(icon("info")), len(considered), len(accounts)-len(considered))
+	}
+	// Narrow the accounts by Organizations tags before enumerating roles.
+	return filterAccountsByTags(considered, accountTagFilters)
+}
+
+// filterAccountsByIdAndName keeps the accounts allowed by -account-id and
+// -account-name-regex. When both are set an account must satisfy both.
+func filterAccountsByIdAndName(accounts []ssoTypesAccount) []ssoTypesAccount {
+	if len(accountIdFilter) == 0 && accountNameRegex == nil {
+		return accounts
+	}
+	var kept []ssoTypesAccount
+	for _, account := range accounts {
+		if len(accountIdFilter) > 0 && !accountIdFilter[account.AccountId] {
+			continue
+		}
+		if accountNameRegex != nil && !accountNameRegex.MatchString(account.AccountName) {
+			continue
+		}
+		kept = append(kept, account)
+	}
+	return kept
+}
 
-	// Create a map for fast role lookup
+// getRolesForAccounts enumerates the roles of each account and returns the
+// ones matching the requested role names and -filter.
+func getRolesForAccounts(accessToken string, accounts []ssoTypesAccount, roleNames []string) ([]CombinedRole, error) {
+	// Create a map for fast role lookup. Entries are either bare role names
+	// or role@accountId selections scoped to a single account.
 	roleMap := make(map[string]bool)
 	for _, roleName := range roleNames {
 		roleMap[roleName] = true
 	}
 
+	// Fetch each account's roles with up to accountConcurrency workers. The
+	// account list is complete before any worker starts, so every progress
+	// event carries the final total; index counts completed accounts.
+	workers := accountConcurrency
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(accounts) {
+		workers = len(accounts)
+	}
+	selected := make([][]CombinedRole, len(accounts))
+	errs := make([]error, len(accounts))
+	var scanned atomic.Int64
+	var emitMu sync.Mutex
+	var failed atomic.Bool
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				account := accounts[i]
+				roles, err := getListOfSsoAccountRolesForAccountFunc(accessToken, account.AccountId)
+				if err != nil {
+					errs[i] = err
+					failed.Store(true)
+					continue
+				}
+				// Count and emit together so indexes reach the stream in order.
+				emitMu.Lock()
+				emitProgress("account_scanned", map[string]interface{}{
+					"account":   account.AccountName,
+					"accountId": account.AccountId,
+					"index":     scanned.Add(1),
+					"total":     len(accounts),
+				})
+				emitMu.Unlock()
+				for _, role := range roles {
+					if roleIncluded(roleMap, role.RoleName, account.AccountId) {
+						selected[i] = append(selected[i], CombinedRole{
+							AccountId:   account.AccountId,
+							RoleName:    role.RoleName,
+							AccountName: account.AccountName,
+						})
+					}
+				}
+			}
+		}()
+	}
+	for i := range accounts {
+		if failed.Load() {
+			break
+		}
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	// Assemble in discovery order and apply -filter serially.
 	var combined []CombinedRole
-	for _, account := range accounts {
-		roles, err := getListOfSsoAccountRolesForAccount(accessToken, account.AccountId)
-		if err != nil {
-			return nil, err
+	for i := range accounts {
+		if errs[i] != nil {
+			return nil, errs[i]
 		}
-		for _, role := range roles {
-			if roleMap[role.RoleName] {
-				combined = append(combined, CombinedRole{
-					AccountId:   account.AccountId,
-					RoleName:    role.RoleName,
-					AccountName: account.AccountName,
-				})
+		for _, candidate := range selected[i] {
+			keep, err := matchesRoleFilter(candidate)
+			if err != nil {
+				return nil, err
+			}
+			if keep {
+				combined = append(combined, candidate)
 			}
 		}
 	}
 	return combined, nil
 }
 
-// listAllRolesPerAccount prints all roles available per account (used in dry-run)
+// roleIncluded applies -exclude-role on top of the -role and -role-regex
+// selection. A role is selected if it is in the -role list or matches any
+// -role-regex. Without either but with exclusions every role is included
+// except the excluded ones; otherwise the selection is narrowed by the
+// exclusions. Matching is exact and case-sensitive, as for -role.
+func roleIncluded(roleMap map[string]bool, roleName, accountId string) bool {
+	if excludedRoles[roleName] {
+		return false
+	}
+	if matchingRoleRegex(roleName) != nil {
+		return true
+	}
+	if len(roleMap) == 0 {
+		return len(excludedRoles) > 0 && len(roleRegexes) == 0
+	}
+	return roleSelected(roleMap, roleName, accountId)
+}
+
+// matchingRoleRegex returns the first -role-regex matching roleName, or nil.
+func matchingRoleRegex(roleName string) *regexp.Regexp {
+	for _, re := range roleRegexes {
+		if re.MatchString(roleName) {
+			return re
+		}
+	}
+	return nil
+}
+
+// compileRoleRegexes compiles the -role-regex patterns.
+func compileRoleRegexes(patterns []string) ([]*regexp.Regexp, error) {
+	var out []*regexp.Regexp
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -role-regex %q: %v", p, err)
+		}
+		out = append(out, re)
+	}
+	return out, nil
+}
+
+// rolesRequested reports whether the run selects roles to configure, either
+// with -role, -role-regex or -exclude-role alone.
+func rolesRequested() bool {
+	return len(ssoRoleNames) > 0 || len(roleRegexes) > 0 || len(excludedRoles) > 0
+}
+
+// describeRoleSelection summarizes the role selection for status output.
+func describeRoleSelection() string {
+	var excluded []string
+	for name := range excludedRoles {
+		excluded = append(excluded, name)
+	}
+	sort.Strings(excluded)
+	selected := append([]string{}, ssoRoleNames...)
+	for _, re := range roleRegexes {
+		selected = append(selected, "/"+re.String()+"/")
+	}
+	switch {
+	case len(excluded) == 0:
+		return strings.Join(selected, ", ")
+	case len(selected) == 0:
+		return "all except " + strings.Join(excluded, ", ")
+	}
+	return strings.Join(selected, ", ") + " except " + strings.Join(excluded, ", ")
+}
+
+// roleSelected reports whether a role in the given account was requested,
+// either by its bare name (matching in every account) or by a
+// role@accountId selection scoped to that account.
+func roleSelected(roleMap map[string]bool, roleName, accountId string) bool {
+	return roleMap[roleName] || roleMap[roleName+"@"+accountId]
+}
+
+// compileRoleFilter compiles the -filter JMESPath expression.
+func compileRoleFilter(expr string) (*jmespath.JMESPath, error) {
+	compiled, err := jmespath.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -filter expression %q: %v", expr, err)
+	}
+	return compiled, nil
+}
+
+// matchesRoleFilter evaluates the -filter expression against an
+// {accountId, accountName, roleName} object and reports whether the result is
+// truthy in the JMESPath sense (not false, null or empty). Without a filter
+// every role matches.
+func matchesRoleFilter(role CombinedRole) (bool, error) {
+	if roleFilter == nil {
+		return true, nil
+	}
+	data := map[string]interface{}{
+		"accountId":   role.AccountId,
+		"accountName": role.AccountName,
+		"roleName":    role.RoleName,
+	}
+	result, err := roleFilter.Search(data)
+	if err != nil {
+		return false, fmt.Errorf("evaluating -filter for %s/%s: %v", role.AccountId, role.RoleName, err)
+	}
+	switch v := result.(type) {
+	case nil:
+		return false, nil
+	case bool:
+		return v, nil
+	case string:
+		return v != "", nil
+	case []interface{}:
+		return len(v) > 0, nil
+	case map[string]interface{}:
+		return len(v) > 0, nil
+	}
+	return true, nil
+}
+
+// listAllRolesPerAccount prints all roles available per account (used in
+// dry-run) in the -list-format layout
 func listAllRolesPerAccount(accessToken string) error {
-	accounts, err := getListOfSsoAccounts(accessToken)
+	listings, err := gatherAccountRoleListings(accessToken)
 	if err != nil {
 		return err
 	}
+	printAccountRoleListings(listings, listFormat)
+	return nil
+}
+
+// gatherAccountRoleListings fetches the roles of every selected account and
+// decorates their names for display: excluded roles are dimmed and requested
+// roles highlighted, with the matching -role-regex pattern if any.
+func gatherAccountRoleListings(accessToken string) ([]accountRoleListing, error) {
+	accounts, err := getListOfSsoAccountsFunc(accessToken)
+	if err != nil {
+		return nil, err
+	}
+	// Apply the account filters the sync uses, -account-tag included.
+	accounts, err = filterAccountsByTags(filterAccountsByIdAndName(accounts), accountTagFilters)
+	if err != nil {
+		return nil, err
+	}
+	var listings []accountRoleListing
 	for _, account := range accounts {
-		roles, err := getListOfSsoAccountRolesForAccount(accessToken, account.AccountId)
+		roles, err := getListOfSsoAccountRolesForAccountFunc(accessToken, account.AccountId)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		// Collect raw role names and sort them so output is deterministic
 		var raw []string
 		for _, r := range roles {
 			raw = append(raw, r.RoleName)
 		}
-		if len(raw) == 0 {
-			fmt.Printf("    %s %s: (no roles)\n", cyan("🔐"), account.AccountName)
-			continue
-		}
 		// Sort alphabetically
 		sort.Strings(raw)
 
@@ -398,15 +1034,19 @@ func listAllRolesPerAccount(accessToken string) error {
 		}
 		var display []string
 		for _, name := range raw {
-			if wanted[name] {
+			if excludedRoles[name] {
+				display = append(display, dim(name))
+			} else if re := matchingRoleRegex(name); re != nil {
+				display = append(display, green(bold(name))+" (/"+re.String()+"/)")
+			} else if roleIncluded(wanted, name, account.AccountId) {
 				display = append(display, green(bold(name)))
 			} else {
 				display = append(display, name)
 			}
 		}
-		fmt.Printf("    %s %s: %s\n", cyan("🔐"), account.AccountName, strings.Join(display, ", "))
+		listings = append(listings, accountRoleListing{AccountId: account.AccountId, AccountName: account.AccountName, Roles: display})
 	}
-	return nil
+	return listings, nil
 }
 
 // Generate profile prefix from role name by stripping AWS and Access
@@ -415,27 +1055,139 @@ func generatePrefixFromRole(roleName string) string {
 	cleaned := strings.TrimPrefix(roleName, "AWS")
 	cleaned = strings.TrimSuffix(cleaned, "Access")
 
-	if cleaned != "" {
+	if cleaned = sanitizeRoleName(cleaned); cleaned != "" {
 		return cleaned + "_"
 	}
 	return ""
 }
 
-// Format profile name
+// roleSeparatorPattern matches the runs of whitespace and slashes that
+// custom permission set names may contain.
+var roleSeparatorPattern = regexp.MustCompile(`[\s/\\]+`)
+
+// sanitizeRoleName makes a role name usable in a profile name: runs of
+// whitespace and slashes become "-", leading and trailing "-" are dropped,
+// and INI-unsafe characters are replaced (see sanitizeProfileName).
+// Underscores are kept, so names derived from existing roles don't change.
+func sanitizeRoleName(roleName string) string {
+	return strings.Trim(sanitizeProfileName(roleSeparatorPattern.ReplaceAllString(roleName, "-")), "-")
+}
+
+// builtinAbbreviations are the word abbreviations applied by -compact-names.
+// Keys are matched case-insensitively against whole words.
+var builtinAbbreviations = map[string]string{
+	"production":    "prod",
+	"development":   "dev",
+	"staging":       "stg",
+	"sandbox":       "sbx",
+	"readonly":      "ro",
+	"administrator": "admin",
+	"poweruser":     "pu",
+}
+
+// parseAbbreviations parses -abbrev values of the form word=short.
+func parseAbbreviations(values []string) (map[string]string, error) {
+	out := make(map[string]string)
+	for _, v := range values {
+		parts := strings.SplitN(v, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || strings.TrimSpace(parts[1]) == "" {
+			return nil, fmt.Errorf("invalid -abbrev %q: expected word=short", v)
+		}
+		out[strings.ToLower(strings.TrimSpace(parts[0]))] = strings.TrimSpace(parts[1])
+	}
+	return out, nil
+}
+
+// abbreviateWords replaces whole words in s using the -abbrev overrides,
+// falling back to builtinAbbreviations.
+func abbreviateWords(s string) string {
+	re := regexp.MustCompile(`[A-Za-z0-9]+`)
+	return re.ReplaceAllStringFunc(s, func(word string) string {
+		lower := strings.ToLower(word)
+		if short, ok := userAbbreviations[lower]; ok {
+			return short
+		}
+		if short, ok := builtinAbbreviations[lower]; ok {
+			return short
+		}
+		return word
+	})
+}
+
+// Supported values for -region-source
+const (
+	regionSourceProfile     = "profile"
+	regionSourceSessionOnly = "session-only"
+)
+
+// validateRegionSource checks that -region-source is one of the supported
+// values.
+func validateRegionSource(source string) error {
+	switch source {
+	case regionSourceProfile, regionSourceSessionOnly:
+		return nil
+	}
+	return fmt.Errorf("invalid -region-source %q: expected %s or %s", source, regionSourceProfile, regionSourceSessionOnly)
+}
+
+// Supported values for -name-style
+const (
+	nameStyleRoleAccount = "role-account"
+	nameStyleAccountOnly = "account-only"
+	nameStyleRoleOnly    = "role-only"
+)
+
+// validateNameStyle checks that -name-style is one of the supported values.
+func validateNameStyle(style string) error {
+	switch style {
+	case nameStyleRoleAccount, nameStyleAccountOnly, nameStyleRoleOnly:
+		return nil
+	}
+	return fmt.Errorf("invalid -name-style %q: expected %s, %s or %s", style, nameStyleRoleAccount, nameStyleAccountOnly, nameStyleRoleOnly)
+}
+
+// Format profile name, namespaced by the current geography if any
 func getProfileNameFromRole(role CombinedRole) string {
-	re := regexp.MustCompile(`[_\s]+`)
-	safeAccountName := re.ReplaceAllString(role.AccountName, "-")
+	return sanitizeProfileName(profileNamespace + baseProfileName(role))
+}
+
+// unsafeSectionChars matches runs of characters that break a [profile <name>]
+// header or confuse INI parsers: brackets, comment and assignment characters,
+// whitespace and control characters.
+var unsafeSectionChars = regexp.MustCompile(`[\[\]#;=\s\x00-\x1f\x7f]+`)
 
-	// Determine the prefix to use
-	var prefix string
-	if profilePrefix != "" {
-		// Use custom prefix if provided
-		prefix = profilePrefix
-	} else if useAutoPrefix {
-		// Auto-generate prefix from role name
-		prefix = generatePrefixFromRole(role.RoleName)
+// sanitizeProfileName replaces each run of INI-unsafe characters in name
+// with "-". Every profile name is passed through it, so the names written
+// and the names looked up in existing sections always agree.
+func sanitizeProfileName(name string) string {
+	return unsafeSectionChars.ReplaceAllString(name, "-")
+}
+
+// baseProfileName builds the profile name from the prefix, account and role
+// according to -name-style.
+func baseProfileName(role CombinedRole) string {
+	if role.ProfileName != "" {
+		return role.ProfileName
+	}
+	re := regexp.MustCompile(`[_\s]+`)
+	accountName := role.AccountName
+	if compactNames {
+		accountName = abbreviateWords(accountName)
+	}
+	safeAccountName := re.ReplaceAllString(accountName, "-")
+	prefix := rolePrefix(role)
+
+	switch nameStyle {
+	case nameStyleAccountOnly:
+		return fmt.Sprintf("%s_%s", safeAccountName, role.AccountId)
+	case nameStyleRoleOnly:
+		// Use the prefix without its trailing separator, falling back to the
+		// sanitized role name when no prefix applies.
+		if name := strings.TrimSuffix(prefix, "_"); name != "" {
+			return name
+		}
+		return sanitizeRoleName(role.RoleName)
 	}
-	// If prefix is empty (either by choice or no auto-prefix), use no prefix
 
 	if prefix != "" {
 		return fmt.Sprintf("%s%s_%s", prefix, safeAccountName, role.AccountId)
@@ -443,16 +1195,92 @@ func getProfileNameFromRole(role CombinedRole) string {
 	return fmt.Sprintf("%s_%s", safeAccountName, role.AccountId)
 }
 
+// rolePrefix returns the profile name prefix for role: a per-role prefix or
+// alias from -role-map-file, -prefix, or the auto-generated one. It is empty
+// when none applies.
+func rolePrefix(role CombinedRole) string {
+	setting := roleSettings[role.RoleName]
+	switch {
+	case setting.Prefix != "":
+		// A per-role prefix from -role-map-file wins over everything
+		return setting.Prefix
+	case profilePrefix != "":
+		return profilePrefix
+	case setting.Alias != "":
+		return setting.Alias + "_"
+	case useAutoPrefix:
+		prefix := generatePrefixFromRole(role.RoleName)
+		if compactNames {
+			prefix = abbreviateWords(prefix)
+		}
+		return prefix
+	}
+	return ""
+}
+
+// checkProfileNameCollisions returns an error if two of the given roles would
+// be written to the same profile name. The account-only and role-only name
+// styles drop part of the identity, and sanitizing can map different names
+// to one, so roles would otherwise silently overwrite each other. With
+// -dedupe-names the later role of each collision is renamed instead (see
+// dedupeProfileName) and the rename is reported.
+func checkProfileNameCollisions(roles []CombinedRole) error {
+	taken := make(map[string]bool)
+	for _, role := range roles {
+		taken[getProfileNameFromRole(role)] = true
+	}
+	first := make(map[string]CombinedRole)
+	for i, role := range roles {
+		name := getProfileNameFromRole(role)
+		prev, ok := first[name]
+		if !ok {
+			first[name] = role
+			continue
+		}
+		if !dedupeNames {
+			return fmt.Errorf("profile name %q would be used for both %s/%s and %s/%s with -name-style=%s; select fewer roles, use a different -name-style or pass -dedupe-names",
+				name, prev.AccountId, prev.RoleName, role.AccountId, role.RoleName, nameStyle)
+		}
+		roles[i].ProfileName = dedupeProfileName(role, taken)
+		renamed := getProfileNameFromRole(roles[i])
+		taken[renamed] = true
+		verb := "Renaming"
+		if dryRun {
+			verb = "Would rename"
+		}
+		warnf("%sProfile name collision: %q is used by both %s/%s and %s/%s. %s the second to %q.\n",
+			yellow(icon("warn")), name, prev.AccountId, prev.RoleName, role.AccountId, role.RoleName, verb, renamed)
+	}
+	return nil
+}
+
+// dedupeProfileName returns a base profile name for role that no name in
+// taken uses: the colliding name with the sanitized role name appended, then
+// also the account ID, then a counter.
+func dedupeProfileName(role CombinedRole, taken map[string]bool) string {
+	base := baseProfileName(role) + "_" + sanitizeRoleName(role.RoleName)
+	candidates := []string{base, base + "_" + role.AccountId}
+	for n := 2; ; n++ {
+		for _, candidate := range candidates {
+			if !taken[sanitizeProfileName(profileNamespace+candidate)] {
+				return candidate
+			}
+		}
+		candidates = []string{fmt.Sprintf("%s_%s_%d", base, role.AccountId, n)}
+	}
+}
+
 // Ensure SSO session config block is present in ~/.aws/config
 func ensureSsoSessionConfigPresent() (bool, error) {
-	awsConfigPath := ssoConfigFile
+	awsConfigPath := sessionConfigPath()
 	sessionHeader := fmt.Sprintf("[sso-session %s]", ssoSessionConfigName)
-	sessionBlock := fmt.Sprintf(
-		`[sso-session %s]
-sso_start_url = %s
-sso_region = %s
-sso_registration_scopes = sso:account:access
-`, ssoSessionConfigName, strings.TrimRight(ssoStartURL, "/"), ssoRegion)
+	// With -session-name-from-url an unnamed session gets a name derived from
+	// the start URL, so an existing "default" block (likely another
+	// instance's) is only reused if it matches below.
+	deriveName := sessionNameFromURL && (ssoSessionConfigName == defaultSSOSessionConfigName || ssoSessionConfigName == "")
+	if err := acquireConfigLock(); err != nil {
+		return false, err
+	}
 
 	// Read the config file if it exists. If it doesn't exist, we'll create
 	// a new one below.
@@ -461,9 +1289,10 @@ sso_registration_scopes = sso:account:access
 		return false, err
 	}
 
-	// If the exact named session header already exists, nothing to do.
-	if strings.Contains(string(data), sessionHeader) {
-		return false, nil // Already present
+	// If the exact named session header already exists, nothing to do
+	// beyond checking its scopes.
+	if !deriveName && strings.Contains(string(data), sessionHeader) {
+		return false, checkSessionScopes(ssoSessionConfigName, awsConfigPath) // Already present
 	}
 
 	// If the caller didn't explicitly set a session name (i.e. we're using
@@ -476,26 +1305,51 @@ sso_registration_scopes = sso:account:access
 		// doesn't exist, findAllMatchingSsoSessionNames would fail.
 		if _, statErr := os.Stat(awsConfigPath); statErr == nil {
 			if matches, mErr := findAllMatchingSsoSessionNames(ssoStartURL, ssoRegion, awsConfigPath); mErr == nil {
-				if len(matches) == 1 {
+				name, err := selectMatchingSession(matches)
+				if err != nil {
+					return false, err
+				}
+				if name != "" {
 					// Reuse the existing session name instead of creating a new
 					// default block.
-					ssoSessionConfigName = matches[0]
+					ssoSessionConfigName = name
 					if dryRun {
-						fmt.Printf("    %s Would reuse existing SSO session configuration: %s\n", cyan("📝"), bold(ssoSessionConfigName))
+						infof("    %sWould reuse existing SSO session configuration: %s\n", cyan(icon("write")), bold(ssoSessionConfigName))
 					}
-					return false, nil
-				} else if len(matches) > 1 {
-					return false, fmt.Errorf("multiple matching sso-session blocks found for startUrl %s and region %s", ssoStartURL, ssoRegion)
+					return false, checkSessionScopes(ssoSessionConfigName, awsConfigPath)
 				}
 			}
 		}
 	}
 
-	if dryRun {
-		// In dry-run mode, show what would be written
-		fmt.Printf("    %s Would add SSO session configuration:\n", cyan("📝"))
-		printBlockIndented("      ", sessionBlock)
-		return true, nil // Pretend it would be added
+	if deriveName {
+		ssoSessionConfigName = deriveSessionName(ssoStartURL)
+		if strings.Contains(string(data), fmt.Sprintf("[sso-session %s]", ssoSessionConfigName)) {
+			return false, checkSessionScopes(ssoSessionConfigName, awsConfigPath) // Already present
+		}
+	}
+	sessionBlock := fmt.Sprintf(
+		`[sso-session %s]
+sso_start_url = %s
+sso_region = %s
+sso_registration_scopes = sso:account:access
+`, ssoSessionConfigName, strings.TrimRight(ssoStartURL, "/"), ssoRegion)
+	if annotateSession {
+		// Provenance comments only go on blocks we create; existing blocks
+		// are never rewritten to add them.
+		sessionBlock = fmt.Sprintf("# region: %s\n# created-by: aws-sso-profile-sync %s\n", ssoRegion, version) + sessionBlock
+	}
+
+	if diffMode {
+		// The block shows up in the diff instead.
+		pendingSessionBlock = sessionBlock
+		return true, nil
+	}
+	if dryRun {
+		// In dry-run mode, show what would be written
+		infof("    %sWould add SSO session configuration:\n", cyan(icon("write")))
+		printBlockIndented("      ", sessionBlock)
+		return true, nil // Pretend it would be added
 	}
 
 	needsNewline := len(data) > 0 && data[len(data)-1] != '\n'
@@ -503,6 +1357,16 @@ sso_registration_scopes = sso:account:access
 	if needsNewline {
 		toWrite = "\n" + sessionBlock
 	}
+	// A -profiles-dir session file is ours alone, so only the main config
+	// file is backed up.
+	if profilesDir == "" {
+		if err := ensureConfigBackup(); err != nil {
+			return false, err
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(awsConfigPath), 0o700); err != nil {
+		return false, err
+	}
 	f, err := os.OpenFile(awsConfigPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
 	if err != nil {
 		return false, err
@@ -552,41 +1416,98 @@ func findMatchingSsoSessionName(startURL, region, configPath string) (string, bo
 // multiple matching sessions.
 func findAllMatchingSsoSessionNames(startURL, region, configPath string) ([]string, error) {
 	normStart := strings.TrimRight(startURL, "/")
-	cfg, err := ini.Load(configPath)
+	sessions, err := readSsoSessions(configPath)
 	if err != nil {
 		return nil, err
 	}
 	var matches []string
-	for _, section := range cfg.Sections() {
-		name := section.Name()
-		if strings.HasPrefix(name, "sso-session ") {
-			ssoStart := strings.TrimRight(section.Key("sso_start_url").String(), "/")
-			ssoRegion := section.Key("sso_region").String()
-			if ssoStart == normStart && ssoRegion == region {
-				parts := strings.SplitN(name, " ", 2)
-				if len(parts) == 2 {
-					matches = append(matches, parts[1])
-				}
-			}
+	for _, session := range sessions {
+		if session.StartURL == normStart && session.Region == region {
+			matches = append(matches, session.Name)
 		}
 	}
 	return matches, nil
 }
 
+// selectMatchingSession picks the session to reuse from the sso-session
+// names matching the requested start URL and region. A single match is used
+// as-is; among multiple matches -prefer-session selects one. Without it, a
+// user on a terminal is asked to choose (see promptSessionChoice); otherwise,
+// or when -prefer-session names a session that isn't a match, an error is
+// returned. No matches yields an empty name.
+func selectMatchingSession(matches []string) (string, error) {
+	switch {
+	case len(matches) == 0:
+		return "", nil
+	case len(matches) == 1:
+		return matches[0], nil
+	}
+	if preferSession != "" {
+		for _, m := range matches {
+			if m == preferSession {
+				return m, nil
+			}
+		}
+		return "", fmt.Errorf("-prefer-session %q is not among the matching sso-session blocks for startUrl %s and region %s (matches: %s)", preferSession, ssoStartURL, ssoRegion, strings.Join(matches, ", "))
+	}
+	if interactiveSessionResolution && stdinIsTerminal() {
+		return promptSessionChoice(matches)
+	}
+	return "", fmt.Errorf("multiple matching sso-session blocks found for startUrl %s and region %s", ssoStartURL, ssoRegion)
+}
+
+// reuseMatchingSession switches ssoSessionConfigName to an existing matching
+// sso-session (see selectMatchingSession) and reports the choice. prefix is
+// printed before the message so callers can control spacing.
+func reuseMatchingSession(prefix string) error {
+	configPath := sessionConfigPath()
+	matches, err := findAllMatchingSsoSessionNames(ssoStartURL, ssoRegion, configPath)
+	if err != nil {
+		return nil
+	}
+	name, err := selectMatchingSession(matches)
+	if err != nil {
+		if preferSession == "" {
+			errorf("%sMultiple matching sso-session blocks found (%d). Please pass -sso-session-name or -prefer-session to select one, or remove duplicates. Matches: %s\n", red(icon("error")), len(matches), strings.Join(matches, ", "))
+		} else {
+			errorf("%s%v\n", red(icon("error")), err)
+		}
+		return err
+	}
+	if name == "" {
+		return nil
+	}
+	ssoSessionConfigName = name
+	if len(matches) > 1 {
+		infof("%s%sReusing SSO session configuration %s selected by -prefer-session among %d matches\n\n", prefix, cyan(icon("write")), bold(ssoSessionConfigName), len(matches))
+	} else {
+		infof("%s%sReusing SSO session configuration %s because -sso-session-name was not provided\n\n", prefix, cyan(icon("write")), bold(ssoSessionConfigName))
+	}
+	return checkSessionScopes(ssoSessionConfigName, configPath)
+}
+
+// loadSsoSessionSection returns the [sso-session <name>] section of the
+// config file at configPath.
+func loadSsoSessionSection(sessionName, configPath string) (*ini.Section, error) {
+	cfg, err := ini.Load(configPath)
+	if err != nil {
+		return nil, err
+	}
+	section, err := cfg.GetSection("sso-session " + sessionName)
+	if err != nil {
+		return nil, fmt.Errorf("sso-session %s not found", sessionName)
+	}
+	return section, nil
+}
+
 // getExistingSsoSessionBlock returns the textual block for an existing
 // sso-session <name> from the config file (same format used when we would add one).
 func getExistingSsoSessionBlock(sessionName, configPath string) (string, error) {
-	cfg, err := ini.Load(configPath)
+	section, err := loadSsoSessionSection(sessionName, configPath)
 	if err != nil {
 		return "", err
 	}
 
-	sectionName := "sso-session " + sessionName
-	section := cfg.Section(sectionName)
-	if section == nil {
-		return "", fmt.Errorf("sso-session %s not found", sessionName)
-	}
-
 	ssoStart := strings.TrimRight(section.Key("sso_start_url").String(), "/")
 	ssoRegion := section.Key("sso_region").String()
 	ssoScopes := section.Key("sso_registration_scopes").String()
@@ -613,160 +1534,907 @@ func printBlockIndented(indent, block string) {
 			break
 		}
 		if i == 0 {
-			fmt.Printf("%s%s\n", indent, l)
+			infof("%s%s\n", indent, l)
 		} else {
-			fmt.Printf("%s  %s\n", indent, l)
+			infof("%s  %s\n", indent, l)
 		}
 	}
 }
 
-// openBrowserURL attempts to open the provided URL in the user's default
-// browser. It's a convenience for the device authorization flow.
-func openBrowserURL(url string) error {
-	var cmd *exec.Cmd
-	switch runtime.GOOS {
-	case "darwin":
-		cmd = exec.Command("open", url)
-	case "windows":
-		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
-	default:
-		cmd = exec.Command("xdg-open", url)
+// isHeadlessEnvironment reports whether the process looks like it is running
+// without a usable browser: an SSH session, or Linux/BSD without a display
+// server. getenv is injected so tests can simulate environments.
+func isHeadlessEnvironment(goos string, getenv func(string) string) bool {
+	if getenv("SSH_TTY") != "" || getenv("SSH_CONNECTION") != "" {
+		return true
+	}
+	switch goos {
+	case "darwin", "windows":
+		return false
+	}
+	return getenv("DISPLAY") == "" && getenv("WAYLAND_DISPLAY") == ""
+}
+
+// flagWasSet reports whether the named flag was explicitly passed on the
+// command line.
+func flagWasSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// isUnderHomeAwsDir reports whether path resolves to a location inside the
+// user's ~/.aws directory.
+func isUnderHomeAwsDir(path string) bool {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return false
+	}
+	awsDir := filepath.Join(homeDir, ".aws")
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(awsDir, absPath)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// checkConfigFileLocation guards against accidentally writing to a config
+// file outside ~/.aws (e.g. a typo'd -config-file /etc/passwd). Paths under
+// ~/.aws always pass; anything else prints a warning and is refused unless
+// -allow-external-config was given.
+func checkConfigFileLocation(path string) error {
+	if isUnderHomeAwsDir(path) {
+		return nil
+	}
+	warnf("%sConfig file %s is outside your ~/.aws directory.\n", yellow(icon("warn")), path)
+	if !allowExternalConfig {
+		return fmt.Errorf("refusing to use config file outside ~/.aws: %s (pass -allow-external-config to proceed)", path)
+	}
+	return nil
+}
+
+// checkConfigReadable fails early when the config file exists but can't be
+// read. Without this, lookups that treat "can't load" as "not there" would
+// write duplicate profiles, or a save would replace the unreadable file.
+// A missing file is fine; it is created on first write.
+func checkConfigReadable(path string) error {
+	f, err := os.Open(path)
+	if err == nil {
+		return f.Close()
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if errors.Is(err, fs.ErrPermission) {
+		return fmt.Errorf("config file %s exists but is not readable (permission denied); fix its permissions (e.g. chmod 600 %s) and re-run", path, path)
 	}
-	return cmd.Start()
+	return fmt.Errorf("cannot read config file %s: %v", path, err)
 }
 
 // Add SSO session config if needed
 func configureSsoSessionConfig() error {
 	added, err := ensureSsoSessionConfigPresent()
 	if err != nil {
-		fmt.Printf("%s %s %v\n", red("❌"), bold("Error adding SSO session config:"), err)
+		errorf("%s%s %v\n", red(icon("error")), bold("Error adding SSO session config:"), err)
 		return err
 	}
 	if added {
 		if dryRun {
-			fmt.Printf("%s %s [%s] to %s\n", green("✅"), bold("Would add SSO session config block for"), ssoSessionConfigName, ssoConfigFile)
+			infof("%s%s [%s] to %s\n", green(icon("ok")), bold("Would add SSO session config block for"), ssoSessionConfigName, sessionConfigPath())
 		} else {
-			fmt.Printf("%s %s [%s] to %s\n", green("✅"), bold("Added SSO session config block for"), ssoSessionConfigName, ssoConfigFile)
+			infof("%s%s [%s] to %s\n", green(icon("ok")), bold("Added SSO session config block for"), ssoSessionConfigName, sessionConfigPath())
 		}
 	}
 	return nil
 }
 
+// profileKey returns the key name a logical profile key is written under,
+// honoring any -key-name remapping.
+func profileKey(logical string) string {
+	if name, ok := profileKeyNames[logical]; ok && name != "" {
+		return name
+	}
+	return logical
+}
+
+// parseKeyNameMappings parses -key-name values of the form logical=actual
+// into a mapping. Every required logical key must end up mapped to a
+// distinct, non-empty key name (unmapped keys keep their standard name).
+func parseKeyNameMappings(values []string) (map[string]string, error) {
+	known := make(map[string]bool)
+	for _, k := range requiredProfileKeys {
+		known[k] = true
+	}
+	mapping := make(map[string]string)
+	for _, v := range values {
+		parts := strings.SplitN(v, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid -key-name %q: expected logical=actual", v)
+		}
+		logical := strings.TrimSpace(parts[0])
+		actual := strings.TrimSpace(parts[1])
+		if !known[logical] {
+			return nil, fmt.Errorf("invalid -key-name %q: unknown key %q (expected one of %s)", v, logical, strings.Join(requiredProfileKeys, ", "))
+		}
+		if actual == "" {
+			return nil, fmt.Errorf("invalid -key-name %q: key name must not be empty", v)
+		}
+		mapping[logical] = actual
+	}
+	// Make sure every required logical key resolves to a unique name so one
+	// value can't silently overwrite another in the written section.
+	seen := make(map[string]string)
+	for _, logical := range requiredProfileKeys {
+		name := logical
+		if actual, ok := mapping[logical]; ok {
+			name = actual
+		}
+		if other, dup := seen[name]; dup {
+			return nil, fmt.Errorf("invalid -key-name mapping: %s and %s both map to %q", other, logical, name)
+		}
+		seen[name] = logical
+	}
+	return mapping, nil
+}
+
+// outputInherit as the -output value omits the output key, so the AWS CLI
+// falls back to [default] or its built-in format.
+const outputInherit = "inherit"
+
+// profileValues returns the values written into a profile for the given
+// role, keyed by logical key name. Keys that should not be written (output
+// under -output=inherit) are absent.
+func profileValues(role CombinedRole) map[string]string {
+	region := ssoRegion
+	if role.Region != "" {
+		region = role.Region
+	}
+	values := map[string]string{
+		"sso_session":    ssoSessionConfigName,
+		"sso_account_id": role.AccountId,
+		"sso_role_name":  role.RoleName,
+		"region":         region,
+		"output":         profileOutput,
+	}
+	if profileOutput == outputInherit {
+		delete(values, "output")
+	}
+	applyRoleSettings(role, values)
+	if legacyKeys != nil {
+		// Legacy profiles carry the session settings inline, limited to the
+		// keys the consuming tool expects.
+		delete(values, "sso_session")
+		inline := map[string]string{"sso_start_url": strings.TrimRight(ssoStartURL, "/"), "sso_region": ssoRegion}
+		for _, k := range legacyKeys {
+			if v, ok := inline[k]; ok {
+				values[k] = v
+			}
+		}
+		for _, k := range defaultLegacyKeys {
+			if !slices.Contains(legacyKeys, k) {
+				delete(values, k)
+			}
+		}
+	}
+	if regionSource == regionSourceSessionOnly {
+		delete(values, "region")
+	}
+	if credentialProcessMode {
+		// The command carries the account, role and session settings, so
+		// none of the SSO keys are written.
+		for _, k := range []string{"sso_session", "sso_account_id", "sso_role_name"} {
+			delete(values, k)
+		}
+		if command, err := credentialProcessCommand(role); err == nil {
+			values["credential_process"] = command
+		} else {
+			warnf("%s%v\n", yellow(icon("warn")), err)
+		}
+	}
+	applyExtraKeys(values)
+	return values
+}
+
 // Write profile configuration directly to AWS config file using ini package
 func writeProfileToConfig(profileName string, role CombinedRole) error {
+	values := profileValues(role)
 	if dryRun {
 		// In dry-run mode, show what would be written
-		fmt.Printf("    %s Would write profile configuration:\n", cyan("📝"))
+		infof("    %sWould write profile configuration:\n", cyan(icon("write")))
 		block := fmt.Sprintf("[profile %s]\n", profileName)
-		block += fmt.Sprintf("sso_session = %s\n", ssoSessionConfigName)
-		block += fmt.Sprintf("sso_account_id = %s\n", role.AccountId)
-		block += fmt.Sprintf("sso_role_name = %s\n", role.RoleName)
-		block += fmt.Sprintf("region = %s\n", ssoRegion)
-		block += fmt.Sprintf("output = %s\n\n", profileOutput)
+		for _, logical := range profileKeys() {
+			if value, ok := values[logical]; ok {
+				block += fmt.Sprintf("%s = %s\n", profileKey(logical), value)
+			}
+		}
+		block += "\n"
 		printBlockIndented("      ", block)
 		return nil
 	}
-
-	// Load or create the config file
-	cfg, err := ini.Load(ssoConfigFile)
-	if err != nil {
-		// If file doesn't exist, create a new one
-		cfg = ini.Empty()
+	if err := acquireConfigLock(); err != nil {
+		return err
 	}
 
-	// Create the profile section name
-	sectionName := fmt.Sprintf("profile %s", profileName)
-
-	// Get or create the profile section
-	section, err := cfg.NewSection(sectionName)
-	if err != nil {
-		// Section might already exist, get it instead
-		section = cfg.Section(sectionName)
+	// Read the config file. Only a missing file starts empty; any other
+	// read or parse error must not lead to the existing file being replaced.
+	path := profileConfigPath(profileName)
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if _, err := ini.Load(data); err != nil {
+		return err
 	}
 
-	// Set the profile properties
-	section.Key("sso_session").SetValue(ssoSessionConfigName)
-	section.Key("sso_account_id").SetValue(role.AccountId)
-	section.Key("sso_role_name").SetValue(role.RoleName)
-	section.Key("region").SetValue(ssoRegion)
-	section.Key("output").SetValue(profileOutput)
+	data = setProfileKeys(data, profileName, role, values)
+	if profilesDir != "" {
+		return writeProfileFile(path, data)
+	}
+	return writeConfigFile(data)
+}
 
-	// Ensure parent directory exists before saving (tests may use temp dirs).
-	if err := os.MkdirAll(filepath.Dir(ssoConfigFile), 0o700); err != nil {
-		return err
+// setProfileKeys returns data with the profile section of profileName set to
+// values. Only the profile's own keys change; comments, spacing and every
+// other section are kept untouched.
+func setProfileKeys(data []byte, profileName string, role CombinedRole, values map[string]string) []byte {
+	var managed []string
+	var keyValues []keyValue
+	for _, logical := range profileKeys() {
+		managed = append(managed, profileKey(logical))
+		if value, ok := values[logical]; ok {
+			keyValues = append(keyValues, keyValue{profileKey(logical), value})
+		}
 	}
-	// Touch the file to ensure it exists (some test environments check for its
-	// presence immediately after SaveTo; creating it first avoids races).
-	if err := os.WriteFile(ssoConfigFile, []byte{}, 0o600); err != nil {
-		return err
+
+	// Mark the start of a -group-by group the first time it appears.
+	var comment string
+	if profileGroupBy != nil {
+		if group := profileGroupBy.group(role); group != "" {
+			if header := groupHeader(group); !hasGroupHeader(data, header) {
+				comment = header
+			}
+		}
 	}
-	// Save the file
-	return cfg.SaveTo(ssoConfigFile)
+	return upsertSection(data, "profile "+profileName, managed, keyValues, comment)
 }
 
-// Check if profile exists by name
+// Check if profile exists by name (in its own file with -profiles-dir)
 func profileExists(profileName, configPath string) bool {
+	if profilesDir != "" {
+		configPath = profileConfigPath(profileName)
+	}
 	// Load the config file as INI and check for a section named "profile <name>".
 	cfg, err := ini.Load(configPath)
 	if err != nil {
 		return false
 	}
 	sectionName := fmt.Sprintf("profile %s", profileName)
-	return cfg.Section(sectionName) != nil && cfg.Section(sectionName).HasKey("sso_session")
+	section := cfg.Section(sectionName)
+	if legacyKeys != nil && section.HasKey(profileKey("sso_account_id")) {
+		return true
+	}
+	if credentialProcessMode && section.HasKey(profileKey("credential_process")) {
+		return true
+	}
+	return section.HasKey(profileKey("sso_session"))
+}
+
+// isManagedProfile reports whether an INI section is a profile generated for
+// the active sso-session, i.e. a [profile ...] section whose sso_session key
+// names ssoSessionConfigName, or one whose profile name matches
+// -managed-pattern.
+func isManagedProfile(section *ini.Section) bool {
+	if !strings.HasPrefix(section.Name(), "profile ") {
+		return false
+	}
+	if managedPattern != nil && managedPattern.MatchString(strings.TrimPrefix(section.Name(), "profile ")) {
+		return true
+	}
+	key := profileKey("sso_session")
+	return section.HasKey(key) && section.Key(key).String() == ssoSessionConfigName
+}
+
+// setRegionForManagedProfiles updates only the region key of every managed
+// profile of the active session, leaving all other keys untouched. It
+// returns the names of the profiles whose region changed (or would change in
+// dry-run).
+func setRegionForManagedProfiles(region string) ([]string, error) {
+	cfg, err := ini.Load(ssoConfigFile)
+	if err != nil {
+		return nil, err
+	}
+	regionKey := profileKey("region")
+	var updated []string
+	for _, section := range cfg.Sections() {
+		if !isManagedProfile(section) {
+			continue
+		}
+		current := section.Key(regionKey).String()
+		if current == region {
+			continue
+		}
+		profileName := strings.TrimPrefix(section.Name(), "profile ")
+		if dryRun {
+			infof("%sWould update region of profile %s: %s -> %s\n", cyan(icon("write")), bold(profileName), current, region)
+		} else {
+			infof("%sUpdating region of profile %s: %s -> %s\n", green(icon("edit")), bold(profileName), current, region)
+		}
+		updated = append(updated, profileName)
+	}
+	if dryRun || len(updated) == 0 {
+		return updated, nil
+	}
+	data, err := os.ReadFile(ssoConfigFile)
+	if err != nil {
+		return nil, err
+	}
+	for _, profileName := range updated {
+		data = upsertSection(data, "profile "+profileName, []string{regionKey}, []keyValue{{regionKey, region}}, "")
+	}
+	return updated, writeConfigFile(data)
+}
+
+// inPruneScope reports whether the account/role pair of a managed profile is
+// inside this run's selection, so that its absence from the discovered roles
+// means the role was revoked rather than filtered out. The role must be
+// selected by -role, -role-regex and -exclude-role, and the account must be
+// allowed by -account-id. With -account-name-regex, -account-tag or -filter,
+// whose inputs are only known for discovered accounts, the account must also
+// be among selected, the accounts that passed the account filters.
+func inPruneScope(accountId, roleName string, selected map[string]ssoTypesAccount) (bool, error) {
+	roleMap := make(map[string]bool)
+	for _, name := range ssoRoleNames {
+		roleMap[name] = true
+	}
+	if !roleIncluded(roleMap, roleName, accountId) {
+		return false, nil
+	}
+	if len(accountIdFilter) > 0 && !accountIdFilter[accountId] {
+		return false, nil
+	}
+	account, ok := selected[accountId]
+	if (accountNameRegex != nil || len(accountTagFilters) > 0 || roleFilter != nil) && !ok {
+		return false, nil
+	}
+	if roleFilter != nil {
+		return matchesRoleFilter(CombinedRole{AccountId: accountId, AccountName: account.AccountName, RoleName: roleName})
+	}
+	return true, nil
+}
+
+// staleProfiles returns the managed profiles (see isManagedProfile) in cfg
+// that -prune removes: those within -prune-scope-prefix and the run's
+// selection (see inPruneScope) whose account/role pair is not among roles.
+// accounts are the accounts that passed the account filters.
+func staleProfiles(cfg *ini.File, roles []CombinedRole, accounts []ssoTypesAccount) ([]ProfileResult, error) {
+	valid := make(map[string]bool)
+	for _, r := range roles {
+		valid[r.AccountId+"/"+r.RoleName] = true
+	}
+	selected := make(map[string]ssoTypesAccount)
+	for _, a := range accounts {
+		selected[a.AccountId] = a
+	}
+	var stale []ProfileResult
+	for _, section := range cfg.Sections() {
+		if !isManagedProfile(section) {
+			continue
+		}
+		// -prune-scope-prefix narrows pruning to one team's profiles.
+		if !strings.HasPrefix(strings.TrimPrefix(section.Name(), "profile "), pruneScopePrefix) {
+			continue
+		}
+		entry := ProfileResult{
+			ProfileName: strings.TrimPrefix(section.Name(), "profile "),
+			AccountId:   section.Key(profileKey("sso_account_id")).String(),
+			RoleName:    section.Key(profileKey("sso_role_name")).String(),
+		}
+		if valid[entry.AccountId+"/"+entry.RoleName] {
+			continue
+		}
+		inScope, err := inPruneScope(entry.AccountId, entry.RoleName, selected)
+		if err != nil {
+			return nil, err
+		}
+		if inScope {
+			stale = append(stale, entry)
+		}
+	}
+	return stale, nil
+}
+
+// pruneStaleProfiles removes the profiles staleProfiles selects. In dry-run
+// it only reports them. It returns the pruned profiles.
+func pruneStaleProfiles(roles []CombinedRole, accounts []ssoTypesAccount) ([]ProfileResult, error) {
+	cfg, err := ini.Load(ssoConfigFile)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	stale, err := staleProfiles(cfg, roles, accounts)
+	if err != nil {
+		return nil, err
+	}
+	var pruned []ProfileResult
+	for _, entry := range stale {
+		profileName := entry.ProfileName
+		if dryRun {
+			infof("%sWould remove profile: %s %s\n", red(icon("remove")), bold(profileName), "(no longer assigned)")
+		} else {
+			infof("%sRemoving profile: %s %s\n", red(icon("remove")), bold(profileName), "(no longer assigned)")
+		}
+		pruned = append(pruned, entry)
+	}
+	if dryRun || len(pruned) == 0 {
+		return pruned, nil
+	}
+	data, err := os.ReadFile(ssoConfigFile)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range pruned {
+		data = removeSection(data, "profile "+entry.ProfileName)
+	}
+	return pruned, writeConfigFile(data)
 }
 
-// Add profiles for all accounts with any of the desired roles
-func configureSsoProfiles(accessToken string) error {
+// Add profiles for all accounts with any of the desired roles. The returned
+// result is empty for the modes that write no profiles (estimate, export,
+// plan, ARN listing).
+func configureSsoProfiles(accessToken string) (SyncResult, error) {
+	started := time.Now()
+	if err := checkConfigReadable(ssoConfigFile); err != nil {
+		errorf("%s%s %v\n", red(icon("error")), bold("Error:"), err)
+		return SyncResult{}, err
+	}
+	if estimateMode {
+		return SyncResult{}, printCallEstimate(accessToken)
+	}
+	if exportPath != "" {
+		return SyncResult{}, exportSnapshot(accessToken, exportPath)
+	}
+	if dumpRolesFormat != "" {
+		return SyncResult{}, dumpRoles(accessToken)
+	}
 	// In dry-run, print available roles per account first so the user can see
 	// what roles exist and which ones will be selected.
-	if dryRun {
-		fmt.Printf("%s Available roles per account:\n", cyan("🔎"))
+	if dryRun && !planMode && !diffMode && !printRoleArns && !healthCheck {
+		infof("%sAvailable roles per account:\n", cyan(icon("search")))
 		if err := listAllRolesPerAccount(accessToken); err != nil {
-			fmt.Printf("%s %s %v\n", red("❌"), bold("Error listing roles:"), err)
-			return err
+			errorf("%s%s %v\n", red(icon("error")), bold("Error listing roles:"), err)
+			return SyncResult{}, err
 		}
-		fmt.Println()
+		infof("\n")
 	}
 
-	roles, err := getCombinedListOfSsoAccountsAndRoles(accessToken, ssoRoleNames)
+	accounts, err := getSelectedSsoAccounts(accessToken)
 	if err != nil {
-		fmt.Printf("%s %s %v\n", red("❌"), bold("Error fetching accounts:"), err)
-		return err
+		errorf("%s%s %v\n", red(icon("error")), bold("Error fetching accounts:"), err)
+		return SyncResult{}, err
+	}
+	selectedAccounts := accounts
+	// Record fully processed accounts so an interrupted run can -resume. A
+	// checkpoint is bound to the token and ignored once it changes.
+	var checkpoint *syncCheckpoint
+	resumed := 0
+	if !dryRun {
+		key := checkpointKey(ssoStartURL, accessToken)
+		if resumeMode {
+			checkpoint = loadCheckpoint(checkpointPath(), key)
+		}
+		if checkpoint == nil {
+			if resumeMode {
+				infof("%sNo checkpoint for the current token; syncing every account.\n", cyan(icon("info")))
+			}
+			checkpoint = &syncCheckpoint{Key: key, path: checkpointPath()}
+		}
+		var remaining []ssoTypesAccount
+		for _, account := range accounts {
+			if checkpoint.done(account.AccountId) {
+				resumed++
+				continue
+			}
+			remaining = append(remaining, account)
+		}
+		if resumed > 0 {
+			infof("%sResuming: skipping %d account(s) already processed.\n", cyan(icon("info")), resumed)
+		}
+		accounts = remaining
+	}
+	roles, err := getRolesForAccounts(accessToken, accounts, ssoRoleNames)
+	if err != nil {
+		errorf("%s%s %v\n", red(icon("error")), bold("Error fetching accounts:"), err)
+		return SyncResult{}, err
+	}
+	infof("\n%s%s %d account(s) with roles %s\n\n", cyan(icon("search")), bold("Found"), len(roles), describeRoleSelection())
+	if regionTagKey != "" {
+		applyRegionsFromTags(roles, regionTagKey)
+	}
+	if accountRegions != nil {
+		applyRegionMap(roles, accountRegions)
+	}
+	if roleSettings != nil {
+		reportUnknownMappedRoles(roles)
+	}
+	if nameCommand != "" {
+		if err := applyNameCommand(roles, nameCommand); err != nil {
+			errorf("%s%s %v\n", red(icon("error")), bold("Error:"), err)
+			return SyncResult{}, err
+		}
+	}
+	if profileNameTemplate != nil {
+		if err := applyProfileTemplate(roles, profileNameTemplate); err != nil {
+			errorf("%s%s %v\n", red(icon("error")), bold("Error:"), err)
+			return SyncResult{}, err
+		}
+	}
+	if err := checkProfileNameCollisions(roles); err != nil {
+		errorf("%s%s %v\n", red(icon("error")), bold("Error:"), err)
+		return SyncResult{}, err
+	}
+	if printRoleArns {
+		printRoleArnList(roles)
+		return SyncResult{}, nil
+	}
+	if healthCheck {
+		return SyncResult{}, runHealthCheck(accessToken, roles)
+	}
+	if planMode {
+		if err := printProfilePlan(roles, selectedAccounts); err != nil {
+			errorf("%s%s %v\n", red(icon("error")), bold("Error building plan:"), err)
+			return SyncResult{}, err
+		}
+		return SyncResult{}, nil
+	}
+	if profileGroupBy != nil {
+		sortRolesByGroup(roles, profileGroupBy)
+	}
+	if diffMode {
+		if err := printConfigDiff(roles, selectedAccounts); err != nil {
+			errorf("%s%s %v\n", red(icon("error")), bold("Error building diff:"), err)
+			return SyncResult{}, err
+		}
+		return SyncResult{}, nil
 	}
-	fmt.Printf("\n%s %s %d account(s) with roles %s\n\n", cyan("🔎"), bold("Found"), len(roles), strings.Join(ssoRoleNames, ", "))
 	awsConfigPath := ssoConfigFile
-	added := 0
-	skipped := 0
+	result := SyncResult{DryRun: dryRun, SessionName: ssoSessionConfigName, RoleNames: ssoRoleNames, AccountsTotal: len(accounts) + resumed}
+	if reportEmptyAccounts {
+		result.EmptyAccounts = findAccountsWithoutRoles(accounts, roles)
+	}
+	pending := make(map[string]int)
+	for _, role := range roles {
+		pending[role.AccountId]++
+	}
+	failedAccounts := make(map[string]bool)
+	// accountProcessed checkpoints an account once all its roles are handled.
+	accountProcessed := func(accountId string) {
+		pending[accountId]--
+		if pending[accountId] == 0 && !failedAccounts[accountId] {
+			if err := checkpoint.markDone(accountId); err != nil {
+				warnf("%sFailed to write checkpoint: %v\n", yellow(icon("warn")), err)
+			}
+		}
+	}
 	for _, role := range roles {
 		profileName := getProfileNameFromRole(role)
+		entry := ProfileResult{ProfileName: profileName, AccountId: role.AccountId, AccountName: role.AccountName, RoleName: role.RoleName}
+		if profileExists(profileName, awsConfigPath) && forceUpdate {
+			changes, err := profileChanges(profileName, role)
+			switch {
+			case err != nil:
+				errorf("%sFailed to read profile %s: %v\n", red(icon("error")), profileName, err)
+				result.Failed = append(result.Failed, entry)
+				emitProfileProgress("profile_failed", entry)
+				failedAccounts[role.AccountId] = true
+			case len(changes) == 0:
+				infof("%sSkipping profile: %s %s\n", yellow(icon("skip")), bold(profileName), "(up to date)")
+				result.Skipped = append(result.Skipped, entry)
+				emitProfileProgress("profile_skipped", entry)
+			case dryRun:
+				infof("%sWould update profile: %s\n", cyan(icon("edit")), bold(profileName))
+				printKeyChanges(changes)
+				result.Updated = append(result.Updated, entry)
+				emitProfileProgress("profile_updated", entry)
+			default:
+				infof("%sUpdating profile: %s\n", cyan(icon("edit")), bold(profileName))
+				printKeyChanges(changes)
+				if err := writeProfileToConfigFunc(profileName, role); err != nil {
+					errorf("%sFailed to write profile %s: %v\n", red(icon("error")), profileName, err)
+					result.Failed = append(result.Failed, entry)
+					emitProfileProgress("profile_failed", entry)
+					failedAccounts[role.AccountId] = true
+					break
+				}
+				result.Updated = append(result.Updated, entry)
+				emitProfileProgress("profile_updated", entry)
+			}
+			accountProcessed(role.AccountId)
+			continue
+		}
 		if profileExists(profileName, awsConfigPath) {
 			if dryRun {
-				fmt.Printf("%s Would skip profile: %s %s\n", yellow("➖"), bold(profileName), "(already exists)")
+				infof("%sWould skip profile: %s %s\n", yellow(icon("skip")), bold(profileName), "(already exists)")
 			} else {
-				fmt.Printf("%s Skipping profile: %s %s\n", yellow("➖"), bold(profileName), "(already exists)")
+				infof("%sSkipping profile: %s %s\n", yellow(icon("skip")), bold(profileName), "(already exists)")
 			}
-			skipped++
+			result.Skipped = append(result.Skipped, entry)
+			emitProfileProgress("profile_skipped", entry)
+			accountProcessed(role.AccountId)
 			continue
 		}
 		if dryRun {
-			fmt.Printf("%s Would add profile: %s (Account: %s, AccountId: %s, Role: %s)\n", green("➕"), bold(profileName), role.AccountName, role.AccountId, role.RoleName)
+			infof("%sWould add profile: %s (Account: %s, AccountId: %s, Role: %s)\n", green(icon("add")), bold(profileName), role.AccountName, role.AccountId, role.RoleName)
 		} else {
-			fmt.Printf("%s Adding profile: %s (Account: %s, AccountId: %s, Role: %s)\n", green("➕"), bold(profileName), role.AccountName, role.AccountId, role.RoleName)
+			infof("%sAdding profile: %s (Account: %s, AccountId: %s, Role: %s)\n", green(icon("add")), bold(profileName), role.AccountName, role.AccountId, role.RoleName)
 		}
 
 		// Write profile configuration directly to config file
-		if err := writeProfileToConfig(profileName, role); err != nil {
-			fmt.Printf("%s Failed to write profile %s: %v\n", red("❌"), profileName, err)
+		if err := writeProfileToConfigFunc(profileName, role); err != nil {
+			errorf("%sFailed to write profile %s: %v\n", red(icon("error")), profileName, err)
+			result.Failed = append(result.Failed, entry)
+			emitProfileProgress("profile_failed", entry)
+			failedAccounts[role.AccountId] = true
+			accountProcessed(role.AccountId)
 			continue
 		}
-		added++
+		result.Added = append(result.Added, entry)
+		emitProfileProgress("profile_added", entry)
+		accountProcessed(role.AccountId)
 	}
-	if dryRun {
-		fmt.Printf("\n%s %s %d profile(s) would be added, %d already configured.\n", cyan("📦"), bold("Dry-run summary:"), added, skipped)
-	} else {
-		fmt.Printf("\n%s %s %d new profile(s), %d already configured.\n", cyan("📦"), bold("Summary:"), added, skipped)
+	if len(result.Failed) > 0 {
+		printRestoreHint()
 	}
-	return nil
+	if len(result.Failed) == 0 {
+		if err := checkpoint.remove(); err != nil {
+			warnf("%sFailed to remove checkpoint: %v\n", yellow(icon("warn")), err)
+		}
+	}
+	if pruneMode && resumed > 0 {
+		warnf("%sSkipping -prune on a resumed run, as the skipped accounts' roles were not rediscovered.\n", yellow(icon("warn")))
+	} else if pruneMode {
+		pruned, err := pruneStaleProfiles(roles, selectedAccounts)
+		if err != nil {
+			errorf("%s%s %v\n", red(icon("error")), bold("Error pruning profiles:"), err)
+			return SyncResult{}, err
+		}
+		result.Pruned = pruned
+	}
+	if reportEmptyAccounts {
+		printEmptyAccounts(result.EmptyAccounts)
+	}
+	emitProgress("sync_complete", map[string]interface{}{
+		"dryRun":  result.DryRun,
+		"added":   len(result.Added),
+		"updated": len(result.Updated),
+		"skipped": len(result.Skipped),
+		"failed":  len(result.Failed),
+	})
+	result.Duration = time.Since(started)
+	return result, printSyncSummary(result)
+}
+
+// emitProgress writes one newline-delimited JSON progress event to
+// progressWriter (set by -progress-json). It is a no-op when progress output
+// is disabled.
+func emitProgress(event string, fields map[string]interface{}) {
+	if progressWriter == nil {
+		return
+	}
+	data := map[string]interface{}{"event": event}
+	for k, v := range fields {
+		data[k] = v
+	}
+	b, err := json.Marshal(data)
+	if err != nil {
+		return
+	}
+	progressMu.Lock()
+	defer progressMu.Unlock()
+	fmt.Fprintln(progressWriter, string(b))
+}
+
+// progressMu serializes writes to progressWriter from concurrent workers.
+var progressMu sync.Mutex
+
+// emitProfileProgress emits a progress event describing a single profile.
+func emitProfileProgress(event string, entry ProfileResult) {
+	emitProgress(event, map[string]interface{}{
+		"profile":   entry.ProfileName,
+		"account":   entry.AccountName,
+		"accountId": entry.AccountId,
+		"role":      entry.RoleName,
+		"dryRun":    dryRun,
+	})
+}
+
+// ProfileResult describes one profile handled during a sync run.
+type ProfileResult struct {
+	ProfileName string `json:"profileName"`
+	AccountId   string `json:"accountId"`
+	AccountName string `json:"accountName"`
+	RoleName    string `json:"roleName"`
+}
+
+// AccountResult identifies an account in a sync run.
+type AccountResult struct {
+	AccountId   string
+	AccountName string
+}
+
+// SyncResult summarizes a sync run. It is the data passed to
+// -summary-template.
+type SyncResult struct {
+	DryRun      bool
+	SessionName string
+	RoleNames   []string
+	Added       []ProfileResult
+	// Updated lists existing profiles rewritten by -force.
+	Updated []ProfileResult
+	Skipped []ProfileResult
+	Failed  []ProfileResult
+	// EmptyAccounts lists the accounts that passed the account filters but
+	// had none of the requested roles (populated with -report-empty-accounts).
+	EmptyAccounts []AccountResult
+	// Pruned lists the stale profiles removed (or, in dry-run, that would be
+	// removed) by -prune. Their AccountName is not known.
+	Pruned []ProfileResult
+	// AccountsTotal is the number of accounts that passed the account
+	// filters, including any skipped by -resume.
+	AccountsTotal int
+	// Duration is the time spent syncing, from account discovery to the
+	// summary (excluding sign-in).
+	Duration time.Duration
+}
+
+// findAccountsWithoutRoles returns the accounts for which no role was
+// selected, in discovery order.
+func findAccountsWithoutRoles(accounts []ssoTypesAccount, roles []CombinedRole) []AccountResult {
+	withRoles := make(map[string]bool)
+	for _, r := range roles {
+		withRoles[r.AccountId] = true
+	}
+	var empty []AccountResult
+	for _, a := range accounts {
+		if !withRoles[a.AccountId] {
+			empty = append(empty, AccountResult{AccountId: a.AccountId, AccountName: a.AccountName})
+		}
+	}
+	return empty
+}
+
+// printEmptyAccounts reports the accounts that produced no configured roles.
+func printEmptyAccounts(empty []AccountResult) {
+	if len(empty) == 0 {
+		resultf("\n%sEvery considered account had at least one matching role.\n", green(icon("ok")))
+		return
+	}
+	resultf("\n%s%d account(s) had none of the requested roles:\n", yellow(icon("warn")), len(empty))
+	for _, a := range empty {
+		resultf("    %s (AccountId: %s)\n", a.AccountName, a.AccountId)
+	}
+}
+
+// parseSummaryTemplate parses the -summary-template text.
+func parseSummaryTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("summary").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -summary-template: %v", err)
+	}
+	return tmpl, nil
+}
+
+// renderSummaryTemplate executes tmpl against result, ensuring the output
+// ends with a newline.
+func renderSummaryTemplate(tmpl *template.Template, result SyncResult) (string, error) {
+	var b strings.Builder
+	if err := tmpl.Execute(&b, result); err != nil {
+		return "", fmt.Errorf("rendering -summary-template: %v", err)
+	}
+	out := b.String()
+	if !strings.HasSuffix(out, "\n") {
+		out += "\n"
+	}
+	return out, nil
+}
+
+// printSyncSummary prints the end-of-run summary, using -summary-template
+// in place of the default lines when one was given.
+func printSyncSummary(result SyncResult) error {
+	if jsonSummaryPath != "" {
+		if err := writeJSONSummary(result); err != nil {
+			errorf("%s%s %v\n", red(icon("error")), bold("Error writing JSON summary:"), err)
+			return err
+		}
+	}
+	if metricsPath != "" {
+		if err := writeMetricsFile(metricsPath, result, time.Now()); err != nil {
+			errorf("%s%s %v\n", red(icon("error")), bold("Error writing metrics:"), err)
+			return err
+		}
+	}
+	if summaryTemplate != nil {
+		out, err := renderSummaryTemplate(summaryTemplate, result)
+		if err != nil {
+			errorf("%s%s %v\n", red(icon("error")), bold("Error:"), err)
+			return err
+		}
+		resultf("%s", out)
+		return nil
+	}
+	switch {
+	case result.DryRun && forceUpdate:
+		resultf("\n%s%s %d profile(s) would be added, %d updated, %d already up to date.\n", cyan(icon("summary")), bold("Dry-run summary:"), len(result.Added), len(result.Updated), len(result.Skipped))
+	case forceUpdate:
+		resultf("\n%s%s %d new profile(s), %d updated, %d already up to date.\n", cyan(icon("summary")), bold("Summary:"), len(result.Added), len(result.Updated), len(result.Skipped))
+	case result.DryRun:
+		resultf("\n%s%s %d profile(s) would be added, %d already configured.\n", cyan(icon("summary")), bold("Dry-run summary:"), len(result.Added), len(result.Skipped))
+	default:
+		resultf("\n%s%s %d new profile(s), %d already configured.\n", cyan(icon("summary")), bold("Summary:"), len(result.Added), len(result.Skipped))
+	}
+	if len(result.Pruned) > 0 {
+		if result.DryRun {
+			resultf("%s%d stale profile(s) would be removed.\n", cyan(icon("summary")), len(result.Pruned))
+		} else {
+			resultf("%s%d stale profile(s) removed.\n", cyan(icon("summary")), len(result.Pruned))
+		}
+	}
+	return nil
+}
+
+// jsonSummarySchemaVersion is bumped on incompatible changes to the
+// -json-summary document.
+const jsonSummarySchemaVersion = 1
+
+// jsonSummary is the document written by -json-summary.
+type jsonSummary struct {
+	SchemaVersion int             `json:"schemaVersion"`
+	DryRun        bool            `json:"dryRun"`
+	SessionName   string          `json:"sessionName"`
+	Added         []ProfileResult `json:"added"`
+	Updated       []ProfileResult `json:"updated"`
+	Skipped       []ProfileResult `json:"skipped"`
+	Failed        []ProfileResult `json:"failed"`
+	Removed       []ProfileResult `json:"removed"`
+}
+
+// writeJSONSummary writes the run's result as JSON to jsonSummaryOut when
+// -json-summary is "-", or to the file it names.
+func writeJSONSummary(result SyncResult) error {
+	orEmpty := func(p []ProfileResult) []ProfileResult {
+		if p == nil {
+			return []ProfileResult{}
+		}
+		return p
+	}
+	data, err := json.MarshalIndent(jsonSummary{
+		SchemaVersion: jsonSummarySchemaVersion,
+		DryRun:        result.DryRun,
+		SessionName:   result.SessionName,
+		Added:         orEmpty(result.Added),
+		Updated:       orEmpty(result.Updated),
+		Skipped:       orEmpty(result.Skipped),
+		Failed:        orEmpty(result.Failed),
+		Removed:       orEmpty(result.Pruned),
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if jsonSummaryPath == "-" {
+		_, err = jsonSummaryOut.Write(data)
+		return err
+	}
+	return os.WriteFile(jsonSummaryPath, data, 0o644)
 }
 
 // Check if the token is valid by attempting to list accounts
@@ -774,6 +2442,85 @@ func isSsoTokenValid(accessToken string) bool {
 	return isSsoTokenValidFunc(accessToken)
 }
 
+// tokenRemainingLifetime reads the expiresAt field of a token cache file and
+// returns how long the token remains valid. ok is false when the file has no
+// parseable expiry.
+func tokenRemainingLifetime(tokenPath string) (time.Duration, bool) {
+	data, err := os.ReadFile(tokenPath)
+	if err != nil {
+		return 0, false
+	}
+	var cache struct {
+		ExpiresAt string `json:"expiresAt"`
+	}
+	if err := json.Unmarshal(data, &cache); err != nil || cache.ExpiresAt == "" {
+		return 0, false
+	}
+	expiresAt, ok := parseTokenExpiry(cache.ExpiresAt)
+	if !ok {
+		return 0, false
+	}
+	return expiresAt.Sub(nowFunc()), true
+}
+
+// parseTokenExpiry parses a cached token's expiresAt value.
+func parseTokenExpiry(value string) (time.Time, bool) {
+	// Our writer uses RFC3339; older AWS CLI versions wrote a "UTC" suffix.
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05UTC"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// loginAndFetchToken runs the device authorization flow and then waits for
+// a valid token to appear in the cache, returning the token and its path.
+func loginAndFetchToken() (string, string, error) {
+	// Let runAwsSsoLogin handle displaying the verification URL, opening the
+	// browser (if requested), and starting polling. We avoid any blocking
+	// pre-login prompts here so the flow is non-blocking and consistent.
+	if err := runAwsSsoLogin(ssoSessionConfigName); err != nil {
+		return "", "", err
+	}
+
+	// After login, fetch the token again and check validity. Use the
+	// injectable getAccessTokenFunc so tests can simulate token arrival.
+	var lastErr error
+	for i := 0; i < 10; i++ {
+		accessToken, tokenPath, err := getAccessTokenFunc()
+		if err == nil && isSsoTokenValid(accessToken) {
+			return accessToken, tokenPath, nil
+		}
+		if err == nil {
+			err = fmt.Errorf("token at %s was rejected: %s", tokenPath, tokenRejectedReason())
+		}
+		lastErr = err
+		if err := sleepFunc(runContext, 500*time.Millisecond); err != nil {
+			return "", "", err
+		}
+	}
+	return "", "", fmt.Errorf("SSO login did not produce a valid access token: %v", lastErr)
+}
+
+// runWithTokenRetry runs op with the given token. When -auto-relogin is set
+// and op fails because the token stopped validating mid-run (e.g. it was
+// revoked), it performs one fresh device login and retries op exactly once.
+func runWithTokenRetry(accessToken string, op func(accessToken string) error) error {
+	err := op(accessToken)
+	if err == nil || !autoRelogin || isSsoTokenValid(accessToken) {
+		return err
+	}
+	warnf("%sToken was rejected during the run (%v); re-authenticating once because -auto-relogin is set.\n", yellow(icon("warn")), err)
+	infof("%sTo continue, you need to authenticate with AWS SSO in your browser to retrieve a new token.\n", yellow(icon("info")))
+	newToken, tokenPath, lerr := loginAndFetchToken()
+	if lerr != nil {
+		return fmt.Errorf("re-login after token rejection failed: %v (original error: %v)", lerr, err)
+	}
+	infof("%sSuccessfully obtained access token for SSO session at: %s\n", green(icon("ok")), tokenPath)
+	return op(newToken)
+}
+
 // Handle login and token retrieval
 func login() error {
 	// Do not configure the sso-session up-front here. We only need to ensure
@@ -784,46 +2531,93 @@ func login() error {
 	// dry-run header is printed in main(); avoid duplicate messages here.
 
 	accessToken, tokenPath, err := getAccessTokenFunc()
+	refreshed := false
+	if errors.Is(err, errTokenExpired) && tokenPath != "" {
+		warnf("%sCached SSO token at %s has expired.\n", yellow(icon("warn")), tokenPath)
+		// The access token is past its expiry, but its refresh token may
+		// still be good.
+		if token, ok := tryRefreshAccessToken(tokenPath); ok {
+			accessToken, err, refreshed = token, nil, true
+		}
+	}
 	if err == nil {
-		fmt.Printf("%s Found existing SSO token at: %s (🌐 ssoUrl: %s, 📍 ssoRegion: %s)\n",
-			cyan("🔑"),
+		infof("%sFound existing SSO token at: %s (%sssoUrl: %s, %sssoRegion: %s)\n",
+			cyan(icon("token")),
 			tokenPath,
+			icon("url"),
 			ssoStartURL,
+			icon("location"),
 			ssoRegion,
 		)
-		if isSsoTokenValid(accessToken) {
-			fmt.Printf("%s Existing token is valid, continuing...\n", green("✅"))
-			// If the session name wasn't explicitly provided, try to detect a
-			// matching sso-session in the config and print the block we will
-			// reuse. This is printed here so it appears after the header and
-			// after confirming the token is valid (in context).
-			if ssoSessionConfigName == defaultSSOSessionConfigName || ssoSessionConfigName == "" {
-				// Look for all matching sessions. If exactly one exists, reuse
-				// it and print a concise line. If multiple exist, instruct the
-				// user to disambiguate with --sso-session-name.
-				if matches, err := findAllMatchingSsoSessionNames(ssoStartURL, ssoRegion, ssoConfigFile); err == nil {
-					if len(matches) == 1 {
-						ssoSessionConfigName = matches[0]
-						fmt.Printf("\n%s Reusing SSO session configuration %s because -sso-session-name was not provided\n\n", cyan("📝"), bold(ssoSessionConfigName))
-					} else if len(matches) > 1 {
-						fmt.Printf("%s Multiple matching sso-session blocks found (%d). Please pass -sso-session-name to select one, or remove duplicates. Matches: %s\n", red("❌"), len(matches), strings.Join(matches, ", "))
-						return fmt.Errorf("multiple matching sso-session blocks found for startUrl %s and region %s", ssoStartURL, ssoRegion)
+		// A just-refreshed token was already validated by the refresh.
+		valid := refreshed || isSsoTokenValid(accessToken)
+		if !valid {
+			warnf("%sExisting %s.\n", yellow(icon("warn")), tokenRejectedReason())
+			// Renew with the cached refresh token before falling back to the
+			// device flow.
+			accessToken, valid = tryRefreshAccessToken(tokenPath)
+		}
+		if valid {
+			remaining, ok := tokenRemainingLifetime(tokenPath)
+			short := ok && remaining < minTokenLifetime
+			if short && !dryRun {
+				// A long sync would outlive this token; get a fresh one up front
+				// rather than failing part-way through.
+				warnf("%sExisting token expires in %s, below -min-token-lifetime %s; re-authenticating so the sync can complete.\n",
+					yellow(icon("warn")),
+					remaining.Round(time.Second),
+					minTokenLifetime,
+				)
+			} else {
+				if short {
+					// A dry-run never logs in, so it keeps the short-lived token.
+					warnf("%sExisting token is valid but expires in %s, below -min-token-lifetime %s; a real run would log in again first.\n",
+						yellow(icon("warn")),
+						remaining.Round(time.Second),
+						minTokenLifetime,
+					)
+				} else {
+					infof("%sExisting token is valid, continuing...\n", green(icon("ok")))
+				}
+				// If the session name wasn't explicitly provided, try to detect a
+				// matching sso-session in the config and print the block we will
+				// reuse. This is printed here so it appears after the header and
+				// after confirming the token is valid (in context).
+				if ssoSessionConfigName == defaultSSOSessionConfigName || ssoSessionConfigName == "" {
+					// Look for all matching sessions. If exactly one exists, reuse
+					// it and print a concise line. If multiple exist, instruct the
+					// user to disambiguate with --sso-session-name.
+					if err := reuseMatchingSession("\n"); err != nil {
+						return err
 					}
 				}
+				if !rolesRequested() {
+					// No roles requested; let caller (main) handle listing available
+					// roles so we don't print found/summary blocks here.
+					return nil
+				}
+				// The profiles reference the session, so make sure its block
+				// exists even though no login was needed.
+				if err := configureSsoSessionConfig(); err != nil {
+					return err
+				}
+				return runWithTokenRetry(accessToken, configureSsoProfilesFunc)
 			}
-			if len(ssoRoleNames) == 0 {
-				// No roles requested; let caller (main) handle listing available
-				// roles so we don't print found/summary blocks here.
-				return nil
-			}
-			return configureSsoProfilesFunc(accessToken)
-		} else {
-			fmt.Println(yellow("⚠️ Existing token is invalid or expired."))
 		}
+	} else if errors.Is(err, errTokenExpired) {
+		warnf("%sSSO token expired (%sssoUrl: %s, %sssoRegion: %s).\n",
+			yellow(icon("warn")),
+			icon("url"),
+			ssoStartURL,
+			icon("location"),
+			ssoRegion,
+		)
 	} else {
-		fmt.Printf("%s No valid SSO token found (🌐 ssoUrl: %s, 📍 ssoRegion: %s).\n",
-			yellow("⚠️"),
+		warnf("%sNo valid SSO token found (%sssoUrl: %s, %sssoRegion: %s).\n",
+			yellow(icon("warn")),
+			icon("url"),
 			ssoStartURL,
+			icon("location"),
 			ssoRegion,
 		)
 	}
@@ -835,7 +2629,7 @@ func login() error {
 		// elsewhere because functions respect `dryRun`). Ensure the sso-session
 		// block exists right before invoking the login so any printed "Would add"
 		// blocks appear in the right place in the output.
-		fmt.Printf("%s %s\n", yellow("ℹ️"), bold("Dry-run: no valid token found; will invoke AWS SSO login to obtain a token for discovery (no files will be written)."))
+		infof("%s%s\n", yellow(icon("info")), bold("Dry-run: no valid token found; will invoke AWS SSO login to obtain a token for discovery (no files will be written)."))
 	}
 
 	// Ensure the sso-session config exists before invoking `aws sso login`.
@@ -848,42 +2642,18 @@ func login() error {
 		}
 	}
 
-	fmt.Printf("%s To continue, you need to authenticate with AWS SSO in your browser to retrieve a new token.\n", yellow("ℹ️"))
-	// Let runAwsSsoLogin handle displaying the verification URL, opening the
-	// browser (if requested), and starting polling. We avoid any blocking
-	// pre-login prompts here so the flow is non-blocking and consistent.
-	if err := runAwsSsoLogin(ssoSessionConfigName); err != nil {
+	infof("%sTo continue, you need to authenticate with AWS SSO in your browser to retrieve a new token.\n", yellow(icon("info")))
+	accessToken, tokenPath, err = loginAndFetchToken()
+	if err != nil {
 		return err
 	}
-
-	// After login, fetch the token again and check validity. Use the
-	// injectable getAccessTokenFunc so tests can simulate token arrival.
-	var lastErr error
-	for i := 0; i < 10; i++ {
-		accessToken, tokenPath, err = getAccessTokenFunc()
-		if err == nil && isSsoTokenValid(accessToken) {
-			lastErr = nil
-			break
-		}
-		lastErr = err
-		time.Sleep(500 * time.Millisecond)
-	}
-	if lastErr != nil {
-		return fmt.Errorf("SSO login did not produce a valid access token: %v", lastErr)
-	}
-	fmt.Printf("%s Successfully obtained access token for SSO session at: %s\n", green("✅"), tokenPath)
+	infof("%sSuccessfully obtained access token for SSO session at: %s\n", green(icon("ok")), tokenPath)
 	// After we have a token, try to detect an existing matching sso-session
 	// in the user's config and prefer reusing it if present. This makes the
 	// behavior consistent whether dry-run is set or not.
 	if ssoSessionConfigName == defaultSSOSessionConfigName || ssoSessionConfigName == "" {
-		if matches, err := findAllMatchingSsoSessionNames(ssoStartURL, ssoRegion, ssoConfigFile); err == nil {
-			if len(matches) == 1 {
-				ssoSessionConfigName = matches[0]
-				fmt.Printf("%s Reusing SSO session configuration %s because -sso-session-name was not provided\n\n", cyan("📝"), bold(ssoSessionConfigName))
-			} else if len(matches) > 1 {
-				fmt.Printf("%s Multiple matching sso-session blocks found (%d). Please pass -sso-session-name to select one, or remove duplicates. Matches: %s\n", red("❌"), len(matches), strings.Join(matches, ", "))
-				return fmt.Errorf("multiple matching sso-session blocks found for startUrl %s and region %s", ssoStartURL, ssoRegion)
-			}
+		if err := reuseMatchingSession(""); err != nil {
+			return err
 		}
 	}
 	// Now ensure/print the sso-session config block (this will be a no-op
@@ -893,37 +2663,560 @@ func login() error {
 		return err
 	}
 
-	if len(ssoRoleNames) == 0 {
+	if !rolesRequested() {
 		// Caller will list available roles; avoid printing found/summary here.
 		return nil
 	}
 
-	return configureSsoProfilesFunc(accessToken)
+	return runWithTokenRetry(accessToken, configureSsoProfilesFunc)
 }
 
-func main() {
-	// Parse command line flags
-	var roleNames stringSliceFlag
-	flag.Var(&roleNames, "role", "SSO role name to include (can be specified multiple times)")
-	flag.StringVar(&profilePrefix, "prefix", "", "Custom profile prefix (leave empty for auto-generated from role name)")
-	flag.BoolVar(&useAutoPrefix, "auto-prefix", true, "Auto-generate prefix from role name (strips AWS and Access)")
-	flag.BoolVar(&dryRun, "dry-run", false, "Show what would be done without making any changes")
-	flag.BoolVar(&openBrowser, "open", true, "Automatically open the verification URL in the default browser during device authorization")
-	flag.StringVar(&profileOutput, "output", "json", "Default output format written into profiles (e.g. json, text)")
+// syncFlags holds the values of the sync flags that are not stored directly
+// in package configuration variables.
+type syncFlags struct {
+	roleNames           stringSliceFlag
+	excludeRoleNames    stringSliceFlag
+	roleRegexes         stringSliceFlag
+	abbrevs             stringSliceFlag
+	accountTags         stringSliceFlag
+	accountIds          stringSliceFlag
+	keyNames            stringSliceFlag
+	version             bool
+	extraKeys           stringSliceFlag
+	useDefaultRoles     bool
+	progressJSON        bool
+	legacy              bool
+	listPermSets        bool
+	filterExpr          string
+	summaryTemplateText string
+	profileTemplate     string
+	groupBy             string
+	managedPattern      string
+	accountNameRegex    string
+	legacyKeys          string
+	regionMap           string
+	fromSnapshot        string
+	bootstrapTemplate   string
+	roleMapFile         string
+	timeout             time.Duration
+	setRegion           string
+	configURL           string
+	configPath          string
+	startURLs           string
+	diffAgainst         string
+	supportBundle       string
+	rolesFromSSM        string
+	settingsFile        string
+	configCacheTTL      time.Duration
+}
+
+// registerSyncFlags registers every flag of the main sync flow on fs. Keeping
+// them in one place lets the completion subcommand enumerate them.
+func registerSyncFlags(fs *flag.FlagSet) *syncFlags {
+	f := &syncFlags{}
+	fs.Var(&f.roleNames, "role", "SSO role name to include (can be specified multiple times; use role@accountId to limit it to one account)")
+	fs.Var(&f.roleRegexes, "role-regex", "Go regular expression; roles whose name matches are included in addition to any -role (can be specified multiple times)")
+	fs.Var(&f.excludeRoleNames, "exclude-role", "SSO role name to leave out (can be specified multiple times); without -role, every other role is configured")
+	fs.BoolVar(&f.useDefaultRoles, "defaults", false, "Include the default permission set roles (AWSReadOnlyAccess, AWSAdministratorAccess, AWSPowerUserAccess) in addition to any -role flags")
+	fs.StringVar(&f.profileTemplate, "profile-template", "", "Go text/template for profile names with {{.AccountName}}, {{.AccountId}}, {{.RoleName}}, {{.Prefix}} and the lower, upper and replace functions, e.g. '{{.AccountId}}-{{lower .RoleName}}' (overrides -name-style)")
+	fs.StringVar(&nameCommand, "name-command", "", "Shell command that names each profile: it gets {\"accountId\",\"accountName\",\"roleName\"} as JSON on stdin and prints the profile name (overrides -prefix and -name-style)")
+	fs.StringVar(&profilePrefix, "prefix", "", "Custom profile prefix (leave empty for auto-generated from role name)")
+	fs.BoolVar(&useAutoPrefix, "auto-prefix", true, "Auto-generate prefix from role name (strips AWS and Access)")
+	fs.StringVar(&f.filterExpr, "filter", "", "JMESPath expression evaluated against each {accountId, accountName, roleName}; only truthy matches are configured")
+	fs.StringVar(&f.summaryTemplateText, "summary-template", "", "Go template rendered against the run's SyncResult in place of the default summary")
+	fs.BoolVar(&compactNames, "compact-names", false, "Abbreviate common words in generated profile names (e.g. Production->prod, ReadOnly->ro)")
+	fs.Var(&f.abbrevs, "abbrev", "Additional -compact-names abbreviation as word=short (overrides built-ins; can be specified multiple times)")
+	fs.Var(&f.accountIds, "account-id", "Only configure this account id (exact match; can be specified multiple times)")
+	fs.StringVar(&f.accountNameRegex, "account-name-regex", "", "Only configure accounts whose name matches this Go regular expression")
+	fs.Var(&f.accountTags, "account-tag", "Only configure accounts with this Organizations tag, as key=value (needs organizations:ListTagsForResource with ambient credentials; can be specified multiple times)")
+	fs.StringVar(&f.groupBy, "group-by", "", "Write '# ===== <group> =====' separators above groups of new profiles; pattern:<regexp> groups by account name, map:<account>=<group>,... by account id or name")
+	fs.StringVar(&f.managedPattern, "managed-pattern", "", "Regexp of profile names to treat as managed in addition to those using -sso-session-name, e.g. to cover hand-created legacy profiles in -plan and -set-region")
+	fs.StringVar(&f.roleMapFile, "role-map-file", "", "JSON file of per-role settings ({\"roles\": {\"<role>\": {\"alias\", \"prefix\", \"output\", \"region\", \"duration_seconds\"}}}) used for naming and writing")
+	fs.StringVar(&f.regionMap, "region-map", "", "JSON or INI file mapping account ids to the region written into their profiles (takes precedence over -region-from-tag; other accounts use -sso-region)")
+	fs.StringVar(&regionSource, "region-source", regionSourceProfile, "Where the region lives: profile writes region into every profile, session-only leaves it out so the CLI uses AWS_REGION or the default profile's region")
+	fs.StringVar(&regionTagKey, "region-from-tag", "", "Write each profile's region from this AWS Organizations account tag (e.g. home_region), falling back to -sso-region")
+	fs.IntVar(&accountConcurrency, "concurrency", 1, "Number of accounts whose roles are fetched in parallel")
+	fs.IntVar(&maxThrottleRetries, "max-retries", 5, "How many times a throttled SSO call (TooManyRequestsException or HTTP 429) is retried with backoff before the run fails")
+	fs.BoolVar(&reportEmptyAccounts, "report-empty-accounts", false, "After selection, list accounts that had none of the requested roles")
+	fs.StringVar(&nameStyle, "name-style", nameStyleRoleAccount, "Profile name composition: role-account, account-only or role-only")
+	fs.StringVar(&listFormat, "list-format", listFormatInline, "Layout of the per-account role listing shown without -role: inline, lines (one role per line) or table")
+	fs.BoolVar(&dryRun, "dry-run", false, "Show what would be done without making any changes")
+	fs.BoolVar(&credentialProcessMode, "credential-process", false, "Write profiles with a credential_process that runs this tool's print-credentials subcommand instead of sso_session keys")
+	fs.BoolVar(&forceUpdate, "force", false, "Rewrite the keys of existing profiles instead of skipping them (dry-run shows which keys would change)")
+	fs.BoolVar(&pruneMode, "prune", false, "After syncing, remove profiles of the SSO session whose account/role pair was not discovered in this run")
+	fs.StringVar(&pruneScopePrefix, "prune-scope-prefix", "", "With -prune, only remove managed profiles whose names start with this prefix")
+	fs.BoolVar(&resumeMode, "resume", false, "Skip accounts already processed by an interrupted run with the same token, as recorded in <config-file>.sync-checkpoint")
+	fs.BoolVar(&printRoleArns, "print-role-arns", false, "Print the best-effort IAM role ARN pattern of each selected role instead of writing profiles (the AWSReservedSSO suffix is not exposed and printed as *)")
+	fs.StringVar(&jsonSummaryPath, "json-summary", "", "Write a JSON summary of added, skipped, failed and pruned profiles to this file, or to stdout with - (all other output then goes to stderr)")
+	fs.StringVar(&metricsPath, "metrics-file", "", "Write Prometheus textfile metrics (profiles added/skipped/removed, accounts, duration, last success) to this file after a sync")
+	fs.StringVar(&dumpRolesFormat, "dump-roles", "", "Write the discovered account/role matrix to stdout as json or csv and exit without configuring profiles (all other output goes to stderr)")
+	fs.StringVar(&exportPath, "export", "", "Write every account and all of its roles to this JSON snapshot file instead of configuring profiles")
+	fs.StringVar(&f.fromSnapshot, "from-snapshot", "", "Replay account and role discovery from a snapshot written by -export instead of calling AWS")
+	fs.BoolVar(&estimateMode, "estimate", false, "Report how many SSO API calls a full run would make and roughly how long it would take, using only ListAccounts (implies -dry-run)")
+	fs.BoolVar(&healthCheck, "health-check", false, "Probe GetRoleCredentials for the selected roles and report how many work, without writing anything (implies -dry-run)")
+	fs.IntVar(&healthCheckSample, "health-check-sample", 0, "With -health-check, probe only this many randomly chosen roles (0 probes all)")
+	fs.StringVar(&f.diffAgainst, "diff-against", "", "Compare the managed profiles of the config file with those in this baseline config, print the differences and exit (1 when they differ; no AWS access)")
+	fs.BoolVar(&planMode, "plan", false, "Show a +/~/- plan of profile additions, updates and removals without making changes (implies -dry-run)")
+	fs.BoolVar(&f.version, "version", false, "Print the version, commit, build date, Go and AWS SDK versions and exit (see the version subcommand for JSON)")
+	fs.BoolVar(&dedupeNames, "dedupe-names", false, "When two roles would get the same profile name, append the role name (and if needed the account ID) to the later one instead of failing")
+	fs.BoolVar(&diffMode, "diff", false, "Show a unified diff of the config file before and after the sync without making changes (implies -dry-run)")
+	fs.DurationVar(&lockTimeout, "lock-timeout", 30*time.Second, "How long to wait for another run's lock on <config>.lock before failing")
+	fs.DurationVar(&browserOpenTimeout, "browser-open-timeout", 5*time.Second, "How long to wait for the browser launcher (open, xdg-open, rundll32) to report success or failure with -open before assuming the browser opened")
+	fs.BoolVar(&openBrowser, "open", true, "Automatically open the verification URL in the default browser during device authorization (defaults to false on headless/SSH sessions)")
+	fs.StringVar(&userCodeFile, "code-file", "", "Also write the device authorization user code to this file (the URL and code are always printed to stderr)")
+	fs.BoolVar(&allowInsecureURL, "allow-insecure-url", false, "Accept any http or https -sso-start-url instead of only AWS access portal URLs (for testing against mock endpoints)")
+	fs.StringVar(&endpointURL, "endpoint-url", "", "Send SSO OIDC and portal API calls to this base URL instead of the AWS endpoint (e.g. a local mock for air-gapped testing)")
+	fs.DurationVar(&f.timeout, "timeout", 0, "Abort the whole run, including waiting for browser authorization, after this long (e.g. 2m; 0 means no limit)")
+	fs.DurationVar(&minTokenLifetime, "min-token-lifetime", 2*time.Minute, "Re-authenticate before syncing if the cached token expires sooner than this")
+	fs.BoolVar(&cliCompatibleCache, "cli-compatible-cache", false, "Cache a new token under the AWS CLI's file name, with its client registration and refresh token fields, so aws sso login and this tool share it")
+	fs.BoolVar(&autoRelogin, "auto-relogin", false, "If the token is rejected mid-run, re-authenticate once and retry")
+	fs.BoolVar(&f.progressJSON, "progress-json", false, "Stream newline-delimited JSON progress events to stderr")
+	fs.StringVar(&profileOutput, "output", "json", "Default output format written into profiles (e.g. json, text), or inherit to omit the key")
+	fs.BoolVar(&f.legacy, "legacy", false, "Write legacy profiles with inline SSO settings instead of an sso_session reference")
+	fs.StringVar(&f.legacyKeys, "legacy-keys", "", "Comma-separated SSO keys written into -legacy profiles, from sso_start_url, sso_region, sso_account_id and sso_role_name (implies -legacy; default all four)")
+	fs.Var(&f.keyNames, "key-name", "Override a written profile key name as logical=actual (e.g. sso_account_id=account_id; can be specified multiple times)")
+	fs.Var(&f.extraKeys, "extra-key", "Write KEY=VALUE into every generated profile (e.g. cli_pager= or ca_bundle=/path/ca.pem; can be specified multiple times; replacing a managed key needs -force)")
 
 	// SSO configuration flags
-	flag.StringVar(&ssoStartURL, "sso-start-url", "", "AWS SSO start URL (required)")
-	flag.StringVar(&ssoSessionConfigName, "sso-session-name", defaultSSOSessionConfigName, "SSO session configuration name")
-	flag.StringVar(&ssoRegion, "sso-region", defaultSSORegion, "AWS SSO region")
-	flag.StringVar(&ssoConfigFile, "config-file", config.DefaultSharedConfigFilename(), "AWS config file path")
+	registerSsoFlags(fs)
+	registerOutputFlags(fs)
+	fs.BoolVar(&strictTokenMatch, "strict-token-match", false, "Only use a cached token whose start URL and region both match the requested ones exactly (after normalizing a trailing slash)")
+	fs.BoolVar(&interactiveSessionResolution, "interactive-session-resolution", true, "When several sso-session blocks match and -prefer-session is not set, ask which to reuse if stdin is a terminal")
+	fs.StringVar(&preferSession, "prefer-session", "", "When several sso-session blocks match the start URL and region, reuse the one with this name")
+	fs.StringVar(&f.bootstrapTemplate, "bootstrap-template", "", "INI file written as the config file before anything else when the config file does not exist yet (never used for an existing file)")
+	fs.BoolVar(&annotateSession, "annotate-session", true, "Write '# region' and '# created-by' comments above newly created sso-session blocks")
+	fs.BoolVar(&backupConfig, "backup", true, "Copy the config file to <config-file>.bak-<timestamp> before the first change of a run (skipped in dry-run)")
+	fs.BoolVar(&sessionOnly, "session-only", false, "Only set up the [sso-session] block (reusing a matching one) and sign in if needed; no profiles are written")
+	fs.BoolVar(&fixScopes, "fix-scopes", false, "Add the sso:account:access scope to a reused sso-session whose sso_registration_scopes lacks it (without this flag only a warning is printed)")
+	fs.BoolVar(&sessionNameFromURL, "session-name-from-url", false, "When -sso-session-name is not given and no matching session exists, name the new sso-session after the start URL's host (e.g. acme for https://acme.awsapps.com/start) instead of default")
+	fs.StringVar(&f.startURLs, "sso-start-urls", "", "Comma-separated start URLs of several IAM Identity Center instances; each is logged in to and synced in turn with its own sso-session named after the URL's host (requires a role selection)")
+	fs.BoolVar(&allowExternalConfig, "allow-external-config", false, "Allow -config-file to point outside the ~/.aws directory")
+	fs.StringVar(&profilesDir, "profiles-dir", "", "Write each profile to its own <profile>.conf file in this directory, and the sso-session block to sso-session.conf there, instead of the config file")
+
+	fs.BoolVar(&f.listPermSets, "list-permission-sets", false, "Admin mode: list every Identity Center permission set and the accounts it is provisioned to using ambient AWS credentials (not the SSO token), then exit")
+	fs.StringVar(&f.setRegion, "set-region", "", "Maintenance mode: update only the region key of existing profiles for the SSO session, then exit")
+	fs.StringVar(&f.settingsFile, "settings-file", "", "YAML file of flag-name: value defaults (default ~/.config/aws-sso-profile-sync/config.yaml, ignored when missing). Precedence: command-line flags, then this file, then -config or -config-from-url, then built-in defaults")
+	fs.StringVar(&f.rolesFromSSM, "roles-from-ssm", "", "SSM parameter holding role names to sync (JSON array, StringList or one per line), merged with -role; read with ambient AWS credentials")
+	fs.StringVar(&f.supportBundle, "support-bundle", "", "Write a zip for bug reports to this path (redacted SSO config sections, token cache listing without tokens, resolved flags, version) and exit")
+	fs.StringVar(&f.configURL, "config-from-url", "", "HTTPS URL of a declarative sync config (JSON) supplying defaults for flags not set on the command line")
+	fs.StringVar(&f.configPath, "config", "", "Local declarative sync config (JSON, single or multi-geography form, as for -config-from-url) supplying defaults for flags not set on the command line")
+	fs.DurationVar(&f.configCacheTTL, "config-cache-ttl", 5*time.Minute, "How long a config fetched with -config-from-url is reused from the local cache")
+
+	return f
+}
+
+// registerSsoFlags registers the SSO configuration flags shared by the main
+// sync flow and the subcommands on the given flag set.
+func registerSsoFlags(fs *flag.FlagSet) {
+	fs.StringVar(&ssoStartURL, "sso-start-url", "", "AWS SSO start URL (required)")
+	fs.StringVar(&ssoSessionConfigName, "sso-session-name", defaultSSOSessionConfigName, "SSO session configuration name")
+	fs.StringVar(&ssoRegion, "sso-region", defaultSSORegion, "AWS SSO region")
+	fs.StringVar(&ssoConfigFile, "config-file", config.DefaultSharedConfigFilename(), "AWS config file path")
+}
+
+func main() {
+	// Subcommands are selected by the first positional argument and parse
+	// their own flags.
+	if len(os.Args) > 1 {
+		if run, ok := subcommands[os.Args[1]]; ok {
+			os.Exit(run(os.Args[2:]))
+		}
+	}
 
+	opts := registerSyncFlags(flag.CommandLine)
+	flag.Usage = func() { printUsage(flag.CommandLine) }
 	flag.Parse()
+	if opts.version {
+		fmt.Print(formatBuildInfo(currentBuildInfo()))
+		os.Exit(exitOK)
+	}
+
+	// Settings apply before validation so -sso-start-url and the rest can
+	// come from the environment or the file; flags given on the command line
+	// still win.
+	if err := applyEnvFallbacks(flag.CommandLine, os.LookupEnv); err != nil {
+		errorf("%s%s %v\n", red(icon("error")), bold("Error applying environment variables:"), err)
+		os.Exit(exitValidationError)
+	}
+	if err := loadSettingsFile(opts.settingsFile, flag.CommandLine); err != nil {
+		errorf("%s%s %v\n", red(icon("error")), bold("Error loading settings file:"), err)
+		os.Exit(exitValidationError)
+	}
+
+	// Keep stdout clean for the JSON summary by sending everything else to
+	// stderr.
+	if dumpRolesFormat != "" {
+		if err := validateDumpRolesFormat(dumpRolesFormat); err != nil {
+			errorf("%s%s %v\n", red(icon("error")), bold("Error:"), err)
+			os.Exit(exitValidationError)
+		}
+		if jsonSummaryPath == "-" {
+			errorf("%s%s -dump-roles and -json-summary - both write to stdout\n", red(icon("error")), bold("Error:"))
+			os.Exit(exitValidationError)
+		}
+		// Like -json-summary -, keep stdout for the dump alone.
+		dumpRolesOut = os.Stdout
+		os.Stdout = os.Stderr
+	}
+	if jsonSummaryPath == "-" {
+		jsonSummaryOut = os.Stdout
+		os.Stdout = os.Stderr
+	}
+
+	// Every AWS call and the device authorization wait run under this
+	// context, so Ctrl-C and -timeout stop them promptly.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if opts.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.timeout)
+		defer cancel()
+	}
+	runContext = ctx
+
+	if opts.configPath != "" && opts.configURL != "" {
+		errorf("%s%s -config and -config-from-url cannot be combined\n", red(icon("error")), bold("Error:"))
+		os.Exit(exitValidationError)
+	}
+	if opts.configPath != "" {
+		cfg, err := loadSyncConfigFile(opts.configPath)
+		if err == nil {
+			err = applySyncConfig(cfg, flag.CommandLine)
+		}
+		if err != nil {
+			errorf("%s%s %v\n", red(icon("error")), bold("Error loading -config:"), err)
+			os.Exit(exitRuntimeError)
+		}
+	}
+	if opts.configURL != "" {
+		client, err := newConfigHTTPClient()
+		if err == nil {
+			var cfg syncConfig
+			cfg, err = fetchSyncConfig(opts.configURL, client, opts.configCacheTTL)
+			if err == nil {
+				err = applySyncConfig(cfg, flag.CommandLine)
+			}
+		}
+		if err != nil {
+			errorf("%s%s %v\n", red(icon("error")), bold("Error loading -config-from-url:"), err)
+			os.Exit(exitRuntimeError)
+		}
+	}
+
+	// The bundle is written before validation so it can be produced for a
+	// run that fails it.
+	if opts.supportBundle != "" {
+		if err := writeSupportBundle(opts.supportBundle, flag.CommandLine); err != nil {
+			errorf("%s%s %v\n", red(icon("error")), bold("Error writing support bundle:"), err)
+			os.Exit(exitRuntimeError)
+		}
+		resultf("%sWrote support bundle to %s. Review it before attaching it to a bug report.\n", green(icon("ok")), opts.supportBundle)
+		os.Exit(exitOK)
+	}
+
+	// Comparing against a baseline only reads config files, so it needs
+	// no start URL either.
+	if opts.diffAgainst != "" {
+		os.Exit(runDiffAgainst(opts.diffAgainst, !flagWasSet(flag.CommandLine, "sso-session-name")))
+	}
+
+	// A plan, a diff, an estimate, an ARN listing, an export, a role dump or
+	// a health check never writes the config.
+	if planMode || diffMode || estimateMode || printRoleArns || exportPath != "" || dumpRolesFormat != "" || healthCheck {
+		dryRun = true
+	}
+
+	if opts.listPermSets {
+		// Admin mode talks to the Identity Center admin API with ambient
+		// credentials; it needs no start URL, SSO login or config file.
+		infof("%s\n", cyan("\n========== IAM Identity Center Permission Sets =========="))
+		if err := listPermissionSets(); err != nil {
+			errorf("%s%s %v\n", red(icon("error")), bold("Error listing permission sets:"), err)
+			os.Exit(exitRuntimeError)
+		}
+		os.Exit(exitOK)
+	}
+
+	if opts.startURLs != "" {
+		startURLs = parseStartURLs(ssoStartURL, opts.startURLs)
+		if len(startURLs) > 0 {
+			ssoStartURL = startURLs[0]
+		}
+	}
 
 	// Validate required flags
-	if ssoStartURL == "" {
-		fmt.Printf("%s %s\n", red("❌"), bold("Error: -sso-start-url is required (tenant-specific, cannot be guessed)"))
+	if ssoStartURL == "" && len(geographies) == 0 {
+		errorf("%s%s\n", red(icon("error")), bold("Error: -sso-start-url is required (tenant-specific, cannot be guessed)"))
 		flag.Usage()
-		os.Exit(1)
+		os.Exit(exitValidationError)
+	}
+
+	// Every start URL of the run is checked before anything contacts AWS.
+	toValidate := append([]string{ssoStartURL}, startURLs...)
+	for _, g := range geographies {
+		toValidate = append(toValidate, g.StartURL)
+	}
+	for _, u := range toValidate {
+		if u == "" {
+			continue
+		}
+		if err := validateStartURL(u); err != nil {
+			errorf("%s%s %v\n", red(icon("error")), bold("Error:"), err)
+			os.Exit(exitValidationError)
+		}
+	}
+
+	if err := validateNameStyle(nameStyle); err != nil {
+		errorf("%s%s %v\n", red(icon("error")), bold("Error:"), err)
+		os.Exit(exitValidationError)
+	}
+
+	if err := validateListFormat(listFormat); err != nil {
+		errorf("%s%s %v\n", red(icon("error")), bold("Error:"), err)
+		os.Exit(exitValidationError)
+	}
+
+	if err := validateRegionSource(regionSource); err != nil {
+		errorf("%s%s %v\n", red(icon("error")), bold("Error:"), err)
+		os.Exit(exitValidationError)
+	}
+	if regionSource == regionSourceSessionOnly {
+		for _, name := range []string{"region-map", "region-from-tag", "set-region"} {
+			if flagWasSet(flag.CommandLine, name) {
+				errorf("%s%s -%s cannot be combined with -region-source=%s\n", red(icon("error")), bold("Error:"), name, regionSourceSessionOnly)
+				os.Exit(exitValidationError)
+			}
+		}
+	}
+
+	if pruneScopePrefix != "" && !pruneMode {
+		errorf("%s%s -prune-scope-prefix requires -prune\n", red(icon("error")), bold("Error:"))
+		os.Exit(exitValidationError)
+	}
+
+	if healthCheckSample < 0 {
+		errorf("%s%s -health-check-sample must not be negative\n", red(icon("error")), bold("Error:"))
+		os.Exit(exitValidationError)
+	}
+
+	if maxThrottleRetries < 0 {
+		errorf("%s%s -max-retries must not be negative\n", red(icon("error")), bold("Error:"))
+		os.Exit(exitValidationError)
+	}
+
+	// Default -open to false on headless machines where launching a browser
+	// would fail or hang; an explicit -open=true still forces an attempt.
+	if openBrowser && !flagWasSet(flag.CommandLine, "open") && isHeadlessEnvironment(runtime.GOOS, os.Getenv) {
+		openBrowser = false
+		infof("%sHeadless or SSH session detected; the login URL and code will be printed instead of opening a browser (pass -open=true to force).\n", yellow(icon("info")))
+	}
+
+	if opts.progressJSON {
+		progressWriter = os.Stderr
+	}
+
+	tagFilters, err := parseAccountTagFilters(opts.accountTags)
+	if err != nil {
+		errorf("%s%s %v\n", red(icon("error")), bold("Error:"), err)
+		os.Exit(exitValidationError)
+	}
+	accountTagFilters = tagFilters
+
+	abbreviations, err := parseAbbreviations(opts.abbrevs)
+	if err != nil {
+		errorf("%s%s %v\n", red(icon("error")), bold("Error:"), err)
+		os.Exit(exitValidationError)
+	}
+	userAbbreviations = abbreviations
+
+	if opts.filterExpr != "" {
+		compiled, err := compileRoleFilter(opts.filterExpr)
+		if err != nil {
+			errorf("%s%s %v\n", red(icon("error")), bold("Error:"), err)
+			os.Exit(exitValidationError)
+		}
+		roleFilter = compiled
+	}
+
+	if opts.groupBy != "" {
+		grouper, err := parseGroupBy(opts.groupBy)
+		if err != nil {
+			errorf("%s%s %v\n", red(icon("error")), bold("Error:"), err)
+			os.Exit(exitValidationError)
+		}
+		profileGroupBy = grouper
+	}
+
+	if len(opts.roleRegexes) > 0 {
+		compiled, err := compileRoleRegexes(opts.roleRegexes)
+		if err != nil {
+			errorf("%s%s %v\n", red(icon("error")), bold("Error:"), err)
+			os.Exit(exitValidationError)
+		}
+		roleRegexes = compiled
+	}
+
+	if opts.fromSnapshot != "" {
+		snap, err := loadSnapshot(opts.fromSnapshot)
+		if err != nil {
+			errorf("%s%s %v\n", red(icon("error")), bold("Error:"), err)
+			os.Exit(exitValidationError)
+		}
+		useSnapshot(snap)
+	}
+
+	if endpointURL != "" {
+		if err := validateEndpointURL(endpointURL); err != nil {
+			errorf("%s%s %v\n", red(icon("error")), bold("Error:"), err)
+			os.Exit(exitValidationError)
+		}
+	}
+
+	if opts.roleMapFile != "" {
+		settings, err := loadRoleMapFile(opts.roleMapFile)
+		if err != nil {
+			errorf("%s%s %v\n", red(icon("error")), bold("Error:"), err)
+			os.Exit(exitValidationError)
+		}
+		roleSettings = settings
+	}
+
+	if opts.regionMap != "" {
+		regions, err := loadRegionMap(opts.regionMap)
+		if err != nil {
+			errorf("%s%s %v\n", red(icon("error")), bold("Error:"), err)
+			os.Exit(exitValidationError)
+		}
+		accountRegions = regions
+	}
+
+	if opts.legacyKeys != "" {
+		keys, err := parseLegacyKeys(opts.legacyKeys)
+		if err != nil {
+			errorf("%s%s %v\n", red(icon("error")), bold("Error:"), err)
+			os.Exit(exitValidationError)
+		}
+		legacyKeys = keys
+	} else if opts.legacy {
+		legacyKeys = defaultLegacyKeys
+	}
+	if credentialProcessMode && legacyKeys != nil {
+		errorf("%s%s -credential-process cannot be combined with -legacy or -legacy-keys\n", red(icon("error")), bold("Error:"))
+		os.Exit(exitValidationError)
+	}
+	if diffMode && profilesDir != "" {
+		errorf("%s%s -diff compares the single config file and cannot be combined with -profiles-dir\n", red(icon("error")), bold("Error:"))
+		os.Exit(exitValidationError)
+	}
+	if profilesDir != "" {
+		for _, f := range []struct {
+			set  bool
+			name string
+		}{{pruneMode, "-prune"}, {opts.setRegion != "", "-set-region"}, {planMode, "-plan"}} {
+			if f.set {
+				errorf("%s%s %s works on the single config file and cannot be combined with -profiles-dir\n", red(icon("error")), bold("Error:"), f.name)
+				os.Exit(exitValidationError)
+			}
+		}
+	}
+
+	if len(opts.accountIds) > 0 {
+		accountIdFilter = make(map[string]bool)
+		for _, id := range opts.accountIds {
+			accountIdFilter[id] = true
+		}
+	}
+	if opts.accountNameRegex != "" {
+		re, err := regexp.Compile(opts.accountNameRegex)
+		if err != nil {
+			errorf("%s%s invalid -account-name-regex: %v\n", red(icon("error")), bold("Error:"), err)
+			os.Exit(exitValidationError)
+		}
+		accountNameRegex = re
+	}
+
+	if opts.managedPattern != "" {
+		re, err := regexp.Compile(opts.managedPattern)
+		if err != nil {
+			errorf("%s%s invalid -managed-pattern: %v\n", red(icon("error")), bold("Error:"), err)
+			os.Exit(exitValidationError)
+		}
+		managedPattern = re
+	}
+
+	if opts.profileTemplate != "" {
+		if nameCommand != "" {
+			errorf("%s%s -profile-template cannot be combined with -name-command\n", red(icon("error")), bold("Error:"))
+			os.Exit(exitValidationError)
+		}
+		tmpl, err := parseProfileTemplate(opts.profileTemplate)
+		if err != nil {
+			errorf("%s%s %v\n", red(icon("error")), bold("Error:"), err)
+			os.Exit(exitValidationError)
+		}
+		profileNameTemplate = tmpl
+	}
+
+	if opts.summaryTemplateText != "" {
+		tmpl, err := parseSummaryTemplate(opts.summaryTemplateText)
+		if err != nil {
+			errorf("%s%s %v\n", red(icon("error")), bold("Error:"), err)
+			os.Exit(exitValidationError)
+		}
+		summaryTemplate = tmpl
+	}
+
+	if err := checkConfigFileLocation(ssoConfigFile); err != nil {
+		errorf("%s%s %v\n", red(icon("error")), bold("Error:"), err)
+		os.Exit(exitValidationError)
+	}
+	if err := checkConfigReadable(ssoConfigFile); err != nil {
+		errorf("%s%s %v\n", red(icon("error")), bold("Error:"), err)
+		os.Exit(exitValidationError)
+	}
+
+	if opts.bootstrapTemplate != "" {
+		template, err := loadBootstrapTemplate(opts.bootstrapTemplate)
+		if err != nil {
+			errorf("%s%s %v\n", red(icon("error")), bold("Error:"), err)
+			os.Exit(exitValidationError)
+		}
+		bootstrapTemplate = template
+	}
+
+	mapping, err := parseKeyNameMappings(opts.keyNames)
+	if err != nil {
+		errorf("%s%s %v\n", red(icon("error")), bold("Error:"), err)
+		os.Exit(exitValidationError)
+	}
+	profileKeyNames = mapping
+	extras, err := parseExtraKeys(opts.extraKeys, forceUpdate)
+	if err != nil {
+		errorf("%s%s %v\n", red(icon("error")), bold("Error:"), err)
+		os.Exit(exitValidationError)
+	}
+	extraProfileKeys = extras
+
+	if opts.setRegion != "" {
+		// Maintenance mode works purely on the local config: resolve the
+		// session the profiles belong to, then rewrite their region keys.
+		infof("%s\n", cyan("\n========== AWS SSO Profile Region Update =========="))
+		if ssoSessionConfigName == defaultSSOSessionConfigName || ssoSessionConfigName == "" {
+			if err := reuseMatchingSession(""); err != nil {
+				os.Exit(exitRuntimeError)
+			}
+		}
+		updated, err := setRegionForManagedProfiles(opts.setRegion)
+		if err != nil {
+			errorf("%s%s %v\n", red(icon("error")), bold("Error updating profile regions:"), err)
+			os.Exit(exitRuntimeError)
+		}
+		if dryRun {
+			resultf("\n%s%s %d profile(s) would be updated to region %s.\n", cyan(icon("summary")), bold("Dry-run summary:"), len(updated), opts.setRegion)
+		} else {
+			resultf("\n%s%s %d profile(s) updated to region %s.\n", cyan(icon("summary")), bold("Summary:"), len(updated), opts.setRegion)
+		}
+		os.Exit(exitOK)
 	}
 
 	// Session detection and reuse will be printed at runtime after auth so the
@@ -933,39 +3226,114 @@ func main() {
 	// available roles (this mirrors the dry-run behavior). This makes the
 	// experience consistent between dry-run and apply: both will show the
 	// available roles and exit so the user can decide which to configure.
-	ssoRoleNames = roleNames
+	roleNames := opts.roleNames
+	if opts.rolesFromSSM != "" {
+		// The central selection adds to any -role flags given locally.
+		ssmRoles, err := rolesFromSSM(opts.rolesFromSSM)
+		if err != nil {
+			errorf("%s%s %v\n", red(icon("error")), bold("Error loading -roles-from-ssm:"), err)
+			os.Exit(exitRuntimeError)
+		}
+		debugf("Loaded %d role(s) from SSM parameter %s\n", len(ssmRoles), opts.rolesFromSSM)
+		roleNames = append(roleNames, ssmRoles...)
+	}
+	ssoRoleNames = expandRoleNames(roleNames, opts.useDefaultRoles)
+	if len(opts.excludeRoleNames) > 0 {
+		excludedRoles = make(map[string]bool)
+		for _, name := range opts.excludeRoleNames {
+			excludedRoles[name] = true
+		}
+	}
 
-	fmt.Println(cyan("\n========== AWS SSO Profile Setup =========="))
+	if sessionOnly {
+		switch {
+		case len(geographies) > 0, len(startURLs) > 1:
+			errorf("%s%s -session-only sets up a single session and cannot be combined with a multi-geography config or several -sso-start-urls\n", red(icon("error")), bold("Error:"))
+			os.Exit(exitValidationError)
+		case rolesRequested():
+			errorf("%s%s -session-only writes no profiles; drop -role, -role-regex and -exclude-role\n", red(icon("error")), bold("Error:"))
+			os.Exit(exitValidationError)
+		}
+	}
+
+	if len(startURLs) > 1 {
+		switch {
+		case len(geographies) > 0:
+			errorf("%s%s -sso-start-urls cannot be combined with a multi-geography config\n", red(icon("error")), bold("Error:"))
+			os.Exit(exitValidationError)
+		case flagWasSet(flag.CommandLine, "sso-session-name"):
+			errorf("%s%s -sso-session-name cannot be used with several start URLs; each gets a session named after its host\n", red(icon("error")), bold("Error:"))
+			os.Exit(exitValidationError)
+		case !rolesRequested():
+			errorf("%s%s several start URLs need a role selection (-role, -role-regex or -exclude-role)\n", red(icon("error")), bold("Error:"))
+			os.Exit(exitValidationError)
+		}
+	}
+
+	infof("%s\n", cyan("\n========== AWS SSO Profile Setup =========="))
 	if dryRun {
 		// Print a single concise dry-run header to avoid repetition
-		fmt.Printf("%s %s — %s\n\n", yellow("🔍"), bold("DRY-RUN MODE: No changes will be made"), "This will show what would be configured without making actual changes")
+		infof("%s%s — %s\n\n", yellow(icon("check")), bold("DRY-RUN MODE: No changes will be made"), "This will show what would be configured without making actual changes")
+	}
+	if bootstrapTemplate != nil {
+		if _, err := bootstrapConfigFile(bootstrapTemplate); err != nil {
+			errorf("%s%s %v\n", red(icon("error")), bold("Error bootstrapping config:"), err)
+			os.Exit(exitRuntimeError)
+		}
 	}
-	// If no roles were requested, perform the login/discovery flow and
-	// list available roles per account, then exit. This mirrors the dry-run
-	// listing behavior so users see identical output in apply vs dry-run.
-	if len(ssoRoleNames) == 0 {
+	if sessionOnly {
+		if err := runSessionOnly(); err != nil {
+			errorf("%s%v\n", red(icon("error")), err)
+			os.Exit(exitRuntimeError)
+		}
+		os.Exit(exitOK)
+	}
+	// A multi-geography config logs in and syncs once per instance.
+	if len(geographies) > 0 {
+		if err := syncGeographies(geographies, login); err != nil {
+			errorf("%s%v\n", red(icon("error")), err)
+			os.Exit(exitRuntimeError)
+		}
+	} else if len(startURLs) > 1 {
+		// Several start URLs log in and sync once per instance.
+		if err := syncStartURLs(startURLs, login); err != nil {
+			errorf("%s%v\n", red(icon("error")), err)
+			os.Exit(exitRuntimeError)
+		}
+	} else if !rolesRequested() {
+		// If no roles were requested, perform the login/discovery flow and
+		// list available roles per account, then exit. This mirrors the
+		// dry-run listing behavior so users see identical output in apply vs
+		// dry-run.
 		// We still need a valid token to discover accounts/roles. Reuse the
 		// login() flow which will either use an existing token or prompt the
 		// user to authenticate and obtain one.
 		if err := login(); err != nil {
-			fmt.Printf("%s %v\n", red("❌"), err)
-			os.Exit(1)
+			errorf("%s%v\n", red(icon("error")), err)
+			os.Exit(exitRuntimeError)
 		}
 		// After login(), fetch the token and list available roles per account.
 		accessToken, _, err := getAccessTokenFunc()
 		if err != nil {
-			fmt.Printf("%s %v\n", red("❌"), err)
-			os.Exit(1)
+			errorf("%s%v\n", red(icon("error")), err)
+			os.Exit(exitRuntimeError)
+		}
+		if estimateMode || exportPath != "" || dumpRolesFormat != "" {
+			if err := runWithTokenRetry(accessToken, configureSsoProfilesFunc); err != nil {
+				errorf("%s%s %v\n", red(icon("error")), bold("Error:"), err)
+				os.Exit(exitRuntimeError)
+			}
+			os.Exit(exitOK)
 		}
 		// Reuse the same listing logic as dry-run
-		fmt.Printf("%s Available roles per account:\n", cyan("🔎"))
-		if err := listAllRolesPerAccount(accessToken); err != nil {
-			fmt.Printf("%s %s %v\n", red("❌"), bold("Error listing roles:"), err)
-			os.Exit(1)
+		infof("%sAvailable roles per account:\n", cyan(icon("search")))
+		if err := runWithTokenRetry(accessToken, listAllRolesPerAccount); err != nil {
+			errorf("%s%s %v\n", red(icon("error")), bold("Error listing roles:"), err)
+			os.Exit(exitRuntimeError)
 		}
 		// Friendly guidance: tell the user to pick role(s) and re-run the tool
-		fmt.Println()
-		fmt.Printf("%s No role selected. Choose the role(s) you'd like to add and re-run the command with one or more -role flags.\n", yellow("ℹ️"))
+		infof("\n")
+		infof("%sNo role selected. Choose the role(s) you'd like to add and re-run the command with one or more -role flags.\n", yellow(icon("info")))
 		// Show a concrete example using the current executable name. If the
 		// current run was a dry-run, include the -dry-run flag so the example
 		// mirrors the invocation that produced this output.
@@ -975,19 +3343,23 @@ func main() {
 			exampleCmd = fmt.Sprintf("%s %s", exampleCmd, "-dry-run")
 		}
 		exampleCmd = fmt.Sprintf("%s -sso-start-url \"%s\" -role AWSReadOnlyAccess", exampleCmd, ssoStartURL)
-		fmt.Printf("  Example: %s\n", exampleCmd)
-		fmt.Println()
+		infof("  Example: %s\n", exampleCmd)
+		infof("\n")
 		// Exit after listing so the user can re-run with -role flags
-		os.Exit(0)
+		os.Exit(exitOK)
 	}
 
-	if err := login(); err != nil {
-		fmt.Printf("%s %v\n", red("❌"), err)
-		os.Exit(1)
+	if len(geographies) == 0 && len(startURLs) <= 1 {
+		if err := login(); err != nil {
+			errorf("%s%v\n", red(icon("error")), err)
+			os.Exit(exitRuntimeError)
+		}
 	}
 	if dryRun {
-		fmt.Println(green("\n🎉 Dry-run complete! Use without -dry-run to apply these changes."))
+		infof("%s\n", green("\n"+icon("done")+"Dry-run complete! Use without -dry-run to apply these changes."))
 	} else {
-		fmt.Println(green("\n🎉 AWS SSO login and profile configuration complete!"))
+		infof("%s\n", green("\n"+icon("done")+"AWS SSO login and profile configuration complete!"))
 	}
+	releaseConfigLock()
+	os.Exit(syncExitCode(syncResults, dryRun))
 }
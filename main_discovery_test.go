@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/fatih/color"
+)
+
+// captureStdout runs fn and returns everything it printed to stdout.
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	return captureFile(t, &os.Stdout, fn)
+}
+
+// captureStderr runs fn and returns everything it printed to stderr.
+func captureStderr(t *testing.T, fn func()) string {
+	t.Helper()
+	return captureFile(t, &os.Stderr, fn)
+}
+
+// captureFile swaps *f for a pipe while fn runs and returns what was written.
+func captureFile(t *testing.T, f **os.File, fn func()) string {
+	t.Helper()
+	old := *f
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("failed to create pipe: %v", err)
+	}
+	*f = w
+	done := make(chan string)
+	go func() {
+		var buf bytes.Buffer
+		io.Copy(&buf, r)
+		done <- buf.String()
+	}()
+	defer func() { *f = old }()
+	fn()
+	w.Close()
+	return <-done
+}
+
+// stubDiscovery replaces account/role discovery with the given synthetic
+// data for the duration of the test.
+func stubDiscovery(t *testing.T, accounts []ssoTypesAccount, roles map[string][]string) {
+	t.Helper()
+	origAccounts := getListOfSsoAccountsFunc
+	origRoles := getListOfSsoAccountRolesForAccountFunc
+	t.Cleanup(func() {
+		getListOfSsoAccountsFunc = origAccounts
+		getListOfSsoAccountRolesForAccountFunc = origRoles
+	})
+	getListOfSsoAccountsFunc = func(accessToken string) ([]ssoTypesAccount, error) {
+		return accounts, nil
+	}
+	getListOfSsoAccountRolesForAccountFunc = func(accessToken, accountId string) ([]ssoTypesRole, error) {
+		var out []ssoTypesRole
+		for _, r := range roles[accountId] {
+			out = append(out, ssoTypesRole{RoleName: r})
+		}
+		return out, nil
+	}
+}
+
+// TestScopedRoleSelection verifies a role@accountId selection matches only in
+// that account while an unscoped role name still matches everywhere.
+func TestScopedRoleSelection(t *testing.T) {
+	stubDiscovery(t,
+		[]ssoTypesAccount{{AccountId: "111111111111", AccountName: "Prod"}, {AccountId: "222222222222", AccountName: "Dev"}},
+		map[string][]string{
+			"111111111111": {"AWSReadOnlyAccess", "AWSAdministratorAccess"},
+			"222222222222": {"AWSReadOnlyAccess", "AWSAdministratorAccess"},
+		})
+
+	got, err := getCombinedListOfSsoAccountsAndRoles("token", []string{"AWSAdministratorAccess@222222222222", "AWSReadOnlyAccess"})
+	if err != nil {
+		t.Fatalf("getCombinedListOfSsoAccountsAndRoles failed: %v", err)
+	}
+	var admin []string
+	readOnly := 0
+	for _, r := range got {
+		switch r.RoleName {
+		case "AWSAdministratorAccess":
+			admin = append(admin, r.AccountId)
+		case "AWSReadOnlyAccess":
+			readOnly++
+		}
+	}
+	if len(admin) != 1 || admin[0] != "222222222222" {
+		t.Fatalf("expected scoped admin role only in 222222222222, got %v", admin)
+	}
+	if readOnly != 2 {
+		t.Fatalf("expected unscoped read-only role in both accounts, got %d", readOnly)
+	}
+}
+
+// TestReportEmptyAccounts verifies -report-empty-accounts lists an account
+// that has none of the requested roles, in both dry-run and normal mode.
+func TestReportEmptyAccounts(t *testing.T) {
+	stubDiscovery(t,
+		[]ssoTypesAccount{{AccountId: "111", AccountName: "Prod"}, {AccountId: "222", AccountName: "Sandbox"}},
+		map[string][]string{"111": {"AWSReadOnlyAccess"}, "222": {"AWSAdministratorAccess"}})
+
+	oldReport, oldConfig, oldDry, oldRoles := reportEmptyAccounts, ssoConfigFile, dryRun, ssoRoleNames
+	defer func() {
+		reportEmptyAccounts, ssoConfigFile, dryRun, ssoRoleNames = oldReport, oldConfig, oldDry, oldRoles
+	}()
+	reportEmptyAccounts = true
+	ssoConfigFile = filepath.Join(t.TempDir(), "config")
+	ssoRoleNames = []string{"AWSReadOnlyAccess"}
+
+	for _, dry := range []bool{true, false} {
+		dryRun = dry
+		var err error
+		out := captureStdout(t, func() { _, err = configureSsoProfiles("token") })
+		if err != nil {
+			t.Fatalf("configureSsoProfiles (dry-run=%v) failed: %v", dry, err)
+		}
+		if !strings.Contains(out, "1 account(s) had none of the requested roles") || !strings.Contains(out, "Sandbox (AccountId: 222)") {
+			t.Fatalf("expected Sandbox to be reported as empty (dry-run=%v), output:\n%s", dry, out)
+		}
+		if strings.Contains(out, "Prod (AccountId: 111)") {
+			t.Fatalf("account with a matching role was reported as empty (dry-run=%v)", dry)
+		}
+	}
+}
+
+// TestExcludeRole covers -exclude-role on its own (every role except the
+// excluded ones), combined with -role (the -role set minus exclusions), its
+// exact case-sensitive matching, and dimming in the role listing.
+func TestExcludeRole(t *testing.T) {
+	stubDiscovery(t,
+		[]ssoTypesAccount{{AccountId: "111", AccountName: "Prod"}},
+		map[string][]string{"111": {"AWSAdministratorAccess", "AWSReadOnlyAccess", "Billing"}})
+	oldExcluded, oldRoles := excludedRoles, ssoRoleNames
+	defer func() { excludedRoles, ssoRoleNames = oldExcluded, oldRoles }()
+
+	names := func(roles []CombinedRole) string {
+		var out []string
+		for _, r := range roles {
+			out = append(out, r.RoleName)
+		}
+		return strings.Join(out, ",")
+	}
+	cases := []struct {
+		roles    []string
+		excluded []string
+		want     string
+	}{
+		{nil, []string{"Billing"}, "AWSAdministratorAccess,AWSReadOnlyAccess"},
+		{[]string{"AWSReadOnlyAccess", "Billing"}, []string{"Billing"}, "AWSReadOnlyAccess"},
+		{nil, []string{"billing"}, "AWSAdministratorAccess,AWSReadOnlyAccess,Billing"},
+		{nil, nil, ""},
+	}
+	for _, c := range cases {
+		excludedRoles = nil
+		if c.excluded != nil {
+			excludedRoles = make(map[string]bool)
+			for _, e := range c.excluded {
+				excludedRoles[e] = true
+			}
+		}
+		got, err := getCombinedListOfSsoAccountsAndRoles("token", c.roles)
+		if err != nil {
+			t.Fatalf("roles=%v excluded=%v: %v", c.roles, c.excluded, err)
+		}
+		if names(got) != c.want {
+			t.Errorf("roles=%v excluded=%v: got %q, want %q", c.roles, c.excluded, names(got), c.want)
+		}
+	}
+
+	oldNoColor := color.NoColor
+	defer func() { color.NoColor = oldNoColor }()
+	color.NoColor = false
+	excludedRoles = map[string]bool{"Billing": true}
+	ssoRoleNames = nil
+	out := captureStdout(t, func() { listAllRolesPerAccount("token") })
+	if !strings.Contains(out, dim("Billing")) || !strings.Contains(out, green(bold("AWSReadOnlyAccess"))) {
+		t.Fatalf("expected Billing dimmed and other roles highlighted, got %q", out)
+	}
+}
+
+// TestRoleRegex asserts -role-regex selects matching roles alongside literal
+// -role names, that invalid patterns are rejected, and that the dry-run
+// listing shows which pattern matched.
+func TestRoleRegex(t *testing.T) {
+	stubDiscovery(t,
+		[]ssoTypesAccount{{AccountId: "111", AccountName: "Prod"}},
+		map[string][]string{"111": {"AWSReadOnlyAccess-Team1", "AWSReadOnlyAccess-Team2", "Billing", "Other"}})
+	oldRegexes, oldRoles, oldExcluded := roleRegexes, ssoRoleNames, excludedRoles
+	defer func() { roleRegexes, ssoRoleNames, excludedRoles = oldRegexes, oldRoles, oldExcluded }()
+	excludedRoles = nil
+
+	if _, err := compileRoleRegexes([]string{"ok", "("}); err == nil || !strings.Contains(err.Error(), `"("`) {
+		t.Fatalf("expected an invalid pattern to be rejected, got %v", err)
+	}
+	compiled, err := compileRoleRegexes([]string{`^AWSReadOnlyAccess-Team\d+$`})
+	if err != nil {
+		t.Fatalf("compileRoleRegexes failed: %v", err)
+	}
+	roleRegexes = compiled
+
+	got, err := getCombinedListOfSsoAccountsAndRoles("token", []string{"Billing"})
+	if err != nil {
+		t.Fatalf("getCombinedListOfSsoAccountsAndRoles failed: %v", err)
+	}
+	var names []string
+	for _, r := range got {
+		names = append(names, r.RoleName)
+	}
+	if strings.Join(names, ",") != "AWSReadOnlyAccess-Team1,AWSReadOnlyAccess-Team2,Billing" {
+		t.Fatalf("unexpected selection %v", names)
+	}
+
+	ssoRoleNames = nil
+	out := captureStdout(t, func() { listAllRolesPerAccount("token") })
+	if !strings.Contains(out, `AWSReadOnlyAccess-Team1 (/^AWSReadOnlyAccess-Team\d+$/)`) {
+		t.Fatalf("expected the matching pattern in the listing, got %q", out)
+	}
+}
+
+// TestAccountIdAndNameFilters asserts -account-id and -account-name-regex
+// must both hold, that filtered accounts are never enumerated, and that the
+// dry-run output reports the filtered count.
+func TestAccountIdAndNameFilters(t *testing.T) {
+	stubDiscovery(t,
+		[]ssoTypesAccount{{AccountId: "111", AccountName: "team-prod"}, {AccountId: "222", AccountName: "team-dev"}, {AccountId: "333", AccountName: "sandbox"}},
+		map[string][]string{"111": {"ReadOnly"}, "222": {"ReadOnly"}, "333": {"ReadOnly"}})
+	stubbed := getListOfSsoAccountRolesForAccountFunc
+	var enumerated []string
+	getListOfSsoAccountRolesForAccountFunc = func(accessToken, accountId string) ([]ssoTypesRole, error) {
+		enumerated = append(enumerated, accountId)
+		return stubbed(accessToken, accountId)
+	}
+	oldIds, oldName, oldDry := accountIdFilter, accountNameRegex, dryRun
+	defer func() { accountIdFilter, accountNameRegex, dryRun = oldIds, oldName, oldDry }()
+	accountIdFilter = map[string]bool{"111": true, "333": true}
+	accountNameRegex = regexp.MustCompile(`^team-`)
+	dryRun = true
+
+	var got []CombinedRole
+	var err error
+	out := captureStdout(t, func() { got, err = getCombinedListOfSsoAccountsAndRoles("token", []string{"ReadOnly"}) })
+	if err != nil {
+		t.Fatalf("getCombinedListOfSsoAccountsAndRoles failed: %v", err)
+	}
+	if len(got) != 1 || got[0].AccountId != "111" {
+		t.Fatalf("expected only account 111, got %+v", got)
+	}
+	if strings.Join(enumerated, ",") != "111" {
+		t.Fatalf("filtered accounts were enumerated: %v", enumerated)
+	}
+	if !strings.Contains(out, "1 account(s) considered, 2 filtered out") {
+		t.Fatalf("expected filter counts in dry-run output, got %q", out)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/ini.v1"
+)
+
+// TestLoginAndSyncAgainstMockServer runs the real login() -> device flow ->
+// token cache -> configureSsoProfiles path with -endpoint-url pointing at the
+// in-repo mock SSO server, and checks the profiles written to the config.
+func TestLoginAndSyncAgainstMockServer(t *testing.T) {
+	server := newMockSSOServer(t,
+		[]ssoTypesAccount{{AccountId: "111111111111", AccountName: "Prod"}, {AccountId: "222222222222", AccountName: "Dev"}},
+		map[string][]string{"111111111111": {"AWSReadOnlyAccess", "AWSAdministratorAccess"}, "222222222222": {"AWSReadOnlyAccess"}})
+	server.pendingPolls = 1
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("AWS_PROFILE", "")
+	oldEndpoint, oldURL, oldRegion, oldSession, oldConfig, oldRoles, oldOpen, oldDry, oldSleep, oldAuto :=
+		endpointURL, ssoStartURL, ssoRegion, ssoSessionConfigName, ssoConfigFile, ssoRoleNames, openBrowser, dryRun, sleepFunc, useAutoPrefix
+	defer func() {
+		endpointURL, ssoStartURL, ssoRegion, ssoSessionConfigName, ssoConfigFile, ssoRoleNames, openBrowser, dryRun, sleepFunc, useAutoPrefix =
+			oldEndpoint, oldURL, oldRegion, oldSession, oldConfig, oldRoles, oldOpen, oldDry, oldSleep, oldAuto
+	}()
+	endpointURL = server.URL
+	ssoStartURL = "https://mock.awsapps.com/start"
+	ssoRegion = "us-east-1"
+	ssoSessionConfigName = "mock"
+	ssoConfigFile = filepath.Join(home, ".aws", "config")
+	ssoRoleNames = []string{"AWSReadOnlyAccess"}
+	openBrowser = false
+	dryRun = false
+	useAutoPrefix = true
+	sleepFunc = func(ctx context.Context, d time.Duration) error { return nil }
+
+	oldCodeFile := userCodeFile
+	defer func() { userCodeFile = oldCodeFile }()
+	userCodeFile = filepath.Join(home, "user-code")
+
+	var err error
+	var out string
+	prompts := captureStderr(t, func() {
+		out = captureStdout(t, func() { err = login() })
+	})
+	if err != nil {
+		t.Fatalf("login failed: %v\n%s", err, out)
+	}
+	if !strings.Contains(prompts, "ABCD-EFGH") || strings.Contains(out, "ABCD-EFGH") {
+		t.Errorf("expected the user code on stderr only, stdout:\n%s\nstderr:\n%s", out, prompts)
+	}
+	if code, _ := os.ReadFile(userCodeFile); string(code) != "ABCD-EFGH\n" {
+		t.Errorf("-code-file holds %q", code)
+	}
+	if got := server.callCount("CreateToken"); got != 2 {
+		t.Errorf("expected one pending poll and one successful CreateToken, got %d calls", got)
+	}
+
+	cfg, err := ini.Load(ssoConfigFile)
+	if err != nil {
+		t.Fatalf("failed to load written config: %v", err)
+	}
+	if got := cfg.Section("sso-session mock").Key("sso_start_url").String(); got != ssoStartURL {
+		t.Errorf("sso-session start URL = %q", got)
+	}
+	for _, name := range []string{"ReadOnly_Prod_111111111111", "ReadOnly_Dev_222222222222"} {
+		section := cfg.Section("profile " + name)
+		if section.Key("sso_session").String() != "mock" || section.Key("sso_role_name").String() != "AWSReadOnlyAccess" {
+			t.Errorf("profile %s not written as expected: %v", name, section.KeysHash())
+		}
+	}
+	if cfg.HasSection("profile Administrator_Prod_111111111111") {
+		t.Errorf("unrequested role was configured")
+	}
+
+	// A second run finds the cached token, validates it against the mock and
+	// skips the existing profiles without another device flow.
+	out = captureStdout(t, func() { err = login() })
+	if err != nil {
+		t.Fatalf("second login failed: %v\n%s", err, out)
+	}
+	if server.callCount("RegisterClient") != 1 || !strings.Contains(out, "2 already configured") {
+		t.Errorf("expected the cached token to be reused, output:\n%s", out)
+	}
+
+	for _, bad := range []string{"localhost:8080", "ftp://mock", "http://"} {
+		if validateEndpointURL(bad) == nil {
+			t.Errorf("expected -endpoint-url %q to be rejected", bad)
+		}
+	}
+}
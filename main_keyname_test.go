@@ -0,0 +1,117 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/ini.v1"
+)
+
+// TestWriteProfileUsesRemappedKeyNames verifies that -key-name mappings are
+// honored by writeProfileToConfig: the remapped key is written and the
+// standard name is not.
+func TestWriteProfileUsesRemappedKeyNames(t *testing.T) {
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "config")
+
+	oldConfig := ssoConfigFile
+	oldKeys := profileKeyNames
+	oldDry := dryRun
+	defer func() {
+		ssoConfigFile = oldConfig
+		profileKeyNames = oldKeys
+		dryRun = oldDry
+	}()
+	ssoConfigFile = cfgPath
+	dryRun = false
+
+	mapping, err := parseKeyNameMappings([]string{"sso_account_id=account_id"})
+	if err != nil {
+		t.Fatalf("parseKeyNameMappings failed: %v", err)
+	}
+	profileKeyNames = mapping
+
+	role := CombinedRole{AccountId: "123456789012", RoleName: "AWSReadOnlyAccess", AccountName: "Example"}
+	if err := writeProfileToConfig("Example_123456789012", role); err != nil {
+		t.Fatalf("writeProfileToConfig failed: %v", err)
+	}
+
+	cfg, err := ini.Load(cfgPath)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	sec := cfg.Section("profile Example_123456789012")
+	if got := sec.Key("account_id").String(); got != role.AccountId {
+		t.Fatalf("account_id mismatch: got %q want %q", got, role.AccountId)
+	}
+	if sec.HasKey("sso_account_id") {
+		t.Fatalf("unexpected standard key sso_account_id in remapped profile")
+	}
+	if got := sec.Key("sso_role_name").String(); got != role.RoleName {
+		t.Fatalf("sso_role_name mismatch: got %q want %q", got, role.RoleName)
+	}
+}
+
+// TestParseKeyNameMappingsValidation checks that unknown logical keys, empty
+// targets and mappings that collide with another key are rejected.
+func TestParseKeyNameMappingsValidation(t *testing.T) {
+	bad := [][]string{
+		{"account=account_id"},
+		{"sso_account_id="},
+		{"sso_account_id"},
+		{"sso_account_id=sso_role_name"},
+	}
+	for _, in := range bad {
+		if _, err := parseKeyNameMappings(in); err == nil {
+			t.Fatalf("expected error for %v", in)
+		}
+	}
+}
+
+// TestLegacyKeySets writes legacy profiles with a few -legacy-keys sets and
+// asserts exactly the chosen SSO keys are written, without sso_session, and
+// that sets missing the account or role key are rejected.
+func TestLegacyKeySets(t *testing.T) {
+	oldConfig, oldSession, oldStart, oldRegion, oldOutput, oldLegacy := ssoConfigFile, ssoSessionConfigName, ssoStartURL, ssoRegion, profileOutput, legacyKeys
+	defer func() {
+		ssoConfigFile, ssoSessionConfigName, ssoStartURL, ssoRegion, profileOutput, legacyKeys = oldConfig, oldSession, oldStart, oldRegion, oldOutput, oldLegacy
+	}()
+	ssoSessionConfigName, ssoStartURL, ssoRegion, profileOutput = "corp", "https://corp.awsapps.com/start/", "eu-west-1", "json"
+
+	cases := []struct {
+		value string
+		want  []string
+	}{
+		{"sso_start_url,sso_region,sso_account_id,sso_role_name", []string{"sso_start_url", "sso_region", "sso_account_id", "sso_role_name", "region", "output"}},
+		{"sso_start_url, sso_account_id, sso_role_name", []string{"sso_start_url", "sso_account_id", "sso_role_name", "region", "output"}},
+	}
+	for _, c := range cases {
+		keys, err := parseLegacyKeys(c.value)
+		if err != nil {
+			t.Fatalf("parseLegacyKeys(%q) failed: %v", c.value, err)
+		}
+		legacyKeys = keys
+		ssoConfigFile = filepath.Join(t.TempDir(), "config")
+		if err := writeProfileToConfig("legacy", CombinedRole{AccountId: "111", RoleName: "ReadOnly"}); err != nil {
+			t.Fatalf("writeProfileToConfig failed: %v", err)
+		}
+		cfg, err := ini.Load(ssoConfigFile)
+		if err != nil {
+			t.Fatalf("failed to load config: %v", err)
+		}
+		section := cfg.Section("profile legacy")
+		if got := strings.Join(section.KeyStrings(), ","); got != strings.Join(c.want, ",") {
+			t.Errorf("-legacy-keys %q: wrote keys %s, want %s", c.value, got, strings.Join(c.want, ","))
+		}
+		if v := section.Key("sso_start_url").String(); v != "https://corp.awsapps.com/start" {
+			t.Errorf("-legacy-keys %q: unexpected sso_start_url %q", c.value, v)
+		}
+	}
+
+	for _, bad := range []string{"sso_start_url,sso_region,sso_role_name", "sso_account_id,sso_start_url", "sso_account_id,sso_role_name,sso_session"} {
+		if _, err := parseLegacyKeys(bad); err == nil {
+			t.Errorf("expected -legacy-keys %q to be rejected", bad)
+		}
+	}
+}
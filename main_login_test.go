@@ -0,0 +1,416 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/ssooidc"
+	ssooidctypes "github.com/aws/aws-sdk-go-v2/service/ssooidc/types"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// TestAutoReloginAfterRevokedToken simulates a token that validates at start
+// but is revoked mid-run. With -auto-relogin the run performs exactly one
+// fresh login and retries the configure step with the new token.
+func TestAutoReloginAfterRevokedToken(t *testing.T) {
+	origGet := getAccessTokenFunc
+	origRun := runAwsSsoLogin
+	origIsValid := isSsoTokenValidFunc
+	origConfigure := configureSsoProfilesFunc
+	oldRelogin := autoRelogin
+	oldRoles := ssoRoleNames
+	oldConfig := ssoConfigFile
+	oldDry := dryRun
+	defer func() {
+		getAccessTokenFunc = origGet
+		runAwsSsoLogin = origRun
+		isSsoTokenValidFunc = origIsValid
+		configureSsoProfilesFunc = origConfigure
+		autoRelogin = oldRelogin
+		ssoRoleNames = oldRoles
+		ssoConfigFile = oldConfig
+		dryRun = oldDry
+	}()
+
+	current := "old-token"
+	revoked := false
+	logins := 0
+	var configuredWith []string
+
+	getAccessTokenFunc = func() (string, string, error) { return current, "/tmp/" + current + ".json", nil }
+	runAwsSsoLogin = func(session string) error { logins++; current = "new-token"; return nil }
+	isSsoTokenValidFunc = func(accessToken string) bool {
+		return accessToken == "new-token" || (accessToken == "old-token" && !revoked)
+	}
+	configureSsoProfilesFunc = func(accessToken string) error {
+		configuredWith = append(configuredWith, accessToken)
+		if accessToken == "old-token" {
+			// The token is revoked while the sync is running.
+			revoked = true
+			return errors.New("UnauthorizedException: session token not found or invalid")
+		}
+		return nil
+	}
+
+	ssoConfigFile = filepath.Join(t.TempDir(), "config")
+	ssoRoleNames = []string{"AWSReadOnlyAccess"}
+	dryRun = false
+	autoRelogin = true
+
+	// Silence the run's output
+	old := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+	err := login()
+	w.Close()
+	io.Copy(io.Discard, r)
+	os.Stdout = old
+
+	if err != nil {
+		t.Fatalf("login() returned error with -auto-relogin: %v", err)
+	}
+	if logins != 1 {
+		t.Fatalf("expected exactly one re-login, got %d", logins)
+	}
+	if len(configuredWith) != 2 || configuredWith[1] != "new-token" {
+		t.Fatalf("expected configure to be retried with the new token, got %v", configuredWith)
+	}
+
+	// Without -auto-relogin the revoked token error surfaces directly.
+	current, revoked, logins, configuredWith = "old-token", false, 0, nil
+	autoRelogin = false
+	old = os.Stdout
+	r, w, _ = os.Pipe()
+	os.Stdout = w
+	err = login()
+	w.Close()
+	io.Copy(io.Discard, r)
+	os.Stdout = old
+	if err == nil || logins != 0 {
+		t.Fatalf("expected error and no re-login without -auto-relogin, got err=%v logins=%d", err, logins)
+	}
+}
+
+// TestMinTokenLifetimeTriggersProactiveLogin gives login() a valid token that
+// expires in one minute and asserts a fresh login happens before syncing.
+func TestMinTokenLifetimeTriggersProactiveLogin(t *testing.T) {
+	origGet := getAccessTokenFunc
+	origRun := runAwsSsoLogin
+	origIsValid := isSsoTokenValidFunc
+	origConfigure := configureSsoProfilesFunc
+	oldMin, oldRoles, oldConfig, oldDry := minTokenLifetime, ssoRoleNames, ssoConfigFile, dryRun
+	defer func() {
+		getAccessTokenFunc = origGet
+		runAwsSsoLogin = origRun
+		isSsoTokenValidFunc = origIsValid
+		configureSsoProfilesFunc = origConfigure
+		minTokenLifetime, ssoRoleNames, ssoConfigFile, dryRun = oldMin, oldRoles, oldConfig, oldDry
+	}()
+
+	dir := t.TempDir()
+	writeToken := func(name string, expires time.Time) string {
+		path := filepath.Join(dir, name)
+		body := fmt.Sprintf(`{"startUrl":"https://unit.test/start","accessToken":%q,"expiresAt":%q}`, name, expires.UTC().Format(time.RFC3339))
+		if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+			t.Fatalf("failed to write token: %v", err)
+		}
+		return path
+	}
+	soonPath := writeToken("soon", time.Now().Add(time.Minute))
+	freshPath := writeToken("fresh", time.Now().Add(8*time.Hour))
+
+	current, currentPath := "soon", soonPath
+	logins := 0
+	var configuredWith string
+	getAccessTokenFunc = func() (string, string, error) { return current, currentPath, nil }
+	runAwsSsoLogin = func(session string) error { logins++; current, currentPath = "fresh", freshPath; return nil }
+	isSsoTokenValidFunc = func(accessToken string) bool { return true }
+	configureSsoProfilesFunc = func(accessToken string) error { configuredWith = accessToken; return nil }
+
+	minTokenLifetime = 2 * time.Minute
+	ssoRoleNames = []string{"AWSReadOnlyAccess"}
+	ssoConfigFile = filepath.Join(dir, "config")
+	dryRun = false
+
+	var err error
+	captureStdout(t, func() { err = login() })
+	if err != nil {
+		t.Fatalf("login() failed: %v", err)
+	}
+	if logins != 1 || configuredWith != "fresh" {
+		t.Fatalf("expected one proactive login and sync with the fresh token, got logins=%d token=%q", logins, configuredWith)
+	}
+}
+
+// TestMinTokenLifetimeDryRun asserts a dry-run keeps a valid token that is
+// below -min-token-lifetime, says a real run would log in again, and never
+// reports that no valid token was found.
+func TestMinTokenLifetimeDryRun(t *testing.T) {
+	origGet := getAccessTokenFunc
+	origRun := runAwsSsoLogin
+	origIsValid := isSsoTokenValidFunc
+	origConfigure := configureSsoProfilesFunc
+	oldMin, oldRoles, oldConfig, oldDry := minTokenLifetime, ssoRoleNames, ssoConfigFile, dryRun
+	defer func() {
+		getAccessTokenFunc = origGet
+		runAwsSsoLogin = origRun
+		isSsoTokenValidFunc = origIsValid
+		configureSsoProfilesFunc = origConfigure
+		minTokenLifetime, ssoRoleNames, ssoConfigFile, dryRun = oldMin, oldRoles, oldConfig, oldDry
+	}()
+
+	dir := t.TempDir()
+	soonPath := filepath.Join(dir, "soon")
+	body := fmt.Sprintf(`{"startUrl":"https://unit.test/start","accessToken":"soon","expiresAt":%q}`, time.Now().Add(time.Minute).UTC().Format(time.RFC3339))
+	if err := os.WriteFile(soonPath, []byte(body), 0o600); err != nil {
+		t.Fatalf("failed to write token: %v", err)
+	}
+	logins := 0
+	var configuredWith string
+	getAccessTokenFunc = func() (string, string, error) { return "soon", soonPath, nil }
+	runAwsSsoLogin = func(session string) error { logins++; return nil }
+	isSsoTokenValidFunc = func(accessToken string) bool { return true }
+	configureSsoProfilesFunc = func(accessToken string) error { configuredWith = accessToken; return nil }
+
+	minTokenLifetime = 2 * time.Minute
+	ssoRoleNames = []string{"AWSReadOnlyAccess"}
+	ssoConfigFile = filepath.Join(dir, "config")
+	dryRun = true
+
+	var err error
+	out := captureStdout(t, func() { err = login() })
+	if err != nil {
+		t.Fatalf("login() failed: %v", err)
+	}
+	if logins != 0 || configuredWith != "soon" {
+		t.Fatalf("expected the dry-run to sync with the existing token, got logins=%d token=%q", logins, configuredWith)
+	}
+	if !strings.Contains(out, "Existing token is valid but expires in") || !strings.Contains(out, "a real run would log in again first") {
+		t.Errorf("missing the below-threshold note:\n%s", out)
+	}
+	if strings.Contains(out, "re-authenticating") || strings.Contains(out, "no valid token found") {
+		t.Errorf("dry-run claims a login or a missing token:\n%s", out)
+	}
+}
+
+// TestPollCreateTokenRetriesTransientError injects a pending response, a 503
+// and a timeout before success, and asserts the poll loop survives the
+// transient failures. A non-transient error still aborts immediately.
+func TestPollCreateTokenRetriesTransientError(t *testing.T) {
+	origSleep := sleepFunc
+	defer func() { sleepFunc = origSleep }()
+	var slept []time.Duration
+	sleepFunc = func(ctx context.Context, d time.Duration) error { slept = append(slept, d); return nil }
+
+	responses := []error{
+		errors.New("AuthorizationPendingException: authorization_pending"),
+		&awshttp.ResponseError{ResponseError: &smithyhttp.ResponseError{
+			Response: &smithyhttp.Response{Response: &http.Response{StatusCode: 503}},
+			Err:      errors.New("service unavailable"),
+		}},
+		context.DeadlineExceeded,
+		nil,
+	}
+	calls := 0
+	create := func() (*ssooidc.CreateTokenOutput, error) {
+		err := responses[calls]
+		calls++
+		if err != nil {
+			return nil, err
+		}
+		return &ssooidc.CreateTokenOutput{AccessToken: aws.String("tok")}, nil
+	}
+	out, err := pollCreateToken(context.Background(), create, 5*time.Second, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("pollCreateToken failed: %v", err)
+	}
+	if aws.ToString(out.AccessToken) != "tok" || calls != 4 {
+		t.Fatalf("unexpected result: token=%q calls=%d", aws.ToString(out.AccessToken), calls)
+	}
+	if len(slept) != 3 || slept[0] != 5*time.Second {
+		t.Fatalf("unexpected sleeps: %v", slept)
+	}
+
+	calls = 0
+	_, err = pollCreateToken(context.Background(), func() (*ssooidc.CreateTokenOutput, error) {
+		calls++
+		return nil, errors.New("AccessDeniedException: denied")
+	}, time.Second, time.Now().Add(time.Minute))
+	if err == nil || calls != 1 {
+		t.Fatalf("expected immediate failure on non-transient error, got err=%v calls=%d", err, calls)
+	}
+
+	calls = 0
+	_, err = pollCreateToken(context.Background(), func() (*ssooidc.CreateTokenOutput, error) {
+		calls++
+		return nil, context.DeadlineExceeded
+	}, time.Second, time.Now().Add(time.Minute))
+	if err == nil || calls != maxTransientCreateTokenRetries+1 {
+		t.Fatalf("expected bounded transient retries, got err=%v calls=%d", err, calls)
+	}
+}
+
+// TestPollCreateTokenStopsOnCancel asserts a cancelled context (Ctrl-C or
+// -timeout) ends the wait for authorization promptly instead of polling until
+// the device code expires.
+func TestPollCreateTokenStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	start := time.Now()
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+	_, err := pollCreateToken(ctx, func() (*ssooidc.CreateTokenOutput, error) {
+		calls++
+		return nil, errors.New("AuthorizationPendingException: authorization_pending")
+	}, time.Hour, time.Now().Add(time.Hour))
+	if !errors.Is(err, context.Canceled) || calls != 1 {
+		t.Fatalf("expected cancellation after one poll, got err=%v calls=%d", err, calls)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("cancellation took %v", elapsed)
+	}
+
+	timeoutCtx, cancelTimeout := context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancelTimeout()
+	<-timeoutCtx.Done()
+	_, err = pollCreateToken(timeoutCtx, func() (*ssooidc.CreateTokenOutput, error) {
+		return nil, timeoutCtx.Err()
+	}, time.Second, time.Now().Add(time.Hour))
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "waiting for authorization") {
+		t.Fatalf("expected a timeout to abort rather than be retried, got %v", err)
+	}
+}
+
+// TestLoginAndFetchTokenStopsOnCancel asserts the wait for the token after
+// login honors the run context instead of sleeping through all retries.
+func TestLoginAndFetchTokenStopsOnCancel(t *testing.T) {
+	origGet, origRun, origCtx := getAccessTokenFunc, runAwsSsoLogin, runContext
+	defer func() { getAccessTokenFunc, runAwsSsoLogin, runContext = origGet, origRun, origCtx }()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	runContext = ctx
+	runAwsSsoLogin = func(string) error { return nil }
+	lookups := 0
+	getAccessTokenFunc = func() (string, string, error) {
+		lookups++
+		return "", "", errors.New("no token yet")
+	}
+	if _, _, err := loginAndFetchToken(); !errors.Is(err, context.Canceled) || lookups != 1 {
+		t.Fatalf("expected the cancellation after one lookup, got err=%v lookups=%d", err, lookups)
+	}
+}
+
+// TestLoadSsoClientConfigIgnoresMalformedAmbientConfig points the SDK at a
+// broken ~/.aws/config and a missing AWS_PROFILE. The default loader fails on
+// it, but the OIDC client config used for login must still load.
+func TestLoadSsoClientConfigIgnoresMalformedAmbientConfig(t *testing.T) {
+	oldRegion := ssoRegion
+	defer func() { ssoRegion = oldRegion }()
+	ssoRegion = "eu-west-1"
+
+	cfgPath := filepath.Join(t.TempDir(), "config")
+	broken := "[default]\nsso_session = does-not-exist\n[profile half\nregion\n"
+	if err := os.WriteFile(cfgPath, []byte(broken), 0o600); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	t.Setenv("AWS_CONFIG_FILE", cfgPath)
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "credentials"))
+
+	for _, profile := range []string{"", "missing"} {
+		t.Setenv("AWS_PROFILE", profile)
+		if _, err := config.LoadDefaultConfig(context.TODO()); err == nil {
+			t.Fatalf("AWS_PROFILE=%q: expected the default loader to reject the broken config", profile)
+		}
+		cfg, err := loadSsoClientConfig()
+		if err != nil {
+			t.Fatalf("AWS_PROFILE=%q: loadSsoClientConfig failed: %v", profile, err)
+		}
+		if cfg.Region != "eu-west-1" {
+			t.Fatalf("AWS_PROFILE=%q: expected region eu-west-1, got %q", profile, cfg.Region)
+		}
+		if ssooidc.NewFromConfig(cfg) == nil {
+			t.Fatalf("AWS_PROFILE=%q: failed to build OIDC client", profile)
+		}
+	}
+}
+
+// TestLoginRefreshesExpiredToken gives login() an expired cached token with a
+// refresh token and asserts it is renewed in place without a device login,
+// while an invalid_grant refresh falls back to the device flow.
+func TestLoginRefreshesExpiredToken(t *testing.T) {
+	origGet, origRun, origIsValid, origConfigure, origRefresh := getAccessTokenFunc, runAwsSsoLogin, isSsoTokenValidFunc, configureSsoProfilesFunc, createTokenWithRefreshFunc
+	oldRoles, oldConfig, oldDry := ssoRoleNames, ssoConfigFile, dryRun
+	defer func() {
+		getAccessTokenFunc, runAwsSsoLogin, isSsoTokenValidFunc, configureSsoProfilesFunc, createTokenWithRefreshFunc = origGet, origRun, origIsValid, origConfigure, origRefresh
+		ssoRoleNames, ssoConfigFile, dryRun = oldRoles, oldConfig, oldDry
+	}()
+	dir := t.TempDir()
+	tokenPath := filepath.Join(dir, "token.json")
+	writeExpired := func() {
+		body := `{"startUrl":"https://unit.test/start","accessToken":"expired","expiresAt":"2000-01-01T00:00:00Z","refreshToken":"rt","clientId":"cid","clientSecret":"secret"}`
+		if err := os.WriteFile(tokenPath, []byte(body), 0o600); err != nil {
+			t.Fatalf("failed to write token: %v", err)
+		}
+	}
+	readCache := func() map[string]interface{} {
+		data, _ := os.ReadFile(tokenPath)
+		var m map[string]interface{}
+		json.Unmarshal(data, &m)
+		return m
+	}
+
+	logins := 0
+	var configuredWith string
+	getAccessTokenFunc = func() (string, string, error) {
+		token, _ := readCache()["accessToken"].(string)
+		return token, tokenPath, nil
+	}
+	runAwsSsoLogin = func(session string) error { logins++; return nil }
+	isSsoTokenValidFunc = func(accessToken string) bool { return accessToken == "refreshed" }
+	configureSsoProfilesFunc = func(accessToken string) error { configuredWith = accessToken; return nil }
+	ssoRoleNames, ssoConfigFile, dryRun = []string{"AWSReadOnlyAccess"}, filepath.Join(dir, "config"), false
+
+	writeExpired()
+	createTokenWithRefreshFunc = func(clientId, clientSecret, refreshToken string) (*ssooidc.CreateTokenOutput, error) {
+		if clientId != "cid" || clientSecret != "secret" || refreshToken != "rt" {
+			t.Fatalf("unexpected refresh request %s/%s/%s", clientId, clientSecret, refreshToken)
+		}
+		return &ssooidc.CreateTokenOutput{AccessToken: aws.String("refreshed"), RefreshToken: aws.String("rt2"), ExpiresIn: 28800}, nil
+	}
+	var err error
+	captureStdout(t, func() { err = login() })
+	if err != nil || logins != 0 || configuredWith != "refreshed" {
+		t.Fatalf("expected a refresh without device login, got err=%v logins=%d token=%q", err, logins, configuredWith)
+	}
+	if cache := readCache(); cache["accessToken"] != "refreshed" || cache["refreshToken"] != "rt2" || cache["clientId"] != "cid" {
+		t.Fatalf("refreshed token not written back: %v", cache)
+	}
+
+	writeExpired()
+	createTokenWithRefreshFunc = func(clientId, clientSecret, refreshToken string) (*ssooidc.CreateTokenOutput, error) {
+		return nil, &ssooidctypes.InvalidGrantException{Message: aws.String("expired refresh token")}
+	}
+	runAwsSsoLogin = func(session string) error {
+		logins++
+		os.WriteFile(tokenPath, []byte(`{"startUrl":"https://unit.test/start","accessToken":"refreshed","expiresAt":"2100-01-01T00:00:00Z"}`), 0o600)
+		return nil
+	}
+	captureStdout(t, func() { err = login() })
+	if err != nil || logins != 1 {
+		t.Fatalf("expected invalid_grant to fall back to one device login, got err=%v logins=%d", err, logins)
+	}
+}
@@ -1,6 +1,9 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"os"
 	"path/filepath"
 	"testing"
 
@@ -50,3 +53,114 @@ func TestWriteProfileUsesConfiguredOutput(t *testing.T) {
 		t.Fatalf("expected output 'text', got '%s'", got)
 	}
 }
+
+// Test that a -summary-template renders a custom one-liner from the
+// SyncResult, and that execution errors are reported.
+func TestRenderSummaryTemplate(t *testing.T) {
+	tmpl, err := parseSummaryTemplate(`sync {{if .DryRun}}plan{{else}}ok{{end}}: +{{len .Added}} ={{len .Skipped}}{{range .Added}} {{.ProfileName}}{{end}}`)
+	if err != nil {
+		t.Fatalf("parseSummaryTemplate failed: %v", err)
+	}
+	result := SyncResult{
+		Added:   []ProfileResult{{ProfileName: "ReadOnly_Prod_111"}, {ProfileName: "ReadOnly_Dev_222"}},
+		Skipped: []ProfileResult{{ProfileName: "ReadOnly_Test_333"}},
+	}
+	got, err := renderSummaryTemplate(tmpl, result)
+	if err != nil {
+		t.Fatalf("renderSummaryTemplate failed: %v", err)
+	}
+	want := "sync ok: +2 =1 ReadOnly_Prod_111 ReadOnly_Dev_222\n"
+	if got != want {
+		t.Fatalf("unexpected summary: got %q want %q", got, want)
+	}
+
+	bad, err := parseSummaryTemplate(`{{.NoSuchField}}`)
+	if err != nil {
+		t.Fatalf("parseSummaryTemplate failed: %v", err)
+	}
+	if _, err := renderSummaryTemplate(bad, result); err == nil {
+		t.Fatalf("expected render error for unknown field")
+	}
+	if _, err := parseSummaryTemplate(`{{.Added`); err == nil {
+		t.Fatalf("expected parse error for malformed template")
+	}
+}
+
+// Test that -output=inherit omits the output key so the profile inherits
+// from [default], while an explicit format is still written.
+func TestWriteProfileInheritOmitsOutput(t *testing.T) {
+	oldConfig, oldSession, oldOutput, oldDry := ssoConfigFile, ssoSessionConfigName, profileOutput, dryRun
+	defer func() {
+		ssoConfigFile, ssoSessionConfigName, profileOutput, dryRun = oldConfig, oldSession, oldOutput, oldDry
+	}()
+	ssoConfigFile = filepath.Join(t.TempDir(), "config")
+	ssoSessionConfigName = "default"
+	dryRun = false
+
+	profileOutput = "inherit"
+	inherited := CombinedRole{AccountId: "111", RoleName: "AWSReadOnlyAccess", AccountName: "Inherit"}
+	if err := writeProfileToConfig("Inherit_111", inherited); err != nil {
+		t.Fatalf("writeProfileToConfig failed: %v", err)
+	}
+	profileOutput = "yaml"
+	explicit := CombinedRole{AccountId: "222", RoleName: "AWSReadOnlyAccess", AccountName: "Explicit"}
+	if err := writeProfileToConfig("Explicit_222", explicit); err != nil {
+		t.Fatalf("writeProfileToConfig failed: %v", err)
+	}
+
+	cfg, err := ini.Load(ssoConfigFile)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	section := cfg.Section("profile Inherit_111")
+	if section.HasKey("output") {
+		t.Fatalf("expected no output key under -output=inherit, got %q", section.Key("output").String())
+	}
+	if !section.HasKey("sso_role_name") || !section.HasKey("region") {
+		t.Fatalf("inherit dropped other keys: %v", section.KeyStrings())
+	}
+	if got := cfg.Section("profile Explicit_222").Key("output").String(); got != "yaml" {
+		t.Fatalf("expected output 'yaml' for an explicit format, got %q", got)
+	}
+}
+
+// TestJSONSummary asserts -json-summary writes the versioned document to the
+// stdout writer with "-" and to a file otherwise, with empty lists as [].
+func TestJSONSummary(t *testing.T) {
+	oldPath, oldOut := jsonSummaryPath, jsonSummaryOut
+	defer func() { jsonSummaryPath, jsonSummaryOut = oldPath, oldOut }()
+	result := SyncResult{
+		DryRun:      true,
+		SessionName: "corp",
+		Added:       []ProfileResult{{ProfileName: "ReadOnly_Prod_111", AccountId: "111", AccountName: "Prod", RoleName: "ReadOnly"}},
+		Pruned:      []ProfileResult{{ProfileName: "Admin_Old_222", AccountId: "222", RoleName: "Admin"}},
+	}
+
+	var buf bytes.Buffer
+	jsonSummaryPath, jsonSummaryOut = "-", &buf
+	captureStdout(t, func() { printSyncSummary(result) })
+	var doc map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid JSON summary %q: %v", buf.String(), err)
+	}
+	if doc["schemaVersion"] != float64(jsonSummarySchemaVersion) || doc["dryRun"] != true {
+		t.Fatalf("unexpected header fields: %v", doc)
+	}
+	added := doc["added"].([]interface{})[0].(map[string]interface{})
+	if added["profileName"] != "ReadOnly_Prod_111" || added["accountName"] != "Prod" || added["roleName"] != "ReadOnly" || added["accountId"] != "111" {
+		t.Fatalf("unexpected added entry: %v", added)
+	}
+	if skipped, ok := doc["skipped"].([]interface{}); !ok || len(skipped) != 0 {
+		t.Fatalf("expected an empty skipped list, got %v", doc["skipped"])
+	}
+	if removed := doc["removed"].([]interface{}); len(removed) != 1 {
+		t.Fatalf("expected one removed profile, got %v", removed)
+	}
+
+	jsonSummaryPath = filepath.Join(t.TempDir(), "summary.json")
+	captureStdout(t, func() { printSyncSummary(result) })
+	data, err := os.ReadFile(jsonSummaryPath)
+	if err != nil || !bytes.Equal(data, buf.Bytes()) {
+		t.Fatalf("expected the file summary to match the stdout one, err=%v", err)
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestProgressJSONEvents runs configureSsoProfiles against stubbed discovery
+// and asserts the -progress-json stream contains one account_scanned event
+// per account (with index/total), then per-profile events, then a final
+// sync_complete event.
+func TestProgressJSONEvents(t *testing.T) {
+	origAccounts := getListOfSsoAccountsFunc
+	origRoles := getListOfSsoAccountRolesForAccountFunc
+	oldWriter, oldConfig, oldDry, oldRoles := progressWriter, ssoConfigFile, dryRun, ssoRoleNames
+	oldPrefix, oldAuto := profilePrefix, useAutoPrefix
+	defer func() {
+		getListOfSsoAccountsFunc = origAccounts
+		getListOfSsoAccountRolesForAccountFunc = origRoles
+		progressWriter, ssoConfigFile, dryRun, ssoRoleNames = oldWriter, oldConfig, oldDry, oldRoles
+		profilePrefix, useAutoPrefix = oldPrefix, oldAuto
+	}()
+	profilePrefix = ""
+	useAutoPrefix = true
+
+	getListOfSsoAccountsFunc = func(accessToken string) ([]ssoTypesAccount, error) {
+		return []ssoTypesAccount{{AccountId: "111", AccountName: "Prod"}, {AccountId: "222", AccountName: "Dev"}}, nil
+	}
+	getListOfSsoAccountRolesForAccountFunc = func(accessToken, accountId string) ([]ssoTypesRole, error) {
+		return []ssoTypesRole{{RoleName: "AWSReadOnlyAccess"}}, nil
+	}
+
+	var stream bytes.Buffer
+	progressWriter = &stream
+	ssoConfigFile = filepath.Join(t.TempDir(), "config")
+	dryRun = false
+	ssoRoleNames = []string{"AWSReadOnlyAccess"}
+
+	old := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+	_, err := configureSsoProfiles("token")
+	w.Close()
+	io.Copy(io.Discard, r)
+	os.Stdout = old
+	if err != nil {
+		t.Fatalf("configureSsoProfiles failed: %v", err)
+	}
+
+	var events []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(stream.String()), "\n") {
+		var ev map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &ev); err != nil {
+			t.Fatalf("progress line is not JSON: %q (%v)", line, err)
+		}
+		events = append(events, ev)
+	}
+
+	var names []string
+	for _, ev := range events {
+		names = append(names, ev["event"].(string))
+	}
+	want := []string{"account_scanned", "account_scanned", "profile_added", "profile_added", "sync_complete"}
+	if strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Fatalf("unexpected event order: %v", names)
+	}
+	if events[1]["index"].(float64) != 2 || events[1]["total"].(float64) != 2 || events[1]["account"] != "Dev" {
+		t.Fatalf("unexpected account_scanned shape: %v", events[1])
+	}
+	if events[2]["profile"] != "ReadOnly_Prod_111" || events[2]["accountId"] != "111" {
+		t.Fatalf("unexpected profile_added shape: %v", events[2])
+	}
+	if events[4]["added"].(float64) != 2 {
+		t.Fatalf("unexpected sync_complete shape: %v", events[4])
+	}
+}
+
+// TestConcurrentProgressTotals scans many accounts with several workers and
+// asserts every account_scanned event reports the full account count as its
+// total, indexes run 1..total in order, and roles keep discovery order.
+func TestConcurrentProgressTotals(t *testing.T) {
+	var accounts []ssoTypesAccount
+	roles := make(map[string][]string)
+	for i := 0; i < 25; i++ {
+		id := fmt.Sprintf("%03d", i)
+		accounts = append(accounts, ssoTypesAccount{AccountId: id, AccountName: "Account" + id})
+		roles[id] = []string{"AWSReadOnlyAccess"}
+	}
+	stubDiscovery(t, accounts, roles)
+
+	oldWriter, oldConcurrency := progressWriter, accountConcurrency
+	defer func() { progressWriter, accountConcurrency = oldWriter, oldConcurrency }()
+	var stream bytes.Buffer
+	progressWriter = &stream
+	accountConcurrency = 8
+
+	got, err := getRolesForAccounts("token", accounts, []string{"AWSReadOnlyAccess"})
+	if err != nil {
+		t.Fatalf("getRolesForAccounts failed: %v", err)
+	}
+	if len(got) != len(accounts) {
+		t.Fatalf("expected %d roles, got %d", len(accounts), len(got))
+	}
+	for i, role := range got {
+		if role.AccountId != accounts[i].AccountId {
+			t.Fatalf("roles out of discovery order at %d: %v", i, role)
+		}
+	}
+
+	lines := strings.Split(strings.TrimSpace(stream.String()), "\n")
+	if len(lines) != len(accounts) {
+		t.Fatalf("expected %d progress events, got %d", len(accounts), len(lines))
+	}
+	seen := make(map[string]bool)
+	for i, line := range lines {
+		var ev map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &ev); err != nil {
+			t.Fatalf("progress line is not JSON: %q (%v)", line, err)
+		}
+		if ev["total"].(float64) != float64(len(accounts)) {
+			t.Fatalf("event %d reports total %v, want %d", i, ev["total"], len(accounts))
+		}
+		if ev["index"].(float64) != float64(i+1) {
+			t.Fatalf("event %d reports index %v, want %d", i, ev["index"], i+1)
+		}
+		seen[ev["accountId"].(string)] = true
+	}
+	if len(seen) != len(accounts) {
+		t.Fatalf("expected every account scanned once, saw %d", len(seen))
+	}
+}
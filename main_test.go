@@ -3,6 +3,8 @@ package main
 import (
 	"os"
 	"path/filepath"
+	"regexp"
+	"runtime"
 	"strings"
 	"testing"
 
@@ -20,6 +22,11 @@ func TestGeneratePrefixFromRole(t *testing.T) {
 		"CustomRole":         "CustomRole_",
 		// role name "Access" becomes empty after trimming and should return ""
 		"Access": "",
+		// custom permission set names with spaces and slashes
+		"My Custom / Role":   "My-Custom-Role_",
+		" Team\\Deploy ":     "Team-Deploy_",
+		"Data_Eng[ops]":      "Data_Eng-ops_",
+		"AWS Billing Access": "Billing_",
 	}
 	for in, want := range cases {
 		got := generatePrefixFromRole(in)
@@ -251,3 +258,806 @@ func TestEnsureSsoSessionConfigPresent(t *testing.T) {
 		t.Fatalf("expected second call to indicate nothing was added")
 	}
 }
+
+func TestRegionSourceSessionOnly(t *testing.T) {
+	// TestRegionSourceSessionOnly verifies -region-source=session-only
+	// leaves region out of written profiles, even with a role-map region,
+	// while the session block keeps its sso_region.
+	cfgPath := filepath.Join(t.TempDir(), "config")
+	oldConfig, oldSession, oldRegion, oldStart, oldSource, oldDry, oldAnnotate := ssoConfigFile, ssoSessionConfigName, ssoRegion, ssoStartURL, regionSource, dryRun, annotateSession
+	defer func() {
+		ssoConfigFile, ssoSessionConfigName, ssoRegion, ssoStartURL, regionSource, dryRun, annotateSession = oldConfig, oldSession, oldRegion, oldStart, oldSource, oldDry, oldAnnotate
+	}()
+	ssoConfigFile, ssoSessionConfigName, ssoRegion, ssoStartURL = cfgPath, "corp", "eu-west-1", "https://unit.test/start"
+	regionSource, dryRun, annotateSession = regionSourceSessionOnly, false, false
+
+	if _, err := ensureSsoSessionConfigPresent(); err != nil {
+		t.Fatalf("ensureSsoSessionConfigPresent failed: %v", err)
+	}
+	role := CombinedRole{AccountId: "111", AccountName: "Prod", RoleName: "AWSReadOnlyAccess", Region: "us-west-2"}
+	if err := writeProfileToConfig("ReadOnly_Prod_111", role); err != nil {
+		t.Fatalf("writeProfileToConfig failed: %v", err)
+	}
+	cfg, err := ini.Load(cfgPath)
+	if err != nil {
+		t.Fatalf("failed to load written config: %v", err)
+	}
+	if sec := cfg.Section("profile ReadOnly_Prod_111"); sec.HasKey("region") || !sec.HasKey("sso_session") {
+		t.Fatalf("expected an SSO profile without region, got keys %v", sec.KeyStrings())
+	}
+	if got := cfg.Section("sso-session corp").Key("sso_region").String(); got != "eu-west-1" {
+		t.Fatalf("expected the session block to keep sso_region, got %q", got)
+	}
+	if validateRegionSource("account") == nil {
+		t.Fatal("expected -region-source=account to be rejected")
+	}
+}
+
+func TestValidateStartURL(t *testing.T) {
+	// TestValidateStartURL checks the accepted AWS access portal URL forms,
+	// typical typos, and that -allow-insecure-url lets mock URLs through.
+	oldInsecure := allowInsecureURL
+	defer func() { allowInsecureURL = oldInsecure }()
+	allowInsecureURL = false
+
+	cases := []struct {
+		url   string
+		valid bool
+	}{
+		{"https://mycompany.awsapps.com/start", true},
+		{"https://mycompany.awsapps.com/start/", true},
+		{"https://mycompany.awsapps.com/start/#/", true},
+		{"https://view.awsapps.com/start/#/?tab=accounts", true},
+		{"https://d-1234567890.awsapps.com/start", true},
+		{"https://MyCompany.AWSApps.com/start", true},
+		{"https://start.us-gov-home.awsapps.com/directory/d-1234567890", true},
+		{"https://mycompany.awsapps.cn/start", true},
+		{"https://ssoins-7223abcd.portal.us-east-1.app.aws", true},
+		{"https://ssoins-7223abcd.portal.eu-west-1.app.aws/", true},
+		{"http://mycompany.awsapps.com/start", false},
+		{"mycompany.awsapps.com/start", false},
+		{"https://mycompany.awsapp.com/start", false},
+		{"https://mycompany.awsapps.com/strat", false},
+		{"https://mycompany.awsapps.com", false},
+		{"https://mycompany.awsapps.com:8443/start", false},
+		{"https://ssoins-1.portal.us-east-1.app.aws/start", false},
+		{"https://awsapps.com/start", false},
+		{"https://unit.test/start", false},
+		{"", false},
+	}
+	for _, c := range cases {
+		if err := validateStartURL(c.url); (err == nil) != c.valid {
+			t.Errorf("validateStartURL(%q) = %v, want valid=%v", c.url, err, c.valid)
+		}
+	}
+
+	allowInsecureURL = true
+	if err := validateStartURL("http://127.0.0.1:8080/start"); err != nil {
+		t.Errorf("expected a mock URL to pass with -allow-insecure-url, got %v", err)
+	}
+	if err := validateStartURL("ftp://mock/start"); err == nil {
+		t.Error("expected ftp to be rejected even with -allow-insecure-url")
+	}
+}
+
+func TestCheckConfigFileLocation(t *testing.T) {
+	// TestCheckConfigFileLocation verifies the external config guard refuses
+	// paths outside ~/.aws unless -allow-external-config is set, and never
+	// triggers for paths under ~/.aws.
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	oldAllow := allowExternalConfig
+	defer func() { allowExternalConfig = oldAllow }()
+	allowExternalConfig = false
+
+	if err := checkConfigFileLocation(filepath.Join(home, ".aws", "config")); err != nil {
+		t.Fatalf("unexpected guard error for path under ~/.aws: %v", err)
+	}
+	if err := checkConfigFileLocation("/etc/passwd"); err == nil {
+		t.Fatalf("expected guard to trigger for out-of-home path")
+	}
+	if err := checkConfigFileLocation(filepath.Join(home, ".aws-other", "config")); err == nil {
+		t.Fatalf("expected guard to trigger for sibling directory of ~/.aws")
+	}
+
+	allowExternalConfig = true
+	if err := checkConfigFileLocation("/etc/passwd"); err != nil {
+		t.Fatalf("unexpected guard error with -allow-external-config: %v", err)
+	}
+}
+
+func TestGetProfileNameFromRoleNameStyles(t *testing.T) {
+	// TestGetProfileNameFromRoleNameStyles checks the profile name produced
+	// for each -name-style value.
+	oldPrefix, oldAuto, oldStyle := profilePrefix, useAutoPrefix, nameStyle
+	defer func() { profilePrefix, useAutoPrefix, nameStyle = oldPrefix, oldAuto, oldStyle }()
+	profilePrefix = ""
+	useAutoPrefix = true
+
+	role := CombinedRole{AccountId: "123", AccountName: "My App", RoleName: "AWSReadOnlyAccess"}
+	cases := map[string]string{
+		nameStyleRoleAccount: "ReadOnly_My-App_123",
+		nameStyleAccountOnly: "My-App_123",
+		nameStyleRoleOnly:    "ReadOnly",
+	}
+	for style, want := range cases {
+		nameStyle = style
+		if got := getProfileNameFromRole(role); got != want {
+			t.Fatalf("name-style %s: got %q want %q", style, got, want)
+		}
+	}
+
+	if err := validateNameStyle("bogus"); err == nil {
+		t.Fatalf("expected invalid -name-style to be rejected")
+	}
+}
+
+func TestGetProfileNameFromRoleSanitizesUnsafeNames(t *testing.T) {
+	// TestGetProfileNameFromRoleSanitizesUnsafeNames checks that account names
+	// with INI-unsafe characters give a name that round-trips through a
+	// written section, so the profile is found again on the next run.
+	oldPrefix, oldAuto, oldStyle, oldConfig := profilePrefix, useAutoPrefix, nameStyle, ssoConfigFile
+	defer func() {
+		profilePrefix, useAutoPrefix, nameStyle, ssoConfigFile = oldPrefix, oldAuto, oldStyle, oldConfig
+	}()
+	profilePrefix, useAutoPrefix, nameStyle = "", true, nameStyleRoleAccount
+	ssoConfigFile = filepath.Join(t.TempDir(), "config")
+
+	cases := map[string]string{
+		"Prod]#1":  "ReadOnly_Prod-1_111",
+		"team=ops": "ReadOnly_team-ops_111",
+		"a;[b]":    "ReadOnly_a-b-_111",
+	}
+	for accountName, want := range cases {
+		role := CombinedRole{AccountId: "111", AccountName: accountName, RoleName: "AWSReadOnlyAccess"}
+		name := getProfileNameFromRole(role)
+		if name != want {
+			t.Fatalf("account %q: got %q want %q", accountName, name, want)
+		}
+		if err := writeProfileToConfig(name, role); err != nil {
+			t.Fatalf("writeProfileToConfig(%q) failed: %v", name, err)
+		}
+		if !profileExists(getProfileNameFromRole(role), ssoConfigFile) {
+			t.Fatalf("profile %q was not found after writing it", name)
+		}
+	}
+}
+
+func TestGetProfileNameFromRoleSanitizesRoleNames(t *testing.T) {
+	// TestGetProfileNameFromRoleSanitizesRoleNames checks that the
+	// role-derived part of the name is cleaned for both the auto prefix and
+	// the role-only style.
+	oldPrefix, oldAuto, oldStyle := profilePrefix, useAutoPrefix, nameStyle
+	defer func() { profilePrefix, useAutoPrefix, nameStyle = oldPrefix, oldAuto, oldStyle }()
+	profilePrefix, useAutoPrefix = "", true
+	role := CombinedRole{AccountId: "111", AccountName: "Prod", RoleName: "My Custom / Role"}
+
+	nameStyle = nameStyleRoleAccount
+	if got := getProfileNameFromRole(role); got != "My-Custom-Role_Prod_111" {
+		t.Fatalf("role-account style: got %q", got)
+	}
+	nameStyle = nameStyleRoleOnly
+	if got := getProfileNameFromRole(role); got != "My-Custom-Role" {
+		t.Fatalf("role-only style: got %q", got)
+	}
+}
+
+func TestCheckProfileNameCollisions(t *testing.T) {
+	// TestCheckProfileNameCollisions verifies that account-only naming errors
+	// when two roles in the same account would share a profile name, and that
+	// role-account naming keeps them distinct.
+	oldPrefix, oldAuto, oldStyle := profilePrefix, useAutoPrefix, nameStyle
+	defer func() { profilePrefix, useAutoPrefix, nameStyle = oldPrefix, oldAuto, oldStyle }()
+	profilePrefix = ""
+	useAutoPrefix = true
+
+	roles := []CombinedRole{
+		{AccountId: "123", AccountName: "My App", RoleName: "AWSReadOnlyAccess"},
+		{AccountId: "123", AccountName: "My App", RoleName: "AWSAdministratorAccess"},
+	}
+	nameStyle = nameStyleAccountOnly
+	if err := checkProfileNameCollisions(roles); err == nil {
+		t.Fatalf("expected collision error for account-only with two roles in one account")
+	}
+	nameStyle = nameStyleRoleAccount
+	if err := checkProfileNameCollisions(roles); err != nil {
+		t.Fatalf("unexpected collision for role-account: %v", err)
+	}
+	nameStyle = nameStyleRoleOnly
+	acrossAccounts := []CombinedRole{
+		{AccountId: "123", AccountName: "A", RoleName: "AWSReadOnlyAccess"},
+		{AccountId: "456", AccountName: "B", RoleName: "AWSReadOnlyAccess"},
+	}
+	if err := checkProfileNameCollisions(acrossAccounts); err == nil {
+		t.Fatalf("expected collision error for role-only with one role across accounts")
+	}
+}
+
+func TestDedupeProfileNames(t *testing.T) {
+	// TestDedupeProfileNames verifies two roles that sanitize to the same
+	// profile name fail without -dedupe-names and get a role-derived suffix
+	// with it, and that the dry-run output flags the collision.
+	oldPrefix, oldAuto, oldStyle, oldDedupe, oldDry := profilePrefix, useAutoPrefix, nameStyle, dedupeNames, dryRun
+	defer func() {
+		profilePrefix, useAutoPrefix, nameStyle, dedupeNames, dryRun = oldPrefix, oldAuto, oldStyle, oldDedupe, oldDry
+	}()
+	profilePrefix, useAutoPrefix, nameStyle = "", true, nameStyleRoleOnly
+
+	newRoles := func() []CombinedRole {
+		return []CombinedRole{
+			{AccountId: "123", AccountName: "App", RoleName: "Ops/Admin"},
+			{AccountId: "123", AccountName: "App", RoleName: "Ops Admin"},
+			{AccountId: "456", AccountName: "Other", RoleName: "Ops Admin"},
+		}
+	}
+	roles := newRoles()
+	if getProfileNameFromRole(roles[0]) != getProfileNameFromRole(roles[1]) {
+		t.Fatalf("test roles should collide, got %q and %q", getProfileNameFromRole(roles[0]), getProfileNameFromRole(roles[1]))
+	}
+	dedupeNames = false
+	if err := checkProfileNameCollisions(roles); err == nil || !strings.Contains(err.Error(), "-dedupe-names") {
+		t.Fatalf("expected a collision error mentioning -dedupe-names, got %v", err)
+	}
+
+	dedupeNames, dryRun = true, true
+	roles = newRoles()
+	var err error
+	out := captureStdout(t, func() { err = checkProfileNameCollisions(roles) })
+	if err != nil {
+		t.Fatalf("unexpected error with -dedupe-names: %v", err)
+	}
+	names := make(map[string]bool)
+	for _, role := range roles {
+		names[getProfileNameFromRole(role)] = true
+	}
+	if len(names) != 3 {
+		t.Fatalf("expected three distinct names, got %v", names)
+	}
+	if got := getProfileNameFromRole(roles[1]); got != "Ops-Admin_Ops-Admin" {
+		t.Fatalf("expected a role-derived suffix, got %q", got)
+	}
+	if got := getProfileNameFromRole(roles[2]); got != "Ops-Admin_Ops-Admin_456" {
+		t.Fatalf("expected the account ID once the role suffix is taken, got %q", got)
+	}
+	if !strings.Contains(out, "Profile name collision") || !strings.Contains(out, "Would rename") {
+		t.Fatalf("expected the collision to be flagged, got %q", out)
+	}
+}
+
+func TestExpandRoleNames(t *testing.T) {
+	// TestExpandRoleNames asserts -defaults adds the default permission set
+	// roles after any explicit -role values, without duplicates.
+	got := expandRoleNames([]string{"CustomRole", "AWSReadOnlyAccess"}, true)
+	want := []string{"CustomRole", "AWSReadOnlyAccess", "AWSAdministratorAccess", "AWSPowerUserAccess"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("expanded roles: got %v want %v", got, want)
+	}
+	if got := expandRoleNames([]string{"CustomRole"}, false); len(got) != 1 || got[0] != "CustomRole" {
+		t.Fatalf("unexpected roles without -defaults: %v", got)
+	}
+}
+
+func TestMatchesRoleFilter(t *testing.T) {
+	// TestMatchesRoleFilter compiles a JMESPath -filter expression and checks
+	// it keeps only production accounts with the read-only role.
+	oldFilter := roleFilter
+	defer func() { roleFilter = oldFilter }()
+	compiled, err := compileRoleFilter("contains(accountName, 'prod') && roleName == 'AWSReadOnlyAccess'")
+	if err != nil {
+		t.Fatalf("compileRoleFilter failed: %v", err)
+	}
+	roleFilter = compiled
+
+	cases := []struct {
+		role CombinedRole
+		want bool
+	}{
+		{CombinedRole{AccountId: "1", AccountName: "payments-prod", RoleName: "AWSReadOnlyAccess"}, true},
+		{CombinedRole{AccountId: "2", AccountName: "payments-prod", RoleName: "AWSAdministratorAccess"}, false},
+		{CombinedRole{AccountId: "3", AccountName: "payments-dev", RoleName: "AWSReadOnlyAccess"}, false},
+	}
+	for _, c := range cases {
+		got, err := matchesRoleFilter(c.role)
+		if err != nil {
+			t.Fatalf("matchesRoleFilter(%v) error: %v", c.role, err)
+		}
+		if got != c.want {
+			t.Fatalf("matchesRoleFilter(%v): got %v want %v", c.role, got, c.want)
+		}
+	}
+
+	if _, err := compileRoleFilter("accountName =="); err == nil {
+		t.Fatalf("expected invalid expression to fail to compile")
+	}
+}
+
+func TestIsHeadlessEnvironment(t *testing.T) {
+	// TestIsHeadlessEnvironment stubs the environment to check the headless
+	// auto-detection used to default -open to false.
+	env := func(vars map[string]string) func(string) string {
+		return func(k string) string { return vars[k] }
+	}
+	if !isHeadlessEnvironment("linux", env(map[string]string{})) {
+		t.Fatalf("expected Linux without DISPLAY to be headless")
+	}
+	if !isHeadlessEnvironment("darwin", env(map[string]string{"SSH_CONNECTION": "10.0.0.1 5555 10.0.0.2 22"})) {
+		t.Fatalf("expected SSH session to be headless")
+	}
+	if isHeadlessEnvironment("linux", env(map[string]string{"DISPLAY": ":0"})) {
+		t.Fatalf("expected Linux with DISPLAY not to be headless")
+	}
+	if isHeadlessEnvironment("darwin", env(map[string]string{})) {
+		t.Fatalf("expected local macOS session not to be headless")
+	}
+}
+
+func TestPreferSessionAmongMultipleMatches(t *testing.T) {
+	// TestPreferSessionAmongMultipleMatches seeds two sso-session blocks
+	// matching the same start URL and region and verifies -prefer-session
+	// resolves the ambiguity, while an unknown preference errors.
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "config")
+	cfg := ini.Empty()
+	for _, name := range []string{"first", "second"} {
+		sec, _ := cfg.NewSection("sso-session " + name)
+		sec.NewKey("sso_start_url", "https://unit.test/start")
+		sec.NewKey("sso_region", "us-east-1")
+	}
+	if err := cfg.SaveTo(cfgPath); err != nil {
+		t.Fatalf("failed to write temp config: %v", err)
+	}
+
+	oldConfigFile, oldSession, oldStart, oldRegion, oldDry, oldPrefer := ssoConfigFile, ssoSessionConfigName, ssoStartURL, ssoRegion, dryRun, preferSession
+	defer func() {
+		ssoConfigFile, ssoSessionConfigName, ssoStartURL, ssoRegion, dryRun, preferSession = oldConfigFile, oldSession, oldStart, oldRegion, oldDry, oldPrefer
+	}()
+	ssoConfigFile = cfgPath
+	ssoStartURL = "https://unit.test/start/"
+	ssoRegion = "us-east-1"
+	dryRun = false
+
+	// Without a preference the ambiguity is an error.
+	ssoSessionConfigName = defaultSSOSessionConfigName
+	preferSession = ""
+	if _, err := ensureSsoSessionConfigPresent(); err == nil {
+		t.Fatalf("expected error for multiple matching sessions without -prefer-session")
+	}
+
+	ssoSessionConfigName = defaultSSOSessionConfigName
+	preferSession = "second"
+	added, err := ensureSsoSessionConfigPresent()
+	if err != nil {
+		t.Fatalf("ensureSsoSessionConfigPresent with -prefer-session failed: %v", err)
+	}
+	if added || ssoSessionConfigName != "second" {
+		t.Fatalf("expected preferred session 'second' to be reused, got %q added=%v", ssoSessionConfigName, added)
+	}
+
+	ssoSessionConfigName = defaultSSOSessionConfigName
+	preferSession = "missing"
+	if _, err := ensureSsoSessionConfigPresent(); err == nil || !strings.Contains(err.Error(), "-prefer-session") {
+		t.Fatalf("expected -prefer-session error for a non-matching name, got %v", err)
+	}
+}
+
+func TestSetRegionForManagedProfiles(t *testing.T) {
+	// TestSetRegionForManagedProfiles seeds a managed profile and a profile
+	// belonging to another session, runs the -set-region maintenance mode and
+	// asserts only the managed profile's region key changed.
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "config")
+	cfg := ini.Empty()
+	managed, _ := cfg.NewSection("profile ReadOnly_Prod_111")
+	managed.NewKey("sso_session", "corp")
+	managed.NewKey("sso_account_id", "111")
+	managed.NewKey("sso_role_name", "AWSReadOnlyAccess")
+	managed.NewKey("region", "us-east-1")
+	managed.NewKey("output", "json")
+	other, _ := cfg.NewSection("profile Other_222")
+	other.NewKey("sso_session", "someone-else")
+	other.NewKey("region", "us-east-1")
+	if err := cfg.SaveTo(cfgPath); err != nil {
+		t.Fatalf("failed to write temp config: %v", err)
+	}
+
+	oldConfig, oldSession, oldDry := ssoConfigFile, ssoSessionConfigName, dryRun
+	defer func() { ssoConfigFile, ssoSessionConfigName, dryRun = oldConfig, oldSession, oldDry }()
+	ssoConfigFile = cfgPath
+	ssoSessionConfigName = "corp"
+
+	// Dry-run reports the change without writing it.
+	dryRun = true
+	updated, err := setRegionForManagedProfiles("eu-central-1")
+	if err != nil || len(updated) != 1 {
+		t.Fatalf("dry-run setRegionForManagedProfiles: updated=%v err=%v", updated, err)
+	}
+	if reloaded, _ := ini.Load(cfgPath); reloaded.Section("profile ReadOnly_Prod_111").Key("region").String() != "us-east-1" {
+		t.Fatalf("dry-run modified the config file")
+	}
+
+	dryRun = false
+	if _, err := setRegionForManagedProfiles("eu-central-1"); err != nil {
+		t.Fatalf("setRegionForManagedProfiles failed: %v", err)
+	}
+	reloaded, err := ini.Load(cfgPath)
+	if err != nil {
+		t.Fatalf("failed to reload config: %v", err)
+	}
+	sec := reloaded.Section("profile ReadOnly_Prod_111")
+	if sec.Key("region").String() != "eu-central-1" {
+		t.Fatalf("managed profile region not updated: %q", sec.Key("region").String())
+	}
+	if sec.Key("sso_session").String() != "corp" || sec.Key("sso_account_id").String() != "111" || sec.Key("sso_role_name").String() != "AWSReadOnlyAccess" || sec.Key("output").String() != "json" {
+		t.Fatalf("unexpected change to non-region keys: %v", sec.KeysHash())
+	}
+	if reloaded.Section("profile Other_222").Key("region").String() != "us-east-1" {
+		t.Fatalf("profile of another session was modified")
+	}
+}
+
+func TestCompactNames(t *testing.T) {
+	// TestCompactNames asserts -compact-names abbreviates built-in words in
+	// both the account name and the role-derived prefix, and that -abbrev
+	// overrides take precedence over the built-in map.
+	oldPrefix, oldAuto, oldStyle, oldCompact, oldAbbrev := profilePrefix, useAutoPrefix, nameStyle, compactNames, userAbbreviations
+	defer func() {
+		profilePrefix, useAutoPrefix, nameStyle, compactNames, userAbbreviations = oldPrefix, oldAuto, oldStyle, oldCompact, oldAbbrev
+	}()
+	profilePrefix = ""
+	useAutoPrefix = true
+	nameStyle = nameStyleRoleAccount
+	compactNames = true
+	userAbbreviations = nil
+
+	role := CombinedRole{AccountId: "111", AccountName: "Payments Production", RoleName: "AWSReadOnlyAccess"}
+	if got := getProfileNameFromRole(role); got != "ro_Payments-prod_111" {
+		t.Fatalf("unexpected compact name: %q", got)
+	}
+
+	overrides, err := parseAbbreviations([]string{"Production=prd", "Payments=pay"})
+	if err != nil {
+		t.Fatalf("parseAbbreviations failed: %v", err)
+	}
+	userAbbreviations = overrides
+	if got := getProfileNameFromRole(role); got != "ro_pay-prd_111" {
+		t.Fatalf("expected user overrides to win, got %q", got)
+	}
+
+	if _, err := parseAbbreviations([]string{"Production"}); err == nil {
+		t.Fatalf("expected malformed -abbrev to be rejected")
+	}
+}
+
+func TestAnnotatedSessionBlock(t *testing.T) {
+	// TestAnnotatedSessionBlock verifies a newly created sso-session block is
+	// preceded by region and created-by comments, that the comments survive a
+	// later profile write, and that -annotate-session=false omits them.
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "config")
+
+	oldConfigFile, oldSession, oldStart, oldRegion, oldDry, oldAnnotate := ssoConfigFile, ssoSessionConfigName, ssoStartURL, ssoRegion, dryRun, annotateSession
+	defer func() {
+		ssoConfigFile, ssoSessionConfigName, ssoStartURL, ssoRegion, dryRun, annotateSession = oldConfigFile, oldSession, oldStart, oldRegion, oldDry, oldAnnotate
+	}()
+	ssoConfigFile = cfgPath
+	ssoSessionConfigName = "annotated"
+	ssoStartURL = "https://unit.test/start"
+	ssoRegion = "eu-west-1"
+	dryRun = false
+	annotateSession = true
+
+	if _, err := ensureSsoSessionConfigPresent(); err != nil {
+		t.Fatalf("ensureSsoSessionConfigPresent failed: %v", err)
+	}
+	role := CombinedRole{AccountId: "111", AccountName: "Prod", RoleName: "AWSReadOnlyAccess"}
+	if err := writeProfileToConfig("Prod_111", role); err != nil {
+		t.Fatalf("writeProfileToConfig failed: %v", err)
+	}
+	data, err := os.ReadFile(cfgPath)
+	if err != nil {
+		t.Fatalf("failed to read config: %v", err)
+	}
+	for _, want := range []string{"# region: eu-west-1", "# created-by: aws-sso-profile-sync " + version} {
+		if !strings.Contains(string(data), want) {
+			t.Fatalf("expected %q in config:\n%s", want, data)
+		}
+	}
+
+	// Without annotation no comments are written.
+	ssoConfigFile = filepath.Join(dir, "plain")
+	annotateSession = false
+	if _, err := ensureSsoSessionConfigPresent(); err != nil {
+		t.Fatalf("ensureSsoSessionConfigPresent failed: %v", err)
+	}
+	plain, _ := os.ReadFile(ssoConfigFile)
+	if strings.Contains(string(plain), "#") {
+		t.Fatalf("unexpected comment with -annotate-session=false:\n%s", plain)
+	}
+}
+
+// TestUnreadableConfigAbortsEarly makes the config file unreadable (mode
+// 000) and asserts the sync stops with a permissions error instead of
+// treating the file as empty and overwriting it.
+func TestUnreadableConfigAbortsEarly(t *testing.T) {
+	if runtime.GOOS == "windows" || os.Geteuid() == 0 {
+		t.Skip("file mode permissions are not enforced for this platform/user")
+	}
+	cfgPath := filepath.Join(t.TempDir(), "config")
+	original := "[profile keep]\nregion = us-east-1\n"
+	if err := os.WriteFile(cfgPath, []byte(original), 0o000); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	defer os.Chmod(cfgPath, 0o600)
+
+	err := checkConfigReadable(cfgPath)
+	if err == nil || !strings.Contains(err.Error(), "not readable (permission denied)") {
+		t.Fatalf("expected a permissions error, got %v", err)
+	}
+	if err := checkConfigReadable(filepath.Join(t.TempDir(), "missing")); err != nil {
+		t.Fatalf("a missing config should be allowed, got %v", err)
+	}
+
+	oldConfig, oldDry := ssoConfigFile, dryRun
+	defer func() { ssoConfigFile, dryRun = oldConfig, oldDry }()
+	ssoConfigFile, dryRun = cfgPath, false
+	if err := writeProfileToConfig("new", CombinedRole{AccountId: "111", RoleName: "AWSReadOnlyAccess"}); err == nil {
+		t.Fatalf("expected writeProfileToConfig to fail on an unreadable config")
+	}
+	os.Chmod(cfgPath, 0o600)
+	if data, _ := os.ReadFile(cfgPath); string(data) != original {
+		t.Fatalf("unreadable config was overwritten:\n%s", data)
+	}
+}
+
+// TestPruneScopePrefix seeds revoked profiles under two team prefixes and
+// asserts -prune-scope-prefix only removes the ones under its prefix.
+func TestPruneScopePrefix(t *testing.T) {
+	cfgPath := filepath.Join(t.TempDir(), "config")
+	cfg := ini.Empty()
+	for name, keys := range map[string][]string{
+		"profile teamA-ReadOnly_Prod_111": {"111", "AWSReadOnlyAccess"},
+		"profile teamA-Admin_Prod_111":    {"111", "AWSAdministratorAccess"},
+		"profile teamB-Admin_Prod_111":    {"111", "AWSAdministratorAccess"},
+	} {
+		section, _ := cfg.NewSection(name)
+		section.NewKey("sso_session", "corp")
+		section.NewKey("sso_account_id", keys[0])
+		section.NewKey("sso_role_name", keys[1])
+	}
+	if err := cfg.SaveTo(cfgPath); err != nil {
+		t.Fatalf("failed to write temp config: %v", err)
+	}
+
+	oldConfig, oldSession, oldDry, oldScope, oldRoles := ssoConfigFile, ssoSessionConfigName, dryRun, pruneScopePrefix, ssoRoleNames
+	defer func() {
+		ssoConfigFile, ssoSessionConfigName, dryRun, pruneScopePrefix, ssoRoleNames = oldConfig, oldSession, oldDry, oldScope, oldRoles
+	}()
+	ssoConfigFile, ssoSessionConfigName, dryRun, pruneScopePrefix, ssoRoleNames = cfgPath, "corp", false, "teamA-", defaultRoleNames
+	roles := []CombinedRole{{AccountId: "111", AccountName: "Prod", RoleName: "AWSReadOnlyAccess"}}
+
+	var pruned []ProfileResult
+	var err error
+	captureStdout(t, func() { pruned, err = pruneStaleProfiles(roles, nil) })
+	if err != nil || len(pruned) != 1 || pruned[0].ProfileName != "teamA-Admin_Prod_111" {
+		t.Fatalf("pruneStaleProfiles: pruned=%v err=%v", pruned, err)
+	}
+	reloaded, err := ini.Load(cfgPath)
+	if err != nil {
+		t.Fatalf("failed to reload config: %v", err)
+	}
+	if reloaded.HasSection("profile teamA-Admin_Prod_111") {
+		t.Fatalf("scoped revoked profile was not removed")
+	}
+	if !reloaded.HasSection("profile teamB-Admin_Prod_111") || !reloaded.HasSection("profile teamA-ReadOnly_Prod_111") {
+		t.Fatalf("prune removed a profile outside its scope: %v", reloaded.SectionStrings())
+	}
+}
+
+// TestPruneStaleProfiles seeds a still-assigned profile, a revoked one and a
+// profile of another session, and asserts -prune only removes the revoked
+// profile, and only reports it in dry-run.
+func TestPruneStaleProfiles(t *testing.T) {
+	cfgPath := filepath.Join(t.TempDir(), "config")
+	cfg := ini.Empty()
+	for name, keys := range map[string][]string{
+		"profile ReadOnly_Prod_111": {"corp", "111", "AWSReadOnlyAccess"},
+		"profile Admin_Prod_111":    {"corp", "111", "AWSAdministratorAccess"},
+		"profile Other_222":         {"someone-else", "222", "AWSReadOnlyAccess"},
+	} {
+		section, _ := cfg.NewSection(name)
+		section.NewKey("sso_session", keys[0])
+		section.NewKey("sso_account_id", keys[1])
+		section.NewKey("sso_role_name", keys[2])
+	}
+	if err := cfg.SaveTo(cfgPath); err != nil {
+		t.Fatalf("failed to write temp config: %v", err)
+	}
+
+	oldConfig, oldSession, oldDry, oldRoles := ssoConfigFile, ssoSessionConfigName, dryRun, ssoRoleNames
+	defer func() {
+		ssoConfigFile, ssoSessionConfigName, dryRun, ssoRoleNames = oldConfig, oldSession, oldDry, oldRoles
+	}()
+	ssoConfigFile, ssoSessionConfigName, ssoRoleNames = cfgPath, "corp", defaultRoleNames
+	roles := []CombinedRole{{AccountId: "111", AccountName: "Prod", RoleName: "AWSReadOnlyAccess"}}
+
+	dryRun = true
+	var pruned []ProfileResult
+	var err error
+	out := captureStdout(t, func() { pruned, err = pruneStaleProfiles(roles, nil) })
+	if err != nil || len(pruned) != 1 || pruned[0] != (ProfileResult{ProfileName: "Admin_Prod_111", AccountId: "111", RoleName: "AWSAdministratorAccess"}) {
+		t.Fatalf("dry-run pruneStaleProfiles: pruned=%v err=%v", pruned, err)
+	}
+	if !strings.Contains(out, "Would remove profile: Admin_Prod_111") {
+		t.Fatalf("expected a would-remove line, got %q", out)
+	}
+	if reloaded, _ := ini.Load(cfgPath); !reloaded.HasSection("profile Admin_Prod_111") {
+		t.Fatalf("dry-run modified the config file")
+	}
+
+	dryRun = false
+	captureStdout(t, func() { pruned, err = pruneStaleProfiles(roles, nil) })
+	if err != nil {
+		t.Fatalf("pruneStaleProfiles failed: %v", err)
+	}
+	reloaded, err := ini.Load(cfgPath)
+	if err != nil {
+		t.Fatalf("failed to reload config: %v", err)
+	}
+	if reloaded.HasSection("profile Admin_Prod_111") {
+		t.Fatalf("revoked profile was not removed")
+	}
+	if !reloaded.HasSection("profile ReadOnly_Prod_111") || !reloaded.HasSection("profile Other_222") {
+		t.Fatalf("prune removed a profile it should have kept: %v", reloaded.SectionStrings())
+	}
+}
+
+func TestCheckSessionScopes(t *testing.T) {
+	// TestCheckSessionScopes verifies a reused session missing
+	// sso:account:access is only warned about by default, shown as a change
+	// in dry-run with -fix-scopes, and updated in place otherwise.
+	cfgPath := filepath.Join(t.TempDir(), "config")
+	original := "[sso-session corp]\nsso_start_url = https://unit.test/start\nsso_region = us-east-1\nsso_registration_scopes = openid\n"
+	if err := os.WriteFile(cfgPath, []byte(original), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	oldConfig, oldSession, oldFix, oldDry, oldChecked := ssoConfigFile, ssoSessionConfigName, fixScopes, dryRun, scopesChecked
+	defer func() {
+		ssoConfigFile, ssoSessionConfigName, fixScopes, dryRun, scopesChecked = oldConfig, oldSession, oldFix, oldDry, oldChecked
+	}()
+	ssoConfigFile, ssoSessionConfigName = cfgPath, "corp"
+
+	run := func(fix, dry bool) (string, string) {
+		scopesChecked = make(map[string]bool)
+		fixScopes, dryRun = fix, dry
+		var stdout string
+		stderr := captureStderr(t, func() {
+			stdout = captureStdout(t, func() {
+				if _, err := ensureSsoSessionConfigPresent(); err != nil {
+					t.Fatalf("ensureSsoSessionConfigPresent failed: %v", err)
+				}
+			})
+		})
+		return stdout, stderr
+	}
+	unchanged := func() {
+		t.Helper()
+		if data, _ := os.ReadFile(cfgPath); string(data) != original {
+			t.Fatalf("config changed unexpectedly:\n%s", data)
+		}
+	}
+
+	if stdout, stderr := run(false, false); !strings.Contains(stdout+stderr, "-fix-scopes") {
+		t.Fatalf("expected a warning mentioning -fix-scopes, got %q", stdout+stderr)
+	}
+	unchanged()
+	if stdout, _ := run(true, true); !strings.Contains(stdout, `"openid" -> "openid, sso:account:access"`) {
+		t.Fatalf("expected the dry-run scope change, got %q", stdout)
+	}
+	unchanged()
+
+	run(true, false)
+	cfg, err := ini.Load(cfgPath)
+	if err != nil {
+		t.Fatalf("failed to load updated config: %v", err)
+	}
+	sec := cfg.Section("sso-session corp")
+	if got := sec.Key("sso_registration_scopes").String(); got != "openid, sso:account:access" {
+		t.Fatalf("unexpected scopes %q", got)
+	}
+	if sec.Key("sso_start_url").String() != "https://unit.test/start" {
+		t.Fatal("expected the other session keys to be kept")
+	}
+	if stdout, stderr := run(false, false); stdout+stderr != "" {
+		t.Fatalf("expected no output once the scope is present, got %q", stdout+stderr)
+	}
+}
+
+func TestCheckSessionScopesDefault(t *testing.T) {
+	// TestCheckSessionScopesDefault verifies a session with an empty or absent
+	// sso_registration_scopes is accepted as is: the AWS CLI requests
+	// sso:account:access by default, so nothing is reported or rewritten.
+	oldConfig, oldFix, oldDry, oldChecked := ssoConfigFile, fixScopes, dryRun, scopesChecked
+	defer func() {
+		ssoConfigFile, fixScopes, dryRun, scopesChecked = oldConfig, oldFix, oldDry, oldChecked
+	}()
+	fixScopes, dryRun = true, false
+
+	for name, original := range map[string]string{
+		"absent": "[sso-session corp]\nsso_start_url = https://unit.test/start\nsso_region = us-east-1\n",
+		"empty":  "[sso-session corp]\nsso_start_url = https://unit.test/start\nsso_region = us-east-1\nsso_registration_scopes =\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			cfgPath := filepath.Join(t.TempDir(), "config")
+			if err := os.WriteFile(cfgPath, []byte(original), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			ssoConfigFile, scopesChecked = cfgPath, make(map[string]bool)
+			var stdout string
+			stderr := captureStderr(t, func() {
+				stdout = captureStdout(t, func() {
+					if err := checkSessionScopes("corp", cfgPath); err != nil {
+						t.Fatalf("checkSessionScopes failed: %v", err)
+					}
+				})
+			})
+			if stdout+stderr != "" {
+				t.Fatalf("expected no output, got %q", stdout+stderr)
+			}
+			if data, _ := os.ReadFile(cfgPath); string(data) != original {
+				t.Fatalf("config changed unexpectedly:\n%s", data)
+			}
+		})
+	}
+}
+
+// TestPruneKeepsProfilesOutsideSelection runs -prune with a narrowed role
+// and account selection and asserts only the revoked profile inside the
+// selection is removed; profiles of unselected roles and of accounts the
+// filters left out are kept even though discovery did not return them.
+func TestPruneKeepsProfilesOutsideSelection(t *testing.T) {
+	cfgPath := filepath.Join(t.TempDir(), "config")
+	cfg := ini.Empty()
+	for name, keys := range map[string][]string{
+		"profile ReadOnly_Prod_111":   {"111", "AWSReadOnlyAccess"},
+		"profile PowerUser_Prod_111":  {"111", "AWSPowerUserAccess"},
+		"profile Admin_Prod_111":      {"111", "AWSAdministratorAccess"},
+		"profile ReadOnly_Dev_222":    {"222", "AWSReadOnlyAccess"},
+		"profile ReadOnly_Search_333": {"333", "AWSReadOnlyAccess"},
+	} {
+		section, _ := cfg.NewSection(name)
+		section.NewKey("sso_session", "corp")
+		section.NewKey("sso_account_id", keys[0])
+		section.NewKey("sso_role_name", keys[1])
+	}
+	if err := cfg.SaveTo(cfgPath); err != nil {
+		t.Fatalf("failed to write temp config: %v", err)
+	}
+
+	oldConfig, oldSession, oldDry, oldRoles, oldIds, oldNames := ssoConfigFile, ssoSessionConfigName, dryRun, ssoRoleNames, accountIdFilter, accountNameRegex
+	defer func() {
+		ssoConfigFile, ssoSessionConfigName, dryRun, ssoRoleNames, accountIdFilter, accountNameRegex = oldConfig, oldSession, oldDry, oldRoles, oldIds, oldNames
+	}()
+	ssoConfigFile, ssoSessionConfigName, dryRun = cfgPath, "corp", false
+	ssoRoleNames = []string{"AWSReadOnlyAccess", "AWSPowerUserAccess"}
+	accountIdFilter = map[string]bool{"111": true, "333": true}
+	accountNameRegex = regexp.MustCompile(`^Prod$`)
+	selected := []ssoTypesAccount{{AccountId: "111", AccountName: "Prod"}}
+	roles := []CombinedRole{{AccountId: "111", AccountName: "Prod", RoleName: "AWSReadOnlyAccess"}}
+
+	var pruned []ProfileResult
+	var err error
+	captureStdout(t, func() { pruned, err = pruneStaleProfiles(roles, selected) })
+	if err != nil || len(pruned) != 1 || pruned[0].ProfileName != "PowerUser_Prod_111" {
+		t.Fatalf("pruneStaleProfiles: pruned=%v err=%v", pruned, err)
+	}
+	reloaded, err := ini.Load(cfgPath)
+	if err != nil {
+		t.Fatalf("failed to reload config: %v", err)
+	}
+	for _, kept := range []string{"ReadOnly_Prod_111", "Admin_Prod_111", "ReadOnly_Dev_222", "ReadOnly_Search_333"} {
+		if !reloaded.HasSection("profile " + kept) {
+			t.Errorf("prune removed %s, which is outside the selection", kept)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// metricsPrefix is prepended to every metric written by -metrics-file.
+const metricsPrefix = "aws_sso_profile_sync_"
+
+// metricsPath is the Prometheus textfile written by -metrics-file.
+var metricsPath string
+
+// writeMetricsFile writes the run's result to path in the Prometheus text
+// exposition format. The file is replaced atomically so the node exporter's
+// textfile collector never reads a partial file. The last-success timestamp
+// only moves on a real run without failures; otherwise the previous value is
+// carried over.
+func writeMetricsFile(path string, result SyncResult, now time.Time) error {
+	lastSuccess := float64(now.Unix())
+	if result.DryRun || len(result.Failed) > 0 {
+		var ok bool
+		if lastSuccess, ok = readMetricValue(path, metricsPrefix+"last_success_timestamp"); !ok {
+			lastSuccess = 0
+		}
+	}
+	metrics := []struct {
+		name, help string
+		value      float64
+	}{
+		{"profiles_added", "Profiles added by the last run.", float64(len(result.Added))},
+		{"profiles_updated", "Existing profiles rewritten by -force in the last run.", float64(len(result.Updated))},
+		{"profiles_skipped", "Profiles that already existed in the last run.", float64(len(result.Skipped))},
+		{"profiles_removed", "Stale profiles removed by the last run.", float64(len(result.Pruned))},
+		{"accounts_total", "Accounts considered by the last run.", float64(result.AccountsTotal)},
+		{"duration_seconds", "Duration of the last run's sync in seconds.", result.Duration.Seconds()},
+		{"last_success_timestamp", "Unix time of the last run that completed without failures.", lastSuccess},
+	}
+	var b strings.Builder
+	for _, m := range metrics {
+		fmt.Fprintf(&b, "# HELP %s%s %s\n", metricsPrefix, m.name, m.help)
+		fmt.Fprintf(&b, "# TYPE %s%s gauge\n", metricsPrefix, m.name)
+		fmt.Fprintf(&b, "%s%s %s\n", metricsPrefix, m.name, strconv.FormatFloat(m.value, 'g', -1, 64))
+	}
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, []byte(b.String()), 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
+
+// readMetricValue returns the value of an unlabelled metric in an existing
+// textfile, if present.
+func readMetricValue(path, name string) (float64, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, false
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == name {
+			v, err := strconv.ParseFloat(fields[1], 64)
+			return v, err == nil
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestMetricsFile simulates a sync with -metrics-file and asserts every
+// metric is written with the run's values, and that a failed run keeps the
+// previous last-success timestamp.
+func TestMetricsFile(t *testing.T) {
+	stubDiscovery(t,
+		[]ssoTypesAccount{{AccountId: "111", AccountName: "Prod"}, {AccountId: "222", AccountName: "Dev"}},
+		map[string][]string{"111": {"AWSReadOnlyAccess"}, "222": {"AWSReadOnlyAccess"}})
+	dir := t.TempDir()
+	oldMetrics, oldConfig, oldDry, oldRoles := metricsPath, ssoConfigFile, dryRun, ssoRoleNames
+	defer func() { metricsPath, ssoConfigFile, dryRun, ssoRoleNames = oldMetrics, oldConfig, oldDry, oldRoles }()
+	metricsPath = filepath.Join(dir, "sync.prom")
+	ssoConfigFile = filepath.Join(dir, "config")
+	dryRun = false
+	ssoRoleNames = []string{"AWSReadOnlyAccess"}
+
+	var err error
+	captureStdout(t, func() { _, err = configureSsoProfiles("token") })
+	if err != nil {
+		t.Fatalf("configureSsoProfiles failed: %v", err)
+	}
+	data, err := os.ReadFile(metricsPath)
+	if err != nil {
+		t.Fatalf("metrics file not written: %v", err)
+	}
+	text := string(data)
+	for _, want := range []string{
+		"aws_sso_profile_sync_profiles_added 2\n",
+		"aws_sso_profile_sync_profiles_skipped 0\n",
+		"aws_sso_profile_sync_profiles_removed 0\n",
+		"aws_sso_profile_sync_accounts_total 2\n",
+		"# TYPE aws_sso_profile_sync_duration_seconds gauge\n",
+		"aws_sso_profile_sync_duration_seconds ",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("metrics missing %q:\n%s", want, text)
+		}
+	}
+	lastSuccess, ok := readMetricValue(metricsPath, "aws_sso_profile_sync_last_success_timestamp")
+	if !ok || lastSuccess <= 0 {
+		t.Fatalf("expected a last success timestamp, got %v", lastSuccess)
+	}
+	if _, err := os.Stat(metricsPath + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("temporary metrics file left behind: %v", err)
+	}
+
+	failed := SyncResult{Failed: []ProfileResult{{ProfileName: "x"}}}
+	if err := writeMetricsFile(metricsPath, failed, time.Unix(int64(lastSuccess)+3600, 0)); err != nil {
+		t.Fatalf("writeMetricsFile failed: %v", err)
+	}
+	if got, _ := readMetricValue(metricsPath, "aws_sso_profile_sync_last_success_timestamp"); got != lastSuccess {
+		t.Errorf("failed run moved the last success timestamp from %v to %v", lastSuccess, got)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// mockSSOServer is an httptest server speaking the SSO OIDC device flow
+// (RegisterClient, StartDeviceAuthorization, CreateToken) and the SSO portal
+// API (ListAccounts, ListAccountRoles). CreateToken answers
+// authorization_pending pendingPolls times before issuing accessToken, and
+// the portal only accepts that token.
+type mockSSOServer struct {
+	*httptest.Server
+	accessToken  string
+	accounts     []ssoTypesAccount
+	roles        map[string][]string
+	pendingPolls int
+
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+// newMockSSOServer starts a mock server that is closed when the test ends.
+func newMockSSOServer(t *testing.T, accounts []ssoTypesAccount, roles map[string][]string) *mockSSOServer {
+	t.Helper()
+	m := &mockSSOServer{accessToken: "mock-access-token", accounts: accounts, roles: roles, calls: make(map[string]int)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /client/register", func(w http.ResponseWriter, r *http.Request) {
+		m.count("RegisterClient")
+		writeMockJSON(w, http.StatusOK, map[string]interface{}{
+			"clientId": "mock-client", "clientSecret": "mock-secret", "clientSecretExpiresAt": 4102444800,
+		})
+	})
+	mux.HandleFunc("POST /device_authorization", func(w http.ResponseWriter, r *http.Request) {
+		m.count("StartDeviceAuthorization")
+		writeMockJSON(w, http.StatusOK, map[string]interface{}{
+			"deviceCode": "mock-device", "userCode": "ABCD-EFGH",
+			"verificationUri": m.URL + "/verify", "verificationUriComplete": m.URL + "/verify?code=ABCD-EFGH",
+			"expiresIn": 600, "interval": 1,
+		})
+	})
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		if m.count("CreateToken") <= m.pendingPolls {
+			w.Header().Set("X-Amzn-Errortype", "AuthorizationPendingException")
+			writeMockJSON(w, http.StatusBadRequest, map[string]string{"error": "authorization_pending"})
+			return
+		}
+		writeMockJSON(w, http.StatusOK, map[string]interface{}{
+			"accessToken": m.accessToken, "tokenType": "Bearer", "expiresIn": 28800, "refreshToken": "mock-refresh",
+		})
+	})
+	mux.HandleFunc("GET /assignment/accounts", func(w http.ResponseWriter, r *http.Request) {
+		m.count("ListAccounts")
+		if !m.authorized(w, r) {
+			return
+		}
+		var list []map[string]string
+		for _, a := range m.accounts {
+			list = append(list, map[string]string{"accountId": a.AccountId, "accountName": a.AccountName})
+		}
+		writeMockJSON(w, http.StatusOK, map[string]interface{}{"accountList": list})
+	})
+	mux.HandleFunc("GET /assignment/roles", func(w http.ResponseWriter, r *http.Request) {
+		m.count("ListAccountRoles")
+		if !m.authorized(w, r) {
+			return
+		}
+		accountId := r.URL.Query().Get("account_id")
+		var list []map[string]string
+		for _, role := range m.roles[accountId] {
+			list = append(list, map[string]string{"roleName": role, "accountId": accountId})
+		}
+		writeMockJSON(w, http.StatusOK, map[string]interface{}{"roleList": list})
+	})
+	m.Server = httptest.NewServer(mux)
+	t.Cleanup(m.Close)
+	return m
+}
+
+// count records a call to operation and returns how often it was called.
+func (m *mockSSOServer) count(operation string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls[operation]++
+	return m.calls[operation]
+}
+
+// callCount returns how often operation was called.
+func (m *mockSSOServer) callCount(operation string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.calls[operation]
+}
+
+// authorized rejects portal calls without the issued bearer token.
+func (m *mockSSOServer) authorized(w http.ResponseWriter, r *http.Request) bool {
+	if r.Header.Get("X-Amz-Sso_bearer_token") == m.accessToken {
+		return true
+	}
+	w.Header().Set("X-Amzn-Errortype", "UnauthorizedException")
+	writeMockJSON(w, http.StatusUnauthorized, map[string]string{"message": "Session token not found or invalid"})
+	return false
+}
+
+func writeMockJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
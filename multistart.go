@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// startURLs holds the start URLs of a run against several IAM Identity
+// Center instances (-sso-start-urls plus any -sso-start-url). With more than
+// one, login, discovery and profile configuration run once per URL.
+var startURLs []string
+
+// parseStartURLs merges -sso-start-url and the comma-separated
+// -sso-start-urls list into one list without duplicates, keeping
+// -sso-start-url first.
+func parseStartURLs(single, list string) []string {
+	var out []string
+	seen := make(map[string]bool)
+	for _, u := range append([]string{single}, strings.Split(list, ",")...) {
+		u = strings.TrimRight(strings.TrimSpace(u), "/")
+		if u == "" || seen[u] {
+			continue
+		}
+		seen[u] = true
+		out = append(out, u)
+	}
+	return out
+}
+
+// startURLSessionNames returns the sso-session name for each start URL.
+// Names that two URLs would share get a numeric suffix.
+func startURLSessionNames(urls []string) []string {
+	names := make([]string, len(urls))
+	used := make(map[string]int)
+	for i, u := range urls {
+		name := deriveSessionName(u)
+		used[name]++
+		if used[name] > 1 {
+			name = fmt.Sprintf("%s-%d", name, used[name])
+		}
+		names[i] = name
+	}
+	return names
+}
+
+// syncStartURLs runs sync once per start URL, each with its own sso-session
+// block named by startURLSessionNames, stopping at the first failure. The
+// cached token is looked up for the current URL on every pass because
+// ssoStartURL is switched before sync runs. Afterwards it prints totals
+// across every instance.
+func syncStartURLs(urls []string, sync func() error) error {
+	names := startURLSessionNames(urls)
+	first := len(syncResults)
+	for i, u := range urls {
+		infof("\n%s%s %d/%d: %s (session %s)\n", cyan(icon("url")), bold("Start URL"), i+1, len(urls), u, names[i])
+		ssoStartURL, ssoSessionConfigName = u, names[i]
+		if err := sync(); err != nil {
+			return fmt.Errorf("start URL %s: %w", u, err)
+		}
+	}
+	printAggregateSummary(syncResults[first:], len(urls))
+	return nil
+}
+
+// printAggregateSummary prints the added, updated, skipped and failed counts
+// summed over the results of a run across several instances.
+func printAggregateSummary(results []SyncResult, instances int) {
+	var added, updated, skipped, failed int
+	for _, r := range results {
+		added += len(r.Added)
+		updated += len(r.Updated)
+		skipped += len(r.Skipped)
+		failed += len(r.Failed)
+	}
+	if dryRun {
+		resultf("\n%s%s %d profile(s) would be added, %d updated, %d already configured, %d failed.\n", cyan(icon("summary")), bold(fmt.Sprintf("Dry-run total across %d instance(s):", instances)), added, updated, skipped, failed)
+		return
+	}
+	resultf("\n%s%s %d new profile(s), %d updated, %d already configured, %d failed.\n", cyan(icon("summary")), bold(fmt.Sprintf("Total across %d instance(s):", instances)), added, updated, skipped, failed)
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestSyncStartURLs asserts several start URLs are merged without
+// duplicates, get host-derived session names, are synced one after another
+// with the globals switched per URL, and that the totals add up.
+func TestSyncStartURLs(t *testing.T) {
+	urls := parseStartURLs("https://corp.awsapps.com/start/", "https://corp.awsapps.com/start, https://ssoins-1.portal.eu-west-1.app.aws,https://corp.awsapps.com/other")
+	if strings.Join(urls, " ") != "https://corp.awsapps.com/start https://ssoins-1.portal.eu-west-1.app.aws https://corp.awsapps.com/other" {
+		t.Fatalf("unexpected start URLs %v", urls)
+	}
+	if names := startURLSessionNames(urls); strings.Join(names, " ") != "corp ssoins-1 corp-2" {
+		t.Fatalf("unexpected session names %v", names)
+	}
+
+	oldStart, oldSession, oldResults, oldDry := ssoStartURL, ssoSessionConfigName, syncResults, dryRun
+	defer func() {
+		ssoStartURL, ssoSessionConfigName, syncResults, dryRun = oldStart, oldSession, oldResults, oldDry
+	}()
+	syncResults, dryRun = nil, false
+	var seen []string
+	out := captureStdout(t, func() {
+		err := syncStartURLs(urls[:2], func() error {
+			seen = append(seen, ssoStartURL+"="+ssoSessionConfigName)
+			syncResults = append(syncResults, SyncResult{Added: make([]ProfileResult, 2), Skipped: make([]ProfileResult, 1)})
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("syncStartURLs failed: %v", err)
+		}
+	})
+	if strings.Join(seen, " ") != "https://corp.awsapps.com/start=corp https://ssoins-1.portal.eu-west-1.app.aws=ssoins-1" {
+		t.Fatalf("unexpected per-URL settings %v", seen)
+	}
+	if !strings.Contains(out, "Total across 2 instance(s): 4 new profile(s), 0 updated, 2 already configured, 0 failed.") {
+		t.Fatalf("expected aggregated totals, got:\n%s", out)
+	}
+}
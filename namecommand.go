@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+// nameCommand is the external command that names profiles (-name-command).
+var nameCommand string
+
+// unsafeProfileNameChars matches characters that may not appear in a profile
+// name taken from -name-command output.
+var unsafeProfileNameChars = regexp.MustCompile(`[^A-Za-z0-9._@+-]+`)
+
+// nameCommandInput is the JSON document written to -name-command's stdin.
+type nameCommandInput struct {
+	AccountId   string `json:"accountId"`
+	AccountName string `json:"accountName"`
+	RoleName    string `json:"roleName"`
+}
+
+// runNameCommand runs command through the shell with role as JSON on stdin
+// and returns the sanitized profile name from the first line of its stdout.
+func runNameCommand(command string, role CombinedRole) (string, error) {
+	input, err := json.Marshal(nameCommandInput{role.AccountId, role.AccountName, role.RoleName})
+	if err != nil {
+		return "", err
+	}
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(runContext, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(runContext, "sh", "-c", command)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdin = bytes.NewReader(append(input, '\n'))
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%v: %s", err, msg)
+		}
+		return "", err
+	}
+	line, _, _ := strings.Cut(stdout.String(), "\n")
+	name := strings.Trim(unsafeProfileNameChars.ReplaceAllString(strings.TrimSpace(line), "-"), "-")
+	if name == "" {
+		return "", fmt.Errorf("printed no usable profile name (output %q)", stdout.String())
+	}
+	return name, nil
+}
+
+// applyNameCommand names every role's profile with nameCommand. It fails on
+// the first role the command cannot name, so no profile is written under a
+// name the command did not choose.
+func applyNameCommand(roles []CombinedRole, command string) error {
+	for i, role := range roles {
+		name, err := runNameCommand(command, role)
+		if err != nil {
+			return fmt.Errorf("-name-command failed for %s in %s (%s): %v", role.RoleName, role.AccountId, role.AccountName, err)
+		}
+		debugf("-name-command named %s in %s: %s\n", role.RoleName, role.AccountId, name)
+		roles[i].ProfileName = name
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// TestNameCommand runs a small script that builds the name from the JSON on
+// its stdin and asserts the names are used, sanitized and that a failing or
+// silent command stops the sync with a clear error.
+func TestNameCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a POSIX shell script")
+	}
+	dir := t.TempDir()
+	script := filepath.Join(dir, "name.sh")
+	body := `#!/bin/sh
+read input
+id=$(echo "$input" | sed 's/.*"accountId":"\([0-9]*\)".*/\1/')
+role=$(echo "$input" | sed 's/.*"roleName":"\([^"]*\)".*/\1/')
+echo "team x/$role-$id"
+`
+	if err := os.WriteFile(script, []byte(body), 0o755); err != nil {
+		t.Fatalf("failed to write script: %v", err)
+	}
+
+	roles := []CombinedRole{{AccountId: "111", AccountName: "Prod", RoleName: "ReadOnly"}}
+	if err := applyNameCommand(roles, script); err != nil {
+		t.Fatalf("applyNameCommand failed: %v", err)
+	}
+	if got := getProfileNameFromRole(roles[0]); got != "team-x-ReadOnly-111" {
+		t.Fatalf("getProfileNameFromRole = %q, want the sanitized command output", got)
+	}
+
+	err := applyNameCommand(roles, "echo broken >&2; exit 3")
+	if err == nil || !strings.Contains(err.Error(), "-name-command failed for ReadOnly in 111") || !strings.Contains(err.Error(), "broken") {
+		t.Fatalf("expected a clear error for a failing command, got %v", err)
+	}
+	if err := applyNameCommand(roles, "echo '///'"); err == nil || !strings.Contains(err.Error(), "no usable profile name") {
+		t.Fatalf("expected an error for an empty name, got %v", err)
+	}
+
+	stubDiscovery(t,
+		[]ssoTypesAccount{{AccountId: "111", AccountName: "Prod"}, {AccountId: "222", AccountName: "Dev"}},
+		map[string][]string{"111": {"ReadOnly"}, "222": {"ReadOnly"}})
+	oldConfig, oldRoles, oldDry, oldCommand := ssoConfigFile, ssoRoleNames, dryRun, nameCommand
+	defer func() { ssoConfigFile, ssoRoleNames, dryRun, nameCommand = oldConfig, oldRoles, oldDry, oldCommand }()
+	ssoConfigFile = filepath.Join(dir, "config")
+	ssoRoleNames, dryRun, nameCommand = []string{"ReadOnly"}, false, script
+	captureStdout(t, func() { _, err = configureSsoProfiles("token") })
+	if err != nil {
+		t.Fatalf("configureSsoProfiles failed: %v", err)
+	}
+	data, _ := os.ReadFile(ssoConfigFile)
+	if !strings.Contains(string(data), "[profile team-x-ReadOnly-111]") || !strings.Contains(string(data), "[profile team-x-ReadOnly-222]") {
+		t.Fatalf("expected command-chosen profile names, got:\n%s", data)
+	}
+}
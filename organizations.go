@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+)
+
+// accountTagSource looks up the tags on an AWS account. The SSO portal API
+// does not expose tags, so this uses AWS Organizations with the ambient
+// credentials (not the SSO token).
+type accountTagSource interface {
+	AccountTags(ctx context.Context, accountId string) (map[string]string, error)
+}
+
+// organizationsClient implements accountTagSource using the Organizations
+// ListTagsForResource API.
+type organizationsClient struct {
+	client *awsJSONClient
+}
+
+// newAccountTagSourceFunc creates the tag source used by -account-tag. Tests
+// can override this to stub Organizations.
+var newAccountTagSourceFunc = func() (accountTagSource, error) {
+	// Organizations is a global service served from us-east-1.
+	cfg, err := config.LoadDefaultConfig(runContext, config.WithRegion("us-east-1"))
+	if err != nil {
+		return nil, err
+	}
+	return &organizationsClient{client: &awsJSONClient{
+		cfg:           cfg,
+		endpoint:      "https://organizations.us-east-1.amazonaws.com/",
+		signingName:   "organizations",
+		signingRegion: "us-east-1",
+		targetPrefix:  "AWSOrganizationsV20161128",
+	}}, nil
+}
+
+func (o *organizationsClient) AccountTags(ctx context.Context, accountId string) (map[string]string, error) {
+	tags := make(map[string]string)
+	var nextToken string
+	for {
+		in := map[string]string{"ResourceId": accountId}
+		if nextToken != "" {
+			in["NextToken"] = nextToken
+		}
+		var out struct {
+			Tags []struct {
+				Key   string
+				Value string
+			}
+			NextToken string
+		}
+		if err := o.client.call(ctx, "ListTagsForResource", in, &out); err != nil {
+			return nil, err
+		}
+		for _, t := range out.Tags {
+			tags[t.Key] = t.Value
+		}
+		if out.NextToken == "" {
+			return tags, nil
+		}
+		nextToken = out.NextToken
+	}
+}
+
+// parseAccountTagFilters parses -account-tag values of the form key=value.
+func parseAccountTagFilters(values []string) (map[string]string, error) {
+	filters := make(map[string]string)
+	for _, v := range values {
+		parts := strings.SplitN(v, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf("invalid -account-tag %q: expected key=value", v)
+		}
+		filters[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	return filters, nil
+}
+
+// filterAccountsByTags keeps only the accounts whose tags include every
+// key=value pair in filters. Without filters the accounts are returned as-is.
+func filterAccountsByTags(accounts []ssoTypesAccount, filters map[string]string) ([]ssoTypesAccount, error) {
+	if len(filters) == 0 {
+		return accounts, nil
+	}
+	source, err := newAccountTagSourceFunc()
+	if err != nil {
+		return nil, fmt.Errorf("loading credentials for -account-tag: %v", err)
+	}
+	var kept []ssoTypesAccount
+	for _, account := range accounts {
+		tags, err := source.AccountTags(runContext, account.AccountId)
+		if err != nil {
+			if isAccessDeniedError(err) {
+				return nil, fmt.Errorf("-account-tag needs organizations:ListTagsForResource with your ambient AWS credentials, which were denied (%v); use -filter or role@accountId selections instead", err)
+			}
+			return nil, fmt.Errorf("reading tags for account %s: %v", account.AccountId, err)
+		}
+		match := true
+		for k, v := range filters {
+			if tags[k] != v {
+				match = false
+				break
+			}
+		}
+		if match {
+			kept = append(kept, account)
+		}
+	}
+	return kept, nil
+}
+
+// applyRegionsFromTags sets each role's Region from the tagKey tag on its
+// account, for -region-from-tag. Lookups are best effort: without
+// Organizations access a warning is printed and the default region is kept.
+func applyRegionsFromTags(roles []CombinedRole, tagKey string) {
+	source, err := newAccountTagSourceFunc()
+	if err != nil {
+		warnf("%sCould not load credentials for -region-from-tag, using the default region: %v\n", yellow(icon("warn")), err)
+		return
+	}
+	regions := make(map[string]string)
+	for i, role := range roles {
+		region, looked := regions[role.AccountId]
+		if !looked {
+			tags, err := source.AccountTags(runContext, role.AccountId)
+			if err != nil {
+				if isAccessDeniedError(err) {
+					warnf("%s-region-from-tag needs organizations:ListTagsForResource, which was denied; using the default region: %v\n", yellow(icon("warn")), err)
+					return
+				}
+				warnf("%sCould not read tags for account %s, using the default region: %v\n", yellow(icon("warn")), role.AccountId, err)
+			}
+			region = tags[tagKey]
+			regions[role.AccountId] = region
+		}
+		roles[i].Region = region
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/ini.v1"
+)
+
+// fakeTagSource is an in-memory accountTagSource.
+type fakeTagSource struct {
+	tags map[string]map[string]string
+	err  error
+}
+
+func (f fakeTagSource) AccountTags(ctx context.Context, accountId string) (map[string]string, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return f.tags[accountId], nil
+}
+
+func stubTagSource(t *testing.T, source accountTagSource) {
+	t.Helper()
+	orig := newAccountTagSourceFunc
+	t.Cleanup(func() { newAccountTagSourceFunc = orig })
+	newAccountTagSourceFunc = func() (accountTagSource, error) { return source, nil }
+}
+
+// TestAccountTagFilter stubs Organizations tags and verifies -account-tag
+// keeps only the tagged account, in the sync and in the role listing, and
+// that an access-denied response produces
+// a helpful error.
+func TestAccountTagFilter(t *testing.T) {
+	stubDiscovery(t,
+		[]ssoTypesAccount{{AccountId: "111", AccountName: "Payments"}, {AccountId: "222", AccountName: "Search"}},
+		map[string][]string{"111": {"AWSReadOnlyAccess"}, "222": {"AWSReadOnlyAccess"}})
+	stubTagSource(t, fakeTagSource{tags: map[string]map[string]string{
+		"111": {"team": "payments"},
+		"222": {"team": "search"},
+	}})
+
+	oldFilters := accountTagFilters
+	defer func() { accountTagFilters = oldFilters }()
+	filters, err := parseAccountTagFilters([]string{"team=payments"})
+	if err != nil {
+		t.Fatalf("parseAccountTagFilters failed: %v", err)
+	}
+	accountTagFilters = filters
+
+	got, err := getCombinedListOfSsoAccountsAndRoles("token", []string{"AWSReadOnlyAccess"})
+	if err != nil {
+		t.Fatalf("getCombinedListOfSsoAccountsAndRoles failed: %v", err)
+	}
+	if len(got) != 1 || got[0].AccountId != "111" {
+		t.Fatalf("expected only the payments account, got %v", got)
+	}
+	listings, err := gatherAccountRoleListings("token")
+	if err != nil {
+		t.Fatalf("gatherAccountRoleListings failed: %v", err)
+	}
+	if len(listings) != 1 || listings[0].AccountId != "111" {
+		t.Fatalf("expected the listing to show only the payments account, got %v", listings)
+	}
+
+	stubTagSource(t, fakeTagSource{err: &awsAPIError{StatusCode: 400, Code: "AccessDeniedException", Message: "not authorized"}})
+	_, err = getCombinedListOfSsoAccountsAndRoles("token", []string{"AWSReadOnlyAccess"})
+	if err == nil || !strings.Contains(err.Error(), "-filter") {
+		t.Fatalf("expected access denied error suggesting other filters, got %v", err)
+	}
+}
+
+// TestRegionFromTag stubs Organizations so one account carries a home_region
+// tag, and verifies only that account's profile is pinned to it. Denied
+// Organizations access falls back to the default region.
+func TestRegionFromTag(t *testing.T) {
+	stubDiscovery(t,
+		[]ssoTypesAccount{{AccountId: "111", AccountName: "Payments"}, {AccountId: "222", AccountName: "Search"}},
+		map[string][]string{"111": {"AWSReadOnlyAccess"}, "222": {"AWSReadOnlyAccess"}})
+	stubTagSource(t, fakeTagSource{tags: map[string]map[string]string{
+		"111": {"home_region": "eu-central-1"},
+	}})
+
+	oldConfig, oldDry, oldRoles, oldTag := ssoConfigFile, dryRun, ssoRoleNames, regionTagKey
+	oldPrefix, oldAuto, oldRegion := profilePrefix, useAutoPrefix, ssoRegion
+	defer func() {
+		ssoConfigFile, dryRun, ssoRoleNames, regionTagKey = oldConfig, oldDry, oldRoles, oldTag
+		profilePrefix, useAutoPrefix, ssoRegion = oldPrefix, oldAuto, oldRegion
+	}()
+	ssoConfigFile = filepath.Join(t.TempDir(), "config")
+	dryRun, ssoRoleNames, regionTagKey = false, []string{"AWSReadOnlyAccess"}, "home_region"
+	profilePrefix, useAutoPrefix, ssoRegion = "", false, "us-east-1"
+
+	var err error
+	if out := captureStdout(t, func() { _, err = configureSsoProfiles("token") }); err != nil {
+		t.Fatalf("configureSsoProfiles failed: %v\n%s", err, out)
+	}
+	cfg, err := ini.Load(ssoConfigFile)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	if got := cfg.Section("profile Payments_111").Key("region").String(); got != "eu-central-1" {
+		t.Errorf("expected tagged account in eu-central-1, got %q", got)
+	}
+	if got := cfg.Section("profile Search_222").Key("region").String(); got != "us-east-1" {
+		t.Errorf("expected untagged account in the default region, got %q", got)
+	}
+
+	stubTagSource(t, fakeTagSource{err: &awsAPIError{StatusCode: 400, Code: "AccessDeniedException"}})
+	roles := []CombinedRole{{AccountId: "111", RoleName: "AWSReadOnlyAccess"}}
+	out := captureStdout(t, func() { applyRegionsFromTags(roles, "home_region") })
+	if roles[0].Region != "" || !strings.Contains(out, "using the default region") {
+		t.Fatalf("expected graceful fallback without Organizations access, region=%q output:\n%s", roles[0].Region, out)
+	}
+}
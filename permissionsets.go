@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+)
+
+// permissionSet is an IAM Identity Center permission set and the accounts it
+// is provisioned to.
+type permissionSet struct {
+	Name        string
+	Arn         string
+	Description string
+	AccountIds  []string
+}
+
+// permissionSetSource enumerates every permission set in the Identity Center
+// instance. Unlike the portal path, which only sees the roles assigned to the
+// signed-in user and is authorized by the SSO token, this is an admin path
+// that uses the ambient AWS credentials.
+type permissionSetSource interface {
+	PermissionSets(ctx context.Context) ([]permissionSet, error)
+}
+
+// ssoAdminClient implements permissionSetSource using the Identity Center
+// admin (sso-admin) API.
+type ssoAdminClient struct {
+	client *awsJSONClient
+}
+
+// newPermissionSetSourceFunc creates the source used by -list-permission-sets.
+// Tests can override this to stub the admin API.
+var newPermissionSetSourceFunc = func() (permissionSetSource, error) {
+	// The admin API lives in the Identity Center instance's home region.
+	cfg, err := config.LoadDefaultConfig(runContext, config.WithRegion(ssoRegion))
+	if err != nil {
+		return nil, err
+	}
+	return &ssoAdminClient{client: &awsJSONClient{
+		cfg:           cfg,
+		endpoint:      fmt.Sprintf("https://sso.%s.amazonaws.com/", ssoRegion),
+		signingName:   "sso",
+		signingRegion: ssoRegion,
+		targetPrefix:  "SWBExternalService",
+	}}, nil
+}
+
+func (s *ssoAdminClient) PermissionSets(ctx context.Context) ([]permissionSet, error) {
+	var instances struct {
+		Instances []struct {
+			InstanceArn string
+		}
+	}
+	if err := s.client.call(ctx, "ListInstances", map[string]string{}, &instances); err != nil {
+		return nil, err
+	}
+	if len(instances.Instances) == 0 {
+		return nil, fmt.Errorf("no IAM Identity Center instance found in %s", s.client.signingRegion)
+	}
+	instanceArn := instances.Instances[0].InstanceArn
+
+	arns, err := s.paginate(ctx, "ListPermissionSets", map[string]string{"InstanceArn": instanceArn}, "PermissionSets")
+	if err != nil {
+		return nil, err
+	}
+	var sets []permissionSet
+	for _, arn := range arns {
+		var described struct {
+			PermissionSet struct {
+				Name        string
+				Description string
+			}
+		}
+		if err := s.client.call(ctx, "DescribePermissionSet", map[string]string{"InstanceArn": instanceArn, "PermissionSetArn": arn}, &described); err != nil {
+			return nil, err
+		}
+		accounts, err := s.paginate(ctx, "ListAccountsForProvisionedPermissionSet", map[string]string{"InstanceArn": instanceArn, "PermissionSetArn": arn}, "AccountIds")
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(accounts)
+		sets = append(sets, permissionSet{
+			Name:        described.PermissionSet.Name,
+			Arn:         arn,
+			Description: described.PermissionSet.Description,
+			AccountIds:  accounts,
+		})
+	}
+	sort.Slice(sets, func(i, j int) bool { return sets[i].Name < sets[j].Name })
+	return sets, nil
+}
+
+// paginate calls a list operation until NextToken is exhausted and collects
+// the string list held in field.
+func (s *ssoAdminClient) paginate(ctx context.Context, operation string, in map[string]string, field string) ([]string, error) {
+	var all []string
+	for {
+		var out map[string]interface{}
+		if err := s.client.call(ctx, operation, in, &out); err != nil {
+			return nil, err
+		}
+		items, _ := out[field].([]interface{})
+		for _, item := range items {
+			if v, ok := item.(string); ok {
+				all = append(all, v)
+			}
+		}
+		next, _ := out["NextToken"].(string)
+		if next == "" {
+			return all, nil
+		}
+		in["NextToken"] = next
+	}
+}
+
+// listPermissionSets prints every permission set with its description and
+// the accounts it is provisioned to.
+func listPermissionSets() error {
+	source, err := newPermissionSetSourceFunc()
+	if err != nil {
+		return fmt.Errorf("loading admin credentials: %v", err)
+	}
+	sets, err := source.PermissionSets(runContext)
+	if err != nil {
+		if isAccessDeniedError(err) {
+			return fmt.Errorf("-list-permission-sets needs Identity Center admin permissions (sso:ListPermissionSets and related) with your ambient AWS credentials, which were denied (%v); it does not use the SSO token", err)
+		}
+		return err
+	}
+	fmt.Printf("%s%s %d permission set(s)\n\n", cyan(icon("sets")), bold("Found"), len(sets))
+	for _, ps := range sets {
+		fmt.Printf("  %s", green(ps.Name))
+		if ps.Description != "" {
+			fmt.Printf(" - %s", ps.Description)
+		}
+		fmt.Println()
+		if len(ps.AccountIds) == 0 {
+			fmt.Printf("    %s\n", yellow("not provisioned to any account"))
+			continue
+		}
+		fmt.Printf("    accounts: %s\n", strings.Join(ps.AccountIds, ", "))
+	}
+	return nil
+}
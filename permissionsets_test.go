@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// TestListPermissionSets stubs the Identity Center admin API with a local
+// server and verifies permission sets are enumerated across pages, described
+// and printed with their provisioned accounts.
+func TestListPermissionSets(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			t.Errorf("admin request was not signed")
+		}
+		var in map[string]string
+		json.NewDecoder(r.Body).Decode(&in)
+		var out interface{}
+		switch strings.TrimPrefix(r.Header.Get("X-Amz-Target"), "SWBExternalService.") {
+		case "ListInstances":
+			out = map[string]interface{}{"Instances": []map[string]string{{"InstanceArn": "arn:instance"}}}
+		case "ListPermissionSets":
+			if in["NextToken"] == "" {
+				out = map[string]interface{}{"PermissionSets": []string{"arn:ps/admin"}, "NextToken": "page2"}
+			} else {
+				out = map[string]interface{}{"PermissionSets": []string{"arn:ps/readonly"}}
+			}
+		case "DescribePermissionSet":
+			names := map[string]string{"arn:ps/admin": "AdministratorAccess", "arn:ps/readonly": "ReadOnlyAccess"}
+			out = map[string]interface{}{"PermissionSet": map[string]string{"Name": names[in["PermissionSetArn"]], "Description": "desc"}}
+		case "ListAccountsForProvisionedPermissionSet":
+			accounts := []string{}
+			if in["PermissionSetArn"] == "arn:ps/readonly" {
+				accounts = []string{"222", "111"}
+			}
+			out = map[string]interface{}{"AccountIds": accounts}
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(out)
+	}))
+	defer server.Close()
+
+	orig := newPermissionSetSourceFunc
+	defer func() { newPermissionSetSourceFunc = orig }()
+	newPermissionSetSourceFunc = func() (permissionSetSource, error) {
+		cfg := aws.Config{Credentials: aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+			return aws.Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"}, nil
+		})}
+		return &ssoAdminClient{client: &awsJSONClient{
+			cfg:           cfg,
+			endpoint:      server.URL,
+			signingName:   "sso",
+			signingRegion: "us-east-1",
+			targetPrefix:  "SWBExternalService",
+		}}, nil
+	}
+
+	var err error
+	out := captureStdout(t, func() { err = listPermissionSets() })
+	if err != nil {
+		t.Fatalf("listPermissionSets failed: %v", err)
+	}
+	for _, want := range []string{"2 permission set(s)", "AdministratorAccess", "not provisioned to any account", "ReadOnlyAccess", "accounts: 111, 222"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Index(out, "AdministratorAccess") > strings.Index(out, "ReadOnlyAccess") {
+		t.Errorf("expected permission sets sorted by name:\n%s", out)
+	}
+}
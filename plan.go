@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+// Plan actions, rendered as the line marker for a profile
+const (
+	planAdd    = "+"
+	planUpdate = "~"
+	planRemove = "-"
+)
+
+// keyChange is one key difference within a planned profile change. Old is
+// empty for added keys and New is empty for removed keys. Removed marks a
+// key deleted from a profile that is kept.
+type keyChange struct {
+	Key     string
+	Old     string
+	New     string
+	Removed bool
+}
+
+// profilePlan is the planned change for a single profile.
+type profilePlan struct {
+	Action      string
+	ProfileName string
+	Changes     []keyChange
+}
+
+// desiredProfiles returns the profile sections the given roles should
+// produce, keyed by profile name, with the written key names and values.
+func desiredProfiles(roles []CombinedRole) map[string]map[string]string {
+	desired := make(map[string]map[string]string)
+	for _, role := range roles {
+		values := profileValues(role)
+		keys := make(map[string]string)
+		for _, logical := range profileKeys() {
+			if value, ok := values[logical]; ok {
+				keys[profileKey(logical)] = value
+			}
+		}
+		desired[getProfileNameFromRole(role)] = keys
+	}
+	return desired
+}
+
+// buildProfilePlan compares the desired profiles with the config: missing
+// profiles are added, existing profiles whose keys differ are updated, and
+// managed profiles (see isManagedProfile) that are no longer desired are
+// removed. Unmanaged profiles are never removed. The result is sorted by
+// profile name.
+func buildProfilePlan(cfg *ini.File, desired map[string]map[string]string) []profilePlan {
+	var plans []profilePlan
+	for name, keys := range desired {
+		section, err := cfg.GetSection("profile " + name)
+		if err != nil {
+			plan := profilePlan{Action: planAdd, ProfileName: name}
+			for _, k := range sortedKeys(keys) {
+				plan.Changes = append(plan.Changes, keyChange{Key: k, New: keys[k]})
+			}
+			plans = append(plans, plan)
+			continue
+		}
+		plan := profilePlan{Action: planUpdate, ProfileName: name}
+		for _, k := range sortedKeys(keys) {
+			if old := section.Key(k).String(); !section.HasKey(k) || old != keys[k] {
+				plan.Changes = append(plan.Changes, keyChange{Key: k, Old: old, New: keys[k]})
+			}
+		}
+		if len(plan.Changes) > 0 {
+			plans = append(plans, plan)
+		}
+	}
+	for _, section := range cfg.Sections() {
+		if !isManagedProfile(section) {
+			continue
+		}
+		name := strings.TrimPrefix(section.Name(), "profile ")
+		if _, ok := desired[name]; ok {
+			continue
+		}
+		plan := profilePlan{Action: planRemove, ProfileName: name}
+		for _, k := range section.KeyStrings() {
+			plan.Changes = append(plan.Changes, keyChange{Key: k, Old: section.Key(k).String()})
+		}
+		plans = append(plans, plan)
+	}
+	sort.Slice(plans, func(i, j int) bool { return plans[i].ProfileName < plans[j].ProfileName })
+	return plans
+}
+
+// sortedKeys returns the keys of m in sorted order.
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// renderProfilePlan renders plans as +/~/- prefixed blocks grouped by
+// profile, followed by a one-line count summary.
+func renderProfilePlan(plans []profilePlan) string {
+	var b strings.Builder
+	counts := map[string]int{}
+	for _, p := range plans {
+		counts[p.Action]++
+		fmt.Fprintf(&b, "%s [profile %s]\n", p.Action, p.ProfileName)
+		for _, c := range p.Changes {
+			switch p.Action {
+			case planAdd:
+				fmt.Fprintf(&b, "    + %s = %s\n", c.Key, c.New)
+			case planRemove:
+				fmt.Fprintf(&b, "    - %s = %s\n", c.Key, c.Old)
+			default:
+				switch {
+				case c.Removed:
+					fmt.Fprintf(&b, "    - %s = %s\n", c.Key, c.Old)
+				case c.Old == "":
+					fmt.Fprintf(&b, "    + %s = %s\n", c.Key, c.New)
+				default:
+					fmt.Fprintf(&b, "    ~ %s = %s -> %s\n", c.Key, c.Old, c.New)
+				}
+			}
+		}
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "Plan: %d to add, %d to change, %d to remove.\n", counts[planAdd], counts[planUpdate], counts[planRemove])
+	return b.String()
+}
+
+// syncPlan narrows plans to what a sync with the current flags would
+// apply: updates need -force, and removals need -prune and must be among
+// the stale profiles pruneStaleProfiles would remove. It also returns the
+// number of entries left out.
+func syncPlan(plans []profilePlan, stale []ProfileResult) ([]profilePlan, int) {
+	prunable := make(map[string]bool)
+	for _, entry := range stale {
+		prunable[entry.ProfileName] = true
+	}
+	var kept []profilePlan
+	for _, p := range plans {
+		if p.Action == planUpdate && !forceUpdate || p.Action == planRemove && !prunable[p.ProfileName] {
+			continue
+		}
+		kept = append(kept, p)
+	}
+	return kept, len(plans) - len(kept)
+}
+
+// printProfilePlan loads the config and prints the plan for the given roles
+// as a sync with the current flags would apply it. accounts are the
+// accounts selected in this run, which bound -prune (see staleProfiles).
+func printProfilePlan(roles []CombinedRole, accounts []ssoTypesAccount) error {
+	cfg, err := ini.LooseLoad(ssoConfigFile)
+	if err != nil {
+		return err
+	}
+	var stale []ProfileResult
+	if pruneMode {
+		if stale, err = staleProfiles(cfg, roles, accounts); err != nil {
+			return err
+		}
+	}
+	plans, skipped := syncPlan(buildProfilePlan(cfg, desiredProfiles(roles)), stale)
+	resultf("%s", renderProfilePlan(plans))
+	if skipped > 0 {
+		infof("%s%d other change(s) to existing profiles need -force or -prune and are not shown.\n", cyan(icon("info")), skipped)
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"gopkg.in/ini.v1"
+)
+
+// TestBuildProfilePlan seeds one managed profile with a stale region, one
+// managed profile that is no longer desired and one unmanaged profile, and
+// asserts the rendered plan has one add, one update and one remove.
+func TestBuildProfilePlan(t *testing.T) {
+	oldPrefix, oldAuto, oldSession, oldStyle := profilePrefix, useAutoPrefix, ssoSessionConfigName, nameStyle
+	oldRegion, oldOutput := ssoRegion, profileOutput
+	defer func() {
+		profilePrefix, useAutoPrefix, ssoSessionConfigName, nameStyle = oldPrefix, oldAuto, oldSession, oldStyle
+		ssoRegion, profileOutput = oldRegion, oldOutput
+	}()
+	profilePrefix, useAutoPrefix, ssoSessionConfigName, nameStyle = "", false, "corp", nameStyleRoleAccount
+	ssoRegion, profileOutput = "eu-west-1", "json"
+
+	roles := []CombinedRole{
+		{AccountId: "111", AccountName: "Prod", RoleName: "ReadOnly"},
+		{AccountId: "222", AccountName: "Dev", RoleName: "ReadOnly"},
+	}
+	desired := desiredProfiles(roles)
+
+	cfg := ini.Empty()
+	stale, _ := cfg.NewSection("profile Prod_111")
+	for k, v := range desired["Prod_111"] {
+		stale.NewKey(k, v)
+	}
+	stale.Key("region").SetValue("us-east-1")
+	gone, _ := cfg.NewSection("profile Old_333")
+	gone.NewKey("sso_session", "corp")
+	gone.NewKey("sso_account_id", "333")
+	foreign, _ := cfg.NewSection("profile Other_444")
+	foreign.NewKey("sso_session", "someone-else")
+
+	plans := buildProfilePlan(cfg, desired)
+	out := renderProfilePlan(plans)
+
+	for _, want := range []string{
+		"+ [profile Dev_222]",
+		"    + sso_account_id = 222",
+		"~ [profile Prod_111]",
+		"    ~ region = us-east-1 -> eu-west-1",
+		"- [profile Old_333]",
+		"    - sso_account_id = 333",
+		"Plan: 1 to add, 1 to change, 1 to remove.",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("plan missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "Other_444") {
+		t.Errorf("plan touches an unmanaged profile:\n%s", out)
+	}
+	if strings.Contains(out, "~ sso_account_id") {
+		t.Errorf("plan reports unchanged keys:\n%s", out)
+	}
+}
+
+// TestManagedPatternIncludesLegacyProfiles asserts a hand-created profile
+// without the session marker is only planned for removal once its name
+// matches -managed-pattern, while marked profiles stay managed either way.
+func TestManagedPatternIncludesLegacyProfiles(t *testing.T) {
+	oldSession, oldPattern := ssoSessionConfigName, managedPattern
+	defer func() { ssoSessionConfigName, managedPattern = oldSession, oldPattern }()
+	ssoSessionConfigName = "corp"
+
+	cfg := ini.Empty()
+	legacy, _ := cfg.NewSection("profile legacy-prod")
+	legacy.NewKey("sso_start_url", "https://corp.awsapps.com/start")
+	legacy.NewKey("sso_account_id", "111")
+	marked, _ := cfg.NewSection("profile Old_333")
+	marked.NewKey("sso_session", "corp")
+	other, _ := cfg.NewSection("profile personal")
+	other.NewKey("region", "us-east-1")
+
+	removed := func() []string {
+		var names []string
+		for _, p := range buildProfilePlan(cfg, map[string]map[string]string{}) {
+			if p.Action == planRemove {
+				names = append(names, p.ProfileName)
+			}
+		}
+		return names
+	}
+
+	managedPattern = nil
+	if got := strings.Join(removed(), ","); got != "Old_333" {
+		t.Fatalf("without -managed-pattern expected only the marked profile removed, got %q", got)
+	}
+	managedPattern = regexp.MustCompile(`^legacy-`)
+	if got := strings.Join(removed(), ","); got != "legacy-prod,Old_333" && got != "Old_333,legacy-prod" {
+		t.Fatalf("with -managed-pattern expected the legacy and marked profiles removed, got %q", got)
+	}
+}
+
+// TestSyncPlanHonorsFlags asserts -plan leaves out updates without -force
+// and removals that -prune would not make.
+func TestSyncPlanHonorsFlags(t *testing.T) {
+	oldForce := forceUpdate
+	defer func() { forceUpdate = oldForce }()
+	plans := []profilePlan{
+		{Action: planAdd, ProfileName: "Dev_222"},
+		{Action: planUpdate, ProfileName: "Prod_111"},
+		{Action: planRemove, ProfileName: "Old_333"},
+		{Action: planRemove, ProfileName: "Other_444"},
+	}
+	actions := func(plans []profilePlan) string {
+		var names []string
+		for _, p := range plans {
+			names = append(names, p.Action+p.ProfileName)
+		}
+		return strings.Join(names, ",")
+	}
+
+	forceUpdate = false
+	got, skipped := syncPlan(plans, nil)
+	if actions(got) != "+Dev_222" || skipped != 3 {
+		t.Fatalf("without -force or -prune expected only the addition, got %q (%d skipped)", actions(got), skipped)
+	}
+	forceUpdate = true
+	got, skipped = syncPlan(plans, []ProfileResult{{ProfileName: "Old_333"}})
+	if actions(got) != "+Dev_222,~Prod_111,-Old_333" || skipped != 1 {
+		t.Fatalf("with -force and -prune expected the stale profile removed only, got %q (%d skipped)", actions(got), skipped)
+	}
+}
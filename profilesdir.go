@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// profilesDir, when set, makes each generated profile go to its own
+// <profile>.conf file in this directory and the sso-session block to
+// sso-session.conf there, for configs assembled from includes
+// (-profiles-dir).
+var profilesDir string
+
+// sessionConfFile is the file in -profiles-dir holding the sso-session block.
+const sessionConfFile = "sso-session.conf"
+
+// sessionConfigPath returns the file the sso-session block is read from and
+// written to.
+func sessionConfigPath() string {
+	if profilesDir != "" {
+		return filepath.Join(profilesDir, sessionConfFile)
+	}
+	return ssoConfigFile
+}
+
+// profileConfigPath returns the file profileName is read from and written
+// to: its own file with -profiles-dir, otherwise the config file. Path
+// separators in the name become "-" so every profile stays in the directory.
+func profileConfigPath(profileName string) string {
+	if profilesDir == "" {
+		return ssoConfigFile
+	}
+	name := strings.NewReplacer("/", "-", `\`, "-").Replace(profileName)
+	return filepath.Join(profilesDir, name+".conf")
+}
+
+// writeProfileFile saves data as a -profiles-dir file, leaving the file
+// alone when it already holds exactly data.
+func writeProfileFile(path string, data []byte) error {
+	if err := acquireConfigLock(); err != nil {
+		return err
+	}
+	current, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err == nil && bytes.Equal(current, data) {
+		debugf("Profile file %s is unchanged, not rewriting it\n", path)
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o600)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestProfilesDir asserts -profiles-dir writes the session block to
+// sso-session.conf and each profile to its own file, leaves the config file
+// alone, and finds the profiles again on the next run.
+func TestProfilesDir(t *testing.T) {
+	stubDiscovery(t,
+		[]ssoTypesAccount{{AccountId: "111", AccountName: "Prod"}},
+		map[string][]string{"111": {"AWSReadOnlyAccess", "AWSAdministratorAccess"}})
+	oldDir, oldConfig, oldRoles, oldSession, oldStart, oldRegion, oldAnnotate, oldOutput := profilesDir, ssoConfigFile, ssoRoleNames, ssoSessionConfigName, ssoStartURL, ssoRegion, annotateSession, profileOutput
+	defer func() {
+		profilesDir, ssoConfigFile, ssoRoleNames, ssoSessionConfigName, ssoStartURL, ssoRegion, annotateSession, profileOutput = oldDir, oldConfig, oldRoles, oldSession, oldStart, oldRegion, oldAnnotate, oldOutput
+	}()
+	tmp := t.TempDir()
+	profilesDir = filepath.Join(tmp, "config.d")
+	ssoConfigFile = filepath.Join(tmp, "config")
+	ssoRoleNames = []string{"AWSReadOnlyAccess", "AWSAdministratorAccess"}
+	ssoSessionConfigName, ssoStartURL, ssoRegion, annotateSession, profileOutput = "corp", "https://unit.test/start", "eu-west-1", false, "json"
+
+	var err error
+	captureStdout(t, func() {
+		if err = configureSsoSessionConfig(); err == nil {
+			_, err = configureSsoProfiles("token")
+		}
+	})
+	if err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+
+	want := map[string]string{
+		"sso-session.conf": "[sso-session corp]\nsso_start_url = https://unit.test/start\nsso_region = eu-west-1\nsso_registration_scopes = sso:account:access\n",
+		"ReadOnly_Prod_111.conf": "[profile ReadOnly_Prod_111]\nsso_session = corp\nsso_account_id = 111\n" +
+			"sso_role_name = AWSReadOnlyAccess\nregion = eu-west-1\noutput = json\n",
+		"Administrator_Prod_111.conf": "[profile Administrator_Prod_111]\nsso_session = corp\nsso_account_id = 111\n" +
+			"sso_role_name = AWSAdministratorAccess\nregion = eu-west-1\noutput = json\n",
+	}
+	entries, err := os.ReadDir(profilesDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("expected %d files, got %v", len(want), entries)
+	}
+	for name, content := range want {
+		data, err := os.ReadFile(filepath.Join(profilesDir, name))
+		if err != nil {
+			t.Fatalf("missing %s: %v", name, err)
+		}
+		if string(data) != content {
+			t.Fatalf("unexpected %s:\n%s\nwant:\n%s", name, data, content)
+		}
+	}
+	if _, err := os.Stat(ssoConfigFile); !os.IsNotExist(err) {
+		t.Fatalf("config file was written with -profiles-dir: %v", err)
+	}
+	if !profileExists("ReadOnly_Prod_111", ssoConfigFile) || profileExists("Other_111", ssoConfigFile) {
+		t.Fatal("profileExists did not check the per-profile file")
+	}
+}
+
+// TestProfilesDirReusesSession asserts session detection reads
+// sso-session.conf under -profiles-dir rather than the config file.
+func TestProfilesDirReusesSession(t *testing.T) {
+	oldDir, oldConfig, oldSession, oldStart, oldRegion, oldPrefer := profilesDir, ssoConfigFile, ssoSessionConfigName, ssoStartURL, ssoRegion, preferSession
+	defer func() {
+		profilesDir, ssoConfigFile, ssoSessionConfigName, ssoStartURL, ssoRegion, preferSession = oldDir, oldConfig, oldSession, oldStart, oldRegion, oldPrefer
+	}()
+	tmp := t.TempDir()
+	profilesDir = filepath.Join(tmp, "config.d")
+	ssoConfigFile = filepath.Join(tmp, "config")
+	if err := os.MkdirAll(profilesDir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	session := "[sso-session corp]\nsso_start_url = https://unit.test/start\nsso_region = eu-west-1\nsso_registration_scopes = sso:account:access\n"
+	if err := os.WriteFile(filepath.Join(profilesDir, sessionConfFile), []byte(session), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	ssoSessionConfigName, ssoStartURL, ssoRegion, preferSession = defaultSSOSessionConfigName, "https://unit.test/start", "eu-west-1", ""
+
+	var err error
+	captureStdout(t, func() { err = reuseMatchingSession("") })
+	if err != nil || ssoSessionConfigName != "corp" {
+		t.Fatalf("expected the session in %s to be reused, got %q err=%v", sessionConfFile, ssoSessionConfigName, err)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// profileNameTemplate formats profile names when -profile-template is set.
+var profileNameTemplate *template.Template
+
+// profileTemplateData is the data a -profile-template is executed with.
+type profileTemplateData struct {
+	AccountName string
+	AccountId   string
+	RoleName    string
+	// Prefix is the prefix the built-in formatting would use (see
+	// rolePrefix), including its trailing separator.
+	Prefix string
+}
+
+// profileTemplateFuncs are the helper functions available to
+// -profile-template.
+var profileTemplateFuncs = template.FuncMap{
+	"lower":   strings.ToLower,
+	"upper":   strings.ToUpper,
+	"replace": func(s, old, new string) string { return strings.ReplaceAll(s, old, new) },
+}
+
+// parseProfileTemplate parses the -profile-template text and checks that it
+// renders a usable name for a sample role.
+func parseProfileTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("profile").Funcs(profileTemplateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -profile-template: %v", err)
+	}
+	sample := CombinedRole{AccountId: "123456789012", AccountName: "Example", RoleName: "ReadOnly"}
+	if _, err := renderProfileName(tmpl, sample); err != nil {
+		return nil, fmt.Errorf("invalid -profile-template: %v", err)
+	}
+	return tmpl, nil
+}
+
+// renderProfileName executes tmpl for role and validates the result.
+func renderProfileName(tmpl *template.Template, role CombinedRole) (string, error) {
+	var b strings.Builder
+	data := profileTemplateData{AccountName: role.AccountName, AccountId: role.AccountId, RoleName: role.RoleName, Prefix: rolePrefix(role)}
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	name := strings.TrimSpace(b.String())
+	if err := validateProfileName(name); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// validateProfileName rejects names that cannot be written as a
+// [profile <name>] section header.
+func validateProfileName(name string) error {
+	switch {
+	case name == "":
+		return fmt.Errorf("profile name is empty")
+	case strings.ContainsAny(name, "]\r\n"):
+		return fmt.Errorf("profile name %q contains ']' or a line break", name)
+	}
+	return nil
+}
+
+// applyProfileTemplate names every role's profile with tmpl.
+func applyProfileTemplate(roles []CombinedRole, tmpl *template.Template) error {
+	for i, role := range roles {
+		name, err := renderProfileName(tmpl, role)
+		if err != nil {
+			return fmt.Errorf("-profile-template for %s in %s (%s): %v", role.RoleName, role.AccountId, role.AccountName, err)
+		}
+		roles[i].ProfileName = name
+	}
+	return nil
+}
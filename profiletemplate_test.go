@@ -0,0 +1,44 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestProfileTemplate asserts a -profile-template with helper functions
+// names profiles, that the built-in prefix is available, and that broken
+// templates and unsafe or empty names are rejected.
+func TestProfileTemplate(t *testing.T) {
+	oldAuto, oldPrefix := useAutoPrefix, profilePrefix
+	defer func() { useAutoPrefix, profilePrefix = oldAuto, oldPrefix }()
+	useAutoPrefix, profilePrefix = true, ""
+
+	tmpl, err := parseProfileTemplate(`{{.AccountId}}-{{lower .RoleName}}-{{replace .AccountName " " "_"}}`)
+	if err != nil {
+		t.Fatalf("parseProfileTemplate failed: %v", err)
+	}
+	roles := []CombinedRole{{AccountId: "111", AccountName: "Team Prod", RoleName: "AWSReadOnlyAccess"}}
+	if err := applyProfileTemplate(roles, tmpl); err != nil {
+		t.Fatalf("applyProfileTemplate failed: %v", err)
+	}
+	if got := getProfileNameFromRole(roles[0]); got != "111-awsreadonlyaccess-Team_Prod" {
+		t.Fatalf("getProfileNameFromRole = %q", got)
+	}
+
+	tmpl, _ = parseProfileTemplate(`{{.Prefix}}{{.AccountId}}`)
+	name, err := renderProfileName(tmpl, CombinedRole{AccountId: "111", RoleName: "AWSReadOnlyAccess"})
+	if err != nil || name != generatePrefixFromRole("AWSReadOnlyAccess")+"111" {
+		t.Fatalf("expected the auto prefix in the name, got %q err=%v", name, err)
+	}
+
+	for _, text := range []string{`{{.AccountId`, `{{.Missing}}`, `{{if false}}x{{end}}`, "{{.AccountId}}]"} {
+		if _, err := parseProfileTemplate(text); err == nil || !strings.Contains(err.Error(), "invalid -profile-template") {
+			t.Errorf("expected %q to be rejected, got %v", text, err)
+		}
+	}
+
+	tmpl, _ = parseProfileTemplate(`{{.AccountName}}`)
+	if err := applyProfileTemplate([]CombinedRole{{AccountId: "222", AccountName: "bad\nname", RoleName: "R"}}, tmpl); err == nil || !strings.Contains(err.Error(), "line break") {
+		t.Fatalf("expected a newline in the rendered name to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"gopkg.in/ini.v1"
+)
+
+// desiredState is the document read by the reconcile subcommand. It extends
+// the declarative sync config with an optional account allow-list.
+type desiredState struct {
+	syncConfig
+	// Accounts limits the managed profiles to these account ids; empty means
+	// every account the roles are found in.
+	Accounts []string `json:"accounts,omitempty"`
+}
+
+// parseDesiredState decodes and validates a desired-state document. Unknown
+// fields are rejected, as for -config-from-url.
+func parseDesiredState(data []byte) (desiredState, error) {
+	var state desiredState
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&state); err != nil {
+		return desiredState{}, fmt.Errorf("desired state does not match the expected schema: %v", err)
+	}
+	fields := state.envFields()
+	for i := range state.Accounts {
+		fields = append(fields, envField{"accounts", &state.Accounts[i]})
+	}
+	if err := expandFieldsEnv(fields); err != nil {
+		return desiredState{}, err
+	}
+	if state.StartURL == "" {
+		return desiredState{}, fmt.Errorf("desired state does not match the expected schema: start_url is required")
+	}
+	if len(state.Roles) == 0 {
+		return desiredState{}, fmt.Errorf("desired state does not match the expected schema: roles must list at least one role")
+	}
+	if state.NameStyle != "" {
+		if err := validateNameStyle(state.NameStyle); err != nil {
+			return desiredState{}, fmt.Errorf("desired state does not match the expected schema: %v", err)
+		}
+	}
+	return state, nil
+}
+
+// applyDesiredState sets the sync settings from state. The desired-state
+// file is the whole source of truth, so unset fields take the flag defaults.
+func applyDesiredState(state desiredState) error {
+	ssoStartURL = state.StartURL
+	ssoRegion = defaultSSORegion
+	if state.Region != "" {
+		ssoRegion = state.Region
+	}
+	ssoSessionConfigName = defaultSSOSessionConfigName
+	if state.SessionName != "" {
+		ssoSessionConfigName = state.SessionName
+	}
+	ssoRoleNames = state.Roles
+	profilePrefix = state.Prefix
+	useAutoPrefix = true
+	profileOutput = "json"
+	if state.Output != "" {
+		profileOutput = state.Output
+	}
+	nameStyle = nameStyleRoleAccount
+	if state.NameStyle != "" {
+		nameStyle = state.NameStyle
+	}
+	roleFilter = nil
+	if state.Filter != "" {
+		compiled, err := compileRoleFilter(state.Filter)
+		if err != nil {
+			return err
+		}
+		roleFilter = compiled
+	}
+	return nil
+}
+
+// configStore reads and writes the AWS config as a whole, so reconcile can
+// apply every change in a single write.
+type configStore interface {
+	Read() ([]byte, error)
+	Write(data []byte) error
+}
+
+// fileConfigStore is a configStore backed by -config-file. The config lock
+// is taken before reading, so the whole read-modify-write cycle is covered,
+// and writes go through writeConfigFile, which backs the file up first.
+type fileConfigStore struct{}
+
+func (fileConfigStore) Read() ([]byte, error) {
+	if err := acquireConfigLock(); err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(ssoConfigFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	return data, err
+}
+
+func (fileConfigStore) Write(data []byte) error {
+	return writeConfigFile(data)
+}
+
+// reconcileResult is the outcome of a reconcile: the profile plan and
+// whether the sso-session block had to be created.
+type reconcileResult struct {
+	Plans      []profilePlan
+	AddSession bool
+}
+
+// reconcile makes the managed profiles in store exactly match the roles the
+// current settings select: missing profiles are added, drifted ones updated,
+// keys the desired state doesn't set removed, and managed profiles outside
+// the desired set removed. All changes,
+// including a missing sso-session block, are saved in one write; in dry-run
+// nothing is saved.
+func reconcile(store configStore, accessToken string, accountIds []string) (reconcileResult, error) {
+	accounts, err := getSelectedSsoAccounts(accessToken)
+	if err != nil {
+		return reconcileResult{}, err
+	}
+	if len(accountIds) > 0 {
+		allowed := make(map[string]bool)
+		for _, id := range accountIds {
+			allowed[id] = true
+		}
+		var kept []ssoTypesAccount
+		for _, a := range accounts {
+			if allowed[a.AccountId] {
+				kept = append(kept, a)
+			}
+		}
+		accounts = kept
+	}
+	roles, err := getRolesForAccounts(accessToken, accounts, ssoRoleNames)
+	if err != nil {
+		return reconcileResult{}, err
+	}
+	if nameCommand != "" {
+		if err := applyNameCommand(roles, nameCommand); err != nil {
+			return reconcileResult{}, err
+		}
+	}
+	if profileNameTemplate != nil {
+		if err := applyProfileTemplate(roles, profileNameTemplate); err != nil {
+			return reconcileResult{}, err
+		}
+	}
+	if err := checkProfileNameCollisions(roles); err != nil {
+		return reconcileResult{}, err
+	}
+
+	data, err := store.Read()
+	if err != nil {
+		return reconcileResult{}, err
+	}
+	cfg, err := ini.Load(data)
+	if err != nil {
+		return reconcileResult{}, err
+	}
+	desired := desiredProfiles(roles)
+	result := reconcileResult{Plans: removeUndesiredKeys(cfg, desired, buildProfilePlan(cfg, desired))}
+	if _, err := cfg.GetSection("sso-session " + ssoSessionConfigName); err != nil {
+		result.AddSession = true
+	}
+	if dryRun {
+		return result, nil
+	}
+
+	// The changes are applied to the raw config, so comments and the layout
+	// of everything reconcile doesn't manage are kept.
+	if result.AddSession {
+		comment := ""
+		if annotateSession {
+			comment = fmt.Sprintf("# region: %s\n# created-by: aws-sso-profile-sync %s", ssoRegion, version)
+		}
+		data = upsertSection(data, "sso-session "+ssoSessionConfigName, nil, []keyValue{
+			{"sso_start_url", strings.TrimRight(ssoStartURL, "/")},
+			{"sso_region", ssoRegion},
+			{"sso_registration_scopes", requiredSsoScope},
+		}, comment)
+	}
+	for _, plan := range result.Plans {
+		sectionName := "profile " + plan.ProfileName
+		if plan.Action == planRemove {
+			data = removeSection(data, sectionName)
+			continue
+		}
+		var keys []string
+		var values []keyValue
+		for _, c := range plan.Changes {
+			keys = append(keys, c.Key)
+			if !c.Removed {
+				values = append(values, keyValue{c.Key, c.New})
+			}
+		}
+		data = upsertSection(data, sectionName, keys, values, "")
+	}
+	if len(result.Plans) == 0 && !result.AddSession {
+		return result, nil
+	}
+	return result, store.Write(data)
+}
+
+// removeUndesiredKeys adds to plans the keys of existing desired profiles
+// that the desired state doesn't set, such as output after an inherit
+// output or the keys of a dropped -extra-key, so reconcile deletes them and
+// the profile matches exactly. The result stays sorted by profile name.
+func removeUndesiredKeys(cfg *ini.File, desired map[string]map[string]string, plans []profilePlan) []profilePlan {
+	index := make(map[string]int)
+	for i, p := range plans {
+		index[p.ProfileName] = i
+	}
+	for name, keys := range desired {
+		section, err := cfg.GetSection("profile " + name)
+		if err != nil {
+			continue
+		}
+		for _, k := range section.KeyStrings() {
+			if _, ok := keys[k]; ok {
+				continue
+			}
+			i, ok := index[name]
+			if !ok {
+				i = len(plans)
+				index[name] = i
+				plans = append(plans, profilePlan{Action: planUpdate, ProfileName: name})
+			}
+			plans[i].Changes = append(plans[i].Changes, keyChange{Key: k, Old: section.Key(k).String(), Removed: true})
+		}
+	}
+	sort.Slice(plans, func(i, j int) bool { return plans[i].ProfileName < plans[j].ProfileName })
+	return plans
+}
+
+// runReconcileCommand implements the reconcile subcommand and returns the
+// process exit code.
+func runReconcileCommand(args []string) int {
+	fs := flag.NewFlagSet("reconcile", flag.ContinueOnError)
+	stateFile := fs.String("file", "", "Desired-state JSON file listing start_url, roles, accounts and naming (required)")
+	fs.StringVar(&ssoConfigFile, "config-file", config.DefaultSharedConfigFilename(), "AWS config file path")
+	fs.BoolVar(&allowExternalConfig, "allow-external-config", false, "Allow -config-file to point outside the ~/.aws directory")
+	fs.BoolVar(&dryRun, "dry-run", false, "Print the full plan without changing the config")
+	registerOutputFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *stateFile == "" {
+		errorf("%s%s\n", red(icon("error")), bold("Error: reconcile requires -file"))
+		fs.Usage()
+		return 2
+	}
+	if err := checkConfigFileLocation(ssoConfigFile); err != nil {
+		errorf("%s%s %v\n", red(icon("error")), bold("Error:"), err)
+		return 2
+	}
+	data, err := os.ReadFile(*stateFile)
+	if err == nil {
+		var state desiredState
+		if state, err = parseDesiredState(data); err == nil {
+			err = applyDesiredState(state)
+		}
+		if err == nil {
+			return reconcileWithStore(fileConfigStore{}, state.Accounts)
+		}
+	}
+	errorf("%s%s %v\n", red(icon("error")), bold("Error loading desired state:"), err)
+	return 1
+}
+
+// reconcileWithStore authenticates with the cached token, reconciles store
+// and prints the plan. Reconcile is meant for automation, so it never starts
+// an interactive login.
+func reconcileWithStore(store configStore, accountIds []string) int {
+	infof("%s\n", cyan("\n========== AWS SSO Profile Reconcile =========="))
+	accessToken, _, err := getAccessTokenFunc()
+	if err == nil && !isSsoTokenValidFunc(accessToken) {
+		err = fmt.Errorf("cached SSO %s", tokenRejectedReason())
+	}
+	if err != nil {
+		errorf("%s%v (run without a subcommand to log in first)\n", red(icon("error")), err)
+		return 1
+	}
+	result, err := reconcile(store, accessToken, accountIds)
+	if err != nil {
+		errorf("%s%s %v\n", red(icon("error")), bold("Error reconciling profiles:"), err)
+		return 1
+	}
+	if result.AddSession {
+		resultf("+ [sso-session %s]\n\n", ssoSessionConfigName)
+	}
+	resultf("%s", renderProfilePlan(result.Plans))
+	if dryRun {
+		resultf("\n%s%s no changes written.\n", cyan(icon("summary")), bold("Dry-run:"))
+	} else {
+		resultf("\n%s%s %s now matches the desired state.\n", green(icon("ok")), bold("Reconciled:"), ssoConfigFile)
+	}
+	return 0
+}
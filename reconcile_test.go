@@ -0,0 +1,276 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/ini.v1"
+)
+
+// memConfigStore is an in-memory configStore that counts writes.
+type memConfigStore struct {
+	data   []byte
+	writes int
+}
+
+func (m *memConfigStore) Read() ([]byte, error) {
+	// Hand out a copy so unsaved changes never leak into the store.
+	return append([]byte(nil), m.data...), nil
+}
+
+func (m *memConfigStore) Write(data []byte) error {
+	m.data = data
+	m.writes++
+	return nil
+}
+
+// TestReconcileEndToEnd reconciles an in-memory config against a desired
+// state: the dry-run prints the full plan without saving, the real run adds,
+// updates and prunes managed profiles in one save, and a second run is a
+// no-op.
+func TestReconcileEndToEnd(t *testing.T) {
+	stubDiscovery(t,
+		[]ssoTypesAccount{{AccountId: "111", AccountName: "Prod"}, {AccountId: "222", AccountName: "Dev"}, {AccountId: "333", AccountName: "Ignored"}},
+		map[string][]string{"111": {"AWSReadOnlyAccess"}, "222": {"AWSReadOnlyAccess"}, "333": {"AWSReadOnlyAccess"}})
+	origGet, origValid := getAccessTokenFunc, isSsoTokenValidFunc
+	oldStart, oldRegion, oldSession, oldRoles := ssoStartURL, ssoRegion, ssoSessionConfigName, ssoRoleNames
+	oldPrefix, oldAuto, oldOutput, oldStyle, oldFilter, oldDry := profilePrefix, useAutoPrefix, profileOutput, nameStyle, roleFilter, dryRun
+	defer func() {
+		getAccessTokenFunc, isSsoTokenValidFunc = origGet, origValid
+		ssoStartURL, ssoRegion, ssoSessionConfigName, ssoRoleNames = oldStart, oldRegion, oldSession, oldRoles
+		profilePrefix, useAutoPrefix, profileOutput, nameStyle, roleFilter, dryRun = oldPrefix, oldAuto, oldOutput, oldStyle, oldFilter, oldDry
+	}()
+	getAccessTokenFunc = func() (string, string, error) { return "token", "/tmp/token.json", nil }
+	isSsoTokenValidFunc = func(string) bool { return true }
+
+	state, err := parseDesiredState([]byte(`{
+		"start_url": "https://corp.awsapps.com/start",
+		"region": "eu-west-1",
+		"session_name": "corp",
+		"roles": ["AWSReadOnlyAccess"],
+		"prefix": "ro-",
+		"accounts": ["111", "222"]
+	}`))
+	if err != nil {
+		t.Fatalf("parseDesiredState failed: %v", err)
+	}
+	if err := applyDesiredState(state); err != nil {
+		t.Fatalf("applyDesiredState failed: %v", err)
+	}
+
+	store := &memConfigStore{data: []byte(`[sso-session corp]
+sso_start_url = https://corp.awsapps.com/start
+sso_region = eu-west-1
+
+[profile ro-Prod_111]
+sso_session = corp
+sso_account_id = 111
+sso_role_name = AWSReadOnlyAccess
+region = us-east-1
+output = json
+
+[profile ro-Old_444]
+sso_session = corp
+sso_account_id = 444
+
+[profile personal]
+region = us-west-2
+`)}
+
+	dryRun = true
+	var code int
+	out := captureStdout(t, func() { code = reconcileWithStore(store, state.Accounts) })
+	if code != 0 || store.writes != 0 {
+		t.Fatalf("dry-run: exit %d, writes %d\n%s", code, store.writes, out)
+	}
+	for _, want := range []string{"+ [profile ro-Dev_222]", "~ [profile ro-Prod_111]", "~ region = us-east-1 -> eu-west-1", "- [profile ro-Old_444]", "Plan: 1 to add, 1 to change, 1 to remove."} {
+		if !strings.Contains(out, want) {
+			t.Errorf("dry-run plan missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "Ignored") {
+		t.Errorf("account outside the desired state appears in the plan:\n%s", out)
+	}
+
+	dryRun = false
+	out = captureStdout(t, func() { code = reconcileWithStore(store, state.Accounts) })
+	if code != 0 || store.writes != 1 {
+		t.Fatalf("reconcile: exit %d, writes %d\n%s", code, store.writes, out)
+	}
+	cfg, err := ini.Load(store.data)
+	if err != nil {
+		t.Fatalf("failed to load reconciled config: %v", err)
+	}
+	if got := cfg.Section("profile ro-Prod_111").Key("region").String(); got != "eu-west-1" {
+		t.Errorf("drifted profile not updated, region=%q", got)
+	}
+	if got := cfg.Section("profile ro-Dev_222").Key("sso_account_id").String(); got != "222" {
+		t.Errorf("missing profile not added, sso_account_id=%q", got)
+	}
+	if _, err := cfg.GetSection("profile ro-Old_444"); err == nil {
+		t.Errorf("stale managed profile was not pruned")
+	}
+	if _, err := cfg.GetSection("profile personal"); err != nil {
+		t.Errorf("unmanaged profile was removed")
+	}
+
+	out = captureStdout(t, func() { code = reconcileWithStore(store, state.Accounts) })
+	if code != 0 || store.writes != 1 || !strings.Contains(out, "Plan: 0 to add, 0 to change, 0 to remove.") {
+		t.Fatalf("second reconcile was not a no-op: exit %d, writes %d\n%s", code, store.writes, out)
+	}
+}
+
+// TestReconcileRemovesUndesiredKeys asserts reconcile deletes the keys of a
+// managed profile that the desired state doesn't set, here output under an
+// inherit output and a key left by an old -extra-key.
+func TestReconcileRemovesUndesiredKeys(t *testing.T) {
+	stubDiscovery(t,
+		[]ssoTypesAccount{{AccountId: "111", AccountName: "Prod"}},
+		map[string][]string{"111": {"AWSReadOnlyAccess"}})
+	origGet, origValid := getAccessTokenFunc, isSsoTokenValidFunc
+	oldStart, oldRegion, oldSession, oldRoles := ssoStartURL, ssoRegion, ssoSessionConfigName, ssoRoleNames
+	oldPrefix, oldAuto, oldOutput, oldStyle, oldFilter, oldDry := profilePrefix, useAutoPrefix, profileOutput, nameStyle, roleFilter, dryRun
+	defer func() {
+		getAccessTokenFunc, isSsoTokenValidFunc = origGet, origValid
+		ssoStartURL, ssoRegion, ssoSessionConfigName, ssoRoleNames = oldStart, oldRegion, oldSession, oldRoles
+		profilePrefix, useAutoPrefix, profileOutput, nameStyle, roleFilter, dryRun = oldPrefix, oldAuto, oldOutput, oldStyle, oldFilter, oldDry
+	}()
+	getAccessTokenFunc = func() (string, string, error) { return "token", "/tmp/token.json", nil }
+	isSsoTokenValidFunc = func(string) bool { return true }
+
+	state, err := parseDesiredState([]byte(`{
+		"start_url": "https://corp.awsapps.com/start",
+		"region": "eu-west-1",
+		"session_name": "corp",
+		"roles": ["AWSReadOnlyAccess"],
+		"output": "inherit"
+	}`))
+	if err != nil {
+		t.Fatalf("parseDesiredState failed: %v", err)
+	}
+	if err := applyDesiredState(state); err != nil {
+		t.Fatalf("applyDesiredState failed: %v", err)
+	}
+	name := getProfileNameFromRole(CombinedRole{AccountId: "111", AccountName: "Prod", RoleName: "AWSReadOnlyAccess"})
+	store := &memConfigStore{data: []byte("[sso-session corp]\nsso_start_url = https://corp.awsapps.com/start\nsso_region = eu-west-1\n\n" +
+		"[profile " + name + "]\nsso_session = corp\nsso_account_id = 111\nsso_role_name = AWSReadOnlyAccess\nregion = eu-west-1\noutput = json\ncli_pager = less\n")}
+
+	dryRun = true
+	var code int
+	out := captureStdout(t, func() { code = reconcileWithStore(store, nil) })
+	if code != 0 {
+		t.Fatalf("dry-run: exit %d\n%s", code, out)
+	}
+	for _, want := range []string{"~ [profile " + name + "]", "    - output = json", "    - cli_pager = less", "Plan: 0 to add, 1 to change, 0 to remove."} {
+		if !strings.Contains(out, want) {
+			t.Errorf("dry-run plan missing %q:\n%s", want, out)
+		}
+	}
+
+	dryRun = false
+	out = captureStdout(t, func() { code = reconcileWithStore(store, nil) })
+	if code != 0 || store.writes != 1 {
+		t.Fatalf("reconcile: exit %d, writes %d\n%s", code, store.writes, out)
+	}
+	want := "[profile " + name + "]\nsso_session = corp\nsso_account_id = 111\nsso_role_name = AWSReadOnlyAccess\nregion = eu-west-1\n"
+	if !strings.HasSuffix(string(store.data), want) {
+		t.Fatalf("undesired keys not removed:\n%s", store.data)
+	}
+	out = captureStdout(t, func() { code = reconcileWithStore(store, nil) })
+	if store.writes != 1 || !strings.Contains(out, "Plan: 0 to add, 0 to change, 0 to remove.") {
+		t.Fatalf("second reconcile was not a no-op: writes %d\n%s", store.writes, out)
+	}
+}
+
+// TestReconcileKeepsComments reconciles a config file on disk and checks
+// that comments and unmanaged sections survive, the file is backed up first
+// and the config lock is taken.
+func TestReconcileKeepsComments(t *testing.T) {
+	stubDiscovery(t,
+		[]ssoTypesAccount{{AccountId: "111", AccountName: "Prod"}},
+		map[string][]string{"111": {"AWSReadOnlyAccess"}})
+	origGet, origValid := getAccessTokenFunc, isSsoTokenValidFunc
+	oldStart, oldRegion, oldSession, oldRoles := ssoStartURL, ssoRegion, ssoSessionConfigName, ssoRoleNames
+	oldPrefix, oldAuto, oldOutput, oldStyle, oldFilter, oldDry := profilePrefix, useAutoPrefix, profileOutput, nameStyle, roleFilter, dryRun
+	oldConfig, oldDir, oldBackup, oldBackupDone, oldBackupPath, oldLock := ssoConfigFile, profilesDir, backupConfig, configBackupDone, configBackupPath, configLock
+	defer func() {
+		getAccessTokenFunc, isSsoTokenValidFunc = origGet, origValid
+		ssoStartURL, ssoRegion, ssoSessionConfigName, ssoRoleNames = oldStart, oldRegion, oldSession, oldRoles
+		profilePrefix, useAutoPrefix, profileOutput, nameStyle, roleFilter, dryRun = oldPrefix, oldAuto, oldOutput, oldStyle, oldFilter, oldDry
+		releaseConfigLock()
+		ssoConfigFile, profilesDir, backupConfig, configBackupDone, configBackupPath, configLock = oldConfig, oldDir, oldBackup, oldBackupDone, oldBackupPath, oldLock
+	}()
+	getAccessTokenFunc = func() (string, string, error) { return "token", "/tmp/token.json", nil }
+	isSsoTokenValidFunc = func(string) bool { return true }
+	dryRun, profilesDir, backupConfig, configBackupDone, configBackupPath, configLock = false, "", true, false, "", nil
+	if err := applyDesiredState(desiredState{syncConfig: syncConfig{StartURL: "https://corp.awsapps.com/start", Region: "eu-west-1", SessionName: "corp", Roles: []string{"AWSReadOnlyAccess"}}}); err != nil {
+		t.Fatalf("applyDesiredState failed: %v", err)
+	}
+
+	ssoConfigFile = filepath.Join(t.TempDir(), "config")
+	original := `# Work accounts, do not edit by hand
+[sso-session corp]
+sso_start_url = https://corp.awsapps.com/start
+sso_region = eu-west-1
+
+[profile ReadOnly_Prod_111]
+# pinned by the platform team
+sso_session = corp
+sso_account_id = 111
+sso_role_name = AWSReadOnlyAccess
+region = us-east-1
+output = json
+
+; personal sandbox
+[profile personal]
+region = us-west-2
+`
+	if err := os.WriteFile(ssoConfigFile, []byte(original), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var code int
+	out := captureStdout(t, func() { code = reconcileWithStore(fileConfigStore{}, nil) })
+	if code != 0 {
+		t.Fatalf("reconcile: exit %d\n%s", code, out)
+	}
+	data, err := os.ReadFile(ssoConfigFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Replace(original, "region = us-east-1", "region = eu-west-1", 1)
+	if string(data) != want {
+		t.Fatalf("unexpected config after reconcile:\n%s\nwant:\n%s", data, want)
+	}
+	if configBackupPath == "" {
+		t.Fatal("expected the config to be backed up before the write")
+	}
+	if backup, _ := os.ReadFile(configBackupPath); string(backup) != original {
+		t.Fatalf("backup does not hold the original config:\n%s", backup)
+	}
+	if configLock == nil {
+		t.Fatal("expected the config lock to be held")
+	}
+}
+
+// TestParseDesiredStateEnvExpansion asserts environment variables are
+// expanded in the desired state, account ids included, and that an unset
+// variable is an error.
+func TestParseDesiredStateEnvExpansion(t *testing.T) {
+	t.Setenv("ACME_SSO_URL", "https://acme.awsapps.com/start")
+	t.Setenv("ACME_PROD_ACCOUNT", "111122223333")
+	state, err := parseDesiredState([]byte(`{"start_url": "${ACME_SSO_URL}", "roles": ["AWSReadOnlyAccess"], "accounts": ["$ACME_PROD_ACCOUNT"]}`))
+	if err != nil {
+		t.Fatalf("parseDesiredState failed: %v", err)
+	}
+	if state.StartURL != "https://acme.awsapps.com/start" || len(state.Accounts) != 1 || state.Accounts[0] != "111122223333" {
+		t.Fatalf("unexpected expanded state: %+v", state)
+	}
+
+	_, err = parseDesiredState([]byte(`{"start_url": "https://acme.awsapps.com/start", "roles": ["${ACME_ROLE}"]}`))
+	if err == nil || !strings.Contains(err.Error(), "roles") || !strings.Contains(err.Error(), "ACME_ROLE is not set") {
+		t.Fatalf("expected an unset variable error for roles, got %v", err)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssooidc"
+	ssooidctypes "github.com/aws/aws-sdk-go-v2/service/ssooidc/types"
+)
+
+// errNoRefreshToken is returned by refreshAccessToken when the cached token
+// cannot be refreshed because it lacks a refresh token or client
+// registration.
+var errNoRefreshToken = errors.New("cached token has no refresh token or client registration")
+
+// createTokenWithRefreshFunc exchanges a refresh token for a new token with
+// the SSO OIDC service. Tests override it to avoid calling AWS.
+var createTokenWithRefreshFunc = func(clientId, clientSecret, refreshToken string) (*ssooidc.CreateTokenOutput, error) {
+	cfg, err := loadSsoClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	return ssooidc.NewFromConfig(cfg).CreateToken(runContext, &ssooidc.CreateTokenInput{
+		ClientId:     aws.String(clientId),
+		ClientSecret: aws.String(clientSecret),
+		GrantType:    aws.String("refresh_token"),
+		RefreshToken: aws.String(refreshToken),
+	})
+}
+
+// refreshAccessToken renews the cached token at tokenPath using its refresh
+// token and client registration, writes the new token back to the same file
+// atomically, and returns the new access token.
+func refreshAccessToken(tokenPath string) (string, error) {
+	data, err := os.ReadFile(tokenPath)
+	if err != nil {
+		return "", err
+	}
+	var cache map[string]interface{}
+	if err := json.Unmarshal(data, &cache); err != nil {
+		return "", fmt.Errorf("parsing %s: %v", tokenPath, err)
+	}
+	refreshToken, _ := cache["refreshToken"].(string)
+	clientId, _ := cache["clientId"].(string)
+	clientSecret, _ := cache["clientSecret"].(string)
+	if refreshToken == "" || clientId == "" || clientSecret == "" {
+		return "", errNoRefreshToken
+	}
+	if regExpiry, ok := cache["registrationExpiresAt"].(string); ok {
+		if expiresAt, ok := parseTokenExpiry(regExpiry); ok && !expiresAt.After(nowFunc()) {
+			return "", errNoRefreshToken
+		}
+	}
+
+	out, err := createTokenWithRefreshFunc(clientId, clientSecret, refreshToken)
+	if err != nil {
+		return "", err
+	}
+	if out == nil || out.AccessToken == nil {
+		return "", fmt.Errorf("refresh returned no access token")
+	}
+	cache["accessToken"] = aws.ToString(out.AccessToken)
+	cache["expiresAt"] = nowFunc().Add(time.Duration(out.ExpiresIn) * time.Second).UTC().Format(time.RFC3339)
+	if out.RefreshToken != nil {
+		cache["refreshToken"] = aws.ToString(out.RefreshToken)
+	}
+
+	b, err := json.MarshalIndent(cache, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	// Write atomically: write to a temp file then rename.
+	tmpPath := tokenPath + ".tmp"
+	if err := os.WriteFile(tmpPath, b, 0o600); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmpPath, tokenPath); err != nil {
+		_ = os.Remove(tmpPath)
+		return "", err
+	}
+	return aws.ToString(out.AccessToken), nil
+}
+
+// tryRefreshAccessToken attempts refreshAccessToken for an invalid cached
+// token and reports the outcome. ok is false when the caller should fall back
+// to the device flow.
+func tryRefreshAccessToken(tokenPath string) (string, bool) {
+	accessToken, err := refreshAccessToken(tokenPath)
+	if err != nil {
+		var invalidGrant *ssooidctypes.InvalidGrantException
+		switch {
+		case errors.Is(err, errNoRefreshToken):
+		case errors.As(err, &invalidGrant):
+			warnf("%sThe cached refresh token is no longer valid; starting a new login.\n", yellow(icon("warn")))
+		default:
+			warnf("%sCould not refresh the token (%v); starting a new login.\n", yellow(icon("warn")), err)
+		}
+		return "", false
+	}
+	if !isSsoTokenValid(accessToken) {
+		warnf("%sThe refreshed token was rejected; starting a new login.\n", yellow(icon("warn")))
+		return "", false
+	}
+	infof("%sRefreshed the access token using the cached refresh token.\n", green(icon("ok")))
+	return accessToken, true
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+
+	"gopkg.in/ini.v1"
+)
+
+// accountIdPattern matches a 12-digit AWS account id.
+var accountIdPattern = regexp.MustCompile(`^\d{12}$`)
+
+// loadRegionMap reads the -region-map file, mapping account ids to the region
+// written into their profiles. The file is either a JSON object or an INI
+// file of accountId = region lines; a file starting with "{" is read as JSON.
+func loadRegionMap(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	regions := make(map[string]string)
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		if err := json.Unmarshal(data, &regions); err != nil {
+			return nil, fmt.Errorf("parsing region map %s: %v", path, err)
+		}
+	} else {
+		cfg, err := ini.Load(data)
+		if err != nil {
+			return nil, fmt.Errorf("parsing region map %s: %v", path, err)
+		}
+		for _, section := range cfg.Sections() {
+			for _, key := range section.Keys() {
+				regions[key.Name()] = key.String()
+			}
+		}
+	}
+	for account, region := range regions {
+		if !accountIdPattern.MatchString(account) {
+			return nil, fmt.Errorf("region map %s: %q is not a 12-digit account id", path, account)
+		}
+		if region == "" {
+			return nil, fmt.Errorf("region map %s: empty region for account %s", path, account)
+		}
+	}
+	return regions, nil
+}
+
+// applyRegionMap sets the profile region of every role whose account is in
+// regions. Other roles keep their region, which falls back to -sso-region.
+func applyRegionMap(roles []CombinedRole, regions map[string]string) {
+	for i, role := range roles {
+		if region, ok := regions[role.AccountId]; ok {
+			roles[i].Region = region
+		}
+	}
+}
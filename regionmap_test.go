@@ -0,0 +1,46 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestRegionMap loads JSON and INI region maps, rejects malformed ones, and
+// asserts mapped accounts get their region while others keep the default.
+func TestRegionMap(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, body string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+		return path
+	}
+
+	for _, path := range []string{
+		write("map.json", `{"111111111111": "eu-west-1"}`),
+		write("map.ini", "# home regions\n111111111111 = eu-west-1\n"),
+	} {
+		regions, err := loadRegionMap(path)
+		if err != nil {
+			t.Fatalf("loadRegionMap(%s) failed: %v", filepath.Base(path), err)
+		}
+		roles := []CombinedRole{{AccountId: "111111111111"}, {AccountId: "222222222222"}}
+		applyRegionMap(roles, regions)
+		if roles[0].Region != "eu-west-1" || roles[1].Region != "" {
+			t.Fatalf("%s: unexpected regions %+v", filepath.Base(path), roles)
+		}
+	}
+
+	for name, body := range map[string]string{
+		"bad.json":   `{"111111111111": "eu-west-1"`,
+		"badid.json": `{"prod": "eu-west-1"}`,
+		"empty.ini":  "111111111111 =\n",
+	} {
+		if _, err := loadRegionMap(write(name, body)); err == nil || !strings.Contains(err.Error(), name) {
+			t.Errorf("%s: expected an error naming the file, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package main
+
+import "fmt"
+
+// ssoRoleArn returns the canonical ARN pattern of the IAM role IAM Identity
+// Center provisions for a permission set. The portal API does not expose the
+// random AWSReservedSSO suffix, so it is written as the wildcard "*". Roles
+// of an Identity Center instance homed in us-east-1 have no region in their
+// path.
+func ssoRoleArn(accountId, roleName, region string) string {
+	path := "aws-reserved/sso.amazonaws.com/"
+	if region != "" && region != "us-east-1" {
+		path += region + "/"
+	}
+	return fmt.Sprintf("arn:aws:iam::%s:role/%sAWSReservedSSO_%s_*", accountId, path, roleName)
+}
+
+// printRoleArnList prints the ARN pattern of each role for -print-role-arns.
+func printRoleArnList(roles []CombinedRole) {
+	resultf("%sRole ARN patterns (the suffix after the role name may differ; it is shown as *):\n", cyan(icon("info")))
+	for _, role := range roles {
+		resultf("%s\n", ssoRoleArn(role.AccountId, role.RoleName, ssoRegion))
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestSsoRoleArn asserts the ARN pattern for a known account, role and
+// region, and the region-less path of us-east-1 instances.
+func TestSsoRoleArn(t *testing.T) {
+	cases := []struct{ region, want string }{
+		{"eu-west-1", "arn:aws:iam::123456789012:role/aws-reserved/sso.amazonaws.com/eu-west-1/AWSReservedSSO_AWSReadOnlyAccess_*"},
+		{"us-east-1", "arn:aws:iam::123456789012:role/aws-reserved/sso.amazonaws.com/AWSReservedSSO_AWSReadOnlyAccess_*"},
+	}
+	for _, c := range cases {
+		if got := ssoRoleArn("123456789012", "AWSReadOnlyAccess", c.region); got != c.want {
+			t.Errorf("region %s: got %s, want %s", c.region, got, c.want)
+		}
+	}
+
+	oldRegion := ssoRegion
+	defer func() { ssoRegion = oldRegion }()
+	ssoRegion = "eu-west-1"
+	out := captureStdout(t, func() {
+		printRoleArnList([]CombinedRole{{AccountId: "123456789012", RoleName: "AWSReadOnlyAccess"}})
+	})
+	if !strings.Contains(out, cases[0].want+"\n") || !strings.Contains(out, "may differ") {
+		t.Fatalf("unexpected output %q", out)
+	}
+}
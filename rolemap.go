@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// roleSetting is the per-role configuration of a -role-map-file entry.
+type roleSetting struct {
+	// Alias replaces the role name when the auto prefix is generated.
+	Alias string `json:"alias,omitempty"`
+	// Prefix is used verbatim as the profile prefix of the role.
+	Prefix string `json:"prefix,omitempty"`
+	// Output, Region and DurationSeconds override the written keys.
+	Output          string `json:"output,omitempty"`
+	Region          string `json:"region,omitempty"`
+	DurationSeconds int    `json:"duration_seconds,omitempty"`
+}
+
+// roleMapFile is the document read by -role-map-file.
+type roleMapFile struct {
+	Roles map[string]roleSetting `json:"roles"`
+}
+
+// roleSettings holds the -role-map-file entries keyed by role name.
+var roleSettings map[string]roleSetting
+
+// roleNamePartPattern limits aliases and prefixes to characters that are
+// safe in a profile section name.
+var roleNamePartPattern = regexp.MustCompile(`^[A-Za-z0-9._-]*$`)
+
+// loadRoleMapFile reads and validates a -role-map-file. Unknown fields are
+// rejected so a typo fails loudly instead of being silently ignored.
+func loadRoleMapFile(path string) (map[string]roleSetting, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var doc roleMapFile
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("role map %s does not match the expected schema: %v", path, err)
+	}
+	for role, s := range doc.Roles {
+		if role == "" {
+			return nil, fmt.Errorf("role map %s: empty role name", path)
+		}
+		if !roleNamePartPattern.MatchString(s.Alias) || !roleNamePartPattern.MatchString(s.Prefix) {
+			return nil, fmt.Errorf("role map %s: alias and prefix of %s may only contain letters, digits, '.', '_' and '-'", path, role)
+		}
+		if s.DurationSeconds != 0 && (s.DurationSeconds < 900 || s.DurationSeconds > 43200) {
+			return nil, fmt.Errorf("role map %s: duration_seconds of %s must be between 900 and 43200", path, role)
+		}
+	}
+	return doc.Roles, nil
+}
+
+// reportUnknownMappedRoles warns about -role-map-file entries for roles that
+// were not discovered in any account.
+func reportUnknownMappedRoles(roles []CombinedRole) {
+	found := make(map[string]bool)
+	for _, r := range roles {
+		found[r.RoleName] = true
+	}
+	var unknown []string
+	for name := range roleSettings {
+		if !found[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	for _, name := range unknown {
+		warnf("%s-role-map-file references role %s, which was not found in any selected account\n", yellow(icon("warn")), name)
+	}
+}
+
+// applyRoleSettings overrides the written values of a profile with the
+// role's -role-map-file settings. A per-account region (-region-map or
+// -region-from-tag) still takes precedence over the role's region.
+func applyRoleSettings(role CombinedRole, values map[string]string) {
+	s, ok := roleSettings[role.RoleName]
+	if !ok {
+		return
+	}
+	if s.Output != "" {
+		values["output"] = s.Output
+		if s.Output == outputInherit {
+			delete(values, "output")
+		}
+	}
+	if s.Region != "" && role.Region == "" {
+		values["region"] = s.Region
+	}
+	if s.DurationSeconds != 0 {
+		values["duration_seconds"] = strconv.Itoa(s.DurationSeconds)
+	}
+}
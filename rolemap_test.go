@@ -0,0 +1,72 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestRoleMapFile loads a sample role map, asserts it changes generated names
+// and written values, rejects malformed maps and reports unknown roles.
+func TestRoleMapFile(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, body string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+		return path
+	}
+	oldSettings, oldPrefix, oldAuto, oldOutput, oldRegion := roleSettings, profilePrefix, useAutoPrefix, profileOutput, ssoRegion
+	defer func() {
+		roleSettings, profilePrefix, useAutoPrefix, profileOutput, ssoRegion = oldSettings, oldPrefix, oldAuto, oldOutput, oldRegion
+	}()
+	profilePrefix, useAutoPrefix, profileOutput, ssoRegion = "", true, "json", "us-east-1"
+
+	settings, err := loadRoleMapFile(write("roles.json", `{"roles": {
+		"AWSAdministratorAccess": {"alias": "admin", "region": "eu-west-1", "duration_seconds": 3600},
+		"AWSReadOnlyAccess": {"prefix": "ro-", "output": "text"},
+		"Missing": {"alias": "gone"}
+	}}`))
+	if err != nil {
+		t.Fatalf("loadRoleMapFile failed: %v", err)
+	}
+	roleSettings = settings
+
+	admin := CombinedRole{AccountId: "111", AccountName: "Prod", RoleName: "AWSAdministratorAccess"}
+	readOnly := CombinedRole{AccountId: "111", AccountName: "Prod", RoleName: "AWSReadOnlyAccess"}
+	if got := getProfileNameFromRole(admin); got != "admin_Prod_111" {
+		t.Errorf("aliased name = %q", got)
+	}
+	if got := getProfileNameFromRole(readOnly); got != "ro-Prod_111" {
+		t.Errorf("prefixed name = %q", got)
+	}
+	values := profileValues(admin)
+	if values["region"] != "eu-west-1" || values["duration_seconds"] != "3600" || values["output"] != "json" {
+		t.Errorf("unexpected admin values %v", values)
+	}
+	if values := profileValues(readOnly); values["output"] != "text" || values["region"] != "us-east-1" {
+		t.Errorf("unexpected read-only values %v", values)
+	}
+	admin.Region = "ap-southeast-2"
+	if values := profileValues(admin); values["region"] != "ap-southeast-2" {
+		t.Errorf("account region should win over the role map, got %v", values)
+	}
+
+	out := captureStdout(t, func() { reportUnknownMappedRoles([]CombinedRole{admin, readOnly}) })
+	if !strings.Contains(out, "role Missing") || strings.Contains(out, "AWSReadOnlyAccess") {
+		t.Errorf("unexpected unknown role report %q", out)
+	}
+
+	for name, body := range map[string]string{
+		"typo.json":     `{"roles": {"Admin": {"alais": "a"}}}`,
+		"duration.json": `{"roles": {"Admin": {"duration_seconds": 60}}}`,
+		"alias.json":    `{"roles": {"Admin": {"alias": "a b"}}}`,
+		"broken.json":   `{"roles": `,
+	} {
+		if _, err := loadRoleMapFile(write(name, body)); err == nil || !strings.Contains(err.Error(), name) {
+			t.Errorf("%s: expected an error naming the file, got %v", name, err)
+		}
+	}
+}
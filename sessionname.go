@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// sessionNameFromURL makes a new sso-session block that has no explicit name
+// take its name from the start URL instead of "default"
+// (-session-name-from-url).
+var sessionNameFromURL bool
+
+// sessionNameUnsafe matches the runs of characters replaced in a derived
+// session name.
+var sessionNameUnsafe = regexp.MustCompile(`[^a-z0-9_-]+`)
+
+// deriveSessionName returns an sso-session name for startURL: the first
+// label of its host, lowercased, with anything but letters, digits, "_" and
+// "-" replaced by "-". For example https://acme.awsapps.com/start gives
+// "acme" and https://ssoins-7223.portal.us-east-1.app.aws gives
+// "ssoins-7223". It falls back to defaultSSOSessionConfigName when the URL
+// has no usable host.
+func deriveSessionName(startURL string) string {
+	raw := strings.TrimSpace(startURL)
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return defaultSSOSessionConfigName
+	}
+	label, _, _ := strings.Cut(u.Hostname(), ".")
+	name := strings.Trim(sessionNameUnsafe.ReplaceAllString(strings.ToLower(label), "-"), "-")
+	if name == "" {
+		return defaultSSOSessionConfigName
+	}
+	return name
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestDeriveSessionName covers the common start URL shapes.
+func TestDeriveSessionName(t *testing.T) {
+	cases := map[string]string{
+		"https://acme.awsapps.com/start":                    "acme",
+		"https://acme.awsapps.com/start/":                   "acme",
+		"https://d-1234567890.awsapps.com/start#/":          "d-1234567890",
+		"https://ssoins-7223.portal.us-east-1.app.aws":      "ssoins-7223",
+		"https://Acme_Corp.awsapps.com/start":               "acme_corp",
+		"acme.awsapps.com/start":                            "acme",
+		"https://my%20org.awsapps.com/start":                defaultSSOSessionConfigName,
+		"":                                                  defaultSSOSessionConfigName,
+		"https://start.us-gov-home.awsapps.com/directory/x": "start",
+	}
+	for in, want := range cases {
+		if got := deriveSessionName(in); got != want {
+			t.Errorf("deriveSessionName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+// TestSessionNameFromURL asserts -session-name-from-url names a new block
+// after the start URL host instead of reusing another instance's "default"
+// block, while a block matching the start URL is still reused.
+func TestSessionNameFromURL(t *testing.T) {
+	cfgPath := filepath.Join(t.TempDir(), "config")
+	other := "[sso-session default]\nsso_start_url = https://other.awsapps.com/start\nsso_region = us-east-1\n"
+	if err := os.WriteFile(cfgPath, []byte(other), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	oldConfig, oldSession, oldStart, oldRegion, oldDry, oldFromURL, oldAnnotate := ssoConfigFile, ssoSessionConfigName, ssoStartURL, ssoRegion, dryRun, sessionNameFromURL, annotateSession
+	defer func() {
+		ssoConfigFile, ssoSessionConfigName, ssoStartURL, ssoRegion, dryRun, sessionNameFromURL, annotateSession = oldConfig, oldSession, oldStart, oldRegion, oldDry, oldFromURL, oldAnnotate
+	}()
+	ssoConfigFile, ssoSessionConfigName, ssoStartURL, ssoRegion = cfgPath, defaultSSOSessionConfigName, "https://acme.awsapps.com/start", "us-east-1"
+	dryRun, sessionNameFromURL, annotateSession = false, true, false
+
+	added, err := ensureSsoSessionConfigPresent()
+	if err != nil || !added || ssoSessionConfigName != "acme" {
+		t.Fatalf("expected a new acme session, got added=%v name=%q err=%v", added, ssoSessionConfigName, err)
+	}
+	data, _ := os.ReadFile(cfgPath)
+	if !strings.Contains(string(data), "[sso-session acme]\nsso_start_url = https://acme.awsapps.com/start") {
+		t.Fatalf("expected the acme block, got:\n%s", data)
+	}
+
+	// A second run finds the block by its start URL.
+	ssoSessionConfigName = defaultSSOSessionConfigName
+	added, err = ensureSsoSessionConfigPresent()
+	if err != nil || added || ssoSessionConfigName != "acme" {
+		t.Fatalf("expected the acme session to be reused, got added=%v name=%q err=%v", added, ssoSessionConfigName, err)
+	}
+}
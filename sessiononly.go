@@ -0,0 +1,44 @@
+package main
+
+import "errors"
+
+// sessionOnly sets up the [sso-session] block and signs in if needed, but
+// writes no profiles (-session-only).
+var sessionOnly bool
+
+// runSessionOnly ensures the sso-session block exists, reusing a matching
+// one when no -sso-session-name was given, then makes sure a valid token is
+// cached, running the device login when it isn't. In dry-run the block is
+// only shown and no login is started. The session name is printed last.
+func runSessionOnly() error {
+	if ssoSessionConfigName == defaultSSOSessionConfigName || ssoSessionConfigName == "" {
+		if err := reuseMatchingSession(""); err != nil {
+			return err
+		}
+	}
+	if err := configureSsoSessionConfig(); err != nil {
+		return err
+	}
+
+	accessToken, tokenPath, err := getAccessTokenFunc()
+	valid := false
+	if errors.Is(err, errTokenExpired) && tokenPath != "" {
+		accessToken, valid = tryRefreshAccessToken(tokenPath)
+	} else if err == nil {
+		valid = isSsoTokenValid(accessToken)
+	}
+	switch {
+	case valid:
+		infof("%sExisting token at %s is valid, no login needed.\n", green(icon("ok")), tokenPath)
+	case dryRun:
+		infof("%sWould sign in to SSO session %s to obtain a token.\n", yellow(icon("info")), bold(ssoSessionConfigName))
+	default:
+		infof("%sTo continue, you need to authenticate with AWS SSO in your browser to retrieve a new token.\n", yellow(icon("info")))
+		if _, tokenPath, err = loginAndFetchToken(); err != nil {
+			return err
+		}
+		infof("%sSuccessfully obtained access token for SSO session at: %s\n", green(icon("ok")), tokenPath)
+	}
+	resultf("\n%s%s %s (%s)\n", cyan(icon("summary")), bold("SSO session:"), ssoSessionConfigName, sessionConfigPath())
+	return nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestSessionOnly verifies -session-only writes the sso-session block, signs
+// in when the cached token is invalid, and never writes a profile.
+func TestSessionOnly(t *testing.T) {
+	origGet, origRun, origIsValid, origConfigure := getAccessTokenFunc, runAwsSsoLogin, isSsoTokenValidFunc, configureSsoProfilesFunc
+	oldConfig, oldSession, oldStart, oldRegion, oldDry := ssoConfigFile, ssoSessionConfigName, ssoStartURL, ssoRegion, dryRun
+	defer func() {
+		getAccessTokenFunc, runAwsSsoLogin, isSsoTokenValidFunc, configureSsoProfilesFunc = origGet, origRun, origIsValid, origConfigure
+		ssoConfigFile, ssoSessionConfigName, ssoStartURL, ssoRegion, dryRun = oldConfig, oldSession, oldStart, oldRegion, oldDry
+	}()
+	ssoConfigFile = filepath.Join(t.TempDir(), "config")
+	ssoSessionConfigName, ssoStartURL, ssoRegion, dryRun = "corp", "https://unit.test/start", "us-east-1", false
+
+	loggedIn := false
+	logins := 0
+	getAccessTokenFunc = func() (string, string, error) { return "tok", "/tmp/tok.json", nil }
+	isSsoTokenValidFunc = func(string) bool { return loggedIn }
+	runAwsSsoLogin = func(session string) error {
+		if session != "corp" {
+			t.Fatalf("login for unexpected session %q", session)
+		}
+		logins++
+		loggedIn = true
+		return nil
+	}
+	configureSsoProfilesFunc = func(string) error {
+		t.Fatal("profiles must not be configured with -session-only")
+		return nil
+	}
+
+	out := captureStdout(t, func() {
+		if err := runSessionOnly(); err != nil {
+			t.Fatalf("runSessionOnly failed: %v", err)
+		}
+	})
+	if logins != 1 {
+		t.Fatalf("expected one login, got %d", logins)
+	}
+	if !strings.Contains(out, "SSO session: corp") {
+		t.Fatalf("expected the session name in the output, got %q", out)
+	}
+	data, err := os.ReadFile(ssoConfigFile)
+	if err != nil {
+		t.Fatalf("config not written: %v", err)
+	}
+	if !strings.Contains(string(data), "[sso-session corp]") || strings.Contains(string(data), "[profile ") {
+		t.Fatalf("expected only the session block, got:\n%s", data)
+	}
+
+	// A valid token needs no second login.
+	captureStdout(t, func() {
+		if err := runSessionOnly(); err != nil {
+			t.Fatalf("second runSessionOnly failed: %v", err)
+		}
+	})
+	if logins != 1 {
+		t.Fatalf("expected the cached token to be reused, got %d logins", logins)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/mattn/go-isatty"
+)
+
+// interactiveSessionResolution lets a terminal user pick among multiple
+// matching sso-session blocks instead of getting an error
+// (-interactive-session-resolution).
+var interactiveSessionResolution = true
+
+// stdinIsTerminal reports whether stdin is a terminal. Tests override it.
+var stdinIsTerminal = func() bool {
+	fd := os.Stdin.Fd()
+	return isatty.IsTerminal(fd) || isatty.IsCygwinTerminal(fd)
+}
+
+// promptInput is where interactive answers are read from. Tests override it.
+var promptInput io.Reader = os.Stdin
+
+// maxPromptAttempts bounds how often an invalid answer is asked again.
+const maxPromptAttempts = 3
+
+// promptSessionChoice asks the user to choose one of matches, by number or
+// by name, and returns the chosen session name.
+func promptSessionChoice(matches []string) (string, error) {
+	promptf("%sMultiple sso-session blocks match startUrl %s and region %s:\n", yellow(icon("info")), ssoStartURL, ssoRegion)
+	for i, name := range matches {
+		promptf("  %d) %s\n", i+1, name)
+	}
+	reader := bufio.NewReader(promptInput)
+	for attempt := 0; attempt < maxPromptAttempts; attempt++ {
+		promptf("Choose a session [1-%d]: ", len(matches))
+		line, err := reader.ReadString('\n')
+		answer := strings.TrimSpace(line)
+		if n, convErr := strconv.Atoi(answer); convErr == nil && n >= 1 && n <= len(matches) {
+			return matches[n-1], nil
+		}
+		for _, name := range matches {
+			if answer == name {
+				return name, nil
+			}
+		}
+		if err != nil {
+			return "", fmt.Errorf("no sso-session chosen: %v", err)
+		}
+		promptf("%s%q is not one of the listed sessions.\n", yellow(icon("warn")), answer)
+	}
+	return "", fmt.Errorf("no valid sso-session chosen after %d attempts", maxPromptAttempts)
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestInteractiveSessionResolution seeds two matching sso-session blocks and
+// asserts a terminal user is asked to choose, an invalid answer is asked
+// again, the chosen session is reused, and a non-terminal keeps the error.
+func TestInteractiveSessionResolution(t *testing.T) {
+	cfgPath := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(cfgPath, []byte(duplicateSessionsConfig), 0o600); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	oldStart, oldRegion, oldSession, oldConfig, oldPrefer := ssoStartURL, ssoRegion, ssoSessionConfigName, ssoConfigFile, preferSession
+	oldTerminal, oldInput := stdinIsTerminal, promptInput
+	defer func() {
+		ssoStartURL, ssoRegion, ssoSessionConfigName, ssoConfigFile, preferSession = oldStart, oldRegion, oldSession, oldConfig, oldPrefer
+		stdinIsTerminal, promptInput = oldTerminal, oldInput
+	}()
+	ssoStartURL, ssoRegion, ssoConfigFile, preferSession = "https://corp.awsapps.com/start", "us-east-1", cfgPath, ""
+
+	ssoSessionConfigName = defaultSSOSessionConfigName
+	stdinIsTerminal = func() bool { return true }
+	promptInput = strings.NewReader("5\ncorp-old\n")
+	var err error
+	prompt := captureStderr(t, func() {
+		captureStdout(t, func() { err = reuseMatchingSession("") })
+	})
+	if err != nil || ssoSessionConfigName != "corp-old" {
+		t.Fatalf("expected the chosen session to be reused, got %q err=%v", ssoSessionConfigName, err)
+	}
+	if !strings.Contains(prompt, "1) corp") || !strings.Contains(prompt, "2) corp-old") || !strings.Contains(prompt, `"5" is not one of the listed sessions`) {
+		t.Fatalf("unexpected prompt:\n%s", prompt)
+	}
+
+	ssoSessionConfigName = defaultSSOSessionConfigName
+	stdinIsTerminal = func() bool { return false }
+	captureStdout(t, func() { err = reuseMatchingSession("") })
+	if err == nil {
+		t.Fatalf("expected the multiple-match error without a terminal")
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"gopkg.in/ini.v1"
+)
+
+// ssoSessionInfo is one [sso-session] block of the config file.
+type ssoSessionInfo struct {
+	Name     string
+	StartURL string
+	Region   string
+	Scopes   string
+}
+
+// readSsoSessions returns the sso-session blocks of the config file at
+// configPath in file order. Start URLs lose their trailing slash, and a block
+// without sso_registration_scopes reports the default scope.
+func readSsoSessions(configPath string) ([]ssoSessionInfo, error) {
+	cfg, err := ini.Load(configPath)
+	if err != nil {
+		return nil, err
+	}
+	var sessions []ssoSessionInfo
+	for _, section := range cfg.Sections() {
+		name, ok := strings.CutPrefix(section.Name(), "sso-session ")
+		if !ok {
+			continue
+		}
+		scopes := section.Key("sso_registration_scopes").String()
+		if scopes == "" {
+			scopes = "sso:account:access"
+		}
+		sessions = append(sessions, ssoSessionInfo{
+			Name:     name,
+			StartURL: strings.TrimRight(section.Key("sso_start_url").String(), "/"),
+			Region:   section.Key("sso_region").String(),
+			Scopes:   scopes,
+		})
+	}
+	return sessions, nil
+}
+
+// runListSessionsCommand implements the list-sessions subcommand, which
+// prints every sso-session block of the config file as a table, and returns
+// the process exit code.
+func runListSessionsCommand(args []string) int {
+	fs := flag.NewFlagSet("list-sessions", flag.ContinueOnError)
+	fs.StringVar(&ssoConfigFile, "config-file", config.DefaultSharedConfigFilename(), "AWS config file path")
+	fs.BoolVar(&allowExternalConfig, "allow-external-config", false, "Allow -config-file to point outside the ~/.aws directory")
+	registerOutputFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if err := checkConfigFileLocation(ssoConfigFile); err != nil {
+		errorf("%s%s %v\n", red(icon("error")), bold("Error:"), err)
+		return 2
+	}
+
+	sessions, err := readSsoSessions(ssoConfigFile)
+	if err != nil {
+		errorf("%s%s %v\n", red(icon("error")), bold("Error reading config:"), err)
+		return 1
+	}
+	if len(sessions) == 0 {
+		resultf("%sNo sso-session blocks found in %s.\n", yellow(icon("info")), ssoConfigFile)
+		return 0
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	w.Write([]byte("NAME\tSTART URL\tREGION\tSCOPES\n"))
+	for _, s := range sessions {
+		w.Write([]byte(s.Name + "\t" + s.StartURL + "\t" + s.Region + "\t" + s.Scopes + "\n"))
+	}
+	w.Flush()
+	return 0
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestListSessionsCommand asserts list-sessions prints every sso-session
+// block with its start URL, region and scopes, defaulting missing scopes,
+// and ignores profile sections.
+func TestListSessionsCommand(t *testing.T) {
+	cfgPath := filepath.Join(t.TempDir(), "config")
+	cfg := `[sso-session corp]
+sso_start_url = https://corp.awsapps.com/start/
+sso_region = us-east-1
+sso_registration_scopes = sso:account:access,codewhisperer:completions
+
+[profile A_111]
+sso_session = corp
+
+[sso-session eu]
+sso_start_url = https://eu.awsapps.com/start
+sso_region = eu-central-1
+`
+	if err := os.WriteFile(cfgPath, []byte(cfg), 0o600); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	oldConfig, oldExternal := ssoConfigFile, allowExternalConfig
+	defer func() { ssoConfigFile, allowExternalConfig = oldConfig, oldExternal }()
+
+	var code int
+	out := captureStdout(t, func() {
+		code = runListSessionsCommand([]string{"-config-file", cfgPath, "-allow-external-config"})
+	})
+	// Skip the warning about the config living outside ~/.aws.
+	_, table, _ := strings.Cut(out, "NAME")
+	lines := strings.Split(strings.TrimSpace("NAME"+table), "\n")
+	if code != 0 || len(lines) != 3 {
+		t.Fatalf("expected a header and two sessions (exit %d):\n%s", code, out)
+	}
+	for i, want := range [][]string{
+		{"NAME", "START URL", "REGION", "SCOPES"},
+		{"corp", "https://corp.awsapps.com/start", "us-east-1", "sso:account:access,codewhisperer:completions"},
+		{"eu", "https://eu.awsapps.com/start", "eu-central-1", "sso:account:access"},
+	} {
+		for _, field := range want {
+			if !strings.Contains(lines[i], field) {
+				t.Fatalf("line %d %q is missing %q", i, lines[i], field)
+			}
+		}
+	}
+}
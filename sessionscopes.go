@@ -0,0 +1,75 @@
+package main
+
+import (
+	"os"
+	"slices"
+	"strings"
+)
+
+// requiredSsoScope is the registration scope the portal API needs to list
+// accounts and roles.
+const requiredSsoScope = "sso:account:access"
+
+// fixScopes makes a reused sso-session that lacks requiredSsoScope get it
+// added instead of only producing a warning (-fix-scopes).
+var fixScopes bool
+
+// scopesChecked records the config path and session pairs already checked,
+// so a session reused twice in one run is only reported once.
+var scopesChecked = make(map[string]bool)
+
+// parseScopes splits an sso_registration_scopes value on commas and
+// whitespace.
+func parseScopes(value string) []string {
+	return strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' })
+}
+
+// checkSessionScopes verifies that the existing sso-session sessionName in
+// configPath registers requiredSsoScope. An empty or absent
+// sso_registration_scopes counts as registering it, since the AWS CLI then
+// requests requiredSsoScope by default. A session that lists other scopes
+// only is reported, or with -fix-scopes updated (dry-run shows the change
+// instead). A session that can't be read is left for the later steps to
+// report.
+func checkSessionScopes(sessionName, configPath string) error {
+	key := configPath + "\x00" + sessionName
+	if scopesChecked[key] {
+		return nil
+	}
+	scopesChecked[key] = true
+	section, err := loadSsoSessionSection(sessionName, configPath)
+	if err != nil {
+		debugf("Not checking scopes of sso-session %s: %v\n", sessionName, err)
+		return nil
+	}
+	current := section.Key("sso_registration_scopes").String()
+	scopes := parseScopes(current)
+	if len(scopes) == 0 || slices.Contains(scopes, requiredSsoScope) {
+		return nil
+	}
+	updated := strings.Join(append(scopes, requiredSsoScope), ", ")
+	switch {
+	case !fixScopes:
+		warnf("%ssso-session %s does not register the %s scope (sso_registration_scopes = %q), so listing accounts may fail after sign-in. Re-run with -fix-scopes to set it to %q.\n",
+			yellow(icon("warn")), sessionName, requiredSsoScope, current, updated)
+		return nil
+	case dryRun:
+		infof("    %sWould update sso_registration_scopes of sso-session %s: %q -> %q\n", cyan(icon("edit")), bold(sessionName), current, updated)
+		return nil
+	}
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return err
+	}
+	data = upsertSection(data, "sso-session "+sessionName, []string{"sso_registration_scopes"}, []keyValue{{"sso_registration_scopes", updated}}, "")
+	if configPath == ssoConfigFile {
+		err = writeConfigFile(data)
+	} else {
+		err = writeProfileFile(configPath, data)
+	}
+	if err != nil {
+		return err
+	}
+	infof("    %sUpdated sso_registration_scopes of sso-session %s: %q -> %q\n", cyan(icon("edit")), bold(sessionName), current, updated)
+	return nil
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// settingEntry is one flag value from a settings file. A list key yields one
+// entry per item so repeatable flags such as -role receive every value.
+type settingEntry struct {
+	Flag  string
+	Value string
+	Line  int
+}
+
+// defaultSettingsPath returns ~/.config/aws-sso-profile-sync/config.yaml, or
+// the same file under $XDG_CONFIG_HOME when that is set.
+func defaultSettingsPath() (string, error) {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, programName, "config.yaml"), nil
+}
+
+// parseSettings reads the flat YAML subset used by settings files: one
+// "flag-name: value" pair per line, where the key is a sync flag name without
+// the leading dash. A list is written either as "[a, b]" or as "- item"
+// lines below a key with no value. Values may be quoted, and "#" starts a
+// comment outside quotes.
+func parseSettings(data []byte) ([]settingEntry, error) {
+	var entries []settingEntry
+	// listKey is the key of the block list being read, if any.
+	listKey := ""
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := stripSettingComment(scanner.Text())
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			continue
+		}
+		if item, ok := strings.CutPrefix(trimmed, "- "); ok || trimmed == "-" {
+			if listKey == "" {
+				return nil, fmt.Errorf("line %d: list item without a key", n)
+			}
+			entries = append(entries, settingEntry{listKey, unquoteSetting(item), n})
+			continue
+		}
+		if line[0] == ' ' || line[0] == '\t' {
+			return nil, fmt.Errorf("line %d: nested settings are not supported", n)
+		}
+		key, value, ok := strings.Cut(trimmed, ":")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected \"flag-name: value\"", n)
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if key == "" {
+			return nil, fmt.Errorf("line %d: missing flag name", n)
+		}
+		listKey = ""
+		switch {
+		case value == "":
+			listKey = key
+		case strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]"):
+			for _, item := range strings.Split(value[1:len(value)-1], ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					entries = append(entries, settingEntry{key, unquoteSetting(item), n})
+				}
+			}
+		default:
+			entries = append(entries, settingEntry{key, unquoteSetting(value), n})
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// stripSettingComment removes a "#" comment that is not inside quotes.
+func stripSettingComment(line string) string {
+	var quote rune
+	for i, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// unquoteSetting strips one pair of matching single or double quotes.
+func unquoteSetting(value string) string {
+	value = strings.TrimSpace(value)
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+// expandSettingsEnv expands environment variable references in every entry
+// value in place (see expandEnv).
+func expandSettingsEnv(entries []settingEntry) error {
+	for i, e := range entries {
+		value, err := expandEnv(e.Value, os.LookupEnv)
+		if err != nil {
+			return fmt.Errorf("line %d: %v", e.Line, err)
+		}
+		entries[i].Value = value
+	}
+	return nil
+}
+
+// expandEnv replaces ${VAR} and $VAR in s with values from lookup. An unset
+// variable is an error unless written as ${VAR:-default}, which also uses the
+// default when the variable is empty. "$$" gives a literal "$", as does a
+// "$" not followed by a variable name.
+func expandEnv(s string, lookup func(string) (string, bool)) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		var name, def string
+		hasDefault := false
+		switch next := s[i+1]; {
+		case next == '$':
+			b.WriteByte('$')
+			i++
+			continue
+		case next == '{':
+			end := strings.IndexByte(s[i+2:], '}')
+			if end < 0 {
+				return "", fmt.Errorf("unterminated ${ in %q", s)
+			}
+			name = s[i+2 : i+2+end]
+			name, def, hasDefault = strings.Cut(name, ":-")
+			i += 2 + end
+		case isEnvNameByte(next, true):
+			j := i + 1
+			for j < len(s) && isEnvNameByte(s[j], j == i+1) {
+				j++
+			}
+			name = s[i+1 : j]
+			i = j - 1
+		default:
+			b.WriteByte('$')
+			continue
+		}
+		if name == "" || !isEnvName(name) {
+			return "", fmt.Errorf("invalid variable name %q in %q", name, s)
+		}
+		value, ok := lookup(name)
+		switch {
+		case hasDefault && value == "":
+			value = def
+		case !ok:
+			return "", fmt.Errorf("environment variable %s is not set (use ${%s:-default} to give a default)", name, name)
+		}
+		b.WriteString(value)
+	}
+	return b.String(), nil
+}
+
+// isEnvName reports whether name is a valid environment variable name.
+func isEnvName(name string) bool {
+	for i := 0; i < len(name); i++ {
+		if !isEnvNameByte(name[i], i == 0) {
+			return false
+		}
+	}
+	return name != ""
+}
+
+// isEnvNameByte reports whether c may appear in a variable name; digits are
+// not allowed first.
+func isEnvNameByte(c byte, first bool) bool {
+	return c == '_' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || !first && c >= '0' && c <= '9'
+}
+
+// applySettings copies settings-file values onto the flags in fs that were
+// not set on the command line. It runs before -config-from-url is applied,
+// so the precedence is: command line, settings file, -config-from-url, then
+// the built-in defaults.
+func applySettings(entries []settingEntry, fs *flag.FlagSet) error {
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+	for _, e := range entries {
+		if e.Flag == "settings-file" || fs.Lookup(e.Flag) == nil {
+			return fmt.Errorf("line %d: unknown flag %q", e.Line, e.Flag)
+		}
+		if explicit[e.Flag] {
+			continue
+		}
+		if err := fs.Set(e.Flag, e.Value); err != nil {
+			return fmt.Errorf("line %d: invalid value for -%s: %v", e.Line, e.Flag, err)
+		}
+	}
+	return nil
+}
+
+// loadSettingsFile applies the settings file at path, or at the default
+// location when path is empty, to fs. A missing file is not an error; an
+// explicitly named one that is missing only produces a warning.
+func loadSettingsFile(path string, fs *flag.FlagSet) error {
+	explicit := path != ""
+	if !explicit {
+		var err error
+		if path, err = defaultSettingsPath(); err != nil {
+			return nil
+		}
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		if explicit {
+			warnf("%sSettings file %s does not exist; using flags and defaults only.\n", yellow(icon("warn")), path)
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	entries, err := parseSettings(data)
+	if err == nil {
+		err = expandSettingsEnv(entries)
+	}
+	if err == nil {
+		err = applySettings(entries, fs)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	debugf("Applied %d setting(s) from %s\n", len(entries), path)
+	return nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const sampleSettings = `# personal defaults
+sso-start-url: "https://corp.awsapps.com/start"
+sso-region: eu-west-1   # overridden on the command line below
+dry-run: true
+role:
+  - AWSReadOnlyAccess
+  - 'Billing'
+exclude-role: [Admin, "Ops"]
+`
+
+// TestSettingsFile parses a settings file and asserts its values fill flags
+// not given on the command line while explicit flags win, that unknown keys
+// are rejected, and that a missing default file is ignored.
+func TestSettingsFile(t *testing.T) {
+	oldStart, oldRegion, oldDry := ssoStartURL, ssoRegion, dryRun
+	defer func() { ssoStartURL, ssoRegion, dryRun = oldStart, oldRegion, oldDry }()
+
+	entries, err := parseSettings([]byte(sampleSettings))
+	if err != nil {
+		t.Fatalf("parseSettings failed: %v", err)
+	}
+	var got []string
+	for _, e := range entries {
+		got = append(got, e.Flag+"="+e.Value)
+	}
+	want := "sso-start-url=https://corp.awsapps.com/start,sso-region=eu-west-1,dry-run=true,role=AWSReadOnlyAccess,role=Billing,exclude-role=Admin,exclude-role=Ops"
+	if strings.Join(got, ",") != want {
+		t.Fatalf("parseSettings = %v, want %s", got, want)
+	}
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(sampleSettings), 0o600); err != nil {
+		t.Fatalf("failed to write settings: %v", err)
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	opts := registerSyncFlags(fs)
+	if err := fs.Parse([]string{"-sso-region", "us-east-2", "-settings-file", path}); err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if err := loadSettingsFile(opts.settingsFile, fs); err != nil {
+		t.Fatalf("loadSettingsFile failed: %v", err)
+	}
+	if ssoStartURL != "https://corp.awsapps.com/start" || ssoRegion != "us-east-2" || !dryRun {
+		t.Fatalf("unexpected values: start=%q region=%q dry-run=%v", ssoStartURL, ssoRegion, dryRun)
+	}
+	if strings.Join(opts.roleNames, ",") != "AWSReadOnlyAccess,Billing" {
+		t.Fatalf("expected both roles from the settings file, got %v", opts.roleNames)
+	}
+
+	if _, err := parseSettings([]byte("sso-start-url:\n    nested: x\n")); err == nil {
+		t.Fatalf("expected nested settings to be rejected")
+	}
+	if err := applySettings([]settingEntry{{"no-such-flag", "x", 3}}, fs); err == nil || !strings.Contains(err.Error(), "line 3") {
+		t.Fatalf("expected an unknown flag error with its line, got %v", err)
+	}
+
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	if err := loadSettingsFile("", flag.NewFlagSet("test", flag.ContinueOnError)); err != nil {
+		t.Fatalf("a missing default settings file should be ignored, got %v", err)
+	}
+}
+
+// TestSettingsFileEnvExpansion asserts environment variables in settings
+// values are expanded, that defaults apply to unset variables, and that an
+// unset variable without a default fails with its line number.
+func TestSettingsFileEnvExpansion(t *testing.T) {
+	oldStart, oldRegion, oldSession := ssoStartURL, ssoRegion, ssoSessionConfigName
+	defer func() { ssoStartURL, ssoRegion, ssoSessionConfigName = oldStart, oldRegion, oldSession }()
+	t.Setenv("ACME_SSO_URL", "https://acme.awsapps.com/start")
+	t.Setenv("ACME_TEAM", "platform")
+	os.Unsetenv("ACME_SSO_REGION")
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	settings := "sso-start-url: ${ACME_SSO_URL}\nsso-region: ${ACME_SSO_REGION:-eu-central-1}\nsso-session-name: $ACME_TEAM-$$1\n"
+	if err := os.WriteFile(path, []byte(settings), 0o600); err != nil {
+		t.Fatalf("failed to write settings: %v", err)
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	registerSyncFlags(fs)
+	if err := loadSettingsFile(path, fs); err != nil {
+		t.Fatalf("loadSettingsFile failed: %v", err)
+	}
+	if ssoStartURL != "https://acme.awsapps.com/start" || ssoRegion != "eu-central-1" || ssoSessionConfigName != "platform-$1" {
+		t.Fatalf("unexpected values: start=%q region=%q session=%q", ssoStartURL, ssoRegion, ssoSessionConfigName)
+	}
+
+	if err := os.WriteFile(path, []byte("sso-region: eu-west-1\nsso-start-url: ${ACME_UNSET_URL}\n"), 0o600); err != nil {
+		t.Fatalf("failed to write settings: %v", err)
+	}
+	err := loadSettingsFile(path, flag.NewFlagSet("test", flag.ContinueOnError))
+	if err == nil || !strings.Contains(err.Error(), "line 2") || !strings.Contains(err.Error(), "ACME_UNSET_URL is not set") {
+		t.Fatalf("expected an unset-variable error on line 2, got %v", err)
+	}
+	if _, err := expandEnv("${ACME_TEAM", os.LookupEnv); err == nil {
+		t.Fatal("expected an unterminated ${ to be rejected")
+	}
+}
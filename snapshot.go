@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// snapshotSchemaVersion is the version of the -export document accepted by
+// -from-snapshot.
+const snapshotSchemaVersion = 1
+
+// discoverySnapshot is the account and role data written by -export and
+// replayed by -from-snapshot.
+type discoverySnapshot struct {
+	SchemaVersion int               `json:"schemaVersion"`
+	StartURL      string            `json:"startUrl"`
+	Accounts      []snapshotAccount `json:"accounts"`
+}
+
+// snapshotAccount is one account of a snapshot with all its roles.
+type snapshotAccount struct {
+	AccountId   string   `json:"accountId"`
+	AccountName string   `json:"accountName"`
+	Roles       []string `json:"roles"`
+}
+
+// exportSnapshot discovers every account and all of its roles, unfiltered,
+// and writes them to path for later use with -from-snapshot.
+func exportSnapshot(accessToken, path string) error {
+	accounts, err := getListOfSsoAccountsFunc(accessToken)
+	if err != nil {
+		return err
+	}
+	snap := discoverySnapshot{SchemaVersion: snapshotSchemaVersion, StartURL: ssoStartURL, Accounts: []snapshotAccount{}}
+	for _, account := range accounts {
+		roles, err := getListOfSsoAccountRolesForAccountFunc(accessToken, account.AccountId)
+		if err != nil {
+			return err
+		}
+		entry := snapshotAccount{AccountId: account.AccountId, AccountName: account.AccountName, Roles: []string{}}
+		for _, role := range roles {
+			entry.Roles = append(entry.Roles, role.RoleName)
+		}
+		snap.Accounts = append(snap.Accounts, entry)
+	}
+	data, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o600); err != nil {
+		return err
+	}
+	infof("%sExported %d account(s) to %s\n", green(icon("ok")), len(snap.Accounts), path)
+	return nil
+}
+
+// loadSnapshot reads and validates a snapshot written by -export.
+func loadSnapshot(path string) (*discoverySnapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var snap discoverySnapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("parsing snapshot %s: %v", path, err)
+	}
+	if snap.SchemaVersion != snapshotSchemaVersion {
+		return nil, fmt.Errorf("snapshot %s has schemaVersion %d, expected %d", path, snap.SchemaVersion, snapshotSchemaVersion)
+	}
+	seen := make(map[string]bool)
+	for i, account := range snap.Accounts {
+		if account.AccountId == "" {
+			return nil, fmt.Errorf("snapshot %s: account %d has no accountId", path, i)
+		}
+		if seen[account.AccountId] {
+			return nil, fmt.Errorf("snapshot %s: account %s is listed twice", path, account.AccountId)
+		}
+		seen[account.AccountId] = true
+		for _, role := range account.Roles {
+			if role == "" {
+				return nil, fmt.Errorf("snapshot %s: account %s has an empty role name", path, account.AccountId)
+			}
+		}
+	}
+	return &snap, nil
+}
+
+// useSnapshot replaces the token lookup and account/role discovery with the
+// snapshot's data, so the rest of the run makes no SSO calls.
+func useSnapshot(snap *discoverySnapshot) {
+	getAccessTokenFunc = func() (string, string, error) { return "snapshot", "", nil }
+	isSsoTokenValidFunc = func(accessToken string) bool { return true }
+	getListOfSsoAccountsFunc = func(accessToken string) ([]ssoTypesAccount, error) {
+		var accounts []ssoTypesAccount
+		for _, a := range snap.Accounts {
+			accounts = append(accounts, ssoTypesAccount{AccountId: a.AccountId, AccountName: a.AccountName})
+		}
+		return accounts, nil
+	}
+	getListOfSsoAccountRolesForAccountFunc = func(accessToken, accountId string) ([]ssoTypesRole, error) {
+		for _, a := range snap.Accounts {
+			if a.AccountId != accountId {
+				continue
+			}
+			var roles []ssoTypesRole
+			for _, r := range a.Roles {
+				roles = append(roles, ssoTypesRole{RoleName: r})
+			}
+			return roles, nil
+		}
+		return nil, fmt.Errorf("account %s is not in the snapshot", accountId)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/ini.v1"
+)
+
+// TestSnapshotRoundTrip exports stubbed discovery data, replays it with
+// -from-snapshot through the full login and write pipeline into a temp
+// config, and asserts malformed snapshots are rejected.
+func TestSnapshotRoundTrip(t *testing.T) {
+	origToken, origValid := getAccessTokenFunc, isSsoTokenValidFunc
+	oldConfig, oldSession, oldStart, oldRoles, oldDry := ssoConfigFile, ssoSessionConfigName, ssoStartURL, ssoRoleNames, dryRun
+	oldPrefix, oldAuto, oldOutput := profilePrefix, useAutoPrefix, profileOutput
+	defer func() {
+		getAccessTokenFunc, isSsoTokenValidFunc = origToken, origValid
+		ssoConfigFile, ssoSessionConfigName, ssoStartURL, ssoRoleNames, dryRun = oldConfig, oldSession, oldStart, oldRoles, oldDry
+		profilePrefix, useAutoPrefix, profileOutput = oldPrefix, oldAuto, oldOutput
+	}()
+	dir := t.TempDir()
+	ssoSessionConfigName, ssoStartURL, dryRun = "corp", "https://corp.awsapps.com/start", false
+	profilePrefix, useAutoPrefix, profileOutput = "", false, "json"
+
+	stubDiscovery(t,
+		[]ssoTypesAccount{{AccountId: "111", AccountName: "Prod"}, {AccountId: "222", AccountName: "Dev"}},
+		map[string][]string{"111": {"ReadOnly", "Admin"}, "222": {"ReadOnly"}})
+	snapPath := filepath.Join(dir, "snapshot.json")
+	captureStdout(t, func() {
+		if err := exportSnapshot("token", snapPath); err != nil {
+			t.Fatalf("exportSnapshot failed: %v", err)
+		}
+	})
+
+	snap, err := loadSnapshot(snapPath)
+	if err != nil {
+		t.Fatalf("loadSnapshot failed: %v", err)
+	}
+	getListOfSsoAccountsFunc = func(string) ([]ssoTypesAccount, error) {
+		t.Fatalf("live discovery called while replaying a snapshot")
+		return nil, nil
+	}
+	useSnapshot(snap)
+
+	ssoConfigFile = filepath.Join(dir, "config")
+	ssoRoleNames = []string{"ReadOnly"}
+	captureStdout(t, func() { err = login() })
+	if err != nil {
+		t.Fatalf("login() from snapshot failed: %v", err)
+	}
+	cfg, err := ini.Load(ssoConfigFile)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	var profiles []string
+	for _, name := range cfg.SectionStrings() {
+		if strings.HasPrefix(name, "profile ") {
+			profiles = append(profiles, name)
+		}
+	}
+	if strings.Join(profiles, ",") != "profile Prod_111,profile Dev_222" {
+		t.Fatalf("unexpected profiles written from snapshot: %v", profiles)
+	}
+
+	for name, body := range map[string]string{
+		"version.json": `{"schemaVersion": 2, "accounts": []}`,
+		"noid.json":    `{"schemaVersion": 1, "accounts": [{"accountName": "Prod", "roles": []}]}`,
+		"dup.json":     `{"schemaVersion": 1, "accounts": [{"accountId": "1"}, {"accountId": "1"}]}`,
+		"broken.json":  `{"schemaVersion": 1,`,
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+		if _, err := loadSnapshot(path); err == nil {
+			t.Errorf("%s: expected the snapshot to be rejected", name)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+)
+
+// parameterSource reads an SSM Parameter Store value. Like the account tag
+// source it uses the ambient AWS credentials, not the SSO token.
+type parameterSource interface {
+	Parameter(ctx context.Context, name string) (value, paramType string, err error)
+}
+
+// ssmClient implements parameterSource using the SSM GetParameter API.
+type ssmClient struct {
+	client *awsJSONClient
+}
+
+// newParameterSourceFunc creates the source used by -roles-from-ssm. Tests
+// can override this to stub SSM.
+var newParameterSourceFunc = func() (parameterSource, error) {
+	cfg, err := config.LoadDefaultConfig(runContext)
+	if err != nil {
+		return nil, err
+	}
+	// Use the ambient region, falling back to the Identity Center region.
+	region := cfg.Region
+	if region == "" {
+		region = ssoRegion
+	}
+	return &ssmClient{client: &awsJSONClient{
+		cfg:           cfg,
+		endpoint:      fmt.Sprintf("https://ssm.%s.amazonaws.com/", region),
+		signingName:   "ssm",
+		signingRegion: region,
+		targetPrefix:  "AmazonSSM",
+	}}, nil
+}
+
+func (s *ssmClient) Parameter(ctx context.Context, name string) (string, string, error) {
+	var out struct {
+		Parameter struct {
+			Value string
+			Type  string
+		}
+	}
+	in := map[string]interface{}{"Name": name, "WithDecryption": true}
+	if err := s.client.call(ctx, "GetParameter", in, &out); err != nil {
+		return "", "", err
+	}
+	return out.Parameter.Value, out.Parameter.Type, nil
+}
+
+// parseRoleList decodes a role list stored in a parameter: a JSON array of
+// strings, a StringList parameter (comma-separated) or one role per line.
+// Blank entries are dropped.
+func parseRoleList(value, paramType string) ([]string, error) {
+	var raw []string
+	switch trimmed := strings.TrimSpace(value); {
+	case strings.HasPrefix(trimmed, "["):
+		if err := json.Unmarshal([]byte(trimmed), &raw); err != nil {
+			return nil, fmt.Errorf("parsing JSON role list: %v", err)
+		}
+	case paramType == "StringList":
+		raw = strings.Split(trimmed, ",")
+	default:
+		raw = strings.Split(trimmed, "\n")
+	}
+	var roles []string
+	for _, r := range raw {
+		if r = strings.TrimSpace(r); r != "" {
+			roles = append(roles, r)
+		}
+	}
+	return roles, nil
+}
+
+// rolesFromSSM fetches the role list stored in the SSM parameter name.
+func rolesFromSSM(name string) ([]string, error) {
+	source, err := newParameterSourceFunc()
+	if err != nil {
+		return nil, err
+	}
+	value, paramType, err := source.Parameter(runContext, name)
+	if err != nil {
+		var apiErr *awsAPIError
+		switch {
+		case errors.As(err, &apiErr) && apiErr.Code == "ParameterNotFound":
+			return nil, fmt.Errorf("SSM parameter %s not found", name)
+		case isAccessDeniedError(err):
+			return nil, fmt.Errorf("reading SSM parameter %s was denied; the ambient credentials need ssm:GetParameter (and kms:Decrypt for a SecureString): %v", name, err)
+		}
+		return nil, fmt.Errorf("reading SSM parameter %s: %v", name, err)
+	}
+	roles, err := parseRoleList(value, paramType)
+	if err != nil {
+		return nil, fmt.Errorf("SSM parameter %s: %v", name, err)
+	}
+	return roles, nil
+}
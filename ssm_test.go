@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// TestRolesFromSSM stubs SSM with a local server and asserts JSON, StringList
+// and newline-separated role lists are decoded, and that a missing parameter
+// and denied access produce clear errors.
+func TestRolesFromSSM(t *testing.T) {
+	params := map[string][2]string{
+		"/roles/json":  {`["AWSReadOnlyAccess", "Billing"]`, "String"},
+		"/roles/list":  {"AWSReadOnlyAccess,Billing", "StringList"},
+		"/roles/lines": {"AWSReadOnlyAccess\n\nBilling\n", "String"},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Amz-Target") != "AmazonSSM.GetParameter" || r.Header.Get("Authorization") == "" {
+			t.Errorf("unexpected request target %q", r.Header.Get("X-Amz-Target"))
+		}
+		var in struct {
+			Name           string
+			WithDecryption bool
+		}
+		json.NewDecoder(r.Body).Decode(&in)
+		if in.Name == "/roles/secret" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"__type": "AccessDeniedException", "message": "not authorized"})
+			return
+		}
+		p, ok := params[in.Name]
+		if !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"__type": "ParameterNotFound"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"Parameter": map[string]string{"Value": p[0], "Type": p[1]}})
+	}))
+	defer server.Close()
+
+	orig := newParameterSourceFunc
+	defer func() { newParameterSourceFunc = orig }()
+	newParameterSourceFunc = func() (parameterSource, error) {
+		cfg := aws.Config{Credentials: aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+			return aws.Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"}, nil
+		})}
+		return &ssmClient{client: &awsJSONClient{
+			cfg:           cfg,
+			endpoint:      server.URL,
+			signingName:   "ssm",
+			signingRegion: "us-east-1",
+			targetPrefix:  "AmazonSSM",
+		}}, nil
+	}
+
+	for name := range params {
+		roles, err := rolesFromSSM(name)
+		if err != nil || strings.Join(roles, ",") != "AWSReadOnlyAccess,Billing" {
+			t.Errorf("%s: roles=%v err=%v", name, roles, err)
+		}
+	}
+	if _, err := rolesFromSSM("/roles/missing"); err == nil || !strings.Contains(err.Error(), "SSM parameter /roles/missing not found") {
+		t.Errorf("expected a not-found error, got %v", err)
+	}
+	if _, err := rolesFromSSM("/roles/secret"); err == nil || !strings.Contains(err.Error(), "ssm:GetParameter") {
+		t.Errorf("expected an access-denied error, got %v", err)
+	}
+}
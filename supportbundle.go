@@ -0,0 +1,189 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/ini.v1"
+)
+
+// secretKeyPattern matches config keys whose values are credentials.
+var secretKeyPattern = regexp.MustCompile(`(?i)(secret|token|password|access_key)`)
+
+// digitRun matches a run of digits; runs of exactly 12 are account IDs.
+var digitRun = regexp.MustCompile(`\d+`)
+
+// bundleRedactor replaces account IDs with stable placeholders, so the
+// relationships between profiles survive redaction.
+type bundleRedactor struct {
+	accounts map[string]string
+}
+
+func (r *bundleRedactor) redact(s string) string {
+	return digitRun.ReplaceAllStringFunc(s, func(id string) string {
+		if len(id) != 12 {
+			return id
+		}
+		if r.accounts == nil {
+			r.accounts = make(map[string]string)
+		}
+		if _, ok := r.accounts[id]; !ok {
+			r.accounts[id] = fmt.Sprintf("ACCOUNT-%d", len(r.accounts)+1)
+		}
+		return r.accounts[id]
+	})
+}
+
+// bundleCacheFile describes one SSO cache file without its tokens.
+type bundleCacheFile struct {
+	Name            string `json:"name"`
+	Size            int64  `json:"size"`
+	StartURL        string `json:"startUrl,omitempty"`
+	Region          string `json:"region,omitempty"`
+	ExpiresAt       string `json:"expiresAt,omitempty"`
+	HasRefreshToken bool   `json:"hasRefreshToken"`
+}
+
+// redactedConfig renders the sso-session blocks and the SSO profiles of the
+// config file with account IDs replaced and credential values removed.
+func redactedConfig(path string, r *bundleRedactor) (string, error) {
+	cfg, err := ini.Load(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "# config file not found\n", nil
+		}
+		return "", err
+	}
+	var b strings.Builder
+	for _, section := range cfg.Sections() {
+		relevant := strings.HasPrefix(section.Name(), "sso-session ") ||
+			(strings.HasPrefix(section.Name(), "profile ") && (section.HasKey(profileKey("sso_session")) || section.HasKey(profileKey("sso_account_id"))))
+		if !relevant {
+			continue
+		}
+		fmt.Fprintf(&b, "[%s]\n", r.redact(section.Name()))
+		for _, key := range section.Keys() {
+			value := key.String()
+			if secretKeyPattern.MatchString(key.Name()) {
+				value = "REDACTED"
+			}
+			fmt.Fprintf(&b, "%s = %s\n", key.Name(), r.redact(value))
+		}
+		b.WriteString("\n")
+	}
+	return b.String(), nil
+}
+
+// listCacheFiles describes the files in the SSO token cache.
+func listCacheFiles() ([]bundleCacheFile, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Join(homeDir, ".aws", "sso", "cache")
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return []bundleCacheFile{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	files := []bundleCacheFile{}
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil || e.IsDir() {
+			continue
+		}
+		f := bundleCacheFile{Name: e.Name(), Size: info.Size()}
+		if data, err := os.ReadFile(filepath.Join(dir, e.Name())); err == nil {
+			var cache struct {
+				StartURL     string `json:"startUrl"`
+				Region       string `json:"region"`
+				ExpiresAt    string `json:"expiresAt"`
+				RefreshToken string `json:"refreshToken"`
+			}
+			if json.Unmarshal(data, &cache) == nil {
+				f.StartURL, f.Region, f.ExpiresAt = cache.StartURL, cache.Region, cache.ExpiresAt
+				f.HasRefreshToken = cache.RefreshToken != ""
+			}
+		}
+		files = append(files, f)
+	}
+	return files, nil
+}
+
+// resolvedSettings returns every flag of fs with its effective value after
+// the settings file and -config-from-url were applied.
+func resolvedSettings(fs *flag.FlagSet, r *bundleRedactor) map[string]string {
+	settings := make(map[string]string)
+	fs.VisitAll(func(f *flag.Flag) {
+		settings[f.Name] = r.redact(f.Value.String())
+	})
+	return settings
+}
+
+// writeSupportBundle writes a zip for bug reports to path. It holds the
+// redacted SSO sections of the config, the token cache listing (never the
+// tokens), the resolved flag values and the tool version.
+func writeSupportBundle(path string, fs *flag.FlagSet) error {
+	var r bundleRedactor
+	config, err := redactedConfig(ssoConfigFile, &r)
+	if err != nil {
+		return fmt.Errorf("reading config: %v", err)
+	}
+	cacheFiles, err := listCacheFiles()
+	if err != nil {
+		return fmt.Errorf("listing token cache: %v", err)
+	}
+	cacheJSON, err := json.MarshalIndent(cacheFiles, "", "  ")
+	if err != nil {
+		return err
+	}
+	settingsJSON, err := json.MarshalIndent(resolvedSettings(fs, &r), "", "  ")
+	if err != nil {
+		return err
+	}
+	versionText := fmt.Sprintf("%s %s\n%s %s/%s\ncreated %s\n", programName, version, runtime.Version(), runtime.GOOS, runtime.GOARCH, nowFunc().UTC().Format(time.RFC3339))
+
+	out, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	zw := zip.NewWriter(out)
+	artifacts := map[string][]byte{
+		"config.txt":     []byte(config),
+		"sso-cache.json": append(cacheJSON, '\n'),
+		"settings.json":  append(settingsJSON, '\n'),
+		"version.txt":    []byte(versionText),
+	}
+	names := make([]string, 0, len(artifacts))
+	for name := range artifacts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		w, err := zw.Create(name)
+		if err == nil {
+			_, err = w.Write(artifacts[name])
+		}
+		if err != nil {
+			zw.Close()
+			out.Close()
+			return err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
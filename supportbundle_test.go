@@ -0,0 +1,87 @@
+package main
+
+import (
+	"archive/zip"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestSupportBundle writes a bundle for a config with SSO profiles, static
+// credentials and a cached token, and asserts each artifact is present with
+// account IDs, keys and tokens redacted.
+func TestSupportBundle(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	cacheDir := filepath.Join(home, ".aws", "sso", "cache")
+	if err := os.MkdirAll(cacheDir, 0o700); err != nil {
+		t.Fatalf("failed to create cache dir: %v", err)
+	}
+	token := `{"startUrl": "https://corp.awsapps.com/start", "region": "us-east-1", "accessToken": "SECRET-ACCESS", "refreshToken": "SECRET-REFRESH", "expiresAt": "2030-01-01T12:00:00Z"}`
+	if err := os.WriteFile(filepath.Join(cacheDir, "abc.json"), []byte(token), 0o600); err != nil {
+		t.Fatalf("failed to write token: %v", err)
+	}
+	cfgPath := filepath.Join(t.TempDir(), "config")
+	cfg := `[sso-session corp]
+sso_start_url = https://corp.awsapps.com/start
+sso_region = us-east-1
+
+[profile ReadOnly_Prod_123456789012]
+sso_session = corp
+sso_account_id = 123456789012
+sso_role_name = ReadOnly
+
+[profile static]
+aws_access_key_id = AKIASECRET
+aws_secret_access_key = SECRET-KEY
+`
+	if err := os.WriteFile(cfgPath, []byte(cfg), 0o600); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	oldConfig := ssoConfigFile
+	defer func() { ssoConfigFile = oldConfig }()
+	ssoConfigFile = cfgPath
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	ids := stringSliceFlag{"123456789012"}
+	fs.Var(&ids, "account-id", "")
+
+	bundle := filepath.Join(t.TempDir(), "bundle.zip")
+	if err := writeSupportBundle(bundle, fs); err != nil {
+		t.Fatalf("writeSupportBundle failed: %v", err)
+	}
+	zr, err := zip.OpenReader(bundle)
+	if err != nil {
+		t.Fatalf("failed to open bundle: %v", err)
+	}
+	defer zr.Close()
+	files := make(map[string]string)
+	for _, f := range zr.File {
+		rc, _ := f.Open()
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		files[f.Name] = string(data)
+	}
+	for _, name := range []string{"config.txt", "sso-cache.json", "settings.json", "version.txt"} {
+		if _, ok := files[name]; !ok {
+			t.Fatalf("bundle is missing %s (has %v)", name, files)
+		}
+	}
+	all := strings.Join([]string{files["config.txt"], files["sso-cache.json"], files["settings.json"]}, "\n")
+	for _, secret := range []string{"123456789012", "SECRET", "AKIA"} {
+		if strings.Contains(all, secret) {
+			t.Fatalf("bundle leaks %q:\n%s", secret, all)
+		}
+	}
+	if !strings.Contains(files["config.txt"], "[profile ReadOnly_Prod_ACCOUNT-1]") || !strings.Contains(files["config.txt"], "sso_account_id = ACCOUNT-1") {
+		t.Fatalf("expected a consistently redacted profile, got:\n%s", files["config.txt"])
+	}
+	if !strings.Contains(files["sso-cache.json"], `"expiresAt": "2030-01-01T12:00:00Z"`) || !strings.Contains(files["sso-cache.json"], `"hasRefreshToken": true`) {
+		t.Fatalf("unexpected cache listing:\n%s", files["sso-cache.json"])
+	}
+	if !strings.Contains(files["settings.json"], `"account-id": "ACCOUNT-1"`) || !strings.Contains(files["version.txt"], version) {
+		t.Fatalf("unexpected settings or version:\n%s\n%s", files["settings.json"], files["version.txt"])
+	}
+}
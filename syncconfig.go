@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// syncConfig is the declarative form of the sync settings, as read by
+// -config or served by -config-from-url. Each field mirrors the command-line flag of the same
+// name; flags given explicitly on the command line take precedence.
+type syncConfig struct {
+	StartURL    string   `json:"start_url"`
+	Region      string   `json:"region,omitempty"`
+	SessionName string   `json:"session_name,omitempty"`
+	Roles       []string `json:"roles,omitempty"`
+	Prefix      string   `json:"prefix,omitempty"`
+	Output      string   `json:"output,omitempty"`
+	NameStyle   string   `json:"name_style,omitempty"`
+	Filter      string   `json:"filter,omitempty"`
+	// Geographies is set when the document is the list form (see
+	// syncGeography) instead of a single object.
+	Geographies []syncGeography `json:"-"`
+}
+
+// parseSyncConfig decodes and validates a declarative config document.
+// Unknown fields are rejected so a schema mismatch fails loudly instead of
+// being silently ignored.
+func parseSyncConfig(data []byte) (syncConfig, error) {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		list, err := parseGeographies(trimmed)
+		if err != nil {
+			return syncConfig{}, err
+		}
+		return syncConfig{Geographies: list}, nil
+	}
+	var cfg syncConfig
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return syncConfig{}, fmt.Errorf("config does not match the expected schema: %v", err)
+	}
+	if err := expandFieldsEnv(cfg.envFields()); err != nil {
+		return syncConfig{}, err
+	}
+	if cfg.StartURL == "" {
+		return syncConfig{}, fmt.Errorf("config does not match the expected schema: start_url is required")
+	}
+	if cfg.NameStyle != "" {
+		if err := validateNameStyle(cfg.NameStyle); err != nil {
+			return syncConfig{}, fmt.Errorf("config does not match the expected schema: %v", err)
+		}
+	}
+	return cfg, nil
+}
+
+// envField is a string value of a config document that may refer to
+// environment variables, with its JSON name for error messages.
+type envField struct {
+	name  string
+	value *string
+}
+
+// envFields returns the string fields of cfg, roles included.
+func (cfg *syncConfig) envFields() []envField {
+	fields := []envField{
+		{"start_url", &cfg.StartURL},
+		{"region", &cfg.Region},
+		{"session_name", &cfg.SessionName},
+		{"prefix", &cfg.Prefix},
+		{"output", &cfg.Output},
+		{"name_style", &cfg.NameStyle},
+		{"filter", &cfg.Filter},
+	}
+	for i := range cfg.Roles {
+		fields = append(fields, envField{"roles", &cfg.Roles[i]})
+	}
+	return fields
+}
+
+// expandFieldsEnv expands environment variable references in each field in
+// place (see expandEnv), so config documents follow the same rules as the
+// settings file.
+func expandFieldsEnv(fields []envField) error {
+	for _, f := range fields {
+		value, err := expandEnv(*f.value, os.LookupEnv)
+		if err != nil {
+			return fmt.Errorf("config value %s: %v", f.name, err)
+		}
+		*f.value = value
+	}
+	return nil
+}
+
+// applySyncConfig copies config values onto the flags in fs that were not
+// set explicitly, so command-line flags always win over the config. A
+// multi-geography config is stored in geographies instead; the per-instance
+// flags cannot be combined with it.
+func applySyncConfig(cfg syncConfig, fs *flag.FlagSet) error {
+	if len(cfg.Geographies) > 0 {
+		for _, name := range []string{"sso-start-url", "sso-region", "sso-session-name", "role"} {
+			if flagWasSet(fs, name) {
+				return fmt.Errorf("-%s cannot be combined with a multi-geography config", name)
+			}
+		}
+		geographies = cfg.Geographies
+		return nil
+	}
+	values := []struct {
+		flag  string
+		value string
+	}{
+		{"sso-start-url", cfg.StartURL},
+		{"sso-region", cfg.Region},
+		{"sso-session-name", cfg.SessionName},
+		{"prefix", cfg.Prefix},
+		{"output", cfg.Output},
+		{"name-style", cfg.NameStyle},
+		{"filter", cfg.Filter},
+	}
+	for _, v := range values {
+		if v.value == "" || flagWasSet(fs, v.flag) {
+			continue
+		}
+		if err := fs.Set(v.flag, v.value); err != nil {
+			return fmt.Errorf("applying config value for -%s: %v", v.flag, err)
+		}
+	}
+	if !flagWasSet(fs, "role") {
+		for _, r := range cfg.Roles {
+			if err := fs.Set("role", r); err != nil {
+				return fmt.Errorf("applying config value for -role: %v", err)
+			}
+		}
+	}
+	return nil
+}
+
+// loadSyncConfigFile reads and validates the declarative config at path
+// (-config), the local counterpart of fetchSyncConfig.
+func loadSyncConfigFile(path string) (syncConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return syncConfig{}, err
+	}
+	cfg, err := parseSyncConfig(data)
+	if err != nil {
+		return syncConfig{}, fmt.Errorf("config %s: %v", path, err)
+	}
+	return cfg, nil
+}
+
+// remoteConfigCachePath returns the local cache file for a config URL.
+func remoteConfigCachePath(configURL string) (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha1.Sum([]byte(configURL))
+	return filepath.Join(cacheDir, "aws-sso-profile-sync", "config-"+hex.EncodeToString(sum[:])+".json"), nil
+}
+
+// newConfigHTTPClient builds the HTTP client used for -config-from-url. It
+// honors the standard proxy environment variables and, like the AWS SDK,
+// trusts the extra CA certificates in AWS_CA_BUNDLE when set.
+func newConfigHTTPClient() (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+	if bundle := os.Getenv("AWS_CA_BUNDLE"); bundle != "" {
+		pem, err := os.ReadFile(bundle)
+		if err != nil {
+			return nil, fmt.Errorf("reading AWS_CA_BUNDLE: %v", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("AWS_CA_BUNDLE %s contains no valid certificates", bundle)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+	return &http.Client{Transport: transport, Timeout: 30 * time.Second}, nil
+}
+
+// fetchSyncConfig returns the declarative config served at configURL. A
+// cached copy younger than ttl is used without contacting the server;
+// otherwise the config is fetched over HTTPS, validated and cached.
+func fetchSyncConfig(configURL string, client *http.Client, ttl time.Duration) (syncConfig, error) {
+	u, err := url.Parse(configURL)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return syncConfig{}, fmt.Errorf("invalid -config-from-url %q: an https:// URL is required", configURL)
+	}
+
+	cachePath, cacheErr := remoteConfigCachePath(configURL)
+	if cacheErr == nil && ttl > 0 {
+		if info, err := os.Stat(cachePath); err == nil && time.Since(info.ModTime()) < ttl {
+			if data, err := os.ReadFile(cachePath); err == nil {
+				if cfg, err := parseSyncConfig(data); err == nil {
+					return cfg, nil
+				}
+			}
+		}
+	}
+
+	resp, err := client.Get(configURL)
+	if err != nil {
+		return syncConfig{}, fmt.Errorf("fetching config from %s: %v", configURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return syncConfig{}, fmt.Errorf("fetching config from %s: unexpected status %s", configURL, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return syncConfig{}, fmt.Errorf("reading config from %s: %v", configURL, err)
+	}
+	cfg, err := parseSyncConfig(data)
+	if err != nil {
+		return syncConfig{}, fmt.Errorf("config from %s: %v", configURL, err)
+	}
+
+	// Cache the validated document; failing to cache is not fatal.
+	if cacheErr == nil {
+		if err := os.MkdirAll(filepath.Dir(cachePath), 0o700); err == nil {
+			tmpPath := cachePath + ".tmp"
+			if err := os.WriteFile(tmpPath, data, 0o600); err == nil {
+				if err := os.Rename(tmpPath, cachePath); err != nil {
+					_ = os.Remove(tmpPath)
+				}
+			}
+		}
+	}
+	return cfg, nil
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestFetchSyncConfig serves a sample declarative config from an HTTPS test
+// server and checks it is fetched, validated, cached and applied to flags
+// that were not set explicitly.
+func TestFetchSyncConfig(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	requests := 0
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch r.URL.Path {
+		case "/sync.json":
+			w.Write([]byte(`{"start_url":"https://acme.awsapps.com/start","region":"eu-west-1","roles":["AWSReadOnlyAccess"],"name_style":"account-only"}`))
+		case "/bad-schema.json":
+			w.Write([]byte(`{"start_url":"https://acme.awsapps.com/start","unexpected":true}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	cfg, err := fetchSyncConfig(srv.URL+"/sync.json", srv.Client(), time.Minute)
+	if err != nil {
+		t.Fatalf("fetchSyncConfig failed: %v", err)
+	}
+	if cfg.StartURL != "https://acme.awsapps.com/start" || cfg.Region != "eu-west-1" || len(cfg.Roles) != 1 {
+		t.Fatalf("unexpected config: %+v", cfg)
+	}
+
+	// A second fetch within the TTL is served from the cache.
+	if _, err := fetchSyncConfig(srv.URL+"/sync.json", srv.Client(), time.Minute); err != nil {
+		t.Fatalf("cached fetchSyncConfig failed: %v", err)
+	}
+	if requests != 1 {
+		t.Fatalf("expected cached config to be reused, server saw %d requests", requests)
+	}
+
+	if _, err := fetchSyncConfig(srv.URL+"/missing.json", srv.Client(), time.Minute); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Fatalf("expected non-200 error, got %v", err)
+	}
+	if _, err := fetchSyncConfig(srv.URL+"/bad-schema.json", srv.Client(), time.Minute); err == nil || !strings.Contains(err.Error(), "schema") {
+		t.Fatalf("expected schema error, got %v", err)
+	}
+	if _, err := fetchSyncConfig("http://example.com/sync.json", srv.Client(), time.Minute); err == nil {
+		t.Fatalf("expected plain http URL to be rejected")
+	}
+
+	// Explicit flags win over config values.
+	var start, region, style string
+	var roles stringSliceFlag
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.StringVar(&start, "sso-start-url", "", "")
+	fs.StringVar(&region, "sso-region", "us-east-1", "")
+	fs.StringVar(&style, "name-style", nameStyleRoleAccount, "")
+	fs.Var(&roles, "role", "")
+	for _, name := range []string{"sso-session-name", "prefix", "output", "filter"} {
+		fs.String(name, "", "")
+	}
+	if err := fs.Parse([]string{"-sso-region", "ap-southeast-2"}); err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if err := applySyncConfig(cfg, fs); err != nil {
+		t.Fatalf("applySyncConfig failed: %v", err)
+	}
+	if start != cfg.StartURL || region != "ap-southeast-2" || style != nameStyleAccountOnly || len(roles) != 1 {
+		t.Fatalf("unexpected applied values: start=%q region=%q style=%q roles=%v", start, region, style, roles)
+	}
+}
+
+// TestParseSyncConfigEnvExpansion asserts environment variables in config
+// values are expanded as in the settings file, and that an unset variable
+// is an error naming the field.
+func TestParseSyncConfigEnvExpansion(t *testing.T) {
+	t.Setenv("ACME_SSO_URL", "https://acme.awsapps.com/start")
+	t.Setenv("ACME_ROLE", "AWSReadOnlyAccess")
+	cfg, err := parseSyncConfig([]byte(`{"start_url": "${ACME_SSO_URL}", "region": "${ACME_REGION:-eu-west-1}", "roles": ["$ACME_ROLE"], "prefix": "$$x-"}`))
+	if err != nil {
+		t.Fatalf("parseSyncConfig failed: %v", err)
+	}
+	if cfg.StartURL != "https://acme.awsapps.com/start" || cfg.Region != "eu-west-1" || len(cfg.Roles) != 1 || cfg.Roles[0] != "AWSReadOnlyAccess" || cfg.Prefix != "$x-" {
+		t.Fatalf("unexpected expanded config: %+v", cfg)
+	}
+
+	_, err = parseSyncConfig([]byte(`{"start_url": "https://acme.awsapps.com/start", "session_name": "${ACME_MISSING}"}`))
+	if err == nil || !strings.Contains(err.Error(), "session_name") || !strings.Contains(err.Error(), "ACME_MISSING is not set") {
+		t.Fatalf("expected an unset variable error for session_name, got %v", err)
+	}
+}
+
+// TestLoadSyncConfigFile loads the multi-geography list form from a local
+// -config file and checks it reaches the geographies the same way a fetched
+// config does, and that a bad file names its path.
+func TestLoadSyncConfigFile(t *testing.T) {
+	oldGeographies := geographies
+	defer func() { geographies = oldGeographies }()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "geographies.json")
+	if err := os.WriteFile(path, []byte(`[
+		{"start_url": "https://eu-corp.awsapps.com/start", "region": "eu-central-1", "session_name": "eu", "roles": ["AWSReadOnlyAccess"]},
+		{"start_url": "https://us-corp.awsapps.com/start", "region": "us-east-1", "session_name": "us", "roles": ["AWSReadOnlyAccess"]}
+	]`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := loadSyncConfigFile(path)
+	if err != nil {
+		t.Fatalf("loadSyncConfigFile failed: %v", err)
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	for _, name := range []string{"sso-start-url", "sso-region", "sso-session-name", "role"} {
+		fs.String(name, "", "")
+	}
+	if err := applySyncConfig(cfg, fs); err != nil {
+		t.Fatalf("applySyncConfig failed: %v", err)
+	}
+	if len(geographies) != 2 || geographies[0].SessionName != "eu" || geographies[1].SessionName != "us" {
+		t.Fatalf("unexpected geographies: %+v", geographies)
+	}
+
+	bad := filepath.Join(dir, "bad.json")
+	if err := os.WriteFile(bad, []byte(`{"region": "eu-west-1"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadSyncConfigFile(bad); err == nil || !strings.Contains(err.Error(), bad) || !strings.Contains(err.Error(), "start_url is required") {
+		t.Fatalf("expected a schema error naming the file, got %v", err)
+	}
+	if _, err := loadSyncConfigFile(filepath.Join(dir, "missing.json")); err == nil {
+		t.Fatal("expected an error for a missing file")
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/mattn/go-isatty"
+)
+
+// Output themes selectable with -theme.
+const (
+	themeEmoji   = "emoji"
+	themeASCII   = "ascii"
+	themeMinimal = "minimal"
+	themeAuto    = "auto"
+)
+
+// outputTheme selects the glyphs printed in front of status lines. The
+// default, auto, uses emoji on a terminal and ASCII when stdout is piped or
+// redirected, so captured output stays plain.
+var outputTheme = themeAuto
+
+// stdoutIsTerminal reports whether stdout is a terminal. Tests override it.
+var stdoutIsTerminal = func() bool {
+	fd := os.Stdout.Fd()
+	return isatty.IsTerminal(fd) || isatty.IsCygwinTerminal(fd)
+}
+
+// effectiveTheme resolves the auto theme against the current stdout.
+func effectiveTheme() string {
+	if outputTheme != themeAuto {
+		return outputTheme
+	}
+	if stdoutIsTerminal() {
+		return themeEmoji
+	}
+	return themeASCII
+}
+
+// glyphs maps each status glyph to its emoji and ASCII forms. Every print
+// site goes through icon so the theme applies everywhere.
+var glyphs = map[string]struct{ emoji, ascii string }{
+	"ok":       {"✅", "[OK]"},
+	"warn":     {"⚠️", "[WARN]"},
+	"error":    {"❌", "[ERR]"},
+	"info":     {"ℹ️", "[INFO]"},
+	"add":      {"➕", "[+]"},
+	"skip":     {"➖", "[-]"},
+	"remove":   {"➖", "[-]"},
+	"repoint":  {"↪", "->"},
+	"write":    {"📝", "[WRITE]"},
+	"summary":  {"📦", "[SUMMARY]"},
+	"search":   {"🔎", "[FIND]"},
+	"check":    {"🔍", "[CHECK]"},
+	"token":    {"🔑", "[TOKEN]"},
+	"auth":     {"🔐", "[AUTH]"},
+	"link":     {"🔗", "[LINK]"},
+	"edit":     {"✏️", "[EDIT]"},
+	"done":     {"🎉", "[DONE]"},
+	"sets":     {"🧩", "[SETS]"},
+	"url":      {"🌐", ""},
+	"location": {"📍", ""},
+	"debug":    {"🐛", "[DEBUG]"},
+}
+
+// icon returns the glyph for name in the current theme followed by a space,
+// or "" when the theme (or the glyph) has nothing to show, so callers write
+// "%s<text>" and minimal output carries no stray spaces. An unknown name
+// also gives "": a missing glyph must never break a run. TestIconNames
+// checks every name used in the code is in glyphs.
+func icon(name string) string {
+	g, ok := glyphs[name]
+	if !ok {
+		return ""
+	}
+	var s string
+	switch effectiveTheme() {
+	case themeASCII:
+		s = g.ascii
+	case themeMinimal:
+		s = ""
+	default:
+		s = g.emoji
+	}
+	if s == "" {
+		return ""
+	}
+	return s + " "
+}
+
+// registerOutputFlags registers -theme, -verbose and -quiet on fs.
+func registerOutputFlags(fs *flag.FlagSet) {
+	currentLogLevel = levelInfo
+	fs.Func("theme", "Status glyphs: auto (default; emoji on a terminal, ascii otherwise), emoji, ascii ([OK]/[WARN]/[ERR]) or minimal (none)", func(v string) error {
+		switch v {
+		case themeAuto, themeEmoji, themeASCII, themeMinimal:
+			outputTheme = v
+			return nil
+		}
+		return fmt.Errorf("must be auto, emoji, ascii or minimal")
+	})
+	fs.BoolFunc("verbose", "Also log each AWS API call, pagination and the token cache files considered", func(string) error {
+		if currentLogLevel == levelError {
+			return fmt.Errorf("cannot be combined with -quiet")
+		}
+		currentLogLevel = levelDebug
+		return nil
+	})
+	fs.BoolFunc("quiet", "Only print errors and the final summary", func(string) error {
+		if currentLogLevel == levelDebug {
+			return fmt.Errorf("cannot be combined with -verbose")
+		}
+		currentLogLevel = levelError
+		return nil
+	})
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestThemeGlyphs runs a subcommand under -theme=ascii and -theme=minimal and
+// asserts the status prefix follows the theme.
+func TestThemeGlyphs(t *testing.T) {
+	oldTheme, oldConfig, oldAllow, oldDry := outputTheme, ssoConfigFile, allowExternalConfig, dryRun
+	defer func() {
+		outputTheme, ssoConfigFile, allowExternalConfig, dryRun = oldTheme, oldConfig, oldAllow, oldDry
+	}()
+
+	cfgPath := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(cfgPath, []byte("[sso-session corp]\nsso_start_url = https://corp.awsapps.com/start\nsso_region = us-east-1\n"), 0o600); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	run := func(theme string) string {
+		var code int
+		out := captureStdout(t, func() {
+			code = runDedupeSessionsCommand([]string{"-config-file", cfgPath, "-allow-external-config", "-theme", theme})
+		})
+		if code != 0 {
+			t.Fatalf("dedupe-sessions -theme=%s exited %d:\n%s", theme, code, out)
+		}
+		return out
+	}
+
+	if out := run("ascii"); !strings.Contains(out, "[OK] No duplicate sso-session blocks found.") || strings.Contains(out, "✅") {
+		t.Fatalf("expected ASCII glyphs, got:\n%s", out)
+	}
+	if out := run("minimal"); !strings.Contains(out, "\nNo duplicate sso-session blocks found.") {
+		t.Fatalf("expected no glyph and no leading space, got:\n%s", out)
+	}
+	if out := run("emoji"); !strings.Contains(out, "✅ No duplicate") {
+		t.Fatalf("expected emoji glyphs, got:\n%s", out)
+	}
+
+	outputTheme = themeASCII
+	for name, g := range glyphs {
+		if g.emoji == "" {
+			t.Errorf("glyph %q has no emoji form", name)
+		}
+		if strings.ContainsFunc(icon(name), func(r rune) bool { return r > 0x7f }) {
+			t.Errorf("ascii glyph %q is not ASCII: %q", name, icon(name))
+		}
+	}
+}
+
+// TestAutoThemeFollowsTerminal asserts the default theme uses ASCII glyphs
+// when stdout is not a terminal and emoji when it is, while an explicit
+// -theme always wins.
+func TestAutoThemeFollowsTerminal(t *testing.T) {
+	oldTheme, oldTerminal := outputTheme, stdoutIsTerminal
+	defer func() { outputTheme, stdoutIsTerminal = oldTheme, oldTerminal }()
+	outputTheme = themeAuto
+
+	// captureStdout swaps stdout for a pipe, which is not a terminal.
+	if out := captureStdout(t, func() { fmt.Print(icon("ok")) }); out != "[OK] " {
+		t.Fatalf("expected ASCII glyph on a non-TTY stdout, got %q", out)
+	}
+	stdoutIsTerminal = func() bool { return true }
+	if got := icon("ok"); got != "✅ " {
+		t.Fatalf("expected emoji glyph on a terminal, got %q", got)
+	}
+	stdoutIsTerminal = func() bool { return false }
+	outputTheme = themeEmoji
+	if got := icon("ok"); got != "✅ " {
+		t.Fatalf("expected an explicit -theme=emoji to be kept, got %q", got)
+	}
+}
+
+// TestIconNames collects every icon("name") call in the package sources and
+// checks each name is a known glyph with an emoji form, so a typo shows up
+// here instead of as a missing glyph at run time. Unknown names give "".
+func TestIconNames(t *testing.T) {
+	oldTheme := outputTheme
+	defer func() { outputTheme = oldTheme }()
+	outputTheme = themeEmoji
+
+	files, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	used := make(map[string][]string)
+	fset := token.NewFileSet()
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, file, nil, 0)
+		if err != nil {
+			t.Fatalf("parsing %s: %v", file, err)
+		}
+		ast.Inspect(f, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok || len(call.Args) != 1 {
+				return true
+			}
+			if fn, ok := call.Fun.(*ast.Ident); !ok || fn.Name != "icon" {
+				return true
+			}
+			if lit, ok := call.Args[0].(*ast.BasicLit); ok && lit.Kind == token.STRING {
+				name, _ := strconv.Unquote(lit.Value)
+				used[name] = append(used[name], fset.Position(call.Pos()).String())
+			}
+			return true
+		})
+	}
+	if len(used) == 0 {
+		t.Fatal("found no icon calls")
+	}
+	for name, sites := range used {
+		t.Run(name, func(t *testing.T) {
+			if _, ok := glyphs[name]; !ok || icon(name) == "" {
+				t.Errorf("icon(%q) is not a known glyph (used at %s)", name, strings.Join(sites, ", "))
+			}
+		})
+	}
+	if got := icon("no-such-glyph"); got != "" {
+		t.Errorf("expected an unknown glyph to give \"\", got %q", got)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"math/rand"
+	"net/http"
+	"time"
+
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/smithy-go"
+)
+
+// maxThrottleRetries is how many times a throttled SSO portal call is
+// retried before the run fails (-max-retries).
+var maxThrottleRetries = 5
+
+// Backoff bounds for throttled calls: the delay doubles from
+// throttleBaseDelay per attempt up to throttleMaxDelay, with jitter.
+const (
+	throttleBaseDelay = 500 * time.Millisecond
+	throttleMaxDelay  = 20 * time.Second
+)
+
+// throttleJitter returns a random duration in [0, d). Tests override it to
+// make the backoff deterministic.
+var throttleJitter = func(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(d)))
+}
+
+// throttlingErrorCodes are the AWS error codes that mean the caller is being
+// rate limited.
+var throttlingErrorCodes = map[string]bool{
+	"TooManyRequestsException": true,
+	"ThrottlingException":      true,
+	"Throttling":               true,
+	"RequestLimitExceeded":     true,
+	"SlowDown":                 true,
+}
+
+// isThrottlingError reports whether err is a rate-limiting response: one of
+// throttlingErrorCodes or an HTTP 429.
+func isThrottlingError(err error) bool {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && throttlingErrorCodes[apiErr.ErrorCode()] {
+		return true
+	}
+	var jsonErr *awsAPIError
+	if errors.As(err, &jsonErr) && (throttlingErrorCodes[jsonErr.Code] || jsonErr.StatusCode == http.StatusTooManyRequests) {
+		return true
+	}
+	var respErr *awshttp.ResponseError
+	return errors.As(err, &respErr) && respErr.HTTPStatusCode() == http.StatusTooManyRequests
+}
+
+// throttleBackoff returns the delay before retry number attempt (1-based):
+// half of the capped exponential delay plus up to the same again as jitter,
+// so concurrent workers don't retry in lockstep.
+func throttleBackoff(attempt int) time.Duration {
+	d := throttleBaseDelay << (attempt - 1)
+	if d <= 0 || d > throttleMaxDelay {
+		d = throttleMaxDelay
+	}
+	return d/2 + throttleJitter(d/2)
+}
+
+// withThrottleRetry runs fn, retrying it with backoff while it fails with a
+// throttling error, up to maxThrottleRetries times. Any other error is
+// returned at once. op names the call in the retry messages.
+func withThrottleRetry(op string, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || !isThrottlingError(err) || attempt > maxThrottleRetries {
+			return err
+		}
+		delay := throttleBackoff(attempt)
+		debugf("%s throttled, retrying in %s (%d/%d): %v\n", op, delay.Round(time.Millisecond), attempt, maxThrottleRetries, err)
+		if err := sleepFunc(runContext, delay); err != nil {
+			return err
+		}
+	}
+}
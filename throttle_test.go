@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/smithy-go"
+)
+
+// TestWithThrottleRetry asserts throttling errors are retried with growing
+// delays up to -max-retries, while other errors fail on the first attempt.
+func TestWithThrottleRetry(t *testing.T) {
+	origSleep, origJitter, origMax := sleepFunc, throttleJitter, maxThrottleRetries
+	defer func() { sleepFunc, throttleJitter, maxThrottleRetries = origSleep, origJitter, origMax }()
+	var delays []time.Duration
+	sleepFunc = func(ctx context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		return nil
+	}
+	throttleJitter = func(d time.Duration) time.Duration { return 0 }
+	maxThrottleRetries = 3
+	throttled := &smithy.GenericAPIError{Code: "TooManyRequestsException", Message: "Rate exceeded"}
+
+	calls := 0
+	err := withThrottleRetry("op", func() error {
+		calls++
+		if calls < 3 {
+			return throttled
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Fatalf("expected success on the third call, got err=%v calls=%d", err, calls)
+	}
+	if len(delays) != 2 || delays[0] != throttleBaseDelay/2 || delays[1] != throttleBaseDelay {
+		t.Fatalf("expected exponential delays, got %v", delays)
+	}
+
+	calls = 0
+	if err := withThrottleRetry("op", func() error { calls++; return throttled }); !errors.Is(err, throttled) || calls != 4 {
+		t.Fatalf("expected the throttling error after 1+3 calls, got err=%v calls=%d", err, calls)
+	}
+
+	calls = 0
+	denied := &smithy.GenericAPIError{Code: "UnauthorizedException"}
+	if err := withThrottleRetry("op", func() error { calls++; return denied }); !errors.Is(err, denied) || calls != 1 {
+		t.Fatalf("expected a non-throttling error to fail at once, got err=%v calls=%d", err, calls)
+	}
+
+	if !isThrottlingError(&awsAPIError{StatusCode: 429}) || isThrottlingError(errors.New("boom")) {
+		t.Fatal("unexpected isThrottlingError classification")
+	}
+}
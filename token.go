@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+)
+
+// tokenInfo is the metadata of a cached SSO token, as printed by the token
+// subcommand. The access token itself is never included.
+type tokenInfo struct {
+	StartURL         string `json:"startUrl"`
+	Region           string `json:"region"`
+	ExpiresAt        string `json:"expiresAt"`
+	RemainingSeconds int64  `json:"remainingSeconds"`
+	Expired          bool   `json:"expired"`
+	Path             string `json:"path"`
+}
+
+// readTokenInfo reads the metadata of the cached token at path without
+// contacting AWS.
+func readTokenInfo(path string) (tokenInfo, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return tokenInfo{}, err
+	}
+	var cache struct {
+		StartURL  string `json:"startUrl"`
+		Region    string `json:"region"`
+		ExpiresAt string `json:"expiresAt"`
+	}
+	if err := json.Unmarshal(data, &cache); err != nil {
+		return tokenInfo{}, fmt.Errorf("parsing %s: %v", path, err)
+	}
+	info := tokenInfo{StartURL: cache.StartURL, Region: cache.Region, ExpiresAt: cache.ExpiresAt, Path: path, Expired: true}
+	if expiresAt, ok := parseTokenExpiry(cache.ExpiresAt); ok {
+		remaining := expiresAt.Sub(nowFunc())
+		info.RemainingSeconds = int64(remaining / time.Second)
+		info.Expired = remaining <= 0
+	}
+	return info, nil
+}
+
+// runTokenCommand implements the token subcommand, which prints the cached
+// token's metadata for the start URL, and returns the process exit code.
+func runTokenCommand(args []string) int {
+	fs := flag.NewFlagSet("token", flag.ContinueOnError)
+	registerSsoFlags(fs)
+	registerOutputFlags(fs)
+	asJSON := fs.Bool("json", false, "Print the metadata as JSON")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if ssoStartURL == "" {
+		fmt.Fprintf(os.Stderr, "%s%s\n", red(icon("error")), bold("Error: token requires -sso-start-url"))
+		fs.Usage()
+		return 2
+	}
+
+	_, path, err := getAccessTokenFromSsoSessionWithPath()
+	if errors.Is(err, errTokenExpired) && path != "" {
+		// Still describe the newest expired token; the expiry is reported
+		// below.
+		err = nil
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s%v\n", red(icon("error")), err)
+		return 1
+	}
+	info, err := readTokenInfo(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s%v\n", red(icon("error")), err)
+		return 1
+	}
+	if info.Expired {
+		fmt.Fprintf(os.Stderr, "%sCached token is expired; run without a subcommand to log in again.\n", yellow(icon("warn")))
+	}
+
+	if *asJSON {
+		b, err := json.MarshalIndent(info, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s%v\n", red(icon("error")), err)
+			return 1
+		}
+		fmt.Println(string(b))
+		return 0
+	}
+	fmt.Printf("%s%s\n", cyan(icon("token")), bold("Cached SSO token"))
+	fmt.Printf("  Start URL:  %s\n", info.StartURL)
+	fmt.Printf("  Region:     %s\n", info.Region)
+	fmt.Printf("  Expires at: %s\n", info.ExpiresAt)
+	if info.Expired {
+		fmt.Printf("  Remaining:  %s\n", red("expired"))
+	} else {
+		fmt.Printf("  Remaining:  %s\n", (time.Duration(info.RemainingSeconds) * time.Second).String())
+	}
+	fmt.Printf("  File:       %s\n", info.Path)
+	return 0
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssooidc"
+)
+
+// TestTokenCommand writes a synthetic token cache file and asserts the token
+// subcommand reports its metadata, both as JSON and as text, without the
+// access token itself.
+func TestTokenCommand(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	cacheDir := filepath.Join(home, ".aws", "sso", "cache")
+	if err := os.MkdirAll(cacheDir, 0o700); err != nil {
+		t.Fatalf("failed to create cache dir: %v", err)
+	}
+	tokenPath := filepath.Join(cacheDir, "token.json")
+	cache := `{"startUrl": "https://corp.awsapps.com/start", "region": "eu-west-1", "accessToken": "secret-token", "expiresAt": "2030-01-01T12:00:00Z"}`
+	if err := os.WriteFile(tokenPath, []byte(cache), 0o600); err != nil {
+		t.Fatalf("failed to write token: %v", err)
+	}
+
+	oldStart, oldSession, oldRegion, oldConfig, oldNow := ssoStartURL, ssoSessionConfigName, ssoRegion, ssoConfigFile, nowFunc
+	defer func() {
+		ssoStartURL, ssoSessionConfigName, ssoRegion, ssoConfigFile, nowFunc = oldStart, oldSession, oldRegion, oldConfig, oldNow
+	}()
+	nowFunc = func() time.Time { return time.Date(2030, 1, 1, 10, 30, 0, 0, time.UTC) }
+
+	var code int
+	out := captureStdout(t, func() {
+		code = runTokenCommand([]string{"-sso-start-url", "https://corp.awsapps.com/start/", "-json"})
+	})
+	if code != 0 {
+		t.Fatalf("token -json exited %d:\n%s", code, out)
+	}
+	var info tokenInfo
+	if err := json.Unmarshal([]byte(out), &info); err != nil {
+		t.Fatalf("invalid JSON output %q: %v", out, err)
+	}
+	want := tokenInfo{StartURL: "https://corp.awsapps.com/start", Region: "eu-west-1", ExpiresAt: "2030-01-01T12:00:00Z", RemainingSeconds: 5400, Path: tokenPath}
+	if info != want {
+		t.Fatalf("unexpected token info:\n got %+v\nwant %+v", info, want)
+	}
+	if strings.Contains(out, "secret-token") {
+		t.Fatalf("token output leaked the access token")
+	}
+
+	nowFunc = func() time.Time { return time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC) }
+	out = captureStdout(t, func() {
+		code = runTokenCommand([]string{"-sso-start-url", "https://corp.awsapps.com/start"})
+	})
+	if code != 0 || !strings.Contains(out, "Region:     eu-west-1") || !strings.Contains(out, "expired") || !strings.Contains(out, tokenPath) {
+		t.Fatalf("unexpected text output (exit %d):\n%s", code, out)
+	}
+}
+
+// TestStrictTokenMatchRejectsOtherRegion caches a token for the requested
+// start URL but another region. The default lookup accepts it; under
+// -strict-token-match it is rejected with an explanatory error.
+func TestStrictTokenMatchRejectsOtherRegion(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	cacheDir := filepath.Join(home, ".aws", "sso", "cache")
+	if err := os.MkdirAll(cacheDir, 0o700); err != nil {
+		t.Fatalf("failed to create cache dir: %v", err)
+	}
+	cache := `{"startUrl": "https://corp.awsapps.com/start/", "region": "eu-west-1", "accessToken": "tok", "expiresAt": "2030-01-01T12:00:00Z"}`
+	if err := os.WriteFile(filepath.Join(cacheDir, "token.json"), []byte(cache), 0o600); err != nil {
+		t.Fatalf("failed to write token: %v", err)
+	}
+
+	oldStart, oldRegion, oldStrict := ssoStartURL, ssoRegion, strictTokenMatch
+	defer func() { ssoStartURL, ssoRegion, strictTokenMatch = oldStart, oldRegion, oldStrict }()
+	ssoStartURL, ssoRegion = "https://corp.awsapps.com/start/", "us-east-1"
+
+	strictTokenMatch = false
+	if token, _, err := getAccessTokenFromSsoSessionWithPath(); err != nil || token != "tok" {
+		t.Fatalf("default lookup: token=%q err=%v", token, err)
+	}
+
+	strictTokenMatch = true
+	if _, _, err := getAccessTokenFromSsoSessionWithPath(); err == nil || !strings.Contains(err.Error(), "-strict-token-match rejected 1 token") {
+		t.Fatalf("expected strict mode to reject the other-region token, got %v", err)
+	}
+
+	// Same region matches strictly even with a different trailing slash.
+	ssoStartURL, ssoRegion = "https://corp.awsapps.com/start", "eu-west-1"
+	if token, _, err := getAccessTokenFromSsoSessionWithPath(); err != nil || token != "tok" {
+		t.Fatalf("strict lookup with matching region: token=%q err=%v", token, err)
+	}
+}
+
+// TestCliCompatibleTokenCache checks the AWS CLI cache file names against
+// known SHA1 vectors and that the compatible cache carries the client
+// registration and refresh token fields, while the default stays private.
+func TestCliCompatibleTokenCache(t *testing.T) {
+	if got := cliTokenCacheFilename("https://my-sso-portal.awsapps.com/start"); got != "c7aaaf71fcc8777ae2475525ed049d39fe16c484.json" {
+		t.Fatalf("unexpected start URL cache name %s", got)
+	}
+	if got := cliTokenCacheFilename("my-sso"); got != "0ad374308c5a4e22f723adf10145eafad7c4031c.json" {
+		t.Fatalf("unexpected session cache name %s", got)
+	}
+
+	oldCompat, oldStart, oldSession, oldLegacy := cliCompatibleCache, ssoStartURL, ssoSessionConfigName, legacyKeys
+	defer func() {
+		cliCompatibleCache, ssoStartURL, ssoSessionConfigName, legacyKeys = oldCompat, oldStart, oldSession, oldLegacy
+	}()
+	ssoStartURL, ssoSessionConfigName, legacyKeys = "https://my-sso-portal.awsapps.com/start/", "my-sso", nil
+	token := &ssooidc.CreateTokenOutput{AccessToken: aws.String("at"), RefreshToken: aws.String("rt"), ExpiresIn: 3600}
+	reg := &ssooidc.RegisterClientOutput{ClientId: aws.String("cid"), ClientSecret: aws.String("secret"), ClientSecretExpiresAt: 1893456000}
+	now := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	cliCompatibleCache = false
+	name, fields := buildTokenCache(token, reg, now)
+	if !strings.HasPrefix(name, "sso_token_") || fields["clientId"] != nil || fields["refreshToken"] != nil {
+		t.Fatalf("default cache changed: %s %v", name, fields)
+	}
+
+	cliCompatibleCache = true
+	name, fields = buildTokenCache(token, reg, now)
+	if name != "0ad374308c5a4e22f723adf10145eafad7c4031c.json" {
+		t.Fatalf("expected the session-keyed CLI name, got %s", name)
+	}
+	if fields["clientId"] != "cid" || fields["clientSecret"] != "secret" || fields["refreshToken"] != "rt" ||
+		fields["registrationExpiresAt"] != "2030-01-01T00:00:00Z" || fields["expiresAt"] != "2030-01-01T01:00:00Z" {
+		t.Fatalf("unexpected compatible cache fields: %v", fields)
+	}
+	legacyKeys = defaultLegacyKeys
+	if name, _ = buildTokenCache(token, reg, now); name != "c7aaaf71fcc8777ae2475525ed049d39fe16c484.json" {
+		t.Fatalf("expected the start URL keyed CLI name for legacy profiles, got %s", name)
+	}
+}
+
+// TestExpiredCachedTokensAreSkipped caches a newer expired token next to an
+// older valid one and asserts the valid one is chosen; with only expired
+// tokens left the lookup returns errTokenExpired with the newest file, and
+// login reports an expired token rather than a missing one.
+func TestExpiredCachedTokensAreSkipped(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	cacheDir := filepath.Join(home, ".aws", "sso", "cache")
+	if err := os.MkdirAll(cacheDir, 0o700); err != nil {
+		t.Fatalf("failed to create cache dir: %v", err)
+	}
+	oldStart, oldRegion, oldNow := ssoStartURL, ssoRegion, nowFunc
+	defer func() { ssoStartURL, ssoRegion, nowFunc = oldStart, oldRegion, oldNow }()
+	ssoStartURL, ssoRegion = "https://corp.awsapps.com/start", "us-east-1"
+	nowFunc = func() time.Time { return time.Date(2030, 1, 1, 10, 0, 0, 0, time.UTC) }
+
+	writeToken := func(name, token, expiresAt string, modTime time.Time) string {
+		path := filepath.Join(cacheDir, name)
+		body := `{"startUrl": "https://corp.awsapps.com/start", "accessToken": "` + token + `", "expiresAt": "` + expiresAt + `"}`
+		if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+			t.Fatalf("failed to write token: %v", err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatalf("failed to set mtime: %v", err)
+		}
+		return path
+	}
+	base := time.Now()
+	writeToken("valid.json", "valid", "2030-01-01T12:00:00Z", base.Add(-2*time.Hour))
+	expiredPath := writeToken("expired.json", "expired", "2030-01-01T09:00:00Z", base.Add(-time.Hour))
+
+	if token, _, err := getAccessTokenFromSsoSessionWithPath(); err != nil || token != "valid" {
+		t.Fatalf("expected the unexpired token, got token=%q err=%v", token, err)
+	}
+
+	os.Remove(filepath.Join(cacheDir, "valid.json"))
+	_, path, err := getAccessTokenFromSsoSessionWithPath()
+	if !errors.Is(err, errTokenExpired) || path != expiredPath {
+		t.Fatalf("expected errTokenExpired for %s, got path=%q err=%v", expiredPath, path, err)
+	}
+
+	origGet, origRun := getAccessTokenFunc, runAwsSsoLogin
+	oldConfig, oldDry := ssoConfigFile, dryRun
+	defer func() {
+		getAccessTokenFunc, runAwsSsoLogin = origGet, origRun
+		ssoConfigFile, dryRun = oldConfig, oldDry
+	}()
+	ssoConfigFile, dryRun = filepath.Join(t.TempDir(), "config"), false
+	getAccessTokenFunc = func() (string, string, error) { return "", "", errTokenExpired }
+	runAwsSsoLogin = func(session string) error { return errors.New("stop") }
+	out := captureStdout(t, func() { login() })
+	if !strings.Contains(out, "SSO token expired") || strings.Contains(out, "No valid SSO token found") {
+		t.Fatalf("expected an expired-token message, got:\n%s", out)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/smithy-go"
+)
+
+// errTokenLacksScope marks a token the portal accepts but that may not list
+// accounts because it was minted without the sso:account:access scope.
+var errTokenLacksScope = errors.New("token valid but lacks " + requiredSsoScope + " scope; re-register with the right scopes")
+
+// lastTokenCheckErr is why the last default token validation failed, so the
+// messages after a rejected token can say whether it expired or only lacks
+// the account-list scope.
+var lastTokenCheckErr error
+
+// isTokenScopeError reports whether err is a portal response that refuses
+// the call rather than the token: an access-denied or forbidden error code
+// or an HTTP 403. An expired or revoked token fails with an
+// UnauthorizedException (HTTP 401) instead.
+func isTokenScopeError(err error) bool {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "AccessDeniedException", "ForbiddenException":
+			return true
+		}
+	}
+	var respErr *awshttp.ResponseError
+	if errors.As(err, &respErr) && respErr.HTTPStatusCode() == http.StatusForbidden {
+		return true
+	}
+	return isAccessDeniedError(err)
+}
+
+// checkSsoToken validates accessToken by listing accounts. A refusal that
+// isn't about the token itself is returned wrapping errTokenLacksScope.
+func checkSsoToken(accessToken string) error {
+	_, err := getListOfSsoAccountsFunc(accessToken)
+	if err != nil && isTokenScopeError(err) {
+		return fmt.Errorf("%w (%v)", errTokenLacksScope, err)
+	}
+	return err
+}
+
+// tokenRejectedReason describes why the last token validation failed for
+// the messages shown after a rejected token.
+func tokenRejectedReason() string {
+	if errors.Is(lastTokenCheckErr, errTokenLacksScope) {
+		return fmt.Sprintf("%s (add %s to sso_registration_scopes, e.g. with -fix-scopes)", errTokenLacksScope, requiredSsoScope)
+	}
+	return "token is invalid or expired"
+}
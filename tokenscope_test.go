@@ -0,0 +1,38 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aws/smithy-go"
+)
+
+// TestTokenLacksAccountScope verifies an access-denied account listing is
+// reported as a missing sso:account:access scope rather than an expired
+// token, while an unauthorized response keeps the expired-token message.
+func TestTokenLacksAccountScope(t *testing.T) {
+	origAccounts, oldErr := getListOfSsoAccountsFunc, lastTokenCheckErr
+	defer func() { getListOfSsoAccountsFunc, lastTokenCheckErr = origAccounts, oldErr }()
+
+	listErr := error(&smithy.GenericAPIError{Code: "AccessDeniedException", Message: "not authorized to list accounts"})
+	getListOfSsoAccountsFunc = func(string) ([]ssoTypesAccount, error) { return nil, listErr }
+
+	if isSsoTokenValid("tok") {
+		t.Fatal("expected the token to be rejected")
+	}
+	if !errors.Is(lastTokenCheckErr, errTokenLacksScope) {
+		t.Fatalf("expected a scope error, got %v", lastTokenCheckErr)
+	}
+	if reason := tokenRejectedReason(); !strings.Contains(reason, "token valid but lacks sso:account:access scope") {
+		t.Fatalf("unexpected reason %q", reason)
+	}
+
+	listErr = &smithy.GenericAPIError{Code: "UnauthorizedException", Message: "Session token not found or invalid"}
+	if isSsoTokenValid("tok") {
+		t.Fatal("expected the token to be rejected")
+	}
+	if errors.Is(lastTokenCheckErr, errTokenLacksScope) || tokenRejectedReason() != "token is invalid or expired" {
+		t.Fatalf("expected an expired-token reason, got %v", lastTokenCheckErr)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// buildInfo is what -version and the version subcommand report.
+type buildInfo struct {
+	Version       string `json:"version"`
+	Commit        string `json:"commit"`
+	BuildDate     string `json:"buildDate"`
+	GoVersion     string `json:"goVersion"`
+	Platform      string `json:"platform"`
+	AWSSDKVersion string `json:"awsSdkVersion"`
+}
+
+// readBuildInfo returns the module build info; tests override it.
+var readBuildInfo = debug.ReadBuildInfo
+
+// currentBuildInfo collects the build metadata. The -ldflags values win;
+// a `go install module@version` build supplies the version and a build from
+// a git checkout the commit and its time. Anything still unknown is
+// reported as "unknown".
+func currentBuildInfo() buildInfo {
+	info := buildInfo{
+		Version:       version,
+		Commit:        commit,
+		BuildDate:     buildDate,
+		GoVersion:     runtime.Version(),
+		Platform:      runtime.GOOS + "/" + runtime.GOARCH,
+		AWSSDKVersion: aws.SDKVersion,
+	}
+	if bi, ok := readBuildInfo(); ok {
+		if info.Version == "dev" && bi.Main.Version != "" && bi.Main.Version != "(devel)" {
+			info.Version = bi.Main.Version
+		}
+		vcs := make(map[string]string)
+		for _, s := range bi.Settings {
+			vcs[s.Key] = s.Value
+		}
+		if info.Commit == "" && vcs["vcs.revision"] != "" {
+			info.Commit = vcs["vcs.revision"]
+			if vcs["vcs.modified"] == "true" {
+				info.Commit += "-dirty"
+			}
+		}
+		if info.BuildDate == "" {
+			info.BuildDate = vcs["vcs.time"]
+		}
+	}
+	if info.Commit == "" {
+		info.Commit = "unknown"
+	}
+	if info.BuildDate == "" {
+		info.BuildDate = "unknown"
+	}
+	return info
+}
+
+// formatBuildInfo renders info as aligned text lines.
+func formatBuildInfo(info buildInfo) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s\n", programName, info.Version)
+	fmt.Fprintf(&b, "  Commit:      %s\n", info.Commit)
+	fmt.Fprintf(&b, "  Built:       %s\n", info.BuildDate)
+	fmt.Fprintf(&b, "  Go:          %s (%s)\n", info.GoVersion, info.Platform)
+	fmt.Fprintf(&b, "  AWS SDK:     %s\n", info.AWSSDKVersion)
+	return b.String()
+}
+
+// runVersionCommand implements the version subcommand, which prints the
+// build metadata as text or, with -json, as a JSON object, and returns the
+// process exit code.
+func runVersionCommand(args []string) int {
+	fs := flag.NewFlagSet("version", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "Print the build information as JSON")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	info := currentBuildInfo()
+	if *asJSON {
+		b, err := json.MarshalIndent(info, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s%v\n", red(icon("error")), err)
+			return 1
+		}
+		fmt.Println(string(b))
+		return 0
+	}
+	fmt.Print(formatBuildInfo(info))
+	return 0
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// TestVersionOutput verifies the version output carries every field, that
+// -ldflags values win over the module build info, and that the build info
+// fills in what the ldflags left unset.
+func TestVersionOutput(t *testing.T) {
+	oldVersion, oldCommit, oldDate, oldRead := version, commit, buildDate, readBuildInfo
+	defer func() { version, commit, buildDate, readBuildInfo = oldVersion, oldCommit, oldDate, oldRead }()
+	readBuildInfo = func() (*debug.BuildInfo, bool) {
+		return &debug.BuildInfo{
+			Main:     debug.Module{Version: "v0.9.0"},
+			Settings: []debug.BuildSetting{{Key: "vcs.revision", Value: "abc123"}, {Key: "vcs.time", Value: "2026-01-02T03:04:05Z"}, {Key: "vcs.modified", Value: "true"}},
+		}, true
+	}
+
+	version, commit, buildDate = "v1.2.3", "deadbeef", "2026-10-01T00:00:00Z"
+	var info buildInfo
+	out := captureStdout(t, func() {
+		if code := runVersionCommand([]string{"-json"}); code != 0 {
+			t.Fatalf("version -json exited with %d", code)
+		}
+	})
+	if err := json.Unmarshal([]byte(out), &info); err != nil {
+		t.Fatalf("invalid JSON %q: %v", out, err)
+	}
+	want := buildInfo{Version: "v1.2.3", Commit: "deadbeef", BuildDate: "2026-10-01T00:00:00Z", GoVersion: runtime.Version(), Platform: runtime.GOOS + "/" + runtime.GOARCH, AWSSDKVersion: aws.SDKVersion}
+	if info != want {
+		t.Fatalf("got %+v, want %+v", info, want)
+	}
+
+	version, commit, buildDate = "dev", "", ""
+	out = captureStdout(t, func() { runVersionCommand(nil) })
+	for _, field := range []string{programName + " v0.9.0", "Commit:      abc123-dirty", "Built:       2026-01-02T03:04:05Z", "Go:          " + runtime.Version(), "AWS SDK:     " + aws.SDKVersion} {
+		if !strings.Contains(out, field) {
+			t.Errorf("expected %q in the version output, got:\n%s", field, out)
+		}
+	}
+
+	readBuildInfo = func() (*debug.BuildInfo, bool) { return nil, false }
+	if info := currentBuildInfo(); info.Version != "dev" || info.Commit != "unknown" || info.BuildDate != "unknown" {
+		t.Fatalf("expected unknown fields without build info, got %+v", info)
+	}
+}
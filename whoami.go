@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// callerIdentity is the result of sts:GetCallerIdentity.
+type callerIdentity struct {
+	Account string
+	Arn     string
+	UserId  string
+}
+
+// whoamiResult is what the whoami subcommand reports.
+type whoamiResult struct {
+	User        string `json:"user"`
+	AccountId   string `json:"accountId"`
+	AccountName string `json:"accountName,omitempty"`
+	RoleName    string `json:"roleName"`
+	Arn         string `json:"arn"`
+	UserId      string `json:"userId"`
+}
+
+// getCallerIdentityFunc calls sts:GetCallerIdentity with the given role
+// credentials. -endpoint-url only applies to the SSO APIs, so STS always
+// uses its AWS endpoint. Tests can override this to avoid contacting AWS.
+var getCallerIdentityFunc = func(creds roleCredentials) (callerIdentity, error) {
+	cfg, err := loadRegionOnlyConfig()
+	if err != nil {
+		return callerIdentity{}, err
+	}
+	cfg.Credentials = aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+		return aws.Credentials{
+			AccessKeyID:     creds.AccessKeyId,
+			SecretAccessKey: creds.SecretAccessKey,
+			SessionToken:    creds.SessionToken,
+			CanExpire:       true,
+			Expires:         creds.Expiration,
+		}, nil
+	})
+	out, err := sts.NewFromConfig(cfg).GetCallerIdentity(runContext, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return callerIdentity{}, err
+	}
+	return callerIdentity{Account: aws.ToString(out.Account), Arn: aws.ToString(out.Arn), UserId: aws.ToString(out.UserId)}, nil
+}
+
+// sessionUserFromArn extracts the role session name from an assumed-role
+// ARN. For SSO roles this is the signed-in user (usually their username or
+// email): arn:aws:sts::<account>:assumed-role/<role>/<user>.
+func sessionUserFromArn(arn string) string {
+	parts := strings.Split(arn, "/")
+	if len(parts) < 3 || !strings.HasSuffix(parts[0], ":assumed-role") {
+		return ""
+	}
+	return parts[len(parts)-1]
+}
+
+// resolveWhoami resolves the identity behind the SSO token by fetching role
+// credentials for accountId/roleName and asking STS who they belong to.
+// Without an explicit account and role, the first role of the first account
+// is used; every role resolves to the same signed-in user.
+func resolveWhoami(accessToken, accountId, roleName string) (whoamiResult, error) {
+	var accountName string
+	if accountId == "" || roleName == "" {
+		accounts, err := getListOfSsoAccountsFunc(accessToken)
+		if err != nil {
+			return whoamiResult{}, err
+		}
+		for _, account := range accounts {
+			if accountId != "" && account.AccountId != accountId {
+				continue
+			}
+			roles, err := getListOfSsoAccountRolesForAccountFunc(accessToken, account.AccountId)
+			if err != nil {
+				return whoamiResult{}, err
+			}
+			if len(roles) > 0 {
+				accountId, accountName = account.AccountId, account.AccountName
+				if roleName == "" {
+					roleName = roles[0].RoleName
+				}
+				break
+			}
+		}
+		if accountId == "" || roleName == "" {
+			return whoamiResult{}, fmt.Errorf("no account/role is assigned to this SSO user to resolve the identity with")
+		}
+	}
+	creds, err := getCachedRoleCredentials(accessToken, accountId, roleName)
+	if err != nil {
+		return whoamiResult{}, fmt.Errorf("fetching credentials for %s/%s: %v", accountId, roleName, err)
+	}
+	id, err := getCallerIdentityFunc(creds)
+	if err != nil {
+		return whoamiResult{}, err
+	}
+	return whoamiResult{
+		User:        sessionUserFromArn(id.Arn),
+		AccountId:   id.Account,
+		AccountName: accountName,
+		RoleName:    roleName,
+		Arn:         id.Arn,
+		UserId:      id.UserId,
+	}, nil
+}
+
+// runWhoamiCommand implements the whoami subcommand and returns the process
+// exit code.
+func runWhoamiCommand(args []string) int {
+	fs := flag.NewFlagSet("whoami", flag.ContinueOnError)
+	registerSsoFlags(fs)
+	registerOutputFlags(fs)
+	accountId := fs.String("account-id", "", "Account to resolve the identity through (default: the first assigned account)")
+	roleName := fs.String("role", "", "Role to resolve the identity through (default: the first role in the account)")
+	asJSON := fs.Bool("json", false, "Print the identity as JSON")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if ssoStartURL == "" {
+		fmt.Fprintf(os.Stderr, "%s%s\n", red(icon("error")), bold("Error: whoami requires -sso-start-url"))
+		fs.Usage()
+		return 2
+	}
+
+	accessToken, _, err := getAccessTokenFunc()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s%v (run without a subcommand to log in first)\n", red(icon("error")), err)
+		return 1
+	}
+	who, err := resolveWhoami(accessToken, *accountId, *roleName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s%s %v\n", red(icon("error")), bold("Error resolving identity:"), err)
+		return 1
+	}
+	if *asJSON {
+		b, err := json.MarshalIndent(who, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s%v\n", red(icon("error")), err)
+			return 1
+		}
+		fmt.Println(string(b))
+		return 0
+	}
+	fmt.Printf("%s%s %s\n", cyan(icon("token")), bold("Signed in as"), who.User)
+	fmt.Printf("  Account: %s", who.AccountId)
+	if who.AccountName != "" {
+		fmt.Printf(" (%s)", who.AccountName)
+	}
+	fmt.Printf("\n  Role:    %s\n", who.RoleName)
+	fmt.Printf("  ARN:     %s\n", who.Arn)
+	fmt.Printf("  UserId:  %s\n", who.UserId)
+	return 0
+}
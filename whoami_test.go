@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestWhoami stubs discovery, role credentials and STS, and asserts whoami
+// resolves the signed-in user through the first assigned role and reports
+// it as JSON.
+func TestWhoami(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	stubDiscovery(t,
+		[]ssoTypesAccount{{AccountId: "111", AccountName: "Empty"}, {AccountId: "222", AccountName: "Prod"}},
+		map[string][]string{"222": {"AWSReadOnlyAccess", "AWSAdministratorAccess"}})
+
+	origGet, origCreds, origIdentity := getAccessTokenFunc, getRoleCredentialsFunc, getCallerIdentityFunc
+	oldStart, oldRegion, oldSession, oldConfig := ssoStartURL, ssoRegion, ssoSessionConfigName, ssoConfigFile
+	defer func() {
+		getAccessTokenFunc, getRoleCredentialsFunc, getCallerIdentityFunc = origGet, origCreds, origIdentity
+		ssoStartURL, ssoRegion, ssoSessionConfigName, ssoConfigFile = oldStart, oldRegion, oldSession, oldConfig
+	}()
+	getAccessTokenFunc = func() (string, string, error) { return "token", "/tmp/token.json", nil }
+	var usedRole string
+	getRoleCredentialsFunc = func(accessToken, accountId, roleName string) (roleCredentials, error) {
+		usedRole = accountId + "/" + roleName
+		return roleCredentials{AccessKeyId: "AKIA", SecretAccessKey: "s", SessionToken: "t", Expiration: time.Now().Add(time.Hour)}, nil
+	}
+	getCallerIdentityFunc = func(creds roleCredentials) (callerIdentity, error) {
+		if creds.AccessKeyId != "AKIA" {
+			t.Errorf("STS called with unexpected credentials: %+v", creds)
+		}
+		return callerIdentity{
+			Account: "222",
+			Arn:     "arn:aws:sts::222:assumed-role/AWSReservedSSO_AWSReadOnlyAccess_abc123/jane@example.com",
+			UserId:  "AROAEXAMPLE:jane@example.com",
+		}, nil
+	}
+
+	var code int
+	out := captureStdout(t, func() {
+		code = runWhoamiCommand([]string{"-sso-start-url", "https://corp.awsapps.com/start", "-json"})
+	})
+	if code != 0 {
+		t.Fatalf("whoami exited %d:\n%s", code, out)
+	}
+	if usedRole != "222/AWSReadOnlyAccess" {
+		t.Fatalf("expected the first role of the first account with roles, used %q", usedRole)
+	}
+	var who whoamiResult
+	if err := json.Unmarshal([]byte(out), &who); err != nil {
+		t.Fatalf("invalid JSON output %q: %v", out, err)
+	}
+	if who.User != "jane@example.com" || who.AccountId != "222" || who.AccountName != "Prod" || who.RoleName != "AWSReadOnlyAccess" {
+		t.Fatalf("unexpected identity: %+v", who)
+	}
+
+	out = captureStdout(t, func() {
+		code = runWhoamiCommand([]string{"-sso-start-url", "https://corp.awsapps.com/start", "-account-id", "222", "-role", "AWSAdministratorAccess"})
+	})
+	if code != 0 || usedRole != "222/AWSAdministratorAccess" || !strings.Contains(out, "Signed in as jane@example.com") {
+		t.Fatalf("unexpected explicit-role whoami (exit %d, role %q):\n%s", code, usedRole, out)
+	}
+}
+
+// TestStsConfigIgnoresEndpointURL asserts -endpoint-url only redirects the
+// SSO clients: the config whoami builds its STS client from keeps the AWS
+// endpoint.
+func TestStsConfigIgnoresEndpointURL(t *testing.T) {
+	oldEndpoint, oldRegion := endpointURL, ssoRegion
+	defer func() { endpointURL, ssoRegion = oldEndpoint, oldRegion }()
+	endpointURL, ssoRegion = "http://127.0.0.1:9999", "eu-west-1"
+
+	ssoCfg, err := loadSsoClientConfig()
+	if err != nil || ssoCfg.BaseEndpoint == nil || *ssoCfg.BaseEndpoint != endpointURL {
+		t.Fatalf("expected the SSO config to use -endpoint-url, got %v (err %v)", ssoCfg.BaseEndpoint, err)
+	}
+	stsCfg, err := loadRegionOnlyConfig()
+	if err != nil || stsCfg.BaseEndpoint != nil || stsCfg.Region != "eu-west-1" {
+		t.Fatalf("expected a region-only config without the endpoint override, got endpoint %v region %q (err %v)", stsCfg.BaseEndpoint, stsCfg.Region, err)
+	}
+}